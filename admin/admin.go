@@ -0,0 +1,260 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// Admin API: an authenticated listener for runtime inspection and control
+
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/spf13/viper"
+
+	sf "github.com/gogufo/gufo-api-gateway/gufodao"
+	mid "github.com/gogufo/gufo-api-gateway/middleware"
+	"github.com/gogufo/gufo-api-gateway/registry"
+	v "github.com/gogufo/gufo-api-gateway/version"
+)
+
+var (
+	started = time.Now()
+	routes  chi.Routes // public router, set via SetRoutes
+)
+
+// Route is a public gateway route as listed by /admin/routes.
+type Route struct {
+	Method  string `json:"method"`
+	Pattern string `json:"pattern"`
+}
+
+// SetRoutes registers the public router so the admin API can list its routes.
+func SetRoutes(r chi.Routes) {
+	routes = r
+}
+
+// TokenHeader carries the admin token on every admin request.
+const TokenHeader = "X-Admin-Token"
+
+// Addr returns the listen address of the admin API (server.admin_ip:server.admin_port).
+func Addr() string {
+	ip := viper.GetString("server.admin_ip")
+	if ip == "" {
+		ip = "127.0.0.1"
+	}
+	port := viper.GetString("server.admin_port")
+	if port == "" {
+		port = "9101"
+	}
+	return ip + ":" + port
+}
+
+// Token returns the configured admin token (server.admin_token or its _env variable).
+func Token() string {
+	return sf.ConfigSecret("server.admin_token")
+}
+
+// Router builds the admin routes.
+func Router() http.Handler {
+	r := chi.NewRouter()
+	r.Use(auth)
+
+	r.Get("/admin/status", status)
+	r.Get("/admin/registry", registryList)
+	r.Delete("/admin/registry/{module}", registryEvict)
+	r.Get("/admin/pool", poolList)
+	r.Get("/admin/routes", routeList)
+	r.Get("/admin/config", config)
+	r.Get("/admin/drain", drainList)
+	r.Post("/admin/drain/{module}", drain)
+	r.Delete("/admin/drain/{module}", undrain)
+	r.Post("/admin/debug", debug)
+	r.Post("/admin/shutdown", shutdown)
+	r.Get("/admin/usage", usage)
+	apiKeyRoutes(r)
+	webhookRoutes(r)
+	cronRoutes(r)
+
+	return r
+}
+
+// Start runs the admin listener. It returns nil when server.admin_token is
+// empty — the admin API is disabled without a token.
+func Start() *http.Server {
+	if Token() == "" {
+		sf.SetLog("🔒 Admin API disabled (server.admin_token is empty)")
+		return nil
+	}
+
+	srv := &http.Server{
+		Addr:         Addr(),
+		Handler:      Router(),
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 15 * time.Second,
+	}
+
+	go func() {
+		sf.SetLog("🛠  Admin API listening on " + srv.Addr)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			sf.SetErrorLog("admin server error: " + err.Error())
+		}
+	}()
+
+	return srv
+}
+
+func auth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := r.Header.Get(TokenHeader)
+		if got == "" {
+			got = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		}
+		if subtle.ConstantTimeCompare([]byte(got), []byte(Token())) != 1 {
+			writeJSON(w, http.StatusUnauthorized, map[string]any{"error": "Unauthorized"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func status(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"version":    v.VERSION,
+		"commit":     v.GitCommit,
+		"build_date": v.BuildDate,
+		"pid":        os.Getpid(),
+		"uptime":     time.Since(started).Round(time.Second).String(),
+		"started":    started,
+		"http_port":  viper.GetString("server.port"),
+		"grpc_port":  viper.GetString("server.grpc_port"),
+		"mode":       strings.Join(sf.SecurityModes("", sf.ListenerGRPC), ","),
+		"edge":       strings.Join(sf.EdgeModes(""), ","),
+		"master":     viper.GetBool("server.masterservice"),
+		"debug":      sf.DebugLogging(),
+		"drained":    registry.Drained(),
+		"middleware": mid.Describe(),
+	})
+}
+
+func registryList(w http.ResponseWriter, r *http.Request) {
+	entries := append([]registry.Entry{}, registry.List()...)
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Module < entries[j].Module })
+	writeJSON(w, http.StatusOK, map[string]any{
+		"entries":    entries,
+		"instances":  registry.Instances(),
+		"discovered": registry.Discovered(),
+	})
+}
+
+func registryEvict(w http.ResponseWriter, r *http.Request) {
+	module := chi.URLParam(r, "module")
+	if !registry.Evict(module) {
+		writeJSON(w, http.StatusNotFound, map[string]any{"error": "module is not cached"})
+		return
+	}
+	sf.SetLog("admin: evicted registry entry " + module)
+	writeJSON(w, http.StatusOK, map[string]any{"module": module, "evicted": true})
+}
+
+func poolList(w http.ResponseWriter, r *http.Request) {
+	conns := append([]sf.PoolConn{}, sf.PoolStats()...)
+	sort.Slice(conns, func(i, j int) bool { return conns[i].Addr < conns[j].Addr })
+	writeJSON(w, http.StatusOK, map[string]any{"connections": conns})
+}
+
+func routeList(w http.ResponseWriter, r *http.Request) {
+	out := []Route{}
+	if routes != nil {
+		chi.Walk(routes, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+			out = append(out, Route{Method: method, Pattern: route})
+			return nil
+		})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Pattern == out[j].Pattern {
+			return out[i].Method < out[j].Method
+		}
+		return out[i].Pattern < out[j].Pattern
+	})
+	writeJSON(w, http.StatusOK, map[string]any{"routes": out})
+}
+
+func config(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, redact(viper.AllSettings()))
+}
+
+func drainList(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{"drained": registry.Drained()})
+}
+
+func drain(w http.ResponseWriter, r *http.Request) {
+	module := chi.URLParam(r, "module")
+	registry.Drain(module)
+	sf.SetLog("admin: drained module " + module)
+	writeJSON(w, http.StatusOK, map[string]any{"module": module, "drained": true})
+}
+
+func undrain(w http.ResponseWriter, r *http.Request) {
+	module := chi.URLParam(r, "module")
+	if !registry.Undrain(module) {
+		writeJSON(w, http.StatusNotFound, map[string]any{"error": "module is not drained"})
+		return
+	}
+	sf.SetLog("admin: undrained module " + module)
+	writeJSON(w, http.StatusOK, map[string]any{"module": module, "drained": false})
+}
+
+func debug(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Enabled == nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": `expected {"enabled": true|false}`})
+		return
+	}
+	sf.SetDebugLogging(*req.Enabled)
+	sf.SetLog("admin: debug logging set to " + boolString(*req.Enabled))
+	writeJSON(w, http.StatusOK, map[string]any{"debug": sf.DebugLogging()})
+}
+
+func shutdown(w http.ResponseWriter, r *http.Request) {
+	sf.SetLog("admin: shutdown requested from " + r.RemoteAddr)
+	writeJSON(w, http.StatusAccepted, map[string]any{"shutdown": true})
+
+	go func() {
+		time.Sleep(200 * time.Millisecond) // let the response go out first
+		sf.TriggerShutdown()
+	}()
+}
+
+func writeJSON(w http.ResponseWriter, code int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
+
+func boolString(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
@@ -0,0 +1,106 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// Admin endpoints for API key management. Hashes are never returned; the
+// plaintext key is returned once, on creation.
+
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/gogufo/gufo-api-gateway/apikey"
+	sf "github.com/gogufo/gufo-api-gateway/gufodao"
+)
+
+type apiKeyCreateRequest struct {
+	Name      string   `json:"name"`
+	Consumer  string   `json:"consumer"`
+	Modules   []string `json:"modules"`
+	Params    []string `json:"params"`
+	RateLimit int      `json:"rate_limit"`
+	Burst     int      `json:"burst"`
+	Quota     int64    `json:"quota"`
+	ExpiresIn string   `json:"expires_in"` // Go duration, e.g. "720h"
+}
+
+func apiKeyRoutes(r chi.Router) {
+	r.Get("/admin/apikeys", apiKeyList)
+	r.Post("/admin/apikeys", apiKeyCreate)
+	r.Delete("/admin/apikeys/{id}", apiKeyRevoke)
+}
+
+func apiKeyList(w http.ResponseWriter, r *http.Request) {
+	keys, err := apikey.GetStore().List()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"keys": keys})
+}
+
+func apiKeyCreate(w http.ResponseWriter, r *http.Request) {
+	var req apiKeyCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid JSON body"})
+		return
+	}
+
+	k := &apikey.Key{
+		Name:      req.Name,
+		Consumer:  req.Consumer,
+		Modules:   req.Modules,
+		Params:    req.Params,
+		RateLimit: req.RateLimit,
+		Burst:     req.Burst,
+		Quota:     req.Quota,
+	}
+	if req.ExpiresIn != "" {
+		d, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid expires_in"})
+			return
+		}
+		k.ExpiresAt = time.Now().Add(d)
+	}
+
+	plain, err := apikey.Create(k)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+		return
+	}
+	sf.SetLog("admin: created API key " + k.ID + " for " + k.Consumer)
+	writeJSON(w, http.StatusCreated, map[string]any{"key": plain, "info": k})
+}
+
+func apiKeyRevoke(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if err := apikey.GetStore().Revoke(id); err != nil {
+		code := http.StatusInternalServerError
+		if errors.Is(err, apikey.ErrNotFound) {
+			code = http.StatusNotFound
+		}
+		writeJSON(w, code, map[string]any{"error": err.Error()})
+		return
+	}
+	sf.SetLog("admin: revoked API key " + id)
+	writeJSON(w, http.StatusOK, map[string]any{"id": id, "revoked": true})
+}
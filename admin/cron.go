@@ -0,0 +1,78 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// Admin endpoints for scheduled module jobs.
+
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	sf "github.com/gogufo/gufo-api-gateway/gufodao"
+	"github.com/gogufo/gufo-api-gateway/scheduler"
+)
+
+func cronRoutes(r chi.Router) {
+	r.Get("/admin/cron", cronList)
+	r.Get("/admin/cron/{module}/{job}/runs", cronRuns)
+	r.Post("/admin/cron/{module}/{job}/run", cronTrigger)
+}
+
+func cronList(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"enabled": scheduler.Enabled(),
+		"node":    scheduler.Node(),
+		"leader":  scheduler.Leader(),
+		"jobs":    scheduler.Jobs(),
+	})
+}
+
+func cronRuns(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	runs, err := scheduler.Runs(cronKey(r), limit)
+	if err != nil {
+		writeCronError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"runs": runs})
+}
+
+func cronTrigger(w http.ResponseWriter, r *http.Request) {
+	key := cronKey(r)
+	sf.SetLog("admin: manual cron run " + key)
+	run, err := scheduler.Trigger(key)
+	if err != nil {
+		writeCronError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, run)
+}
+
+func cronKey(r *http.Request) string {
+	return chi.URLParam(r, "module") + "/" + chi.URLParam(r, "job")
+}
+
+func writeCronError(w http.ResponseWriter, err error) {
+	if errors.Is(err, scheduler.ErrUnknownJob) {
+		writeJSON(w, http.StatusNotFound, map[string]any{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+}
@@ -0,0 +1,97 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// Secret redaction for the effective config view
+
+package admin
+
+import (
+	"regexp"
+	"strings"
+)
+
+const redacted = "******"
+
+// Secrets embedded in otherwise harmless values (url, addr, host ...):
+// URL userinfo ("redis://:pw@host", "amqp://user:pw@host"), MySQL DSNs
+// ("user:pw@tcp(host)/db") and key=value DSNs or queries ("password=pw").
+var (
+	urlPassword   = regexp.MustCompile(`^([a-zA-Z][a-zA-Z0-9+.-]*://[^:/@\s]*):[^@/\s]*@`)
+	dsnPassword   = regexp.MustCompile(`^([^:/@\s]*):[^@\s]*@`)
+	paramPassword = regexp.MustCompile(`(?i)\b(password|passwd|pwd|pass|secret|token)=[^\s;&]+`)
+)
+
+// secretWords mark config keys whose values must never leave the process.
+var secretWords = []string{"password", "secret", "token", "sign", "key", "dsn", "pass"}
+
+// redact returns a copy of settings with secret values masked. Keys ending
+// in _env name environment variables rather than hold secrets and are kept.
+func redact(settings map[string]any) map[string]any {
+	out := make(map[string]any, len(settings))
+	for k, val := range settings {
+		out[k] = redactValue(k, val)
+	}
+	return out
+}
+
+func redactValue(key string, val any) any {
+	switch t := val.(type) {
+	case map[string]any:
+		return redact(t)
+	case []any:
+		items := make([]any, len(t))
+		for i, item := range t {
+			items[i] = redactValue(key, item)
+		}
+		return items
+	case []map[string]any:
+		items := make([]any, len(t))
+		for i, item := range t {
+			items[i] = redact(item)
+		}
+		return items
+	}
+	if isSecret(key) && val != "" {
+		return redacted
+	}
+	if s, ok := val.(string); ok {
+		return redactString(s)
+	}
+	return val
+}
+
+// redactString masks passwords embedded in a value.
+func redactString(s string) string {
+	if strings.Contains(s, "://") {
+		s = urlPassword.ReplaceAllString(s, "${1}:"+redacted+"@")
+	} else {
+		s = dsnPassword.ReplaceAllString(s, "${1}:"+redacted+"@")
+	}
+	return paramPassword.ReplaceAllString(s, "${1}="+redacted)
+}
+
+func isSecret(key string) bool {
+	k := strings.ToLower(key)
+	if strings.HasSuffix(k, "_env") || strings.HasSuffix(k, "_path") || strings.HasSuffix(k, "_ttl") {
+		return false
+	}
+	for _, w := range secretWords {
+		if strings.Contains(k, w) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// Admin endpoint for the usage report: GET /admin/usage?period=2026-10&consumer=acme
+
+package admin
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gogufo/gufo-api-gateway/metering"
+)
+
+func usage(w http.ResponseWriter, r *http.Request) {
+	period := r.URL.Query().Get("period")
+	if period == "" {
+		period = metering.PeriodKey(metering.Month, time.Now())
+	}
+
+	rows, err := metering.Report(period, r.URL.Query().Get("consumer"))
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"period": period, "usage": rows})
+}
@@ -0,0 +1,206 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// Admin endpoints for webhook subscriptions, deliveries and dead letters.
+
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	sf "github.com/gogufo/gufo-api-gateway/gufodao"
+	"github.com/gogufo/gufo-api-gateway/webhook"
+)
+
+func webhookRoutes(r chi.Router) {
+	r.Get("/admin/webhooks", webhookList)
+	r.Post("/admin/webhooks", webhookCreate)
+	r.Get("/admin/webhooks/deliveries", deliveryList)
+	r.Get("/admin/webhooks/deliveries/{id}", deliveryGet)
+	r.Post("/admin/webhooks/deliveries/{id}/redeliver", deliveryRedeliver)
+	r.Get("/admin/webhooks/deadletters", deadLetters)
+	r.Get("/admin/webhooks/{id}", webhookGet)
+	r.Put("/admin/webhooks/{id}", webhookUpdate)
+	r.Patch("/admin/webhooks/{id}", webhookUpdate)
+	r.Delete("/admin/webhooks/{id}", webhookDelete)
+}
+
+// webhookPatch lists the fields a PUT/PATCH may change.
+type webhookPatch struct {
+	URL         *string   `json:"url"`
+	Events      *[]string `json:"events"`
+	Modules     *[]string `json:"modules"`
+	Secret      *string   `json:"secret"`
+	Description *string   `json:"description"`
+	Active      *bool     `json:"active"`
+}
+
+func webhookList(w http.ResponseWriter, r *http.Request) {
+	subs, err := webhook.GetStore().ListSubscriptions()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+		return
+	}
+	for i := range subs {
+		subs[i].Secret = ""
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"webhooks": subs})
+}
+
+func webhookCreate(w http.ResponseWriter, r *http.Request) {
+	var p webhookPatch
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil || p.URL == nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": `expected {"url": "...", "events": [...]}`})
+		return
+	}
+	sub := webhook.Subscription{Active: true}
+	applyPatch(&sub, &p)
+
+	created, err := webhook.Create(sub)
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	sf.SetLog("admin: created webhook " + created.ID + " -> " + created.URL)
+	// The secret is only returned here.
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func webhookGet(w http.ResponseWriter, r *http.Request) {
+	sub, err := webhook.GetStore().GetSubscription(chi.URLParam(r, "id"))
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	sub.Secret = ""
+	writeJSON(w, http.StatusOK, sub)
+}
+
+func webhookUpdate(w http.ResponseWriter, r *http.Request) {
+	st := webhook.GetStore()
+	sub, err := st.GetSubscription(chi.URLParam(r, "id"))
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	var p webhookPatch
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid JSON body"})
+		return
+	}
+	applyPatch(sub, &p)
+	if err := sub.Validate(); err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	sub.UpdatedAt = time.Now().UTC()
+	if err := st.SaveSubscription(sub); err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	sf.SetLog("admin: updated webhook " + sub.ID)
+	sub.Secret = ""
+	writeJSON(w, http.StatusOK, sub)
+}
+
+func webhookDelete(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if err := webhook.GetStore().DeleteSubscription(id); err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	sf.SetLog("admin: deleted webhook " + id)
+	writeJSON(w, http.StatusOK, map[string]any{"id": id, "deleted": true})
+}
+
+func deliveryList(w http.ResponseWriter, r *http.Request) {
+	listDeliveries(w, r, r.URL.Query().Get("status"))
+}
+
+func deadLetters(w http.ResponseWriter, r *http.Request) {
+	listDeliveries(w, r, webhook.Dead)
+}
+
+// listDeliveries answers ?subscription=&limit= (default 100).
+func listDeliveries(w http.ResponseWriter, r *http.Request, status string) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 {
+		limit = 100
+	}
+	out, err := webhook.GetStore().ListDeliveries(status, r.URL.Query().Get("subscription"), limit)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"deliveries": out})
+}
+
+func deliveryGet(w http.ResponseWriter, r *http.Request) {
+	d, err := webhook.GetStore().GetDelivery(chi.URLParam(r, "id"))
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, d)
+}
+
+func deliveryRedeliver(w http.ResponseWriter, r *http.Request) {
+	d, err := webhook.Redeliver(chi.URLParam(r, "id"))
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	sf.SetLog("admin: redelivering webhook delivery " + d.ID)
+	writeJSON(w, http.StatusAccepted, d)
+}
+
+func applyPatch(sub *webhook.Subscription, p *webhookPatch) {
+	if p.URL != nil {
+		sub.URL = *p.URL
+	}
+	if p.Events != nil {
+		sub.Events = *p.Events
+	}
+	if p.Modules != nil {
+		sub.Modules = *p.Modules
+	}
+	if p.Secret != nil {
+		sub.Secret = *p.Secret
+	}
+	if p.Description != nil {
+		sub.Description = *p.Description
+	}
+	if p.Active != nil {
+		sub.Active = *p.Active
+	}
+}
+
+func writeWebhookError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, webhook.ErrNotFound):
+		writeJSON(w, http.StatusNotFound, map[string]any{"error": err.Error()})
+	case errors.Is(err, webhook.ErrInvalid):
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+	default:
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+	}
+}
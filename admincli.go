@@ -0,0 +1,344 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// CLI client for the gateway admin API (gufo status, registry, pool, routes, drain)
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gogufo/gufo-api-gateway/admin"
+	"github.com/urfave/cli/v2"
+)
+
+// adminClient talks to a running gateway through its admin listener.
+type adminClient struct {
+	base   string
+	token  string
+	client *http.Client
+}
+
+// adminFlags are shared by every command that talks to the admin API.
+func adminFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{Name: "addr", Usage: "admin API address (defaults to server.admin_ip:server.admin_port)"},
+		&cli.StringFlag{Name: "token", Usage: "admin token (defaults to server.admin_token)", EnvVars: []string{"GUFO_ADMIN_TOKEN"}},
+		&cli.BoolFlag{Name: "json", Usage: "print raw JSON instead of a table"},
+	}
+}
+
+// adminCommands returns CLI commands backed by the admin API.
+func adminCommands() []*cli.Command {
+	return []*cli.Command{
+		{
+			Name:   "status",
+			Usage:  "Show gateway status",
+			Flags:  adminFlags(),
+			Action: statusCmd,
+		},
+		{
+			Name:  "registry",
+			Usage: "Inspect the service registry cache",
+			Subcommands: []*cli.Command{
+				{Name: "ls", Usage: "List cached registry entries", Flags: adminFlags(), Action: registryLsCmd},
+				{Name: "evict", Usage: "Evict a module from the cache", ArgsUsage: "<module>", Flags: adminFlags(), Action: registryEvictCmd},
+			},
+		},
+		{
+			Name:  "pool",
+			Usage: "Inspect the gRPC connection pool",
+			Subcommands: []*cli.Command{
+				{Name: "ls", Usage: "List pooled gRPC connections", Flags: adminFlags(), Action: poolLsCmd},
+			},
+		},
+		{
+			Name:   "routes",
+			Usage:  "List public HTTP routes",
+			Flags:  adminFlags(),
+			Action: routesCmd,
+		},
+		{
+			Name:      "drain",
+			Usage:     "Take a module out of rotation (without a module: list drained modules)",
+			ArgsUsage: "[module]",
+			Flags: append(adminFlags(),
+				&cli.BoolFlag{Name: "undo", Usage: "put the module back into rotation"},
+			),
+			Action: drainCmd,
+		},
+	}
+}
+
+func newAdminClient(c *cli.Context) *adminClient {
+	addr := c.String("addr")
+	if addr == "" {
+		addr = admin.Addr()
+	}
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+	token := c.String("token")
+	if token == "" {
+		token = admin.Token()
+	}
+	return &adminClient{
+		base:   strings.TrimRight(addr, "/"),
+		token:  token,
+		client: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// do sends a request and decodes the JSON answer into out (if not nil).
+func (a *adminClient) do(method, path string, body any, out any) error {
+	if a.token == "" {
+		return fmt.Errorf("admin token is not configured (server.admin_token or --token)")
+	}
+
+	var rd io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		rd = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, a.base+path, rd)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(admin.TokenHeader, a.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot reach admin API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= 300 {
+		var e struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(raw, &e) == nil && e.Error != "" {
+			return fmt.Errorf("%s: %s", resp.Status, e.Error)
+		}
+		return fmt.Errorf("server responded with %s", resp.Status)
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(raw, out)
+}
+
+// fetch runs a request and prints the raw JSON when --json is set.
+// It returns false when the caller should not print a table.
+func fetch(c *cli.Context, method, path string, out any) (bool, error) {
+	var raw json.RawMessage
+	if err := newAdminClient(c).do(method, path, nil, &raw); err != nil {
+		return false, cli.Exit(err.Error(), 1)
+	}
+	if c.Bool("json") {
+		var pretty bytes.Buffer
+		json.Indent(&pretty, raw, "", "  ")
+		fmt.Println(pretty.String())
+		return false, nil
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		return false, cli.Exit("cannot decode admin answer: "+err.Error(), 1)
+	}
+	return true, nil
+}
+
+func table() *tabwriter.Writer {
+	return tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+}
+
+func statusCmd(c *cli.Context) error {
+	var st map[string]any
+	ok, err := fetch(c, http.MethodGet, "/admin/status", &st)
+	if !ok {
+		return err
+	}
+
+	tw := table()
+	for _, k := range []string{"version", "commit", "build_date", "pid", "uptime", "http_port", "grpc_port", "mode", "edge", "master", "debug"} {
+		fmt.Fprintf(tw, "%s\t%v\n", k, st[k])
+	}
+	if drained, _ := st["drained"].(map[string]any); len(drained) > 0 {
+		names := make([]string, 0, len(drained))
+		for m := range drained {
+			names = append(names, m)
+		}
+		sort.Strings(names)
+		fmt.Fprintf(tw, "drained\t%s\n", strings.Join(names, ", "))
+	}
+	if chain, _ := st["middleware"].([]any); len(chain) > 0 {
+		for _, item := range chain {
+			m, _ := item.(map[string]any)
+			line := fmt.Sprint(m["name"])
+			if state, ok := m["state"]; ok {
+				b, _ := json.Marshal(state)
+				line += " " + string(b)
+			}
+			fmt.Fprintf(tw, "middleware\t%s\n", line)
+		}
+	}
+	return tw.Flush()
+}
+
+func registryLsCmd(c *cli.Context) error {
+	var ans struct {
+		Entries []struct {
+			Module string `json:"module"`
+			Info   struct {
+				Host       string    `json:"host"`
+				Port       string    `json:"port"`
+				LastUpdate time.Time `json:"last_update"`
+			} `json:"info"`
+			Expired bool `json:"expired"`
+		} `json:"entries"`
+	}
+	ok, err := fetch(c, http.MethodGet, "/admin/registry", &ans)
+	if !ok {
+		return err
+	}
+
+	tw := table()
+	fmt.Fprintln(tw, "MODULE\tADDRESS\tUPDATED\tEXPIRED")
+	for _, e := range ans.Entries {
+		fmt.Fprintf(tw, "%s\t%s:%s\t%s\t%v\n", e.Module, e.Info.Host, e.Info.Port,
+			e.Info.LastUpdate.Format(time.RFC3339), e.Expired)
+	}
+	return tw.Flush()
+}
+
+func registryEvictCmd(c *cli.Context) error {
+	module := c.Args().First()
+	if module == "" {
+		return cli.Exit("usage: gufo registry evict <module>", 2)
+	}
+	if err := newAdminClient(c).do(http.MethodDelete, "/admin/registry/"+module, nil, nil); err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+	fmt.Printf("evicted %s\n", module)
+	return nil
+}
+
+func poolLsCmd(c *cli.Context) error {
+	var ans struct {
+		Connections []struct {
+			Addr   string    `json:"addr"`
+			State  string    `json:"state"`
+			Expiry time.Time `json:"expiry"`
+		} `json:"connections"`
+	}
+	ok, err := fetch(c, http.MethodGet, "/admin/pool", &ans)
+	if !ok {
+		return err
+	}
+
+	tw := table()
+	fmt.Fprintln(tw, "ADDRESS\tSTATE\tEXPIRES")
+	for _, conn := range ans.Connections {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", conn.Addr, conn.State, conn.Expiry.Format(time.RFC3339))
+	}
+	return tw.Flush()
+}
+
+func routesCmd(c *cli.Context) error {
+	var ans struct {
+		Routes []admin.Route `json:"routes"`
+	}
+	ok, err := fetch(c, http.MethodGet, "/admin/routes", &ans)
+	if !ok {
+		return err
+	}
+
+	// Group methods per pattern; catch-all handlers register every method
+	var patterns []string
+	methods := map[string][]string{}
+	for _, rt := range ans.Routes {
+		if _, ok := methods[rt.Pattern]; !ok {
+			patterns = append(patterns, rt.Pattern)
+		}
+		methods[rt.Pattern] = append(methods[rt.Pattern], rt.Method)
+	}
+
+	tw := table()
+	fmt.Fprintln(tw, "PATTERN\tMETHODS")
+	for _, p := range patterns {
+		m := strings.Join(methods[p], ",")
+		if len(methods[p]) >= 9 {
+			m = "ANY"
+		}
+		fmt.Fprintf(tw, "%s\t%s\n", p, m)
+	}
+	return tw.Flush()
+}
+
+func drainCmd(c *cli.Context) error {
+	module := c.Args().First()
+	if module == "" {
+		var ans struct {
+			Drained map[string]time.Time `json:"drained"`
+		}
+		ok, err := fetch(c, http.MethodGet, "/admin/drain", &ans)
+		if !ok {
+			return err
+		}
+		names := make([]string, 0, len(ans.Drained))
+		for m := range ans.Drained {
+			names = append(names, m)
+		}
+		sort.Strings(names)
+
+		tw := table()
+		fmt.Fprintln(tw, "MODULE\tDRAINED SINCE")
+		for _, m := range names {
+			fmt.Fprintf(tw, "%s\t%s\n", m, ans.Drained[m].Format(time.RFC3339))
+		}
+		return tw.Flush()
+	}
+
+	method, verb := http.MethodPost, "drained"
+	if c.Bool("undo") {
+		method, verb = http.MethodDelete, "undrained"
+	}
+	if err := newAdminClient(c).do(method, "/admin/drain/"+module, nil, nil); err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+	fmt.Printf("%s %s\n", verb, module)
+	return nil
+}
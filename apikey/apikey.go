@@ -0,0 +1,204 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// API keys for public API consumers (partners, integrations).
+// Keys are stored hashed (SHA-256) in a pluggable Store, carry scopes
+// limiting which modules/params they may call, and have their own rate
+// limit, daily quota and expiry.
+
+package apikey
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	sf "github.com/gogufo/gufo-api-gateway/gufodao"
+	"github.com/gogufo/gufo-api-gateway/metering"
+	mid "github.com/gogufo/gufo-api-gateway/middleware"
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+)
+
+// keyPrefix marks Gufo API keys, which makes leaked keys easy to grep for.
+const keyPrefix = "gufo_"
+
+var (
+	ErrNotFound     = errors.New("api key not found")
+	ErrRevoked      = errors.New("api key revoked")
+	ErrExpired      = errors.New("api key expired")
+	ErrScope        = errors.New("api key not allowed for this module")
+	ErrRateLimited  = errors.New("api key rate limit exceeded")
+	ErrQuotaReached = errors.New("api key quota exceeded")
+	ErrReadOnly     = errors.New("api key store is read-only")
+)
+
+// Key describes one API consumer credential. The plaintext key is never stored.
+type Key struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Hash      string    `json:"-"`
+	Consumer  string    `json:"consumer"`
+	Modules   []string  `json:"modules"`
+	Params    []string  `json:"params"`
+	RateLimit int       `json:"rate_limit"` // requests per second, 0 = unlimited
+	Burst     int       `json:"burst"`      // bucket size, 0 = rate_limit
+	Quota     int64     `json:"quota"`      // requests per day, 0 = unlimited
+	ExpiresAt time.Time `json:"expires_at"`
+	Revoked   bool      `json:"revoked"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Store persists API keys by hash.
+type Store interface {
+	Get(hash string) (*Key, error)
+	List() ([]*Key, error)
+	Save(k *Key) error
+	Revoke(id string) error
+}
+
+var (
+	store     Store
+	storeOnce sync.Once
+
+	limiters sync.Map // key ID -> *keyLimiter
+)
+
+// keyLimiter remembers the limits a key's bucket was built with, so that
+// changing them takes effect on the next request.
+type keyLimiter struct {
+	rate, burst int
+	rl          *mid.RateLimiter
+}
+
+// GetStore returns the configured store (apikeys.store = config | sql | redis).
+func GetStore() Store {
+	storeOnce.Do(func() {
+		switch strings.ToLower(viper.GetString("apikeys.store")) {
+		case "sql":
+			s, err := newSQLStore()
+			if err != nil {
+				sf.SetErrorLog("apikey: sql store unavailable, falling back to config: " + err.Error())
+				store = newConfigStore()
+				return
+			}
+			store = s
+		case "redis":
+			store = newRedisStore()
+		default:
+			store = newConfigStore()
+		}
+	})
+	return store
+}
+
+// Hash returns the at-rest representation of a plaintext key.
+func Hash(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
+
+// Create generates a new key, stores its hash and returns the plaintext once.
+func Create(k *Key) (string, error) {
+	plain := keyPrefix + sf.RandomString(40)
+	k.ID = uuid.New().String()
+	k.Hash = Hash(plain)
+	k.CreatedAt = time.Now()
+	if k.Consumer == "" {
+		k.Consumer = k.ID
+	}
+	if err := GetStore().Save(k); err != nil {
+		return "", err
+	}
+	return plain, nil
+}
+
+// FromRequest extracts the key from the configured header (default X-API-Key)
+// or from "Authorization: ApiKey <key>".
+func FromRequest(r *http.Request) string {
+	header := viper.GetString("apikeys.header")
+	if header == "" {
+		header = "X-API-Key"
+	}
+	if v := strings.TrimSpace(r.Header.Get(header)); v != "" {
+		return v
+	}
+	parts := strings.SplitN(strings.TrimSpace(r.Header.Get("Authorization")), " ", 2)
+	if len(parts) == 2 && strings.EqualFold(parts[0], "ApiKey") {
+		return strings.TrimSpace(parts[1])
+	}
+	return ""
+}
+
+// Authenticate validates plain for module/param and applies the key's
+// rate limit and daily quota.
+func Authenticate(ctx context.Context, r *http.Request, plain, module, param string) (*Key, error) {
+	if plain == "" {
+		return nil, ErrNotFound
+	}
+	k, err := GetStore().Get(Hash(plain))
+	if err != nil {
+		return nil, err
+	}
+	if k.Revoked {
+		return k, ErrRevoked
+	}
+	if !k.ExpiresAt.IsZero() && time.Now().After(k.ExpiresAt) {
+		return k, ErrExpired
+	}
+	if !sf.MatchScope(k.Modules, module) || !sf.MatchScope(k.Params, param) {
+		return k, ErrScope
+	}
+
+	if k.RateLimit > 0 {
+		if _, err := limiter(k).Before(r, ctx); err != nil {
+			return k, ErrRateLimited
+		}
+	}
+
+	// requests are counted per key by the metering package after the response
+	if k.Quota > 0 && metering.UsedByKey(k.ID) >= k.Quota {
+		return k, ErrQuotaReached
+	}
+
+	return k, nil
+}
+
+// limiter returns the token bucket of k: rate_limit tokens per second and
+// room for burst (default rate_limit) requests at once.
+func limiter(k *Key) *mid.RateLimiter {
+	burst := k.Burst
+	if burst <= 0 {
+		burst = k.RateLimit
+	}
+	if v, ok := limiters.Load(k.ID); ok {
+		if l := v.(*keyLimiter); l.rate == k.RateLimit && l.burst == burst {
+			return l.rl
+		}
+	}
+	l := &keyLimiter{
+		rate:  k.RateLimit,
+		burst: burst,
+		rl:    mid.NewKeyRateLimiter(k.RateLimit, burst),
+	}
+	limiters.Store(k.ID, l)
+	return l.rl
+}
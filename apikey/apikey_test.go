@@ -0,0 +1,246 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+
+package apikey
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	sf "github.com/gogufo/gufo-api-gateway/gufodao"
+	"github.com/gogufo/gufo-api-gateway/internal/redistest"
+	"github.com/gogufo/gufo-api-gateway/metering"
+	"github.com/spf13/viper"
+)
+
+// useStore makes s the store returned by GetStore for the test.
+func useStore(t *testing.T, s Store) {
+	t.Helper()
+	storeOnce.Do(func() {})
+	prev := store
+	store = s
+	t.Cleanup(func() { store = prev })
+}
+
+// useRedis points sf.CachePool at an in-process fake for the test.
+func useRedis(t *testing.T) {
+	t.Helper()
+	prev := sf.CachePool
+	sf.CachePool, _ = redistest.NewPool()
+	t.Cleanup(func() { sf.CachePool = prev })
+}
+
+func TestFromRequest(t *testing.T) {
+	t.Cleanup(func() { viper.Set("apikeys.header", "") })
+
+	tests := []struct {
+		name, header string
+		set          map[string]string
+		want         string
+	}{
+		{"default header", "", map[string]string{"X-API-Key": " gufo_abc "}, "gufo_abc"},
+		{"authorization", "", map[string]string{"Authorization": "ApiKey gufo_abc"}, "gufo_abc"},
+		{"bearer is not a key", "", map[string]string{"Authorization": "Bearer gufo_abc"}, ""},
+		{"custom header", "X-Partner-Key", map[string]string{"X-Partner-Key": "gufo_abc", "X-API-Key": "other"}, "gufo_abc"},
+		{"none", "", nil, ""},
+	}
+	for _, tt := range tests {
+		viper.Set("apikeys.header", tt.header)
+		r := httptest.NewRequest("GET", "/api/v3/orders", nil)
+		for k, v := range tt.set {
+			r.Header.Set(k, v)
+		}
+		if got := FromRequest(r); got != tt.want {
+			t.Errorf("%s: FromRequest = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestConfigStoreSeed(t *testing.T) {
+	viper.Set("apikeys.keys", []map[string]interface{}{
+		{"id": "partner", "hash": "ABCDEF", "modules": []string{"orders"}, "rate_limit": 5, "expires_at": "2030-01-02T00:00:00Z"},
+		{"hash": "0123456789abcdef0123"},
+	})
+	t.Cleanup(func() { viper.Set("apikeys.keys", nil) })
+
+	s := newConfigStore()
+	k, err := s.Get("abcdef") // hashes are matched lowercase
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k.ID != "partner" || k.Consumer != "partner" || k.RateLimit != 5 || k.ExpiresAt.Year() != 2030 {
+		t.Fatalf("seeded key = %+v", k)
+	}
+	if k, err := s.Get("0123456789abcdef0123"); err != nil || k.ID != "0123456789ab" {
+		t.Fatalf("key without id = %+v, %v", k, err)
+	}
+	if _, err := s.Get("missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get(missing) = %v, want ErrNotFound", err)
+	}
+}
+
+func TestStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) Store{
+		"config": func(t *testing.T) Store { return &configStore{keys: map[string]*Key{}} },
+		"redis":  func(t *testing.T) Store { useRedis(t); return newRedisStore() },
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			useStore(t, newStore(t))
+
+			plain, err := Create(&Key{Name: "partner", Modules: []string{"orders"}, RateLimit: 10})
+			if err != nil {
+				t.Fatal(err)
+			}
+			k, err := GetStore().Get(Hash(plain))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if k.Name != "partner" || k.Consumer != k.ID || k.Hash != Hash(plain) || k.RateLimit != 10 {
+				t.Fatalf("stored key = %+v", k)
+			}
+
+			keys, err := GetStore().List()
+			if err != nil || len(keys) != 1 {
+				t.Fatalf("List = %v, %v", keys, err)
+			}
+
+			if err := GetStore().Revoke(k.ID); err != nil {
+				t.Fatal(err)
+			}
+			if k, _ := GetStore().Get(Hash(plain)); !k.Revoked {
+				t.Fatal("revoked key is not marked revoked")
+			}
+			if err := GetStore().Revoke("missing"); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("Revoke(missing) = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	useStore(t, &configStore{keys: map[string]*Key{}})
+
+	create := func(k Key) string {
+		plain, err := Create(&k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return plain
+	}
+	valid := create(Key{Modules: []string{"orders"}, Params: []string{"list", "get*"}})
+	expired := create(Key{ExpiresAt: time.Now().Add(-time.Minute)})
+	future := create(Key{ExpiresAt: time.Now().Add(time.Hour)})
+	revoked := create(Key{})
+	k, _ := GetStore().Get(Hash(revoked))
+	GetStore().Revoke(k.ID)
+
+	tests := []struct {
+		name, plain, module, param string
+		want                       error
+	}{
+		{"valid", valid, "orders", "list", nil},
+		{"param prefix", valid, "orders", "getone", nil},
+		{"other module", valid, "users", "list", ErrScope},
+		{"other param", valid, "orders", "delete", ErrScope},
+		{"unknown", "gufo_unknown", "orders", "list", ErrNotFound},
+		{"empty", "", "orders", "list", ErrNotFound},
+		{"expired", expired, "orders", "list", ErrExpired},
+		{"not expired yet", future, "orders", "list", nil},
+		{"revoked", revoked, "orders", "list", ErrRevoked},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/api/v3/"+tt.module, nil)
+		_, err := Authenticate(context.Background(), r, tt.plain, tt.module, tt.param)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: Authenticate = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestAuthenticateRateLimit(t *testing.T) {
+	useStore(t, &configStore{keys: map[string]*Key{}})
+
+	tests := []struct {
+		name    string
+		key     Key
+		allowed int // requests allowed at once
+	}{
+		{"burst defaults to rate", Key{RateLimit: 3}, 3},
+		{"explicit burst", Key{RateLimit: 3, Burst: 5}, 5},
+		{"burst below rate", Key{RateLimit: 10, Burst: 2}, 2},
+	}
+	for _, tt := range tests {
+		plain, err := Create(&tt.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := httptest.NewRequest("GET", "/api/v3/orders", nil)
+		for i := range tt.allowed {
+			if _, err := Authenticate(context.Background(), r, plain, "orders", ""); err != nil {
+				t.Fatalf("%s: request %d: %v", tt.name, i, err)
+			}
+		}
+		if _, err := Authenticate(context.Background(), r, plain, "orders", ""); !errors.Is(err, ErrRateLimited) {
+			t.Fatalf("%s: request over the burst = %v, want ErrRateLimited", tt.name, err)
+		}
+	}
+
+	// raising the limit takes effect on the next request
+	plain, _ := Create(&Key{RateLimit: 1})
+	r := httptest.NewRequest("GET", "/api/v3/orders", nil)
+	Authenticate(context.Background(), r, plain, "orders", "")
+	if _, err := Authenticate(context.Background(), r, plain, "orders", ""); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("second request = %v, want ErrRateLimited", err)
+	}
+	k, _ := GetStore().Get(Hash(plain))
+	k.Burst = 2
+	if _, err := Authenticate(context.Background(), r, plain, "orders", ""); err != nil {
+		t.Fatalf("after raising the burst: %v", err)
+	}
+}
+
+func TestAuthenticateQuotaPerKey(t *testing.T) {
+	useStore(t, &configStore{keys: map[string]*Key{}})
+
+	// two keys of one consumer, each with its own daily quota
+	first, _ := Create(&Key{Consumer: "quota-acme", Quota: 2})
+	second, _ := Create(&Key{Consumer: "quota-acme", Quota: 2})
+
+	r := httptest.NewRequest("GET", "/api/v3/orders", nil)
+	use := func(plain string) error {
+		k, err := Authenticate(context.Background(), r, plain, "orders", "")
+		if err == nil {
+			metering.Track(&metering.Record{Consumer: k.Consumer, Module: "orders", APIKey: true, KeyID: k.ID, Forwarded: true}, 0, 0)
+		}
+		return err
+	}
+
+	for i := range 2 {
+		if err := use(first); err != nil {
+			t.Fatalf("first key, request %d: %v", i, err)
+		}
+	}
+	if err := use(first); !errors.Is(err, ErrQuotaReached) {
+		t.Fatalf("first key over quota = %v, want ErrQuotaReached", err)
+	}
+	if err := use(second); err != nil {
+		t.Fatalf("second key of the same consumer: %v", err)
+	}
+}
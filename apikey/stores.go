@@ -0,0 +1,348 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// API key store backends: config (seeded from settings, runtime changes
+// kept in memory), SQL via gorm (DBConnectv2) and Redis (sf.CachePool).
+
+package apikey
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	sf "github.com/gogufo/gufo-api-gateway/gufodao"
+	"github.com/gomodule/redigo/redis"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+// -------------------------------------------------------------------
+// Config store
+// -------------------------------------------------------------------
+
+type configKey struct {
+	ID        string   `mapstructure:"id"`
+	Name      string   `mapstructure:"name"`
+	Hash      string   `mapstructure:"hash"`
+	Consumer  string   `mapstructure:"consumer"`
+	Modules   []string `mapstructure:"modules"`
+	Params    []string `mapstructure:"params"`
+	RateLimit int      `mapstructure:"rate_limit"`
+	Burst     int      `mapstructure:"burst"`
+	Quota     int64    `mapstructure:"quota"`
+	ExpiresAt string   `mapstructure:"expires_at"`
+}
+
+type configStore struct {
+	mu   sync.RWMutex
+	keys map[string]*Key // hash -> key
+}
+
+// newConfigStore loads [[apikeys.keys]]. Keys created at runtime are kept
+// in memory only and are lost on restart.
+func newConfigStore() *configStore {
+	s := &configStore{keys: map[string]*Key{}}
+
+	var entries []configKey
+	if err := viper.UnmarshalKey("apikeys.keys", &entries); err != nil {
+		sf.SetErrorLog("apikey: cannot parse apikeys.keys: " + err.Error())
+	}
+	for _, e := range entries {
+		k := &Key{
+			ID:        e.ID,
+			Name:      e.Name,
+			Hash:      strings.ToLower(e.Hash),
+			Consumer:  e.Consumer,
+			Modules:   e.Modules,
+			Params:    e.Params,
+			RateLimit: e.RateLimit,
+			Burst:     e.Burst,
+			Quota:     e.Quota,
+		}
+		if k.ID == "" {
+			k.ID = k.Hash[:min(12, len(k.Hash))]
+		}
+		if k.Consumer == "" {
+			k.Consumer = k.ID
+		}
+		if e.ExpiresAt != "" {
+			if ts, err := time.Parse(time.RFC3339, e.ExpiresAt); err == nil {
+				k.ExpiresAt = ts
+			} else {
+				sf.SetErrorLog("apikey: invalid expires_at for " + k.ID)
+			}
+		}
+		s.keys[k.Hash] = k
+	}
+	return s
+}
+
+func (s *configStore) Get(hash string) (*Key, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if k, ok := s.keys[hash]; ok {
+		return k, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (s *configStore) List() ([]*Key, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]*Key, 0, len(s.keys))
+	for _, k := range s.keys {
+		out = append(out, k)
+	}
+	return out, nil
+}
+
+func (s *configStore) Save(k *Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[k.Hash] = k
+	sf.SetLog("apikey: key " + k.ID + " stored in memory (config store is not persistent)")
+	return nil
+}
+
+func (s *configStore) Revoke(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, k := range s.keys {
+		if k.ID == id {
+			k.Revoked = true
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// -------------------------------------------------------------------
+// SQL store
+// -------------------------------------------------------------------
+
+type apiKeyRow struct {
+	ID        string `gorm:"primaryKey;size:64"`
+	Name      string `gorm:"size:255"`
+	Hash      string `gorm:"uniqueIndex;size:64"`
+	Consumer  string `gorm:"index;size:128"`
+	Modules   string `gorm:"size:1024"`
+	Params    string `gorm:"size:1024"`
+	RateLimit int
+	Burst     int
+	Quota     int64
+	ExpiresAt *time.Time
+	Revoked   bool
+	CreatedAt time.Time
+}
+
+func (apiKeyRow) TableName() string { return "gufo_api_keys" }
+
+type sqlStore struct {
+	db *gorm.DB
+}
+
+func newSQLStore() (*sqlStore, error) {
+	db, err := sf.ConnectDBv2()
+	if err != nil {
+		return nil, err
+	}
+	if db == nil || db.Conn == nil {
+		return nil, errors.New("database is not configured")
+	}
+	if err := db.Conn.AutoMigrate(&apiKeyRow{}); err != nil {
+		return nil, err
+	}
+	return &sqlStore{db: db.Conn}, nil
+}
+
+func (s *sqlStore) Get(hash string) (*Key, error) {
+	var row apiKeyRow
+	if err := s.db.Where("hash = ?", hash).First(&row).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return row.toKey(), nil
+}
+
+func (s *sqlStore) List() ([]*Key, error) {
+	var rows []apiKeyRow
+	if err := s.db.Order("created_at").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make([]*Key, 0, len(rows))
+	for i := range rows {
+		out = append(out, rows[i].toKey())
+	}
+	return out, nil
+}
+
+func (s *sqlStore) Save(k *Key) error {
+	row := apiKeyRow{
+		ID:        k.ID,
+		Name:      k.Name,
+		Hash:      k.Hash,
+		Consumer:  k.Consumer,
+		Modules:   strings.Join(k.Modules, ","),
+		Params:    strings.Join(k.Params, ","),
+		RateLimit: k.RateLimit,
+		Burst:     k.Burst,
+		Quota:     k.Quota,
+		Revoked:   k.Revoked,
+		CreatedAt: k.CreatedAt,
+	}
+	if !k.ExpiresAt.IsZero() {
+		row.ExpiresAt = &k.ExpiresAt
+	}
+	return s.db.Save(&row).Error
+}
+
+func (s *sqlStore) Revoke(id string) error {
+	res := s.db.Model(&apiKeyRow{}).Where("id = ?", id).Update("revoked", true)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (row apiKeyRow) toKey() *Key {
+	k := &Key{
+		ID:        row.ID,
+		Name:      row.Name,
+		Hash:      row.Hash,
+		Consumer:  row.Consumer,
+		Modules:   splitList(row.Modules),
+		Params:    splitList(row.Params),
+		RateLimit: row.RateLimit,
+		Burst:     row.Burst,
+		Quota:     row.Quota,
+		Revoked:   row.Revoked,
+		CreatedAt: row.CreatedAt,
+	}
+	if row.ExpiresAt != nil {
+		k.ExpiresAt = *row.ExpiresAt
+	}
+	return k
+}
+
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+// -------------------------------------------------------------------
+// Redis store
+// -------------------------------------------------------------------
+
+const (
+	redisKeyPrefix = "gufo:apikey:"
+	redisIDPrefix  = "gufo:apikey:id:"
+	redisIndex     = "gufo:apikeys"
+)
+
+// redisKey keeps the hash, which is hidden from the JSON API representation.
+type redisKey struct {
+	*Key
+	Hash string `json:"hash"`
+}
+
+type redisStore struct{}
+
+func newRedisStore() *redisStore {
+	sf.EnsureCache()
+	return &redisStore{}
+}
+
+func (s *redisStore) Get(hash string) (*Key, error) {
+	conn := sf.CachePool.Get()
+	defer conn.Close()
+
+	data, err := redis.Bytes(conn.Do("GET", redisKeyPrefix+hash))
+	if err == redis.ErrNil {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	rk := redisKey{Key: &Key{}}
+	if err := json.Unmarshal(data, &rk); err != nil {
+		return nil, err
+	}
+	rk.Key.Hash = rk.Hash
+	return rk.Key, nil
+}
+
+func (s *redisStore) List() ([]*Key, error) {
+	conn := sf.CachePool.Get()
+	defer conn.Close()
+
+	hashes, err := redis.Strings(conn.Do("SMEMBERS", redisIndex))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*Key, 0, len(hashes))
+	for _, h := range hashes {
+		if k, err := s.Get(h); err == nil {
+			out = append(out, k)
+		}
+	}
+	return out, nil
+}
+
+func (s *redisStore) Save(k *Key) error {
+	data, err := json.Marshal(redisKey{Key: k, Hash: k.Hash})
+	if err != nil {
+		return err
+	}
+
+	conn := sf.CachePool.Get()
+	defer conn.Close()
+
+	conn.Send("MULTI")
+	conn.Send("SET", redisKeyPrefix+k.Hash, data)
+	conn.Send("SET", redisIDPrefix+k.ID, k.Hash)
+	conn.Send("SADD", redisIndex, k.Hash)
+	_, err = conn.Do("EXEC")
+	return err
+}
+
+func (s *redisStore) Revoke(id string) error {
+	conn := sf.CachePool.Get()
+	hash, err := redis.String(conn.Do("GET", redisIDPrefix+id))
+	conn.Close()
+	if err == redis.ErrNil {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	k, err := s.Get(hash)
+	if err != nil {
+		return err
+	}
+	k.Revoked = true
+	return s.Save(k)
+}
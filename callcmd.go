@@ -0,0 +1,227 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// gufo call: invoke a microservice through Reverse.Do from the terminal
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	sf "github.com/gogufo/gufo-api-gateway/gufodao"
+	pb "github.com/gogufo/gufo-api-gateway/proto/go"
+	v "github.com/gogufo/gufo-api-gateway/version"
+	"github.com/spf13/viper"
+	"github.com/urfave/cli/v2"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// callCommand returns the `gufo call` command.
+func callCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "call",
+		Usage:     "Invoke a microservice via Reverse.Do",
+		ArgsUsage: "[flags] <module> <param> [paramID]",
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "method", Value: "GET", Usage: "HTTP-style method passed to the module"},
+			&cli.StringSliceFlag{Name: "arg", Usage: "request argument k=v (JSON values are decoded), repeatable"},
+			&cli.StringFlag{Name: "json", Usage: "request arguments as a JSON object, or @file to read them from a file"},
+			&cli.StringFlag{Name: "target", Value: "gateway", Usage: "gateway (the gateway gRPC port) or module (call the module directly)"},
+			&cli.StringFlag{Name: "addr", Usage: "host:port to call, overrides --target resolution"},
+			&cli.StringFlag{Name: "uid", Usage: "UID to put into the request"},
+			&cli.DurationFlag{Name: "timeout", Value: 10 * time.Second, Usage: "call timeout"},
+		},
+		Action: CallModule,
+	}
+}
+
+// CallModule builds a pb.Request from CLI arguments, signs it with the
+// configured security mode and prints the decoded answer.
+func CallModule(c *cli.Context) error {
+	if c.NArg() < 2 {
+		return cli.Exit("usage: gufo call [flags] <module> <param> [paramID]", 2)
+	}
+	for _, a := range c.Args().Slice() {
+		if strings.HasPrefix(a, "-") {
+			return cli.Exit("flags must come before <module>: gufo call [flags] <module> <param> [paramID]", 2)
+		}
+	}
+	module, param := c.Args().Get(0), c.Args().Get(1)
+
+	args, err := callArgs(c)
+	if err != nil {
+		return cli.Exit(err.Error(), 2)
+	}
+
+	host, port, err := callTarget(c, module)
+	if err != nil {
+		return cli.Exit(err.Error(), 2)
+	}
+
+	method := strings.ToUpper(c.String("method"))
+	ip := "127.0.0.1"
+	ua := "gufo-cli/" + v.VERSION
+	apiv := "3"
+	ts := int32(time.Now().Unix())
+
+	t := &pb.Request{
+		Module:     &module,
+		Param:      &param,
+		Method:     &method,
+		IP:         &ip,
+		UserAgent:  &ua,
+		APIVersion: &apiv,
+		TimeStamp:  &ts,
+		Args:       sf.ToMapStringAny(args),
+	}
+	if id := c.Args().Get(2); id != "" {
+		t.ParamID = &id
+	}
+	if uid := c.String("uid"); uid != "" {
+		t.UID = &uid
+	}
+
+	// Connection and credentials follow the module's outbound mode
+	start := time.Now()
+	conn, err := sf.GetGRPCConnFor(
+		module,
+		host,
+		port,
+		viper.GetString("security.ca_path"),
+		viper.GetString("security.cert_path"),
+		viper.GetString("security.key_path"),
+	)
+	if err != nil {
+		return cli.Exit("dial "+net.JoinHostPort(host, port)+": "+err.Error(), 1)
+	}
+	dial := time.Since(start)
+
+	sf.Gufosign(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.Duration("timeout"))
+	defer cancel()
+
+	start = time.Now()
+	resp, err := pb.NewReverseClient(conn).Do(ctx, t)
+	call := time.Since(start)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("call %s/%s failed after %s: %s", module, param, call.Round(time.Millisecond), err), 1)
+	}
+
+	data := sf.ToMapStringInterface(resp.Data)
+
+	fmt.Printf("→ %s %s/%s via %s (%s)\n\n", method, module, param, net.JoinHostPort(host, port), sf.OutboundMode(module))
+	fmt.Println("Data:")
+	printJSON(data)
+
+	if resp.RequestBack != nil {
+		fmt.Println("\nRequestBack:")
+		rb, _ := protojson.MarshalOptions{Multiline: true, Indent: "  "}.Marshal(resp.RequestBack)
+		fmt.Println(string(rb))
+	}
+	if resp.Error != nil {
+		fmt.Println("\nError:")
+		eb, _ := protojson.MarshalOptions{Multiline: true, Indent: "  "}.Marshal(resp.Error)
+		fmt.Println(string(eb))
+	}
+
+	fmt.Printf("\nTiming: dial %s, call %s\n", dial.Round(time.Microsecond), call.Round(time.Microsecond))
+
+	if resp.Error != nil || callFailed(data) {
+		return cli.Exit("", 1)
+	}
+	return nil
+}
+
+// callArgs merges --json and --arg values; --arg wins on conflicts.
+func callArgs(c *cli.Context) (map[string]interface{}, error) {
+	args := map[string]interface{}{}
+
+	if src := c.String("json"); src != "" {
+		raw := []byte(src)
+		if strings.HasPrefix(src, "@") {
+			b, err := os.ReadFile(strings.TrimPrefix(src, "@"))
+			if err != nil {
+				return nil, fmt.Errorf("--json: %w", err)
+			}
+			raw = b
+		}
+		if err := json.Unmarshal(raw, &args); err != nil {
+			return nil, fmt.Errorf("--json: expected a JSON object: %w", err)
+		}
+	}
+
+	for _, kv := range c.StringSlice("arg") {
+		k, val, ok := strings.Cut(kv, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("--arg %q: expected k=v", kv)
+		}
+		var decoded interface{}
+		if err := json.Unmarshal([]byte(val), &decoded); err == nil {
+			args[k] = decoded
+		} else {
+			args[k] = val
+		}
+	}
+
+	return args, nil
+}
+
+// callTarget resolves the address to call: --addr, the gateway gRPC port,
+// or the module address from microservices.<module>.
+func callTarget(c *cli.Context, module string) (host, port string, err error) {
+	if addr := c.String("addr"); addr != "" {
+		return net.SplitHostPort(addr)
+	}
+
+	switch c.String("target") {
+	case "gateway":
+		port = viper.GetString("server.grpc_port")
+		if port == "" {
+			port = "4890"
+		}
+		return "127.0.0.1", port, nil
+	case "module":
+		host = viper.GetString(fmt.Sprintf("microservices.%s.host", module))
+		port = viper.GetString(fmt.Sprintf("microservices.%s.port", module))
+		if host == "" || port == "" {
+			return "", "", fmt.Errorf("microservices.%s.host/port are not configured (use --addr)", module)
+		}
+		return host, port, nil
+	}
+	return "", "", fmt.Errorf("--target must be gateway or module")
+}
+
+// callFailed reports whether the module answered with an error envelope.
+func callFailed(data map[string]interface{}) bool {
+	code, ok := data["httpcode"].(float64)
+	return ok && code >= 400
+}
+
+func printJSON(v interface{}) {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		fmt.Println(v)
+		return
+	}
+	fmt.Println(string(b))
+}
@@ -0,0 +1,543 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// gRPC-Web (binary and text) and protojson transcoding for the Reverse
+// service on the HTTP listener. Calls go through Server.Do / Server.Stream
+// with the same security checks as the gRPC port.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	sf "github.com/gogufo/gufo-api-gateway/gufodao"
+	"github.com/gogufo/gufo-api-gateway/handler"
+	pb "github.com/gogufo/gufo-api-gateway/proto/go"
+	"github.com/spf13/viper"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	grpcWebContentType     = "application/grpc-web"
+	grpcWebTextContentType = "application/grpc-web-text"
+
+	frameData       byte = 0x00
+	frameTrailer    byte = 0x80
+	frameCompressed byte = 0x01
+)
+
+// mountGRPCWeb serves /<service>/Do and /<service>/Stream when
+// grpcweb.enabled is set. Calls run in the public pipeline (rate limit,
+// metrics, metering) like REST calls; see handler.ServeRPC.
+func mountGRPCWeb(r chi.Router) {
+	if !viper.GetBool("grpcweb.enabled") {
+		return
+	}
+	svc := "/" + pb.Reverse_ServiceDesc.ServiceName
+	s := &Server{}
+
+	r.Options(svc+"/*", grpcWebPreflight)
+	r.Post(svc+"/Do", func(w http.ResponseWriter, r *http.Request) {
+		grpcWebCORS(w, r)
+		handler.ServeRPC(w, r, func(w http.ResponseWriter, r *http.Request) int {
+			if isGRPCWeb(r) {
+				grpcWebDo(s, w, r)
+				return http.StatusOK
+			}
+			if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+				return jsonDo(s, w, r)
+			}
+			http.Error(w, "unsupported content type", http.StatusUnsupportedMediaType)
+			return http.StatusUnsupportedMediaType
+		})
+	})
+	r.Post(svc+"/Stream", func(w http.ResponseWriter, r *http.Request) {
+		grpcWebCORS(w, r)
+		handler.ServeRPC(w, r, func(w http.ResponseWriter, r *http.Request) int {
+			if !isGRPCWeb(r) {
+				http.Error(w, "unsupported content type", http.StatusUnsupportedMediaType)
+				return http.StatusUnsupportedMediaType
+			}
+			grpcWebStream(s, w, r)
+			return http.StatusOK
+		})
+	})
+
+	sf.SetLog("🌐 gRPC-Web and JSON transcoding on " + svc + "/{Do,Stream}")
+}
+
+func isGRPCWeb(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("Content-Type"), grpcWebContentType)
+}
+
+func isGRPCWebText(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("Content-Type"), grpcWebTextContentType)
+}
+
+// -------------------------------------------------------------------
+// CORS
+// -------------------------------------------------------------------
+
+// grpcWebCORS allows the origins in grpcweb.allowed_origins ("*" for any).
+// Without the setting browsers may only call from the gateway's own origin.
+func grpcWebCORS(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return
+	}
+	w.Header().Add("Vary", "Origin")
+	for _, a := range viper.GetStringSlice("grpcweb.allowed_origins") {
+		if a == "*" || strings.EqualFold(a, origin) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Expose-Headers", "grpc-status, grpc-message")
+			return
+		}
+	}
+}
+
+func grpcWebPreflight(w http.ResponseWriter, r *http.Request) {
+	grpcWebCORS(w, r)
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers",
+		"Content-Type, X-Grpc-Web, X-User-Agent, Grpc-Timeout, Authorization, X-Sign")
+	w.Header().Set("Access-Control-Max-Age", "600")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// -------------------------------------------------------------------
+// Request side
+// -------------------------------------------------------------------
+
+// rpcContext builds the context Server.Do expects: incoming metadata from
+// the HTTP headers, the TLS peer (for mTLS) and the grpc-timeout deadline.
+func rpcContext(r *http.Request) (context.Context, context.CancelFunc) {
+	ctx := r.Context()
+
+	md := metadata.MD{}
+	for k, v := range r.Header {
+		switch strings.ToLower(k) {
+		case "content-type", "content-length", "connection", "grpc-timeout":
+			continue
+		}
+		md.Append(strings.ToLower(k), v...)
+	}
+	ctx = metadata.NewIncomingContext(ctx, md)
+
+	if r.TLS != nil {
+		ctx = peer.NewContext(ctx, &peer.Peer{AuthInfo: credentials.TLSInfo{State: *r.TLS}})
+	}
+
+	if d, ok := parseGRPCTimeout(r.Header.Get("grpc-timeout")); ok {
+		return context.WithTimeout(ctx, d)
+	}
+	return context.WithCancel(ctx)
+}
+
+// parseGRPCTimeout reads "<n><unit>" with unit H, M, S, m, u or n.
+func parseGRPCTimeout(s string) (time.Duration, bool) {
+	if len(s) < 2 {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(s[:len(s)-1], 10, 64)
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+	units := map[byte]time.Duration{
+		'H': time.Hour, 'M': time.Minute, 'S': time.Second,
+		'm': time.Millisecond, 'u': time.Microsecond, 'n': time.Nanosecond,
+	}
+	unit, ok := units[s[len(s)-1]]
+	if !ok {
+		return 0, false
+	}
+	return time.Duration(n) * unit, true
+}
+
+func maxMessageSize() int64 {
+	if n := viper.GetInt64("grpcweb.max_message_size"); n > 0 {
+		return n
+	}
+	return 4 << 20
+}
+
+// readGRPCWebRequests decodes the length-prefixed messages of the body.
+func readGRPCWebRequests(r *http.Request) ([]*pb.Request, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, maxMessageSize()*2))
+	if err != nil {
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	}
+	if isGRPCWebText(r) {
+		if body, err = decodeBase64Segments(body); err != nil {
+			return nil, status.Error(codes.InvalidArgument, "invalid base64 body")
+		}
+	}
+
+	var out []*pb.Request
+	for len(body) > 0 {
+		if len(body) < 5 {
+			return nil, status.Error(codes.InvalidArgument, "truncated frame")
+		}
+		flag, size := body[0], binary.BigEndian.Uint32(body[1:5])
+		if int64(size) > maxMessageSize() {
+			return nil, status.Errorf(codes.ResourceExhausted, "message larger than %d bytes", maxMessageSize())
+		}
+		if uint32(len(body)-5) < size {
+			return nil, status.Error(codes.InvalidArgument, "truncated frame")
+		}
+		if flag&frameCompressed != 0 {
+			return nil, status.Error(codes.Unimplemented, "compressed messages are not supported")
+		}
+		req := &pb.Request{}
+		if err := proto.Unmarshal(body[5:5+size], req); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		out = append(out, req)
+		body = body[5+size:]
+	}
+	return out, nil
+}
+
+// decodeBase64Segments decodes concatenated, individually padded base64
+// chunks (clients may encode each frame separately).
+func decodeBase64Segments(b []byte) ([]byte, error) {
+	b = bytes.Join(bytes.Fields(b), nil)
+	var out []byte
+	for len(b) > 0 {
+		end := len(b)
+		if i := bytes.IndexByte(b, '='); i >= 0 {
+			end = i
+			for end < len(b) && b[end] == '=' {
+				end++
+			}
+		}
+		chunk := make([]byte, base64.StdEncoding.DecodedLen(end))
+		n, err := base64.StdEncoding.Decode(chunk, b[:end])
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, chunk[:n]...)
+		b = b[end:]
+	}
+	return out, nil
+}
+
+// -------------------------------------------------------------------
+// Response side
+// -------------------------------------------------------------------
+
+type grpcWebWriter struct {
+	w           http.ResponseWriter
+	text        bool
+	contentType string
+	header      metadata.MD
+	wroteHeader bool
+}
+
+func newGRPCWebWriter(w http.ResponseWriter, r *http.Request) *grpcWebWriter {
+	ct := r.Header.Get("Content-Type")
+	if i := strings.IndexByte(ct, ';'); i >= 0 {
+		ct = ct[:i]
+	}
+	return &grpcWebWriter{w: w, text: isGRPCWebText(r), contentType: ct, header: metadata.MD{}}
+}
+
+func (g *grpcWebWriter) writeHeader() {
+	if g.wroteHeader {
+		return
+	}
+	g.wroteHeader = true
+	for k, v := range g.header {
+		for _, val := range v {
+			g.w.Header().Add(k, val)
+		}
+	}
+	g.w.Header().Set("Content-Type", g.contentType)
+	g.w.WriteHeader(http.StatusOK)
+}
+
+func (g *grpcWebWriter) writeFrame(flag byte, payload []byte) error {
+	g.writeHeader()
+
+	frame := make([]byte, 5+len(payload))
+	frame[0] = flag
+	binary.BigEndian.PutUint32(frame[1:5], uint32(len(payload)))
+	copy(frame[5:], payload)
+	if g.text {
+		frame = []byte(base64.StdEncoding.EncodeToString(frame))
+	}
+
+	if _, err := g.w.Write(frame); err != nil {
+		return err
+	}
+	if f, ok := g.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+func (g *grpcWebWriter) writeMessage(resp *pb.Response) error {
+	b, err := proto.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	return g.writeFrame(frameData, b)
+}
+
+// finish sends the trailer frame carrying grpc-status and grpc-message.
+func (g *grpcWebWriter) finish(err error, trailer metadata.MD) {
+	st := status.Convert(err)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "grpc-status: %d\r\n", st.Code())
+	fmt.Fprintf(&b, "grpc-message: %s\r\n", percentEncode(st.Message()))
+	for k, v := range trailer {
+		for _, val := range v {
+			fmt.Fprintf(&b, "%s: %s\r\n", strings.ToLower(k), val)
+		}
+	}
+
+	if err := g.writeFrame(frameTrailer, []byte(b.String())); err != nil {
+		sf.SetErrorLog("grpc-web: " + err.Error())
+	}
+}
+
+// percentEncode escapes grpc-message as required by the gRPC spec.
+func percentEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 0x20 && c <= 0x7e && c != '%' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// -------------------------------------------------------------------
+// Reverse.Do
+// -------------------------------------------------------------------
+
+func grpcWebDo(s *Server, w http.ResponseWriter, r *http.Request) {
+	g := newGRPCWebWriter(w, r)
+	ctx, cancel := rpcContext(r)
+	defer cancel()
+
+	reqs, err := readGRPCWebRequests(r)
+	if err == nil && len(reqs) != 1 {
+		err = status.Errorf(codes.InvalidArgument, "Do expects exactly one message, got %d", len(reqs))
+	}
+	if err != nil {
+		g.finish(err, nil)
+		return
+	}
+
+	resp, err := s.Do(ctx, reqs[0])
+	if err == nil {
+		err = g.writeMessage(resp)
+	}
+	g.finish(err, nil)
+}
+
+// jsonDo transcodes a protojson Request to Server.Do and answers with a
+// protojson Response. The HTTP status follows the answer's httpcode; it is
+// also returned.
+func jsonDo(s *Server, w http.ResponseWriter, r *http.Request) int {
+	ctx, cancel := rpcContext(r)
+	defer cancel()
+
+	writeErr := func(code int, msg string) int {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		b, _ := protojson.Marshal(sf.ErrorReturn(&pb.Request{}, code, "0000400", msg))
+		w.Write(b)
+		return code
+	}
+
+	if err := sf.DecodeRequestBody(r); err != nil {
+		return writeErr(sf.BodyErrorStatus(err), err.Error())
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxMessageSize()))
+	if err != nil {
+		return writeErr(http.StatusRequestEntityTooLarge, err.Error())
+	}
+
+	req := &pb.Request{}
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(body, req); err != nil {
+		return writeErr(http.StatusBadRequest, "invalid protojson Request: "+err.Error())
+	}
+
+	resp, err := s.Do(ctx, req)
+	if err != nil {
+		return writeErr(http.StatusBadGateway, err.Error())
+	}
+
+	b, err := protojson.Marshal(resp)
+	if err != nil {
+		return writeErr(http.StatusInternalServerError, err.Error())
+	}
+	code := responseHTTPCode(resp)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(b)
+	return code
+}
+
+// responseHTTPCode returns Data["httpcode"] of an answer (200 when unset).
+func responseHTTPCode(resp *pb.Response) int {
+	v, ok := resp.GetData()["httpcode"]
+	if !ok {
+		return http.StatusOK
+	}
+	raw, err := sf.ConvertAnyToInterface(v)
+	if err != nil {
+		return http.StatusOK
+	}
+	code, err := strconv.Atoi(fmt.Sprint(raw))
+	if err != nil || code < 100 || code > 599 {
+		return http.StatusOK
+	}
+	return code
+}
+
+// -------------------------------------------------------------------
+// Reverse.Stream
+// -------------------------------------------------------------------
+
+func grpcWebStream(s *Server, w http.ResponseWriter, r *http.Request) {
+	g := newGRPCWebWriter(w, r)
+	ctx, cancel := rpcContext(r)
+	defer cancel()
+
+	reqs, err := readGRPCWebRequests(r)
+	if err != nil {
+		g.finish(err, nil)
+		return
+	}
+
+	// gRPC-Web is half-duplex: all client messages arrive with the request
+	stream := &webStream{ctx: ctx, reqs: reqs, g: g, trailer: metadata.MD{}}
+	err = s.Stream(stream)
+	g.finish(err, stream.trailer)
+}
+
+// webStream adapts a gRPC-Web request to pb.Reverse_StreamServer. Every
+// received message passes authorizeRPC, like a call to Server.Do.
+type webStream struct {
+	ctx     context.Context
+	reqs    []*pb.Request
+	g       *grpcWebWriter
+	trailer metadata.MD
+}
+
+func (ws *webStream) Recv() (*pb.Request, error) {
+	if len(ws.reqs) == 0 {
+		return nil, io.EOF
+	}
+	req := ws.reqs[0]
+	ws.reqs = ws.reqs[1:]
+
+	if _, denied := authorizeRPC(ws.ctx, req); denied != nil {
+		return nil, deniedStatus(denied)
+	}
+	return req, nil
+}
+
+func (ws *webStream) Send(resp *pb.Response) error {
+	if err := ws.ctx.Err(); err != nil {
+		return status.FromContextError(err).Err()
+	}
+	return ws.g.writeMessage(resp)
+}
+
+func (ws *webStream) SetHeader(md metadata.MD) error {
+	if ws.g.wroteHeader {
+		return errors.New("headers already sent")
+	}
+	ws.g.header = metadata.Join(ws.g.header, md)
+	return nil
+}
+
+func (ws *webStream) SendHeader(md metadata.MD) error {
+	if err := ws.SetHeader(md); err != nil {
+		return err
+	}
+	ws.g.writeHeader()
+	return nil
+}
+
+func (ws *webStream) SetTrailer(md metadata.MD) {
+	ws.trailer = metadata.Join(ws.trailer, md)
+}
+
+func (ws *webStream) Context() context.Context { return ws.ctx }
+
+func (ws *webStream) SendMsg(m any) error {
+	resp, ok := m.(*pb.Response)
+	if !ok {
+		return status.Errorf(codes.Internal, "unexpected message type %T", m)
+	}
+	return ws.Send(resp)
+}
+
+func (ws *webStream) RecvMsg(m any) error {
+	req, err := ws.Recv()
+	if err != nil {
+		return err
+	}
+	dst, ok := m.(proto.Message)
+	if !ok {
+		return status.Errorf(codes.Internal, "unexpected message type %T", m)
+	}
+	proto.Merge(dst, req)
+	return nil
+}
+
+// deniedStatus turns an authorizeRPC error answer into a gRPC status.
+func deniedStatus(resp *pb.Response) error {
+	msg := "unauthorized"
+	if v, ok := resp.GetData()["message"]; ok {
+		if raw, err := sf.ConvertAnyToInterface(v); err == nil {
+			msg = fmt.Sprint(raw)
+		}
+	}
+	switch responseHTTPCode(resp) {
+	case http.StatusForbidden:
+		return status.Error(codes.PermissionDenied, msg)
+	case http.StatusUnauthorized:
+		return status.Error(codes.Unauthenticated, msg)
+	default:
+		return status.Error(codes.Internal, msg)
+	}
+}
@@ -0,0 +1,252 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	sf "github.com/gogufo/gufo-api-gateway/gufodao"
+	pb "github.com/gogufo/gufo-api-gateway/proto/go"
+	"github.com/spf13/viper"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// frame builds one gRPC-Web frame.
+func frame(flag byte, payload []byte) []byte {
+	out := make([]byte, 5+len(payload))
+	out[0] = flag
+	binary.BigEndian.PutUint32(out[1:5], uint32(len(payload)))
+	copy(out[5:], payload)
+	return out
+}
+
+func message(t *testing.T, module string) []byte {
+	t.Helper()
+	b, err := proto.Marshal(&pb.Request{Module: &module})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestReadGRPCWebRequests(t *testing.T) {
+	viper.Set("grpcweb.max_message_size", 64)
+	t.Cleanup(func() { viper.Set("grpcweb.max_message_size", nil) })
+
+	users, orders := frame(frameData, message(t, "users")), frame(frameData, message(t, "orders"))
+	b64 := base64.StdEncoding.EncodeToString
+
+	tests := []struct {
+		name    string
+		ctype   string
+		body    []byte
+		modules []string
+		code    codes.Code
+	}{
+		{"one message", grpcWebContentType, users, []string{"users"}, codes.OK},
+		{"two messages", grpcWebContentType + "+proto", append(append([]byte{}, users...), orders...), []string{"users", "orders"}, codes.OK},
+		{"empty", grpcWebContentType, nil, nil, codes.OK},
+		{"short header", grpcWebContentType, users[:3], nil, codes.InvalidArgument},
+		{"short payload", grpcWebContentType, users[:len(users)-1], nil, codes.InvalidArgument},
+		{"too large", grpcWebContentType, frame(frameData, make([]byte, 65)), nil, codes.ResourceExhausted},
+		{"compressed", grpcWebContentType, frame(frameCompressed, message(t, "users")), nil, codes.Unimplemented},
+		{"not protobuf", grpcWebContentType, frame(frameData, []byte{0xff, 0xff}), nil, codes.InvalidArgument},
+		{"text", grpcWebTextContentType, []byte(b64(users)), []string{"users"}, codes.OK},
+		{"text, frames encoded separately", grpcWebTextContentType, []byte(b64(users) + b64(orders)), []string{"users", "orders"}, codes.OK},
+		{"text, line breaks", grpcWebTextContentType, []byte(b64(users)[:8] + "\r\n" + b64(users)[8:]), []string{"users"}, codes.OK},
+		{"text, not base64", grpcWebTextContentType, []byte("!!!!"), nil, codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/Reverse/Do", bytes.NewReader(tt.body))
+		r.Header.Set("Content-Type", tt.ctype)
+
+		reqs, err := readGRPCWebRequests(r)
+		if status.Code(err) != tt.code {
+			t.Errorf("%s: err = %v, want %s", tt.name, err, tt.code)
+			continue
+		}
+		var got []string
+		for _, req := range reqs {
+			got = append(got, req.GetModule())
+		}
+		if strings.Join(got, ",") != strings.Join(tt.modules, ",") {
+			t.Errorf("%s: modules %v, want %v", tt.name, got, tt.modules)
+		}
+	}
+}
+
+func TestDecodeBase64Segments(t *testing.T) {
+	tests := []struct {
+		in, want string
+		ok       bool
+	}{
+		{"aGVsbG8=", "hello", true},
+		{"aGVsbG8=d29ybGQ=", "helloworld", true},
+		{"aGk=aGk=aGk", "hihihi", false}, // unpadded tail
+		{"aGVs bG8=", "hello", true},
+		{"aGVsbG8", "", false},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		got, err := decodeBase64Segments([]byte(tt.in))
+		if (err == nil) != tt.ok || (tt.ok && string(got) != tt.want) {
+			t.Errorf("decodeBase64Segments(%q) = %q, %v", tt.in, got, err)
+		}
+	}
+}
+
+func TestGRPCWebWriter(t *testing.T) {
+	for _, text := range []bool{false, true} {
+		ctype := grpcWebContentType + "+proto"
+		if text {
+			ctype = grpcWebTextContentType
+		}
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/Reverse/Do", nil)
+		r.Header.Set("Content-Type", ctype+"; charset=utf-8")
+
+		g := newGRPCWebWriter(w, r)
+		resp := sf.Interfacetoresponse(&pb.Request{}, map[string]interface{}{"ok": true})
+		if err := g.writeMessage(resp); err != nil {
+			t.Fatal(err)
+		}
+		g.finish(status.Error(codes.NotFound, "no such module: 100%"), nil)
+
+		if got := w.Header().Get("Content-Type"); got != ctype {
+			t.Errorf("text=%v: Content-Type %q, want %q", text, got, ctype)
+		}
+		body := w.Body.Bytes()
+		if text {
+			var err error
+			if body, err = decodeBase64Segments(body); err != nil {
+				t.Fatalf("text body: %v", err)
+			}
+		}
+
+		// data frame, then trailer frame
+		if body[0] != frameData {
+			t.Fatalf("text=%v: first frame flag %x", text, body[0])
+		}
+		size := binary.BigEndian.Uint32(body[1:5])
+		got := &pb.Response{}
+		if err := proto.Unmarshal(body[5:5+size], got); err != nil || !proto.Equal(got, resp) {
+			t.Fatalf("text=%v: message %v, %v", text, got, err)
+		}
+		trailer := body[5+size:]
+		if trailer[0] != frameTrailer || int(binary.BigEndian.Uint32(trailer[1:5])) != len(trailer)-5 {
+			t.Fatalf("text=%v: bad trailer frame % x", text, trailer[:5])
+		}
+		want := "grpc-status: 5\r\ngrpc-message: no such module: 100%25\r\n"
+		if string(trailer[5:]) != want {
+			t.Errorf("text=%v: trailer %q, want %q", text, trailer[5:], want)
+		}
+	}
+}
+
+func TestParseGRPCTimeout(t *testing.T) {
+	tests := []struct {
+		in string
+		ok bool
+	}{
+		{"10S", true}, {"250m", true}, {"1H", true}, {"5", false}, {"0S", false}, {"10x", false}, {"-1S", false},
+	}
+	for _, tt := range tests {
+		if _, ok := parseGRPCTimeout(tt.in); ok != tt.ok {
+			t.Errorf("parseGRPCTimeout(%q) ok = %v", tt.in, ok)
+		}
+	}
+}
+
+func TestGRPCWebCORS(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		origin  string
+		want    string
+	}{
+		{"not configured", nil, "https://evil.example.com", ""},
+		{"listed", []string{"https://app.example.com"}, "https://APP.example.com", "https://APP.example.com"},
+		{"not listed", []string{"https://app.example.com"}, "https://evil.example.com", ""},
+		{"any", []string{"*"}, "https://evil.example.com", "https://evil.example.com"},
+		{"same origin request", nil, "", ""},
+	}
+	t.Cleanup(func() { viper.Set("grpcweb.allowed_origins", nil) })
+	for _, tt := range tests {
+		viper.Set("grpcweb.allowed_origins", tt.allowed)
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/Reverse/Do", nil)
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+		grpcWebCORS(w, r)
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.want {
+			t.Errorf("%s: Allow-Origin %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// Calls run through the public pipeline and are answered in their protocol.
+func TestGRPCWebRoutes(t *testing.T) {
+	viper.Set("grpcweb.enabled", true)
+	viper.Set("security.mode", "hmac")
+	t.Cleanup(func() {
+		viper.Set("grpcweb.enabled", nil)
+		viper.Set("security.mode", nil)
+	})
+	router := chi.NewRouter()
+	mountGRPCWeb(router)
+	svc := "/" + pb.Reverse_ServiceDesc.ServiceName
+
+	// unsigned: Server.Do answers 401 in the message
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, svc+"/Do", bytes.NewReader(frame(frameData, message(t, "users"))))
+	r.Header.Set("Content-Type", grpcWebContentType)
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || !bytes.Contains(w.Body.Bytes(), []byte("grpc-status: 0")) {
+		t.Fatalf("grpc-web: %d %q", w.Code, w.Body)
+	}
+	resp := &pb.Response{}
+	size := binary.BigEndian.Uint32(w.Body.Bytes()[1:5])
+	if err := proto.Unmarshal(w.Body.Bytes()[5:5+size], resp); err != nil || responseHTTPCode(resp) != http.StatusUnauthorized {
+		t.Fatalf("grpc-web answer: %v, %v", resp, err)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodPost, svc+"/Do", strings.NewReader(`{"Module":"users"}`))
+	r.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("json: %d %s", w.Code, w.Body)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodPost, svc+"/Do", strings.NewReader(`x`))
+	r.Header.Set("Content-Type", "text/plain")
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("text/plain: %d", w.Code)
+	}
+}
@@ -30,10 +30,15 @@ import (
 	"strings"
 	"time"
 
+	"github.com/gogufo/gufo-api-gateway/admin"
 	sf "github.com/gogufo/gufo-api-gateway/gufodao"
+	"github.com/gogufo/gufo-api-gateway/metering"
 	mid "github.com/gogufo/gufo-api-gateway/middleware"
+	"github.com/gogufo/gufo-api-gateway/mq"
 	"github.com/gogufo/gufo-api-gateway/registry"
+	"github.com/gogufo/gufo-api-gateway/scheduler"
 	"github.com/gogufo/gufo-api-gateway/transport"
+	"github.com/gogufo/gufo-api-gateway/webhook"
 	"google.golang.org/grpc/keepalive"
 
 	"google.golang.org/grpc"
@@ -78,6 +83,7 @@ func commands() {
 			Name:   "stop",
 			Usage:  "Stop Gufo Server",
 			Action: StopApp,
+			Flags:  adminFlags(),
 		},
 		{
 			Name:  "cert",
@@ -87,10 +93,30 @@ func commands() {
 					Name:   "init",
 					Usage:  "Generate self-signed CA, server, and client certificates for mTLS",
 					Action: sf.GenerateCertificates,
+					Flags: []cli.Flag{
+						&cli.StringSliceFlag{Name: "server-uri", Usage: "URI SAN for the server certificate (e.g. spiffe://gufo/ns/gateway)"},
+						&cli.StringSliceFlag{Name: "client-uri", Usage: "URI SAN for the client certificate (e.g. spiffe://gufo/ns/client)"},
+					},
+				},
+				{
+					Name:   "issue",
+					Usage:  "Issue a service certificate signed by an existing CA",
+					Action: sf.IssueCertificate,
+					Flags: []cli.Flag{
+						&cli.StringFlag{Name: "dir", Value: "./certs", Usage: "directory with ca.pem and ca-key.pem"},
+						&cli.StringFlag{Name: "name", Usage: "output file prefix (<name>.pem, <name>-key.pem)"},
+						&cli.StringFlag{Name: "cn", Usage: "certificate Common Name (defaults to --name)"},
+						&cli.StringSliceFlag{Name: "uri", Usage: "URI SAN, e.g. spiffe://gufo/ns/billing"},
+						&cli.StringSliceFlag{Name: "dns", Usage: "DNS SAN"},
+						&cli.BoolFlag{Name: "server", Usage: "also allow server authentication"},
+						&cli.IntFlag{Name: "days", Value: 365, Usage: "validity in days"},
+					},
 				},
 			},
 		},
 	}
+	app.Commands = append(app.Commands, adminCommands()...)
+	app.Commands = append(app.Commands, callCommand())
 }
 
 // main is the entry point of Gufo API Gateway.
@@ -105,6 +131,7 @@ func main() {
 		os.Exit(1)
 	}
 	sf.EncryptConfigPasswords()
+	sf.OnConfigReload(handler.LoadInboundWebhooks)
 
 	ctx := context.Background()
 	sf.InitTelemetry(ctx)
@@ -154,41 +181,16 @@ func main() {
 	}
 }
 
-// StopApp gracefully stops a running Gufo instance via the /exit endpoint.
+// StopApp gracefully stops a running Gufo instance via the admin API.
 // This ensures that telemetry, Sentry, and all servers shut down cleanly.
 // Works both in Docker and bare-metal setups.
 func StopApp(c *cli.Context) error {
-	addr := viper.GetString("server.ip")
-	port := viper.GetString("server.port")
+	ac := newAdminClient(c)
+	sf.SetLog("CLI command 'gufo stop' → sending shutdown signal to " + ac.base)
 
-	if addr == "" {
-		addr = "127.0.0.1"
-	}
-	if port == "" {
-		port = "8090"
-	}
-
-	url := fmt.Sprintf("http://%s:%s/exit", addr, port)
-	sf.SetLog("CLI command 'gufo stop' → sending shutdown signal to " + url)
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		sf.SetErrorLog("stop: cannot create request: " + err.Error())
-		return err
-	}
-
-	client := &http.Client{Timeout: 3 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		sf.SetErrorLog("stop: cannot reach Gufo server: " + err.Error())
-		return err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		sf.SetErrorLog(fmt.Sprintf("stop: server responded with %s", resp.Status))
-		fmt.Printf("Stop command failed: %s\n", resp.Status)
-		return fmt.Errorf("server responded with %s", resp.Status)
+	if err := ac.do(http.MethodPost, "/admin/shutdown", nil, nil); err != nil {
+		sf.SetErrorLog("stop: " + err.Error())
+		return cli.Exit("Stop command failed: "+err.Error(), 1)
 	}
 
 	fmt.Println("✅ Shutdown signal sent — Gufo is stopping gracefully...")
@@ -196,29 +198,10 @@ func StopApp(c *cli.Context) error {
 	return nil
 }
 
-// ExitApp handles graceful stop via HTTP request (debug mode only).
-func ExitApp(w http.ResponseWriter, r *http.Request) {
-	if !viper.GetBool("server.debug") {
-		http.Error(w, "Forbidden", http.StatusForbidden)
-		return
-	}
-
-	sf.SetLog("Received /exit request — initiating graceful shutdown")
-
-	go func() {
-		time.Sleep(500 * time.Millisecond) // small delay to let HTTP 200 return
-		sf.WaitForShutdown(func() {
-			// This callback will stop servers gracefully (same as SIGTERM)
-			sf.SetLog("Graceful shutdown triggered by /exit endpoint")
-		})
-	}()
-
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("Gufo is shutting down..."))
-}
-
 // StartService is function for start WEB Server to listen port
 func StartService(c *cli.Context) (rtnerr error) {
+	sf.SetDebugLogging(viper.GetBool("server.debug"))
+
 	if viper.GetBool("server.masterservice") {
 		sf.SetLog("MasterService mode enabled — initializing Redis")
 		sf.InitCache()
@@ -230,10 +213,30 @@ func StartService(c *cli.Context) (rtnerr error) {
 	registry.StartSweeper()
 	sf.SetLog("🧠 Registry cache refresher started")
 
+	metering.StartExporter()
+
 	// Register default transport (gRPC)
 	transport.Register(&transport.GRPCTransport{})
 	sf.SetLog("✅ Registered default transport: gRPC")
 
+	// Message broker for async (mq) routes; a misconfigured broker is fatal
+	if err := mq.Init(); err != nil {
+		sf.SetErrorLog(err.Error())
+		return cli.Exit("Startup failed: "+err.Error(), 1)
+	}
+
+	// In-gateway consumer for async (mq) routes, see jobs.local_worker
+	transport.StartMQWorker()
+
+	// Outbound webhook delivery, resumes pending deliveries
+	webhook.Start()
+
+	// Module cron jobs, run by the elected gateway (cron.enabled)
+	if err := scheduler.Start(); err != nil {
+		sf.SetErrorLog(err.Error())
+		return cli.Exit("Startup failed: "+err.Error(), 1)
+	}
+
 	rps := viper.GetInt("gufo.rate_limit_rps")
 	burst := viper.GetInt("gufo.rate_limit_burst")
 
@@ -254,13 +257,14 @@ func StartService(c *cli.Context) (rtnerr error) {
 
 	port := sf.ConfigString("server.port")
 
-	m := fmt.Sprintf("Gufo v%s  (%s, %s) +\n\t\" \" starting on :%s (gRPC :%s, mode=%s)",
+	m := fmt.Sprintf("Gufo v%s  (%s, %s) +\n\t\" \" starting on :%s (gRPC :%s, mode=%s, edge=%s)",
 		v.VERSION,
 		v.GitCommit,
 		v.BuildDate,
 		viper.GetString("server.port"),
 		viper.GetString("server.grpc_port"),
 		strings.ToLower(viper.GetString("security.mode")),
+		strings.Join(sf.EdgeModes(""), ","),
 	)
 
 	sf.SetLog(m)
@@ -278,18 +282,38 @@ func StartService(c *cli.Context) (rtnerr error) {
 	r.Use(sf.RecoveryMiddleware)          // panic-safe middleware
 	r.Use(otelhttp.NewMiddleware("gufo")) // telemetry tracing
 
-	// Routes
-	r.Route("/api/v1", func(r chi.Router) {
-		r.Get("/health", handler.Health)
-		r.Handle("/*", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			handler.API(w, r, 3)
-		}))
-	})
-
-	if viper.GetBool("server.debug") {
-		r.Get("/exit", ExitApp)
+	// Routes: one mount per public API version (api.versions)
+	for _, m := range handler.APIMounts() {
+		r.Route("/api/"+m.Version, func(r chi.Router) {
+			r.Get("/health", handler.Health)
+			r.Get("/jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+				handler.Jobs(w, r, m.Version)
+			})
+			r.Post("/hooks/{name}", func(w http.ResponseWriter, r *http.Request) {
+				handler.InboundHook(w, r, m.Version)
+			})
+			r.Post("/batch", func(w http.ResponseWriter, r *http.Request) {
+				handler.Batch(w, r, m.Version)
+			})
+			r.HandleFunc("/graphql", func(w http.ResponseWriter, r *http.Request) {
+				handler.GraphQL(w, r, m.Version)
+			})
+			r.Get("/graphql/schema", func(w http.ResponseWriter, r *http.Request) {
+				handler.GraphQL(w, r, m.Version)
+			})
+			r.Handle("/*", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				handler.API(w, r, m.Version)
+			}))
+		})
+		sf.SetLog("🔗 Mounted /api/" + m.Version)
 	}
 
+	// Public key for offline verification of internal tokens
+	r.Get("/.well-known/jwks.json", handler.JWKS)
+
+	// gRPC-Web and protojson transcoding for the Reverse service
+	mountGRPCWeb(r)
+
 	// ---------------------------------------------------
 	// Start servers
 	// ---------------------------------------------------
@@ -323,6 +347,10 @@ func StartService(c *cli.Context) (rtnerr error) {
 		}
 	}()
 
+	// Admin API (separate listener, protected by X-Admin-Token)
+	admin.SetRoutes(r)
+	adminSrv := admin.Start()
+
 	srv := &http.Server{
 		Addr:         ":" + port,
 		Handler:      r,
@@ -340,11 +368,15 @@ func StartService(c *cli.Context) (rtnerr error) {
 	}()
 
 	sf.WaitForShutdown(func() {
+		scheduler.Stop()
 		if grpcSrv != nil {
 			grpcSrv.GracefulStop()
 		}
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
+		if adminSrv != nil {
+			adminSrv.Shutdown(ctx)
+		}
 		srv.Shutdown(ctx)
 	})
 
@@ -420,39 +452,79 @@ func StartGRPCService() {
 type Server struct {
 }
 
-// Do handles incoming gRPC requests and verifies authentication
+// Do handles incoming gRPC requests and verifies authentication.
+// The accepted schemes come from sf.SecurityModes, so a module in migration
+// may accept several; the scheme that matched is recorded in metrics.
 func (s *Server) Do(ctx context.Context, request *pb.Request) (*pb.Response, error) {
-	mode := strings.ToLower(viper.GetString("security.mode"))
+	ctx, denied := authorizeRPC(ctx, request)
+	if denied != nil {
+		return denied, nil
+	}
+	return handler.InternalRequest(ctx, request), nil
+}
 
-	switch mode {
-	case "hmac":
-		secret := viper.GetString("security.hmac_secret")
-		maxAge := time.Duration(viper.GetInt("security.max_age")) * time.Second
+// authorizeRPC applies the gRPC listener security modes to request. On
+// failure it returns the error answer; on success the context carries the
+// caller identity (mTLS).
+func authorizeRPC(ctx context.Context, request *pb.Request) (context.Context, *pb.Response) {
+	module, param := "", ""
+	if request.Module != nil {
+		module = *request.Module
+	}
+	if request.Param != nil {
+		param = *request.Param
+	}
 
-		// Extra safety check: avoid nil dereference in request.Module or request.Sign
-		if request.Sign == nil || request.Module == nil ||
-			!sf.VerifyHMAC(secret, *request.Module, *request.Sign, maxAge) {
+	scheme := ""
+	known := false
+	forbidden := false
 
-			sf.SetErrorLog("Unauthorized gRPC request (HMAC mode)")
-			return sf.ErrorReturn(request, 401, "00001", "Invalid or expired signature"), nil
-		}
+	for _, mode := range sf.SecurityModes(module, sf.ListenerGRPC) {
+		switch mode {
+		case "hmac", "sign", "token":
+			known = true
+			if sf.CheckSign(mode, request) {
+				scheme = mode
+			}
 
-	case "sign":
-		if request.Sign == nil || viper.GetString("server.sign") != *request.Sign {
-			sf.SetErrorLog("Unauthorized gRPC request (static sign mode)")
-			return sf.ErrorReturn(request, 401, "00001", "Invalid signature"), nil
+		case "mtls":
+			// Certificate chain is verified by the gRPC TLS layer;
+			// here we only authorize the service identity it carries.
+			known = true
+			id := sf.PeerIdentity(ctx)
+			if id == "" {
+				continue
+			}
+			if !sf.IdentityAllowed(id, module, param) {
+				sf.SetErrorLog(fmt.Sprintf("Forbidden gRPC request (mTLS mode): %s -> %s/%s", id, module, param))
+				forbidden = true
+				continue
+			}
+			ctx = sf.WithIdentity(ctx, id)
+			scheme = mode
 		}
 
-	case "mtls":
-		// For mTLS mode, trust gRPC layer verification — no Sign check needed
-		sf.SetLog("mTLS mode active - skipping sign verification")
+		if scheme != "" {
+			break
+		}
+	}
 
-	default:
+	if !known {
 		sf.SetErrorLog("Unknown security mode")
-		return sf.ErrorReturn(request, 500, "00002", "Security mode not configured"), nil
+		return ctx, sf.ErrorReturn(request, 500, "00002", "Security mode not configured")
 	}
+	if scheme == "" {
+		if forbidden {
+			return ctx, sf.ErrorReturn(request, 403, "00005", "Identity not allowed")
+		}
+		sf.SetErrorLog(fmt.Sprintf("Unauthorized gRPC request for module %q", module))
+		return ctx, sf.ErrorReturn(request, 401, "00001", "Invalid or expired signature")
+	}
+
+	handler.ObserveAuthScheme(sf.ListenerGRPC, module, scheme)
+	handler.MarkRPC(ctx, module, sf.ContextIdentity(ctx)) // gRPC-Web only
 
-	return handler.InternalRequest(request), nil
+	return ctx, nil
 }
 
 // Stream handles bidirectional streaming RPC calls.
@@ -46,7 +46,8 @@ func GRPCConnect(host string, port string, t *pb.Request) map[string]interface{}
 	// fmt.Fprintln(os.Stderr, ">>> Address:", addr)
 
 	// 🔹 Get connection from pool with TLS/mTLS
-	conn, err := GetGRPCConn(
+	conn, err := GetGRPCConnFor(
+		safeModuleName(t),
 		host,
 		port,
 		viper.GetString("security.ca_path"),
@@ -72,7 +73,8 @@ func GRPCConnect(host string, port string, t *pb.Request) map[string]interface{}
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	// 🔹 Perform RPC
+	// 🔹 Perform RPC with a fresh gateway credential
+	Gufosign(t)
 	resp, err := client.Do(ctx, t)
 	if err != nil {
 		logOrSentry(fmt.Errorf("grpc call failed for %s: %w", addr, err))
@@ -39,7 +39,7 @@ func GRPCGen(misroservice string, param string, paramid string, args map[string]
 	}
 
 	header := "Bearer " + token
-	URL := fmt.Sprintf("%s%s:%s/api/v3/%s/%s", tsp, erphost, erpport, misroservice, param)
+	URL := fmt.Sprintf("%s%s:%s/api/%s/%s/%s", tsp, erphost, erpport, InternalAPIVersion(), misroservice, param)
 	if paramid != "" {
 		URL = fmt.Sprintf("%s/%s", URL, paramid)
 	}
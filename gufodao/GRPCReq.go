@@ -35,6 +35,15 @@ const HttpRetry = 3
 // HttpTimeout is timeout per request
 const HttpTimeout = 7 * time.Second
 
+// InternalAPIVersion is the public API mount used by internal REST calls
+// (server.internal_api_version, default "v3"). It must be listed in api.versions.
+func InternalAPIVersion() string {
+	if v := viper.GetString("server.internal_api_version"); v != "" {
+		return v
+	}
+	return "v3"
+}
+
 // Production-ready internal API Gateway request
 func GRPCReq(
 	microservice string,
@@ -65,7 +74,7 @@ func GRPCReq(
 		proto = "https://"
 	}
 
-	url := fmt.Sprintf("%s%s:%s/api/v3/%s/%s", proto, host, port, microservice, param)
+	url := fmt.Sprintf("%s%s:%s/api/%s/%s/%s", proto, host, port, InternalAPIVersion(), microservice, param)
 	if paramID != "" {
 		url += "/" + paramID
 	}
@@ -0,0 +1,128 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// Request body decompression (Content-Encoding: gzip / deflate)
+
+package gufodao
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	viper "github.com/spf13/viper"
+)
+
+// defaultMaxDecodedBody caps decompressed request bodies (compression.max_request_size).
+const defaultMaxDecodedBody = 64 << 20
+
+var (
+	// ErrBodyTooLarge is returned by reads past compression.max_request_size
+	// of a decoded request body. Callers answer 413.
+	ErrBodyTooLarge = errors.New("decoded request body too large")
+	// ErrUnsupportedEncoding is returned for a Content-Encoding other than
+	// gzip or deflate. Callers answer 415.
+	ErrUnsupportedEncoding = errors.New("unsupported Content-Encoding")
+	// ErrCorruptBody wraps errors of the decompressor: the body does not
+	// match its Content-Encoding. Callers answer 400.
+	ErrCorruptBody = errors.New("corrupt request body")
+)
+
+// BodyErrorStatus maps an error of DecodeRequestBody or of reading the
+// decoded body to its HTTP status.
+func BodyErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrBodyTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, ErrUnsupportedEncoding):
+		return http.StatusUnsupportedMediaType
+	}
+	return http.StatusBadRequest
+}
+
+// DecodeRequestBody replaces r.Body with a decompressing reader when the
+// request has Content-Encoding gzip or deflate. The decoded size is limited
+// by compression.max_request_size to guard against compression bombs: a
+// longer body fails with ErrBodyTooLarge instead of being cut short.
+func DecodeRequestBody(r *http.Request) error {
+	enc := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+	if enc == "" || enc == "identity" || r.Body == nil {
+		return nil
+	}
+
+	var rc io.ReadCloser
+	switch enc {
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return fmt.Errorf("%w: gzip: %v", ErrCorruptBody, err)
+		}
+		rc = zr
+	case "deflate":
+		zr, err := zlib.NewReader(r.Body)
+		if err != nil {
+			return fmt.Errorf("%w: deflate: %v", ErrCorruptBody, err)
+		}
+		rc = zr
+	default:
+		return fmt.Errorf("%w %q", ErrUnsupportedEncoding, enc)
+	}
+
+	limit := viper.GetInt64("compression.max_request_size")
+	if limit <= 0 {
+		limit = defaultMaxDecodedBody
+	}
+
+	r.Body = &decodedBody{
+		r:     io.LimitReader(rc, limit+1),
+		limit: limit,
+		dec:   rc,
+		orig:  r.Body,
+	}
+	r.Header.Del("Content-Encoding")
+	r.Header.Del("Content-Length")
+	r.ContentLength = -1
+	return nil
+}
+
+type decodedBody struct {
+	r     io.Reader
+	limit int64
+	read  int64
+	dec   io.Closer
+	orig  io.Closer
+}
+
+func (b *decodedBody) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	b.read += int64(n)
+	if b.read > b.limit {
+		return n - int(b.read-b.limit), ErrBodyTooLarge
+	}
+	if err != nil && err != io.EOF {
+		err = fmt.Errorf("%w: %v", ErrCorruptBody, err)
+	}
+	return n, err
+}
+
+func (b *decodedBody) Close() error {
+	b.dec.Close()
+	return b.orig.Close()
+}
@@ -0,0 +1,103 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+
+package gufodao
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	viper "github.com/spf13/viper"
+)
+
+func gzipped(s string) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(s))
+	zw.Close()
+	return buf.Bytes()
+}
+
+func deflated(s string) []byte {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write([]byte(s))
+	zw.Close()
+	return buf.Bytes()
+}
+
+func TestDecodeRequestBody(t *testing.T) {
+	viper.Set("compression.max_request_size", 64)
+	t.Cleanup(func() { viper.Set("compression.max_request_size", nil) })
+
+	payload := `{"a":1}`
+	big := string(bytes.Repeat([]byte("x"), 65))
+	good := gzipped(payload)
+
+	tests := []struct {
+		name      string
+		encoding  string
+		body      []byte
+		want      string
+		decodeErr error // from DecodeRequestBody
+		readErr   error // from reading the body
+		status    int
+	}{
+		{"identity", "", []byte(payload), payload, nil, nil, 0},
+		{"gzip", "gzip", good, payload, nil, nil, 0},
+		{"x-gzip", "X-GZIP", good, payload, nil, nil, 0},
+		{"deflate", "deflate", deflated(payload), payload, nil, nil, 0},
+		{"unsupported", "br", []byte(payload), "", ErrUnsupportedEncoding, nil, http.StatusUnsupportedMediaType},
+		{"bad gzip header", "gzip", []byte("not gzip"), "", ErrCorruptBody, nil, http.StatusBadRequest},
+		{"truncated gzip", "gzip", good[:len(good)-6], "", nil, ErrCorruptBody, http.StatusBadRequest},
+		{"bomb", "gzip", gzipped(big), "", nil, ErrBodyTooLarge, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(tt.body))
+		if tt.encoding != "" {
+			r.Header.Set("Content-Encoding", tt.encoding)
+		}
+
+		err := DecodeRequestBody(r)
+		if !errors.Is(err, tt.decodeErr) {
+			t.Errorf("%s: DecodeRequestBody = %v, want %v", tt.name, err, tt.decodeErr)
+			continue
+		}
+		if err == nil {
+			var got []byte
+			got, err = io.ReadAll(r.Body)
+			if !errors.Is(err, tt.readErr) {
+				t.Errorf("%s: read = %v, want %v", tt.name, err, tt.readErr)
+				continue
+			}
+			if err == nil && string(got) != tt.want {
+				t.Errorf("%s: body = %q, want %q", tt.name, got, tt.want)
+			}
+			if err == nil && tt.encoding != "" && r.Header.Get("Content-Encoding") != "" {
+				t.Errorf("%s: Content-Encoding kept", tt.name)
+			}
+		}
+		if err != nil && BodyErrorStatus(err) != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, BodyErrorStatus(err), tt.status)
+		}
+	}
+}
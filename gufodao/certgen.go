@@ -25,6 +25,7 @@ import (
 	"encoding/pem"
 	"fmt"
 	"math/big"
+	"net/url"
 	"os"
 	"path/filepath"
 	"time"
@@ -82,6 +83,7 @@ func GenerateCertificates(c *cli.Context) error {
 		KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
 		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
 		IsCA:        false,
+		URIs:        parseURIs(c.StringSlice("server-uri")),
 	}
 	serverDER, _ := x509.CreateCertificate(rand.Reader, serverTemplate, caTemplate, &serverPriv.PublicKey, caPriv)
 	writeCert(serverCertPath, serverDER)
@@ -100,6 +102,7 @@ func GenerateCertificates(c *cli.Context) error {
 		KeyUsage:    x509.KeyUsageDigitalSignature,
 		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
 		IsCA:        false,
+		URIs:        parseURIs(c.StringSlice("client-uri")),
 	}
 	clientDER, _ := x509.CreateCertificate(rand.Reader, clientTemplate, caTemplate, &clientPriv.PublicKey, caPriv)
 	writeCert(clientCertPath, clientDER)
@@ -110,6 +113,109 @@ func GenerateCertificates(c *cli.Context) error {
 	return nil
 }
 
+// IssueCertificate signs a new leaf certificate with an existing CA
+// (ca.pem / ca-key.pem in --dir). URI SANs such as spiffe://gufo/ns/billing
+// become the service identity checked by the gateway in mTLS mode.
+func IssueCertificate(c *cli.Context) error {
+	dir := c.String("dir")
+	name := c.String("name")
+	if name == "" {
+		return fmt.Errorf("--name is required")
+	}
+
+	caCert, caPriv, err := loadCA(filepath.Join(dir, "ca.pem"), filepath.Join(dir, "ca-key.pem"))
+	if err != nil {
+		return err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 62))
+	if err != nil {
+		return fmt.Errorf("failed to generate serial: %w", err)
+	}
+
+	cn := c.String("cn")
+	if cn == "" {
+		cn = name
+	}
+
+	usage := []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	if c.Bool("server") {
+		usage = append(usage, x509.ExtKeyUsageServerAuth)
+	}
+
+	priv, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			Organization: []string{"Gufo Service"},
+			CommonName:   cn,
+		},
+		NotBefore:   time.Now(),
+		NotAfter:    time.Now().AddDate(0, 0, c.Int("days")),
+		KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage: usage,
+		DNSNames:    c.StringSlice("dns"),
+		URIs:        parseURIs(c.StringSlice("uri")),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, caCert, &priv.PublicKey, caPriv)
+	if err != nil {
+		return fmt.Errorf("failed to sign certificate: %w", err)
+	}
+
+	certPath := filepath.Join(dir, name+".pem")
+	keyPath := filepath.Join(dir, name+"-key.pem")
+	writeCert(certPath, der)
+	writeKey(keyPath, priv)
+
+	fmt.Printf("✅ Issued %s (identity: %s)\n", certPath, IdentityFromCert(template))
+	return nil
+}
+
+// loadCA reads a PEM CA certificate and its EC private key.
+func loadCA(certPath, keyPath string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	certPEM, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read CA cert: %w", err)
+	}
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return nil, nil, fmt.Errorf("invalid CA cert PEM: %s", certPath)
+	}
+	caCert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse CA cert: %w", err)
+	}
+
+	keyPEM, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read CA key: %w", err)
+	}
+	block, _ = pem.Decode(keyPEM)
+	if block == nil {
+		return nil, nil, fmt.Errorf("invalid CA key PEM: %s", keyPath)
+	}
+	caPriv, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse CA key: %w", err)
+	}
+	return caCert, caPriv, nil
+}
+
+// parseURIs converts --uri flag values into URI SANs, skipping invalid ones.
+func parseURIs(values []string) []*url.URL {
+	var uris []*url.URL
+	for _, v := range values {
+		u, err := url.Parse(v)
+		if err != nil || u.Scheme == "" {
+			fmt.Printf("⚠️  skipping invalid URI SAN %q\n", v)
+			continue
+		}
+		uris = append(uris, u)
+	}
+	return uris
+}
+
 // writeCert writes PEM-encoded certificate to file
 func writeCert(path string, certDER []byte) {
 	f, _ := os.Create(path)
@@ -22,7 +22,9 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/joho/godotenv"
 	viper "github.com/spf13/viper"
 
@@ -145,6 +147,7 @@ func InitConfig() error {
 	viper.SetDefault("server.session", true)
 	viper.SetDefault("server.masterservice", true)
 	viper.SetDefault("server.ip", "0.0.0.0")
+	viper.SetDefault("compression.enabled", false)
 
 	// 5) Read config file if available
 	if err := viper.ReadInConfig(); err != nil {
@@ -158,9 +161,44 @@ func InitConfig() error {
 		return err
 	}
 
+	// 7) Parse rule lists used on every request once
+	LoadACL()
+
+	// 8) Re-read the file when it changes (server.watch_config, default on)
+	if viper.ConfigFileUsed() != "" && (!viper.IsSet("server.watch_config") || viper.GetBool("server.watch_config")) {
+		viper.OnConfigChange(func(e fsnotify.Event) { ReloadConfig() })
+		viper.WatchConfig()
+	}
+
 	return nil
 }
 
+var (
+	reloadMu  sync.Mutex
+	reloaders []func()
+)
+
+// OnConfigReload registers fn to run after the config file was re-read.
+// Sections parsed once and cached (rule lists, hook tables) reload in fn.
+func OnConfigReload(fn func()) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	reloaders = append(reloaders, fn)
+}
+
+// ReloadConfig re-parses the cached sections after a config change.
+func ReloadConfig() {
+	SetLog("config: reloaded " + viper.ConfigFileUsed())
+	LoadACL()
+
+	reloadMu.Lock()
+	fns := append([]func(){}, reloaders...)
+	reloadMu.Unlock()
+	for _, fn := range fns {
+		fn()
+	}
+}
+
 // ValidateConfig performs minimal validation for required parameters.
 // It returns an error but never exits the process directly.
 func ValidateConfig() error {
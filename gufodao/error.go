@@ -27,4 +27,5 @@ var Errors = map[string]GufoError{
 	"00002": {"00002", "Invalid Session", 401},
 	"00003": {"00003", "Bad Request", 400},
 	"00004": {"00004", "Internal Error", 500},
+	"00005": {"00005", "Forbidden", 403},
 }
@@ -22,11 +22,9 @@ import (
 	"crypto/x509"
 	"fmt"
 	"os"
-	"strings"
 	"sync"
 	"time"
 
-	"github.com/spf13/viper"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/credentials"
@@ -45,10 +43,33 @@ var (
 	ttl      = 5 * time.Minute // connection TTL before re-dial
 )
 
+// PoolConn describes a pooled connection for the admin API.
+type PoolConn struct {
+	Addr   string    `json:"addr"`
+	State  string    `json:"state"`
+	Expiry time.Time `json:"expiry"`
+}
+
 func init() {
 	go poolSweeper()
 }
 
+// PoolStats returns a snapshot of pooled gRPC connections and their
+// connectivity state.
+func PoolStats() []PoolConn {
+	var out []PoolConn
+	connPool.Range(func(key, value any) bool {
+		item := value.(connItem)
+		out = append(out, PoolConn{
+			Addr:   key.(string),
+			State:  item.conn.GetState().String(),
+			Expiry: item.expiry,
+		})
+		return true
+	})
+	return out
+}
+
 // delete expired connections in background
 func poolSweeper() {
 	ticker := time.NewTicker(10 * time.Minute)
@@ -67,21 +88,35 @@ func poolSweeper() {
 	}
 }
 
-// FINAL: stable, production-ready, health-checked pooled dial
+// GetGRPCConn returns a pooled connection using the global security.mode.
 func GetGRPCConn(host, port, ca, cert, key string) (*grpc.ClientConn, error) {
+	return GetGRPCConnFor("", host, port, ca, cert, key)
+}
+
+// FINAL: stable, production-ready, health-checked pooled dial.
+// Transport credentials follow the outbound security mode of module
+// (microservices.<module>.security_mode, falling back to security.mode).
+func GetGRPCConnFor(module, host, port, ca, cert, key string) (*grpc.ClientConn, error) {
 
 	addr := fmt.Sprintf("%s:%s", host, port)
+	mode := OutboundMode(module)
+
+	// plaintext and mTLS connections to the same address must not be shared
+	poolKey := addr
+	if mode == "mtls" {
+		poolKey = "mtls://" + addr
+	}
 
 	// ============================
 	// 1) CHECK POOL FOR EXISTING
 	// ============================
-	if v, ok := connPool.Load(addr); ok {
+	if v, ok := connPool.Load(poolKey); ok {
 		item := v.(connItem)
 
 		// expired → drop
 		if time.Now().After(item.expiry) {
 			item.conn.Close()
-			connPool.Delete(addr)
+			connPool.Delete(poolKey)
 		} else {
 			// check connection health
 			st := item.conn.GetState()
@@ -94,7 +129,7 @@ func GetGRPCConn(host, port, ca, cert, key string) (*grpc.ClientConn, error) {
 			// DEAD → drop and re-dial
 			// fmt.Fprintln(os.Stderr, ">>> GRPC CONN DEAD (state =", st, ") — redialing", addr)
 			item.conn.Close()
-			connPool.Delete(addr)
+			connPool.Delete(poolKey)
 		}
 	}
 
@@ -102,7 +137,6 @@ func GetGRPCConn(host, port, ca, cert, key string) (*grpc.ClientConn, error) {
 	// 2) BUILD TRANSPORT AUTH
 	// ============================
 	var creds credentials.TransportCredentials
-	mode := strings.ToLower(viper.GetString("security.mode"))
 
 	if mode == "mtls" {
 		// mutual TLS
@@ -172,7 +206,7 @@ func GetGRPCConn(host, port, ca, cert, key string) (*grpc.ClientConn, error) {
 	// ============================
 	// 5) STORE IN POOL
 	// ============================
-	connPool.Store(addr, connItem{
+	connPool.Store(poolKey, connItem{
 		conn:   conn,
 		expiry: time.Now().Add(ttl),
 	})
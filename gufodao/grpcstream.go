@@ -33,7 +33,8 @@ func GRPCStream(host, port string, t *pb.Request) map[string]interface{} {
 	answer := make(map[string]interface{})
 	addr := fmt.Sprintf("%s:%s", host, port)
 
-	conn, err := GetGRPCConn(
+	conn, err := GetGRPCConnFor(
+		safeModuleName(t),
 		host,
 		port,
 		viper.GetString("security.ca_path"),
@@ -17,6 +17,7 @@ package gufodao
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -34,8 +35,13 @@ func GRPCStreamPut(host, port string, r *http.Request, t *pb.Request) map[string
 
 	module := safeModuleName(t)
 
-	conn, err := GetGRPCConn(
-		host, port,
+	// Content-Encoding: gzip uploads are decompressed on the fly
+	if err := DecodeRequestBody(r); err != nil {
+		return map[string]interface{}{"httpcode": BodyErrorStatus(err), "message": err.Error()}
+	}
+
+	conn, err := GetGRPCConnFor(
+		module, host, port,
 		viper.GetString("security.ca_path"),
 		viper.GetString("security.cert_path"),
 		viper.GetString("security.key_path"),
@@ -53,6 +59,8 @@ func GRPCStreamPut(host, port string, r *http.Request, t *pb.Request) map[string
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
+	Gufosign(t)
+
 	client := pb.NewReverseClient(conn)
 	stream, err := client.Stream(ctx)
 	if err != nil {
@@ -63,13 +71,15 @@ func GRPCStreamPut(host, port string, r *http.Request, t *pb.Request) map[string
 
 	ct := r.Header.Get("Content-Type")
 	if strings.HasPrefix(strings.ToLower(ct), "multipart/") {
-		if err := streamMultipartFiles(stream, r, t); err != nil {
-			return map[string]interface{}{"httpcode": 500, "message": err.Error()}
-		}
+		err = streamMultipartFiles(stream, r, t)
 	} else {
-		if err := streamSingleBody(stream, r, t); err != nil {
-			return map[string]interface{}{"httpcode": 500, "message": err.Error()}
-		}
+		err = streamSingleBody(stream, r, t)
+	}
+	if errors.Is(err, ErrBodyTooLarge) || errors.Is(err, ErrCorruptBody) {
+		return map[string]interface{}{"httpcode": BodyErrorStatus(err), "message": err.Error()}
+	}
+	if err != nil {
+		return map[string]interface{}{"httpcode": 500, "message": err.Error()}
 	}
 
 	// Receive server responses (optional aggregation)
@@ -180,6 +190,7 @@ func sendChunk(stream pb.Reverse_StreamClient, t *pb.Request, filename string, d
 	}
 	req := &pb.Request{
 		Module: t.Module,
+		Sign:   t.Sign,
 		IR:     t.IR,
 		Args:   map[string]*anypb.Any{"chunk": anyChunk},
 	}
@@ -195,6 +206,7 @@ func sendFileMarker(stream pb.Reverse_StreamClient, t *pb.Request, filename, pha
 	}
 	req := &pb.Request{
 		Module: t.Module,
+		Sign:   t.Sign,
 		IR:     t.IR,
 		Args:   map[string]*anypb.Any{"meta": anyMeta},
 	}
@@ -1,18 +1,20 @@
 package gufodao
 
 import (
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/hex"
-	"strings"
-
 	pb "github.com/gogufo/gufo-api-gateway/proto/go"
 	"github.com/spf13/viper"
 )
 
-// GufoSign sets the correct Sign value depending on security.mode
+// GufoSign sets the correct Sign value depending on the outbound
+// security mode of the destination module (see OutboundMode).
+// It is called for every upstream call, so whatever Sign the caller
+// sent to the gateway is never forwarded to microservices.
 func Gufosign(t *pb.Request) *pb.Request {
-	mode := strings.ToLower(viper.GetString("security.mode"))
+	module := ""
+	if t.Module != nil {
+		module = *t.Module
+	}
+	mode := OutboundMode(module)
 
 	switch mode {
 
@@ -36,13 +38,23 @@ func Gufosign(t *pb.Request) *pb.Request {
 			t.Module = &empty
 		}
 
-		mac := hmac.New(sha256.New, []byte(secret))
-		mac.Write([]byte(*t.Module))
-		sum := mac.Sum(nil)
-
-		sign := hex.EncodeToString(sum)
+		// "<hmac>:<unix ts>", or hex(hmac(module)) with hmac_format = "legacy"
+		sign := HMACSign(secret, *t.Module)
 		t.Sign = &sign
 
+	// -----------------------------
+	// INTERNAL TOKEN MODE
+	// -----------------------------
+	case "token":
+		// short-lived EdDSA token, verifiable offline by the module
+		tkn, err := MintInternalToken(t)
+		if err != nil {
+			SetErrorLog("gufosign: " + err.Error())
+			t.Sign = nil
+			break
+		}
+		t.Sign = &tkn
+
 	// -----------------------------
 	// MTLS MODE
 	// -----------------------------
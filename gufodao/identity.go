@@ -0,0 +1,185 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// Service identity for mTLS peers. A peer is identified by the first
+// URI SAN of its client certificate (SPIFFE style, e.g. spiffe://gufo/ns/svc)
+// or, when the certificate carries no URI SAN, by its Common Name.
+
+package gufodao
+
+import (
+	"context"
+	"crypto/x509"
+	"strings"
+	"sync"
+
+	viper "github.com/spf13/viper"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// ACLRule grants an identity access to modules and params
+// through the internal gRPC port. Empty Modules or Params mean any.
+type ACLRule struct {
+	Identity string   `mapstructure:"identity"`
+	Modules  []string `mapstructure:"modules"`
+	Params   []string `mapstructure:"params"`
+}
+
+var (
+	aclMu     sync.RWMutex
+	aclRules  []ACLRule
+	aclLoaded bool
+)
+
+type identityKey struct{}
+
+type consumerKey struct{}
+
+// ConsumerMetadataKey is the gRPC metadata key carrying the API consumer ID
+// to microservices.
+const ConsumerMetadataKey = "x-gufo-consumer-id"
+
+// IdentityFromCert extracts the service identity from a certificate.
+// spiffe:// URIs win over other URI SANs, which win over the CN.
+func IdentityFromCert(cert *x509.Certificate) string {
+	if cert == nil {
+		return ""
+	}
+	for _, u := range cert.URIs {
+		if strings.EqualFold(u.Scheme, "spiffe") {
+			return u.String()
+		}
+	}
+	if len(cert.URIs) > 0 {
+		return cert.URIs[0].String()
+	}
+	return cert.Subject.CommonName
+}
+
+// PeerIdentity returns the identity of the verified gRPC peer, or "" if the
+// connection is not TLS or the client did not present a certificate.
+func PeerIdentity(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.AuthInfo == nil {
+		return ""
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return ""
+	}
+	if len(info.State.VerifiedChains) > 0 && len(info.State.VerifiedChains[0]) > 0 {
+		return IdentityFromCert(info.State.VerifiedChains[0][0])
+	}
+	return ""
+}
+
+// WithIdentity stores the caller identity in ctx for downstream handlers.
+func WithIdentity(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// ContextIdentity returns the caller identity stored by WithIdentity.
+func ContextIdentity(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(identityKey{}).(string)
+	return id
+}
+
+// WithConsumer stores the authenticated API consumer ID in ctx.
+func WithConsumer(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, consumerKey{}, id)
+}
+
+// ContextConsumer returns the API consumer ID stored by WithConsumer.
+func ContextConsumer(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(consumerKey{}).(string)
+	return id
+}
+
+// LoadACL parses [[security.mtls_acl]] rules from config and caches them.
+// InitConfig calls it; call it again after changing the rules at runtime.
+func LoadACL() []ACLRule {
+	var rules []ACLRule
+	if err := viper.UnmarshalKey("security.mtls_acl", &rules); err != nil {
+		SetErrorLog("identity: cannot parse security.mtls_acl: " + err.Error())
+		rules = nil
+	}
+
+	aclMu.Lock()
+	aclRules, aclLoaded = rules, true
+	aclMu.Unlock()
+	return rules
+}
+
+func acl() []ACLRule {
+	aclMu.RLock()
+	rules, loaded := aclRules, aclLoaded
+	aclMu.RUnlock()
+	if !loaded {
+		return LoadACL()
+	}
+	return rules
+}
+
+// IdentityAllowed reports whether id may call module/param.
+// With no rules configured every verified identity is allowed,
+// which keeps existing mTLS deployments working unchanged.
+func IdentityAllowed(id, module, param string) bool {
+	rules := acl()
+	if len(rules) == 0 {
+		return true
+	}
+	for _, rule := range rules {
+		if matchPattern(rule.Identity, id) && MatchScope(rule.Modules, module) && MatchScope(rule.Params, param) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchAny reports whether value matches one of patterns (see matchPattern).
+func MatchAny(patterns []string, value string) bool {
+	for _, p := range patterns {
+		if matchPattern(p, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchScope is MatchAny for scope lists (ACL rules, API key scopes),
+// where an empty list places no restriction.
+func MatchScope(patterns []string, value string) bool {
+	return len(patterns) == 0 || MatchAny(patterns, value)
+}
+
+// matchPattern supports exact values, "*" and trailing-"*" prefixes
+// such as "spiffe://gufo/ns/*".
+func matchPattern(pattern, value string) bool {
+	if pattern == "*" {
+		return true
+	}
+	if strings.HasSuffix(pattern, "*") {
+		return strings.HasPrefix(value, strings.TrimSuffix(pattern, "*"))
+	}
+	return pattern == value
+}
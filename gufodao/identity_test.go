@@ -0,0 +1,88 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+
+package gufodao
+
+import (
+	"testing"
+
+	viper "github.com/spf13/viper"
+)
+
+func TestMatchScope(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		value    string
+		want     bool
+	}{
+		{nil, "anything", true},
+		{[]string{}, "", true},
+		{[]string{"*"}, "orders", true},
+		{[]string{"orders"}, "orders", true},
+		{[]string{"orders"}, "orders2", false},
+		{[]string{"ord*"}, "orders", true},
+		{[]string{"ord*"}, "users", false},
+		{[]string{"users", "orders"}, "orders", true},
+		{[]string{""}, "orders", false},
+	}
+	for _, tt := range tests {
+		if got := MatchScope(tt.patterns, tt.value); got != tt.want {
+			t.Errorf("MatchScope(%q, %q) = %v, want %v", tt.patterns, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestIdentityAllowed(t *testing.T) {
+	t.Cleanup(func() {
+		viper.Set("security.mtls_acl", nil)
+		LoadACL()
+	})
+
+	viper.Set("security.mtls_acl", nil)
+	LoadACL()
+	if !IdentityAllowed("spiffe://gufo/ns/any", "orders", "list") {
+		t.Fatal("no rules: identity denied")
+	}
+
+	viper.Set("security.mtls_acl", []map[string]interface{}{
+		{"identity": "spiffe://gufo/ns/billing", "modules": []string{"orders"}, "params": []string{"list", "get*"}},
+		{"identity": "spiffe://gufo/ns/admin/*"},
+		{"identity": "spiffe://gufo/ns/reports", "modules": []string{"stats"}, "params": []string{}},
+	})
+	if rules := LoadACL(); len(rules) != 3 {
+		t.Fatalf("LoadACL parsed %d rules, want 3", len(rules))
+	}
+
+	tests := []struct {
+		id, module, param string
+		want              bool
+	}{
+		{"spiffe://gufo/ns/billing", "orders", "list", true},
+		{"spiffe://gufo/ns/billing", "orders", "getone", true},
+		{"spiffe://gufo/ns/billing", "orders", "delete", false},
+		{"spiffe://gufo/ns/billing", "users", "list", false},
+		{"spiffe://gufo/ns/admin/ops", "users", "delete", true}, // empty scopes: any
+		{"spiffe://gufo/ns/reports", "stats", "anything", true},
+		{"spiffe://gufo/ns/reports", "orders", "list", false},
+		{"spiffe://gufo/ns/unknown", "orders", "list", false},
+		{"", "orders", "list", false},
+	}
+	for _, tt := range tests {
+		if got := IdentityAllowed(tt.id, tt.module, tt.param); got != tt.want {
+			t.Errorf("IdentityAllowed(%q, %q, %q) = %v, want %v", tt.id, tt.module, tt.param, got, tt.want)
+		}
+	}
+}
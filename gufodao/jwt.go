@@ -0,0 +1,310 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// Minimal JWT (JWS compact) support:
+//   - HS256 tokens issued by an identity provider for edge authentication;
+//   - EdDSA internal tokens minted by the gateway for every upstream call,
+//     which microservices verify offline with the published public key.
+
+package gufodao
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	pb "github.com/gogufo/gufo-api-gateway/proto/go"
+	"github.com/google/uuid"
+	viper "github.com/spf13/viper"
+)
+
+const internalTokenIssuer = "gufo-gateway"
+
+var (
+	internalKey     ed25519.PrivateKey
+	internalKeyID   string
+	internalKeyOnce sync.Once
+)
+
+// ConfigSecret resolves a secret from the ENV variable named in <key>_env,
+// falling back to the (optionally encrypted) config value.
+func ConfigSecret(key string) string {
+	if envKey := viper.GetString(key + "_env"); envKey != "" {
+		if val, ok := os.LookupEnv(envKey); ok && val != "" {
+			return val
+		}
+	}
+	return DecryptConfigPasswords(viper.GetString(key))
+}
+
+// VerifyJWT validates an HS256 token signed with security.jwt_secret
+// and returns its claims.
+func VerifyJWT(token string) (map[string]interface{}, error) {
+	secret := ConfigSecret("security.jwt_secret")
+	if secret == "" {
+		return nil, errors.New("jwt: security.jwt_secret is not configured")
+	}
+
+	header, claims, signed, sig, err := splitJWT(token)
+	if err != nil {
+		return nil, err
+	}
+	if header["alg"] != "HS256" {
+		return nil, fmt.Errorf("jwt: unsupported alg %v", header["alg"])
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signed))
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return nil, errors.New("jwt: invalid signature")
+	}
+
+	return claims, checkTimeClaims(claims)
+}
+
+// MintInternalToken issues a short-lived EdDSA token for a gateway → module call.
+// Claims: iss, aud (module), sub (user ID), param, iat, exp, jti.
+func MintInternalToken(t *pb.Request) (string, error) {
+	key, kid := internalTokenKey()
+	if key == nil {
+		return "", errors.New("internal token key is not available")
+	}
+
+	ttl := viper.GetDuration("security.internal_token_ttl")
+	if ttl == 0 {
+		ttl = 60 * time.Second
+	}
+	now := time.Now()
+
+	claims := map[string]interface{}{
+		"iss": internalTokenIssuer,
+		"iat": now.Unix(),
+		"exp": now.Add(ttl).Unix(),
+		"jti": uuid.New().String(),
+	}
+	if t.Module != nil {
+		claims["aud"] = *t.Module
+	}
+	if t.Param != nil {
+		claims["param"] = *t.Param
+	}
+	if t.UID != nil {
+		claims["sub"] = *t.UID
+	}
+
+	header := map[string]interface{}{"alg": "EdDSA", "typ": "JWT", "kid": kid}
+	signed, err := encodeJWTParts(header, claims)
+	if err != nil {
+		return "", err
+	}
+	sig := ed25519.Sign(key, []byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// VerifyInternalToken validates a token minted by MintInternalToken for a
+// call to module. Tokens minted for another module are rejected, so a module
+// cannot replay a token it received to reach a different one.
+func VerifyInternalToken(token, module string) (map[string]interface{}, error) {
+	key, _ := internalTokenKey()
+	if key == nil {
+		return nil, errors.New("internal token key is not available")
+	}
+
+	header, claims, signed, sig, err := splitJWT(token)
+	if err != nil {
+		return nil, err
+	}
+	if header["alg"] != "EdDSA" {
+		return nil, fmt.Errorf("jwt: unsupported alg %v", header["alg"])
+	}
+	if !ed25519.Verify(key.Public().(ed25519.PublicKey), []byte(signed), sig) {
+		return nil, errors.New("jwt: invalid signature")
+	}
+	if claims["iss"] != internalTokenIssuer {
+		return nil, errors.New("jwt: unexpected issuer")
+	}
+	if aud, _ := claims["aud"].(string); aud == "" || aud != module {
+		return nil, errors.New("jwt: token not issued for this module")
+	}
+	return claims, checkTimeClaims(claims)
+}
+
+// InternalTokenJWKS returns the public key set microservices use
+// to verify internal tokens offline.
+func InternalTokenJWKS() map[string]interface{} {
+	key, kid := internalTokenKey()
+	keys := []interface{}{}
+	if key != nil {
+		keys = append(keys, map[string]interface{}{
+			"kty": "OKP",
+			"crv": "Ed25519",
+			"alg": "EdDSA",
+			"use": "sig",
+			"kid": kid,
+			"x":   base64.RawURLEncoding.EncodeToString(key.Public().(ed25519.PublicKey)),
+		})
+	}
+	return map[string]interface{}{"keys": keys}
+}
+
+// internalTokenKey loads the Ed25519 key from security.internal_token_key
+// (PKCS#8 PEM). A missing file is generated once so that all replicas
+// sharing the volume use the same key (see createInternalKey).
+func internalTokenKey() (ed25519.PrivateKey, string) {
+	internalKeyOnce.Do(func() {
+		path := viper.GetString("security.internal_token_key")
+		if path == "" {
+			path = filepath.Join(viper.GetString("server.sysdir"), "internal-token.pem")
+		}
+
+		key, err := readInternalKey(path)
+		if errors.Is(err, os.ErrNotExist) {
+			key, err = createInternalKey(path)
+		}
+		if err != nil {
+			SetErrorLog("internal token: " + err.Error())
+			return
+		}
+		internalKey = key
+
+		sum := sha256.Sum256(internalKey.Public().(ed25519.PublicKey))
+		internalKeyID = base64.RawURLEncoding.EncodeToString(sum[:8])
+	})
+	return internalKey, internalKeyID
+}
+
+func readInternalKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("invalid PEM in %s", path)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("key in %s is not Ed25519", path)
+	}
+	return key, nil
+}
+
+// createInternalKey generates a key and publishes it at path with a hard
+// link, which fails if the file exists. Replicas starting together on a
+// shared volume thus agree on one key: the losers read the winner's file.
+// The key file is complete before it appears under path.
+func createInternalKey(path string) (ed25519.PrivateKey, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("cannot generate key: %w", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".internal-token-*")
+	if err == nil {
+		defer os.Remove(tmp.Name())
+		_, err = tmp.Write(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+		if cerr := tmp.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err == nil {
+		err = os.Link(tmp.Name(), path)
+	}
+	switch {
+	case err == nil:
+		SetLog("internal token: generated new signing key at " + path)
+	case errors.Is(err, os.ErrExist):
+		return readInternalKey(path) // another replica won
+	default:
+		SetErrorLog("internal token: cannot persist key, using ephemeral key: " + err.Error())
+	}
+	return key, nil
+}
+
+func encodeJWTParts(header, claims map[string]interface{}) (string, error) {
+	h, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c), nil
+}
+
+func splitJWT(token string) (header, claims map[string]interface{}, signed string, sig []byte, err error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, nil, "", nil, errors.New("jwt: malformed token")
+	}
+
+	hb, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, nil, "", nil, fmt.Errorf("jwt: bad header: %w", err)
+	}
+	cb, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, nil, "", nil, fmt.Errorf("jwt: bad claims: %w", err)
+	}
+	sig, err = base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, nil, "", nil, fmt.Errorf("jwt: bad signature: %w", err)
+	}
+	if err := json.Unmarshal(hb, &header); err != nil {
+		return nil, nil, "", nil, fmt.Errorf("jwt: bad header: %w", err)
+	}
+	if err := json.Unmarshal(cb, &claims); err != nil {
+		return nil, nil, "", nil, fmt.Errorf("jwt: bad claims: %w", err)
+	}
+	return header, claims, parts[0] + "." + parts[1], sig, nil
+}
+
+// checkTimeClaims requires exp: a token without one would be valid forever.
+func checkTimeClaims(claims map[string]interface{}) error {
+	now := float64(time.Now().Unix())
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return errors.New("jwt: missing exp claim")
+	}
+	if now > exp {
+		return errors.New("jwt: token expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now < nbf {
+		return errors.New("jwt: token not yet valid")
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+
+package gufodao
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/gogufo/gufo-api-gateway/proto/go"
+	viper "github.com/spf13/viper"
+)
+
+// hs256 signs claims the way an external identity provider would.
+func hs256(t *testing.T, secret, alg string, claims map[string]interface{}) string {
+	t.Helper()
+	signed, err := encodeJWTParts(map[string]interface{}{"alg": alg, "typ": "JWT"}, claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifyJWT(t *testing.T) {
+	viper.Set("security.jwt_secret", "jwt-secret")
+	t.Cleanup(func() { viper.Set("security.jwt_secret", "") })
+
+	now := time.Now().Unix()
+	valid := map[string]interface{}{"sub": "42", "exp": now + 60}
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{"valid", hs256(t, "jwt-secret", "HS256", valid), false},
+		{"wrong secret", hs256(t, "other", "HS256", valid), true},
+		{"alg none", hs256(t, "jwt-secret", "none", valid), true},
+		{"expired", hs256(t, "jwt-secret", "HS256", map[string]interface{}{"sub": "42", "exp": now - 1}), true},
+		{"no exp", hs256(t, "jwt-secret", "HS256", map[string]interface{}{"sub": "42"}), true},
+		{"not yet valid", hs256(t, "jwt-secret", "HS256", map[string]interface{}{"sub": "42", "exp": now + 60, "nbf": now + 30}), true},
+		{"malformed", "a.b", true},
+		{"bad base64", "a.b.c!", true},
+	}
+	for _, tt := range tests {
+		claims, err := VerifyJWT(tt.token)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+		if !tt.wantErr && claims["sub"] != "42" {
+			t.Errorf("%s: claims = %v", tt.name, claims)
+		}
+	}
+
+	viper.Set("security.jwt_secret", "")
+	if _, err := VerifyJWT(hs256(t, "", "HS256", valid)); err == nil {
+		t.Error("VerifyJWT accepted a token without a configured secret")
+	}
+}
+
+func TestVerifyInternalToken(t *testing.T) {
+	viper.Set("security.internal_token_key", filepath.Join(t.TempDir(), "internal-token.pem"))
+	t.Cleanup(func() { viper.Set("security.internal_token_key", "") })
+
+	module, param, uid := "orders", "list", "42"
+	token, err := MintInternalToken(&pb.Request{Module: &module, Param: &param, UID: &uid})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := VerifyInternalToken(token, "orders")
+	if err != nil {
+		t.Fatalf("VerifyInternalToken: %v", err)
+	}
+	if claims["sub"] != "42" || claims["param"] != "list" {
+		t.Fatalf("claims = %v", claims)
+	}
+
+	// re-sign tampered claims with the gateway key to reach the later checks
+	key, _ := internalTokenKey()
+	resign := func(header, claims map[string]interface{}) string {
+		signed, err := encodeJWTParts(header, claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed + "." + base64.RawURLEncoding.EncodeToString(ed25519.Sign(key, []byte(signed)))
+	}
+	eddsa := map[string]interface{}{"alg": "EdDSA", "typ": "JWT"}
+	exp := time.Now().Add(time.Minute).Unix()
+	parts := strings.Split(token, ".")
+
+	tests := []struct {
+		name, token, module string
+	}{
+		{"other module", token, "users"},
+		{"tampered signature", parts[0] + "." + parts[1] + "." + base64.RawURLEncoding.EncodeToString(make([]byte, ed25519.SignatureSize)), "orders"},
+		{"HS256 header", hs256(t, "x", "HS256", map[string]interface{}{"iss": internalTokenIssuer, "aud": "orders", "exp": exp}), "orders"},
+		{"foreign issuer", resign(eddsa, map[string]interface{}{"iss": "someone", "aud": "orders", "exp": exp}), "orders"},
+		{"no audience", resign(eddsa, map[string]interface{}{"iss": internalTokenIssuer, "exp": exp}), ""},
+		{"expired", resign(eddsa, map[string]interface{}{"iss": internalTokenIssuer, "aud": "orders", "exp": exp - 120}), "orders"},
+	}
+	for _, tt := range tests {
+		if _, err := VerifyInternalToken(tt.token, tt.module); err == nil {
+			t.Errorf("%s: token accepted", tt.name)
+		}
+	}
+}
+
+func TestCreateInternalKeyRace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "internal-token.pem")
+
+	// replicas starting together on a shared volume
+	const replicas = 8
+	keys := make(chan ed25519.PrivateKey, replicas)
+	var wg sync.WaitGroup
+	for range replicas {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			key, err := createInternalKey(path)
+			if err != nil {
+				t.Error(err)
+			}
+			keys <- key
+		}()
+	}
+	wg.Wait()
+	close(keys)
+
+	stored, err := readInternalKey(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key := range keys {
+		if !stored.Equal(key) {
+			t.Fatal("a replica uses a key other than the stored one")
+		}
+	}
+	if matches, _ := filepath.Glob(filepath.Join(filepath.Dir(path), ".internal-token-*")); len(matches) != 0 {
+		t.Fatalf("temporary key files left: %v", matches)
+	}
+}
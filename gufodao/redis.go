@@ -18,6 +18,7 @@ package gufodao
 
 import (
 	"crypto/tls"
+	"sync"
 	"time"
 
 	"github.com/gomodule/redigo/redis"
@@ -26,6 +27,8 @@ import (
 
 var CachePool *redis.Pool
 
+var cacheInitMu sync.Mutex
+
 func InitCache() {
 	host := ConfigString("redis.host")
 	password := ConfigString("redis.password")
@@ -104,3 +107,13 @@ func InitCache() {
 		SetLog("redis: connected")
 	}
 }
+
+// EnsureCache initializes CachePool on first use for features that need
+// Redis even when masterservice mode is disabled.
+func EnsureCache() {
+	cacheInitMu.Lock()
+	defer cacheInitMu.Unlock()
+	if CachePool == nil {
+		InitCache()
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// Security mode resolution.
+//
+// Internal (service ↔ gateway) modes can be set globally (security.mode),
+// for the gRPC listener (security.listeners.grpc) and per microservice
+// (microservices.<name>.security_mode). A comma-separated list such as
+// "hmac,sign" accepts every listed scheme, which lets services be migrated
+// one by one; the first entry is the preferred (new) scheme.
+//
+// Edge (public REST) modes are configured separately, see EdgeModes.
+
+package gufodao
+
+import (
+	"crypto/hmac"
+	"fmt"
+	"strings"
+	"time"
+
+	pb "github.com/gogufo/gufo-api-gateway/proto/go"
+	viper "github.com/spf13/viper"
+)
+
+// Listener names used for per-listener security modes.
+const (
+	ListenerREST = "rest"
+	ListenerGRPC = "grpc"
+)
+
+// SecurityModes returns the accepted security schemes for module on listener.
+// Priority: microservice override > listener override > global security.mode.
+func SecurityModes(module, listener string) []string {
+	raw := ""
+	if module != "" {
+		raw = viper.GetString(fmt.Sprintf("microservices.%s.security_mode", module))
+	}
+	if raw == "" && listener != "" {
+		raw = viper.GetString("security.listeners." + listener)
+	}
+	if raw == "" {
+		raw = viper.GetString("security.mode")
+	}
+	return parseModes(raw)
+}
+
+// EdgeModes returns the accepted schemes for public REST callers of module.
+// Priority: microservices.<name>.edge_mode > security.listeners.rest >
+// security.edge_mode > security.mode. The last fallback keeps the pre-edge
+// behaviour, where REST callers had to pass the internal scheme; public
+// access needs an explicit "anonymous".
+//
+// Edge schemes: "anonymous", "session", "jwt", "apikey" plus the internal schemes
+// ("sign", "hmac", "token", "mtls") for services calling through REST.
+func EdgeModes(module string) []string {
+	raw := ""
+	if module != "" {
+		raw = viper.GetString(fmt.Sprintf("microservices.%s.edge_mode", module))
+	}
+	if raw == "" {
+		raw = viper.GetString("security.listeners." + ListenerREST)
+	}
+	if raw == "" {
+		raw = viper.GetString("security.edge_mode")
+	}
+	if raw == "" {
+		raw = viper.GetString("security.mode")
+	}
+	return parseModes(raw)
+}
+
+// OutboundMode returns the scheme the gateway uses when calling module.
+// For transitional lists the first (preferred) entry is used.
+func OutboundMode(module string) string {
+	raw := ""
+	if module != "" {
+		raw = viper.GetString(fmt.Sprintf("microservices.%s.security_mode", module))
+	}
+	if raw == "" {
+		raw = viper.GetString("security.mode")
+	}
+	modes := parseModes(raw)
+	if len(modes) == 0 {
+		return ""
+	}
+	return modes[0]
+}
+
+// CheckSign validates t.Sign for the signature based schemes
+// ("sign", "hmac", "token"). Certificate based schemes are checked
+// by the listener itself.
+func CheckSign(mode string, t *pb.Request) bool {
+	switch mode {
+	case "token":
+		if t.Sign == nil || t.Module == nil {
+			return false
+		}
+		_, err := VerifyInternalToken(*t.Sign, *t.Module)
+		return err == nil
+	case "hmac":
+		if t.Sign == nil || t.Module == nil {
+			return false
+		}
+		secret := viper.GetString("security.hmac_secret")
+		if LegacyHMAC() && hmac.Equal([]byte(*t.Sign), []byte(ComputeLegacyHMAC(secret, *t.Module))) {
+			return true
+		}
+		maxAge := time.Duration(viper.GetInt("security.max_age")) * time.Second
+		return VerifyHMAC(secret, *t.Module, *t.Sign, maxAge)
+	case "sign":
+		return t.Sign != nil && viper.GetString("server.sign") == *t.Sign
+	}
+	return false
+}
+
+func parseModes(raw string) []string {
+	var modes []string
+	for _, m := range strings.Split(raw, ",") {
+		m = strings.ToLower(strings.TrimSpace(m))
+		if m != "" {
+			modes = append(modes, m)
+		}
+	}
+	return modes
+}
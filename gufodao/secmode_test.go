@@ -0,0 +1,61 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+
+package gufodao
+
+import (
+	"reflect"
+	"testing"
+
+	viper "github.com/spf13/viper"
+)
+
+func TestEdgeModes(t *testing.T) {
+	keys := []string{
+		"security.mode", "security.edge_mode", "security.listeners.rest",
+		"microservices.orders.edge_mode", "microservices.orders.security_mode",
+	}
+	reset := func() {
+		for _, k := range keys {
+			viper.Set(k, "")
+		}
+	}
+	t.Cleanup(reset)
+
+	tests := []struct {
+		name   string
+		config map[string]string
+		module string
+		want   []string
+	}{
+		{"legacy mtls stays mtls", map[string]string{"security.mode": "mtls"}, "orders", []string{"mtls"}},
+		{"legacy list", map[string]string{"security.mode": "hmac, sign"}, "", []string{"hmac", "sign"}},
+		{"module security_mode is internal only", map[string]string{"security.mode": "mtls", "microservices.orders.security_mode": "sign"}, "orders", []string{"mtls"}},
+		{"edge_mode", map[string]string{"security.mode": "mtls", "security.edge_mode": "anonymous"}, "orders", []string{"anonymous"}},
+		{"listener wins over edge_mode", map[string]string{"security.edge_mode": "anonymous", "security.listeners.rest": "JWT,session"}, "orders", []string{"jwt", "session"}},
+		{"module wins", map[string]string{"security.listeners.rest": "jwt", "microservices.orders.edge_mode": "apikey"}, "orders", []string{"apikey"}},
+		{"nothing configured", nil, "orders", nil},
+	}
+	for _, tt := range tests {
+		reset()
+		for k, v := range tt.config {
+			viper.Set(k, v)
+		}
+		if got := EdgeModes(tt.module); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: EdgeModes(%q) = %q, want %q", tt.name, tt.module, got, tt.want)
+		}
+	}
+}
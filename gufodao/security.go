@@ -43,6 +43,31 @@ func ComputeHMAC(secret, module string, ts int64) string {
 	return hex.EncodeToString(mac.Sum(nil))
 }
 
+// ComputeLegacyHMAC is the signature of gateways up to 1.24, hex(hmac(module)) without a
+// timestamp. It never expires, so use it only while modules still verify
+// the old format (security.hmac_format = "legacy").
+func ComputeLegacyHMAC(secret, module string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(module))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// HMACSign returns the Sign value for module in the configured
+// security.hmac_format: "timestamped" (default, "<hmac(module:ts)>:<ts>")
+// or "legacy".
+func HMACSign(secret, module string) string {
+	if LegacyHMAC() {
+		return ComputeLegacyHMAC(secret, module)
+	}
+	ts := time.Now().Unix()
+	return fmt.Sprintf("%s:%d", ComputeHMAC(secret, module, ts), ts)
+}
+
+// LegacyHMAC reports whether security.hmac_format selects the legacy format.
+func LegacyHMAC() bool {
+	return strings.EqualFold(viper.GetString("security.hmac_format"), "legacy")
+}
+
 // VerifyHMAC validates the HMAC signature.
 func VerifyHMAC(secret, module, sign string, maxAge time.Duration) bool {
 	parts := strings.Split(sign, ":")
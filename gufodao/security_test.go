@@ -0,0 +1,49 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+
+package gufodao
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestVerifyHMAC(t *testing.T) {
+	now := time.Now().Unix()
+	sign := func(secret, module string, ts int64) string {
+		return fmt.Sprintf("%s:%d", ComputeHMAC(secret, module, ts), ts)
+	}
+	tests := []struct {
+		name, sign string
+		want       bool
+	}{
+		{"valid", sign("secret", "orders", now), true},
+		{"within max age", sign("secret", "orders", now-30), true},
+		{"too old", sign("secret", "orders", now-120), false},
+		{"wrong secret", sign("other", "orders", now), false},
+		{"other module", sign("secret", "users", now), false},
+		{"legacy format", ComputeLegacyHMAC("secret", "orders"), false},
+		{"bad timestamp", ComputeHMAC("secret", "orders", now) + ":soon", false},
+		{"extra part", sign("secret", "orders", now) + ":1", false},
+		{"empty", "", false},
+	}
+	for _, tt := range tests {
+		if got := VerifyHMAC("secret", "orders", tt.sign, time.Minute); got != tt.want {
+			t.Errorf("%s: VerifyHMAC = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -31,6 +31,7 @@ var (
 	logger      *zap.Logger
 	errorLogger *zap.Logger
 	once        sync.Once
+	logLevel    = zap.NewAtomicLevelAt(zapcore.InfoLevel)
 )
 
 // InitLogger initializes the JSON logger.
@@ -93,7 +94,7 @@ func InitLogger() {
 		core := zapcore.NewCore(
 			zapcore.NewJSONEncoder(encoderCfg),
 			mainWriter,
-			zapcore.InfoLevel,
+			logLevel,
 		)
 
 		errCore := zapcore.NewCore(
@@ -120,6 +121,28 @@ func SetLog(msg string) {
 	logger.Info(msg)
 }
 
+// SetDebugLog writes a debug message (visible only when debug logging is on)
+func SetDebugLog(msg string) {
+	if logger == nil {
+		InitLogger()
+	}
+	logger.Debug(msg)
+}
+
+// SetDebugLogging switches debug logging on or off at runtime
+func SetDebugLogging(enabled bool) {
+	if enabled {
+		logLevel.SetLevel(zapcore.DebugLevel)
+	} else {
+		logLevel.SetLevel(zapcore.InfoLevel)
+	}
+}
+
+// DebugLogging reports whether debug logging is on
+func DebugLogging() bool {
+	return logLevel.Enabled(zapcore.DebugLevel)
+}
+
 // SetErrorLog writes an error message
 func SetErrorLog(msg string) {
 	if errorLogger == nil {
@@ -24,11 +24,22 @@ import (
 	"time"
 )
 
+// sigCh receives system signals and shutdown requests from TriggerShutdown.
+var sigCh = make(chan os.Signal, 1)
+
+// TriggerShutdown starts the same graceful shutdown as SIGTERM
+// (used by the admin API).
+func TriggerShutdown() {
+	select {
+	case sigCh <- syscall.SIGTERM:
+	default: // shutdown already requested
+	}
+}
+
 // WaitForShutdown listens for SIGINT/SIGTERM and runs the provided callback.
 // It gives graceful shutdown to all servers (HTTP, gRPC, metrics, etc.).
 func WaitForShutdown(onShutdown func()) {
-	// Create channel to listen for system signals
-	sigCh := make(chan os.Signal, 1)
+	// Listen for system signals
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 
 	// Block until a signal is received
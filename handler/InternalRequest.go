@@ -17,8 +17,12 @@
 package handler
 
 import (
+	"context"
+	"errors"
+
 	sf "github.com/gogufo/gufo-api-gateway/gufodao"
 	pb "github.com/gogufo/gufo-api-gateway/proto/go"
+	"github.com/gogufo/gufo-api-gateway/registry"
 )
 
 /*
@@ -26,17 +30,39 @@ JSON /api/v3/auth/signin POST username and password
 GRPC /session/savesession POST
 */
 
-func InternalRequest(t *pb.Request) (response *pb.Response) {
+// InternalRequest serves calls arriving on the gateway gRPC port.
+// ctx carries the caller identity in mTLS mode (see sf.ContextIdentity).
+func InternalRequest(ctx context.Context, t *pb.Request) (response *pb.Response) {
 	//Get destination way
 	if t.Module != nil && *t.Module == "heartbeat" {
 		// тут payload можно собрать из t.Args, если нужно
-		ans, err := heartbeatCore(t, nil)
+		ans, err := heartbeatCore(grpcHeartbeatCaller(ctx), t, nil)
+		if errors.Is(err, registry.ErrInvalidInstance) {
+			return sf.ErrorReturn(t, 400, "0000400", err.Error())
+		}
+		if errors.Is(err, registry.ErrNotAllowed) || errors.Is(err, registry.ErrStatic) {
+			return sf.ErrorReturn(t, 403, "00005", err.Error())
+		}
 		if err != nil {
 			return sf.ErrorReturn(t, 500, "0000501", "MasterService heartbeat error")
 		}
 		return sf.Interfacetoresponse(t, ans)
 	}
 
+	// Async job results reported by modules (see transport.MQTransport)
+	if t.GetModule() == "jobs" && t.GetParam() == "report" {
+		return reportJob(ctx, t)
+	}
+
+	// Outbound webhook events (see package webhook)
+	if t.IR != nil && t.IR.GetParam() == "webhook" {
+		return emitWebhook(ctx, t)
+	}
+
+	if t.Module != nil && registry.IsDrained(*t.Module) {
+		return sf.ErrorReturn(t, 503, "0000503", "Module is drained")
+	}
+
 	host, port, _ := GetHostAndPort(t)
 
 	ans := sf.GRPCConnect(host, port, t)
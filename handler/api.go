@@ -27,11 +27,13 @@ import (
 
 	"time"
 
+	sf "github.com/gogufo/gufo-api-gateway/gufodao"
+	"github.com/gogufo/gufo-api-gateway/metering"
 	"github.com/gogufo/gufo-api-gateway/middleware"
 	pb "github.com/gogufo/gufo-api-gateway/proto/go"
 )
 
-var methodHandlers = map[string]func(http.ResponseWriter, *http.Request, *pb.Request, int){
+var methodHandlers = map[string]func(http.ResponseWriter, *http.Request, *pb.Request, string){
 	"OPTIONS": ProcessOPTIONS,
 	"GET":     ProcessREQ,
 	"HEAD":    ProcessREQ,
@@ -44,11 +46,50 @@ var methodHandlers = map[string]func(http.ResponseWriter, *http.Request, *pb.Req
 
 // API is the main entrypoint for all REST requests in Gufo Gateway.
 // It runs the middleware chain (Before/After) around the appropriate handler.
-func API(w http.ResponseWriter, r *http.Request, version int) {
+// version is the mount the request arrived on (/api/<version>).
+func API(w http.ResponseWriter, r *http.Request, version string) {
 	// fmt.Fprintln(os.Stderr, ">>> HTTP IN:", r.Method, r.URL.Path)
 
+	// Per-route transforms; headers are rewritten before they are read
+	rule := matchTransform(r)
+	transformHeaders(r, rule)
+
+	serveEdge(w, r, version, rule, func(w http.ResponseWriter, r *http.Request, t *pb.Request) int {
+		// 2️⃣ Core routing by HTTP method
+		if h, ok := methodHandlers[r.Method]; ok {
+			h(w, r, t, version)
+			return http.StatusOK
+		}
+		ProcessOPTIONS(w, r, t, version)
+		return http.StatusNoContent
+	})
+}
+
+// serveEdge runs handle inside the public pipeline shared by API, Batch and
+// GraphQL: mount, global middleware, usage record, compression, metering
+// and metrics. handle returns the status recorded for the request.
+func serveEdge(w http.ResponseWriter, r *http.Request, version string, rule *TransformRule, handle func(http.ResponseWriter, *http.Request, *pb.Request) int) {
 	t := RequestInit(r)
+	if !applyMount(w, r, t, getAPIMount(version)) {
+		return
+	}
+	runEdge(w, r, t, rule, handle)
+}
+
+// ServeRPC runs a gRPC-Web or protojson call of the Reverse service inside
+// the public pipeline. There is no mount: authentication stays with the
+// gRPC security modes, and authorized calls are metered (see MarkRPC).
+func ServeRPC(w http.ResponseWriter, r *http.Request, handle func(http.ResponseWriter, *http.Request) int) {
+	module, path := "grpcweb", r.URL.Path
+	ip, agent := sf.ReadUserIP(r), r.UserAgent()
+	t := &pb.Request{Module: &module, Path: &path, Method: &r.Method, IP: &ip, UserAgent: &agent}
+
+	runEdge(w, r, t, nil, func(w http.ResponseWriter, r *http.Request, _ *pb.Request) int {
+		return handle(w, r)
+	})
+}
 
+func runEdge(w http.ResponseWriter, r *http.Request, t *pb.Request, rule *TransformRule, handle func(http.ResponseWriter, *http.Request, *pb.Request) int) {
 	// 1️⃣ Run global middleware chain (Before)
 	ctx, err := middleware.RunBefore(r, r.Context())
 	if err != nil {
@@ -57,17 +98,41 @@ func API(w http.ResponseWriter, r *http.Request, version int) {
 	}
 	start := time.Now()
 
-	// 2️⃣ Core routing by HTTP method
-	status := http.StatusOK // default status
-	if h, ok := methodHandlers[r.Method]; ok {
-		h(w, r.WithContext(ctx), t, version)
-	} else {
-		ProcessOPTIONS(w, r, t, version)
-		status = http.StatusNoContent
-	}
-	// 3️⃣ Record metrics (QPS + latency)
+	// usage record, filled once the consumer is known
+	ctx, rec := metering.WithRecord(ctx)
+	ctx = withTransform(ctx, rule)
+	zw := newCompressWriter(w, r) // Accept-Encoding (see compress.go)
+	cw := &countingWriter{ResponseWriter: zw}
+
+	status := handle(cw, r.WithContext(ctx), t)
+
+	finishCompression(zw)
+
+	// 3️⃣ Usage metering (per consumer and module)
+	metering.Track(rec, max(r.ContentLength, 0), cw.written)
+
+	// 4⃣ Record metrics (QPS + latency)
 	ObserveHTTPRequest(r.Method, r.URL.Path, status, start)
 
-	// 4⃣ Run middleware chain (After)
+	// 5⃣ Run middleware chain (After)
 	middleware.RunAfter(w, status, time.Since(start))
 }
+
+// countingWriter counts response body bytes for usage metering.
+type countingWriter struct {
+	http.ResponseWriter
+	written int64
+}
+
+func (cw *countingWriter) Write(b []byte) (int, error) {
+	n, err := cw.ResponseWriter.Write(b)
+	cw.written += int64(n)
+	return n, err
+}
+
+// Flush passes flushes through for streamed answers (gRPC-Web).
+func (cw *countingWriter) Flush() {
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
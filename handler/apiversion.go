@@ -0,0 +1,139 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// Public API version mounts (/api/<version>) with deprecation headers
+// and per-version module mapping
+
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	pb "github.com/gogufo/gufo-api-gateway/proto/go"
+	"github.com/spf13/viper"
+)
+
+// defaultAPIVersions are mounted when api.versions is not set. v3 is the
+// prefix used by internal callers (sf.GRPCReq, sf.GRPCGen).
+var defaultAPIVersions = []string{"v1", "v2", "v3"}
+
+// builtinModules are served by the gateway itself on every mount.
+var builtinModules = map[string]bool{"info": true, "heartbeat": true, "batch": true, "graphql": true, "jobs": true}
+
+// APIMount describes one public API version mounted at /api/<Version>.
+type APIMount struct {
+	Version     string
+	Deprecated  bool
+	Deprecation time.Time         // when the version was deprecated (optional)
+	Sunset      time.Time         // when it stops being served (optional)
+	Link        string            // migration guide
+	Modules     []string          // modules reachable on this version (empty = all)
+	ModuleMap   map[string]string // public module name → internal module
+}
+
+// APIMounts returns the configured API versions (api.versions).
+func APIMounts() []APIMount {
+	versions := viper.GetStringSlice("api.versions")
+	if len(versions) == 0 {
+		versions = defaultAPIVersions
+	}
+
+	out := make([]APIMount, 0, len(versions))
+	for _, v := range versions {
+		out = append(out, getAPIMount(strings.ToLower(strings.TrimSpace(v))))
+	}
+	return out
+}
+
+func getAPIMount(version string) APIMount {
+	prefix := "api." + version + "."
+	m := APIMount{
+		Version:    version,
+		Deprecated: viper.GetBool(prefix + "deprecated"),
+		Link:       viper.GetString(prefix + "link"),
+		Modules:    viper.GetStringSlice(prefix + "modules"),
+		ModuleMap:  viper.GetStringMapString(prefix + "module_map"),
+	}
+	m.Deprecation = parseAPIDate(viper.GetString(prefix + "deprecation"))
+	m.Sunset = parseAPIDate(viper.GetString(prefix + "sunset"))
+	if !m.Deprecation.IsZero() || !m.Sunset.IsZero() {
+		m.Deprecated = true
+	}
+	return m
+}
+
+// parseAPIDate accepts "2006-01-02" or RFC 3339.
+func parseAPIDate(s string) time.Time {
+	if s == "" {
+		return time.Time{}
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t
+	}
+	t, _ := time.Parse(time.RFC3339, s)
+	return t
+}
+
+// applyMount stamps the mount's version on t, maps the module name and
+// writes deprecation headers. It answers 404 for modules that are not
+// available on this version.
+func applyMount(w http.ResponseWriter, r *http.Request, t *pb.Request, m APIMount) bool {
+	vrs := m.Version
+	t.APIVersion = &vrs
+
+	if m.Deprecated {
+		// RFC 9745 / RFC 8594
+		if m.Deprecation.IsZero() {
+			w.Header().Set("Deprecation", "true")
+		} else {
+			w.Header().Set("Deprecation", fmt.Sprintf("@%d", m.Deprecation.Unix()))
+		}
+		if !m.Sunset.IsZero() {
+			w.Header().Set("Sunset", m.Sunset.UTC().Format(http.TimeFormat))
+		}
+		if m.Link != "" {
+			w.Header().Add("Link", fmt.Sprintf("<%s>; rel=\"deprecation\"", m.Link))
+		}
+	}
+
+	if t.Module == nil || builtinModules[*t.Module] {
+		return true
+	}
+
+	module, ok := mountModule(m, *t.Module)
+	if !ok {
+		errorAnswer(w, r, t, 404, "0000404", fmt.Sprintf("Module is not available in API %s", m.Version))
+		return false
+	}
+	t.Module = &module
+
+	return true
+}
+
+// mountModule maps a public module name to the internal one; ok is false
+// when the module is not served on m.
+func mountModule(m APIMount, module string) (string, bool) {
+	if len(m.Modules) > 0 && !containsFold(m.Modules, module) {
+		return "", false
+	}
+	if internal, ok := m.ModuleMap[strings.ToLower(module)]; ok && internal != "" {
+		return internal, true
+	}
+	return module, true
+}
@@ -0,0 +1,184 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	pb "github.com/gogufo/gufo-api-gateway/proto/go"
+)
+
+func TestParseAPIDate(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"", time.Time{}},
+		{"2025-06-30", time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC)},
+		{"2025-06-30T12:00:00+02:00", time.Date(2025, 6, 30, 10, 0, 0, 0, time.UTC)},
+		{"30.06.2025", time.Time{}},
+	}
+	for _, tt := range tests {
+		if got := parseAPIDate(tt.in); !got.Equal(tt.want) {
+			t.Errorf("parseAPIDate(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestGetAPIMount(t *testing.T) {
+	setConfig(t, map[string]interface{}{
+		"api.versions":       []string{"V1", " v2 "},
+		"api.v1.sunset":      "2026-01-01",
+		"api.v1.link":        "https://docs.example.com/migrate",
+		"api.v1.modules":     []string{"users", "orders"},
+		"api.v1.module_map":  map[string]interface{}{"users": "users_legacy"},
+		"api.v9.deprecation": "2025-01-01",
+		"api.v9.sunset":      "bad date",
+	})
+
+	mounts := APIMounts()
+	if len(mounts) != 2 || mounts[0].Version != "v1" || mounts[1].Version != "v2" {
+		t.Fatalf("mounts = %+v", mounts)
+	}
+
+	v1 := mounts[0]
+	if !v1.Deprecated || !v1.Sunset.Equal(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)) || !v1.Deprecation.IsZero() {
+		t.Errorf("v1 = %+v: a sunset date implies deprecation", v1)
+	}
+	if v1.ModuleMap["users"] != "users_legacy" || len(v1.Modules) != 2 {
+		t.Errorf("v1 modules = %v, map = %v", v1.Modules, v1.ModuleMap)
+	}
+	if mounts[1].Deprecated {
+		t.Errorf("v2 = %+v, want current", mounts[1])
+	}
+
+	v9 := getAPIMount("v9")
+	if !v9.Deprecated || v9.Deprecation.IsZero() || !v9.Sunset.IsZero() {
+		t.Errorf("v9 = %+v", v9)
+	}
+}
+
+func TestMountModule(t *testing.T) {
+	m := APIMount{
+		Version:   "v1",
+		Modules:   []string{"Users", "orders"},
+		ModuleMap: map[string]string{"users": "users_legacy", "orders": ""},
+	}
+	tests := []struct {
+		module string
+		want   string
+		ok     bool
+	}{
+		{"users", "users_legacy", true},
+		{"USERS", "users_legacy", true},
+		{"orders", "orders", true}, // empty mapping keeps the name
+		{"billing", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := mountModule(m, tt.module)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("mountModule(%s) = %q, %v, want %q, %v", tt.module, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	if got, ok := mountModule(APIMount{Version: "v3"}, "anything"); !ok || got != "anything" {
+		t.Errorf("open mount: %q, %v", got, ok)
+	}
+}
+
+func TestApplyMount(t *testing.T) {
+	deprecation := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		mount      APIMount
+		module     string
+		ok         bool
+		wantModule string
+		headers    map[string]string
+	}{
+		{
+			name:       "current",
+			mount:      APIMount{Version: "v3"},
+			module:     "users",
+			ok:         true,
+			wantModule: "users",
+			headers:    map[string]string{"Deprecation": "", "Sunset": "", "Link": ""},
+		},
+		{
+			name:       "deprecated without dates",
+			mount:      APIMount{Version: "v1", Deprecated: true},
+			module:     "users",
+			ok:         true,
+			wantModule: "users",
+			headers:    map[string]string{"Deprecation": "true", "Sunset": ""},
+		},
+		{
+			name: "deprecated with dates and link",
+			mount: APIMount{Version: "v1", Deprecated: true, Deprecation: deprecation, Sunset: sunset,
+				Link: "https://docs.example.com/v2", ModuleMap: map[string]string{"users": "users_legacy"}},
+			module:     "users",
+			ok:         true,
+			wantModule: "users_legacy",
+			headers: map[string]string{
+				"Deprecation": "@1735689600",
+				"Sunset":      "Thu, 01 Jan 2026 00:00:00 GMT",
+				"Link":        `<https://docs.example.com/v2>; rel="deprecation"`,
+			},
+		},
+		{
+			name:       "builtin module bypasses the module list",
+			mount:      APIMount{Version: "v1", Modules: []string{"users"}},
+			module:     "batch",
+			ok:         true,
+			wantModule: "batch",
+		},
+		{
+			name:       "module not mounted",
+			mount:      APIMount{Version: "v1", Deprecated: true, Modules: []string{"users"}},
+			module:     "orders",
+			ok:         false,
+			wantModule: "orders",
+			headers:    map[string]string{"Deprecation": "true"},
+		},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/api/"+tt.mount.Version+"/"+tt.module, nil)
+		module := tt.module
+		req := &pb.Request{Module: &module}
+
+		if ok := applyMount(w, r, req, tt.mount); ok != tt.ok {
+			t.Errorf("%s: ok = %v, want %v", tt.name, ok, tt.ok)
+		}
+		if !tt.ok && w.Code != http.StatusNotFound {
+			t.Errorf("%s: status = %d, want 404", tt.name, w.Code)
+		}
+		if *req.Module != tt.wantModule || req.APIVersion == nil || *req.APIVersion != tt.mount.Version {
+			t.Errorf("%s: module = %s, version = %v", tt.name, *req.Module, req.APIVersion)
+		}
+		for k, v := range tt.headers {
+			if got := w.Header().Get(k); got != v {
+				t.Errorf("%s: %s = %q, want %q", tt.name, k, got, v)
+			}
+		}
+	}
+}
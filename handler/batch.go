@@ -0,0 +1,441 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// Batch endpoint: POST /api/<version>/batch fans a list of sub-requests out
+// to modules concurrently and answers with one result per item.
+
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	sf "github.com/gogufo/gufo-api-gateway/gufodao"
+	"github.com/gogufo/gufo-api-gateway/metering"
+	"github.com/gogufo/gufo-api-gateway/middleware"
+	pb "github.com/gogufo/gufo-api-gateway/proto/go"
+	"github.com/gogufo/gufo-api-gateway/registry"
+	"github.com/gogufo/gufo-api-gateway/transport"
+	"github.com/spf13/viper"
+	"google.golang.org/protobuf/proto"
+)
+
+// BatchItem is one sub-request. Args values of the form "${id.path}" are
+// replaced by a field of item id's response data (a dependency).
+type BatchItem struct {
+	ID        string                 `json:"id"`
+	Module    string                 `json:"module"`
+	Param     string                 `json:"param"`
+	ParamID   string                 `json:"paramID"`
+	Method    string                 `json:"method"`
+	Args      map[string]interface{} `json:"args"`
+	DependsOn []string               `json:"depends_on,omitempty"`
+	Timeout   string                 `json:"timeout,omitempty"` // e.g. "2s", capped by batch.max_timeout
+}
+
+// BatchResult is the outcome of one item.
+type BatchResult struct {
+	ID         string                 `json:"id"`
+	Module     string                 `json:"module"`
+	Status     int                    `json:"status"`
+	Data       map[string]interface{} `json:"data,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+	DurationMs int64                  `json:"duration_ms"`
+}
+
+var batchMethods = map[string]bool{
+	http.MethodGet: true, http.MethodPost: true, http.MethodPatch: true, http.MethodDelete: true,
+}
+
+// Batch runs the items with at most batch.concurrency calls in flight.
+// Every item passes the same per-call checks as a REST call (see subCall).
+// Items wait for their dependencies; an item whose dependency failed is
+// answered with 424.
+func Batch(w http.ResponseWriter, r *http.Request, version string) {
+	serveEdge(w, r, version, nil, func(w http.ResponseWriter, r *http.Request, t *pb.Request) int {
+		if !batchEnabled() {
+			errorAnswer(w, r, t, 404, "0000404", "Batch requests are disabled")
+			return http.StatusNotFound
+		}
+
+		items, err := parseBatch(r)
+		if err != nil {
+			status := sf.BodyErrorStatus(err)
+			errorAnswer(w, r, t, status, "0000400", err.Error())
+			return status
+		}
+
+		results := runBatch(r, t, getAPIMount(version), items)
+		moduleAnswerv3(w, r, map[string]interface{}{"results": results}, t)
+		return http.StatusOK
+	})
+}
+
+func batchEnabled() bool {
+	if !viper.IsSet("batch.enabled") {
+		return true
+	}
+	return viper.GetBool("batch.enabled")
+}
+
+// parseBatch reads either a bare JSON array of items or {"requests": [...]}
+// and validates ids, methods and dependencies.
+func parseBatch(r *http.Request) ([]BatchItem, error) {
+	if err := sf.DecodeRequestBody(r); err != nil {
+		return nil, err
+	}
+	raw, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []BatchItem
+	raw = bytes.TrimSpace(raw)
+	if len(raw) > 0 && raw[0] == '[' {
+		err = json.Unmarshal(raw, &items)
+	} else {
+		var env struct {
+			Requests []BatchItem `json:"requests"`
+		}
+		err = json.Unmarshal(raw, &env)
+		items = env.Requests
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid batch body: %w", err)
+	}
+
+	maxItems := viper.GetInt("batch.max_items")
+	if maxItems <= 0 {
+		maxItems = 20
+	}
+	switch {
+	case len(items) == 0:
+		return nil, errors.New("batch is empty")
+	case len(items) > maxItems:
+		return nil, fmt.Errorf("batch has %d items, limit is %d", len(items), maxItems)
+	}
+
+	ids := make(map[string]int, len(items))
+	for i := range items {
+		it := &items[i]
+		if it.ID == "" {
+			it.ID = strconv.Itoa(i)
+		}
+		if _, dup := ids[it.ID]; dup {
+			return nil, fmt.Errorf("duplicate item id %q", it.ID)
+		}
+		ids[it.ID] = i
+
+		it.Method = strings.ToUpper(it.Method)
+		if it.Method == "" {
+			it.Method = http.MethodGet
+		}
+		if !batchMethods[it.Method] {
+			return nil, fmt.Errorf("item %q: method %s is not allowed in a batch", it.ID, it.Method)
+		}
+		if it.Module == "" || builtinModules[it.Module] || it.Module == "entrypoint" {
+			return nil, fmt.Errorf("item %q: invalid module %q", it.ID, it.Module)
+		}
+	}
+
+	for i := range items {
+		it := &items[i]
+		it.DependsOn = append(it.DependsOn, argRefs(it.Args)...)
+		for _, dep := range it.DependsOn {
+			if _, ok := ids[dep]; !ok {
+				return nil, fmt.Errorf("item %q depends on unknown item %q", it.ID, dep)
+			}
+		}
+	}
+	if id := batchCycle(items, ids); id != "" {
+		return nil, fmt.Errorf("dependency cycle at item %q", id)
+	}
+
+	return items, nil
+}
+
+// argRef splits "${id.path}" into id and "$.path".
+func argRef(v interface{}) (id, path string, ok bool) {
+	s, isStr := v.(string)
+	if !isStr || !strings.HasPrefix(s, "${") || !strings.HasSuffix(s, "}") {
+		return "", "", false
+	}
+	ref := s[2 : len(s)-1]
+	if i := strings.IndexAny(ref, ".["); i >= 0 {
+		return ref[:i], "$" + ref[i:], true
+	}
+	return ref, "$", true
+}
+
+func argRefs(args map[string]interface{}) []string {
+	var out []string
+	for _, v := range args {
+		if id, _, ok := argRef(v); ok {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// batchCycle returns the id of an item on a dependency cycle, or "".
+func batchCycle(items []BatchItem, ids map[string]int) string {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(items))
+
+	var visit func(i int) string
+	visit = func(i int) string {
+		switch state[i] {
+		case visiting:
+			return items[i].ID
+		case visited:
+			return ""
+		}
+		state[i] = visiting
+		for _, dep := range items[i].DependsOn {
+			if id := visit(ids[dep]); id != "" {
+				return id
+			}
+		}
+		state[i] = visited
+		return ""
+	}
+
+	for i := range items {
+		if id := visit(i); id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+// runBatch executes the items and returns results in request order.
+func runBatch(r *http.Request, t *pb.Request, m APIMount, items []BatchItem) []BatchResult {
+	limit := viper.GetInt("batch.concurrency")
+	if limit <= 0 {
+		limit = 4
+	}
+	slots := make(chan struct{}, limit)
+
+	results := make([]BatchResult, len(items))
+	done := make(map[string]chan struct{}, len(items))
+	index := make(map[string]int, len(items))
+	for i, it := range items {
+		done[it.ID] = make(chan struct{})
+		index[it.ID] = i
+	}
+
+	ids := &edgeIdentity{}
+	var wg sync.WaitGroup
+	for i := range items {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			it := items[i]
+			defer close(done[it.ID])
+
+			for _, dep := range it.DependsOn {
+				<-done[dep]
+				if res := results[index[dep]]; res.Status >= 400 {
+					results[i] = BatchResult{ID: it.ID, Module: it.Module, Status: http.StatusFailedDependency,
+						Error: fmt.Sprintf("dependency %q failed", dep)}
+					return
+				}
+			}
+
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			results[i] = callBatchItem(r, t, ids, m, it, func(id string) map[string]interface{} {
+				return results[index[id]].Data
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// callBatchItem resolves the arguments of one item and calls it. data
+// returns the response data of a finished dependency.
+func callBatchItem(r *http.Request, t *pb.Request, ids *edgeIdentity, m APIMount, it BatchItem, data func(id string) map[string]interface{}) (res BatchResult) {
+	res = BatchResult{ID: it.ID, Module: it.Module}
+	start := time.Now()
+	defer func() { res.DurationMs = time.Since(start).Milliseconds() }()
+
+	module, ok := mountModule(m, it.Module)
+	if !ok {
+		res.Status, res.Error = 404, fmt.Sprintf("Module is not available in API %s", m.Version)
+		return res
+	}
+	if registry.IsDrained(module) {
+		res.Status, res.Error = 503, "Module is drained"
+		return res
+	}
+	if registry.IsInternal(module) {
+		res.Status, res.Error = 404, "Module not found"
+		return res
+	}
+
+	// resolve "${id.path}" references
+	args := make(map[string]interface{}, len(it.Args))
+	for k, v := range it.Args {
+		if id, path, ok := argRef(v); ok {
+			val, found := jsonPath(data(id), path)
+			if !found {
+				res.Status, res.Error = 400, fmt.Sprintf("arg %q: %s not found in %q", k, path, id)
+				return res
+			}
+			v = val
+		}
+		args[k] = v
+	}
+
+	req := batchRequest(t, module, it, args)
+	rule := matchRoute(it.Method, it.Module, it.Param)
+	res.Status, res.Data, res.Error = subCall(r, ids, req, rule, batchTimeout(it.Timeout))
+	return res
+}
+
+// batchRequest builds the upstream request of an item, carrying over the
+// caller's client fields. Session fields are set by subCall.
+func batchRequest(t *pb.Request, module string, it BatchItem, args map[string]interface{}) *pb.Request {
+	path := fmt.Sprintf("/api/%s/%s", t.GetAPIVersion(), module)
+	req := &pb.Request{
+		Module:     &module,
+		Method:     &it.Method,
+		Args:       sf.ToMapStringAny(args),
+		APIVersion: t.APIVersion,
+		IP:         t.IP,
+		UserAgent:  t.UserAgent,
+		Language:   t.Language,
+		Sign:       t.Sign,
+	}
+	if it.Param != "" {
+		param := it.Param
+		req.Param = &param
+		path += "/" + param
+	}
+	if it.ParamID != "" {
+		paramID := it.ParamID
+		req.ParamID = &paramID
+		path += "/" + paramID
+	}
+	req.Path = &path
+	return req
+}
+
+// subCall makes one module call of a batch or GraphQL request. It applies
+// the per-call part of the REST pipeline: global middleware (rate limit),
+// the route's transform rule (may be nil), edge authentication for the
+// module, the read-only check, the usage quota and usage metering. It
+// returns the HTTP status, the response data and, for failures, a message.
+func subCall(r *http.Request, ids *edgeIdentity, req *pb.Request, rule *TransformRule, timeout time.Duration) (int, map[string]interface{}, string) {
+	module, method := req.GetModule(), req.GetMethod()
+
+	ctx, rec := metering.WithRecord(r.Context())
+	ctx, err := middleware.RunBefore(r, ctx)
+	if err != nil {
+		return 429, nil, err.Error()
+	}
+
+	// headers are rewritten on a copy; the other items see the original
+	if rule != nil {
+		r = r.Clone(withTransform(ctx, rule))
+		transformHeaders(r, rule)
+	} else {
+		r = r.WithContext(ctx)
+	}
+
+	r, fail := edgeAuth(r, req, ids)
+	if fail != nil {
+		return fail.status, nil, fail.msg
+	}
+	// like ProcessREQ: a session, if any, is passed on in anonymous mode too
+	if req.UID == nil {
+		if id := ids.lookup("session", r, req); id != nil {
+			applyIdentity(req, id)
+		}
+	}
+	if req.UID != nil && req.Readonly != nil && *req.Readonly == int32(1) {
+		return 401, nil, "Read Only User"
+	}
+	transformArgs(r, req)
+	if fail := quotaFailure(r, req); fail != nil {
+		return fail.status, nil, fail.msg
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	resp, err := transport.Get().Call(ctx, module, method, req)
+	if err != nil {
+		ObserveUpstream(module, "", "error", start)
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return 504, nil, err.Error()
+		}
+		return 502, nil, err.Error()
+	}
+	rec.Forwarded = true
+	metering.Track(rec, int64(proto.Size(req)), int64(proto.Size(resp)))
+
+	status := upstreamStatus(resp)
+	ObserveUpstream(module, "", status, start)
+
+	code, _ := strconv.Atoi(status)
+	if code == 0 {
+		code = 200
+	}
+	data := sf.ToMapStringInterface(resp.Data)
+	delete(data, "httpcode")
+	if rule != nil && code < 400 {
+		if data == nil {
+			data = map[string]interface{}{}
+		}
+		data = transformData(rule, data)
+	}
+	return code, data, ""
+}
+
+// batchTimeout is the item's timeout, else batch.timeout (default 5s),
+// capped by batch.max_timeout (default 30s).
+func batchTimeout(s string) time.Duration {
+	d := viper.GetDuration("batch.timeout")
+	if d <= 0 {
+		d = 5 * time.Second
+	}
+	if v, err := time.ParseDuration(s); err == nil && v > 0 {
+		d = v
+	}
+	limit := viper.GetDuration("batch.max_timeout")
+	if limit <= 0 {
+		limit = 30 * time.Second
+	}
+	return min(d, limit)
+}
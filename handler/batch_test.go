@@ -0,0 +1,248 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	sf "github.com/gogufo/gufo-api-gateway/gufodao"
+	pb "github.com/gogufo/gufo-api-gateway/proto/go"
+)
+
+func TestParseBatch(t *testing.T) {
+	setConfig(t, map[string]interface{}{"batch.max_items": 3})
+
+	tests := []struct {
+		name string
+		body string
+		err  string // substring; "" = valid
+		want []BatchItem
+	}{
+		{"bare array", `[{"module":"users"},{"id":"o","module":"orders","method":"post"}]`, "", []BatchItem{
+			{ID: "0", Module: "users", Method: "GET"},
+			{ID: "o", Module: "orders", Method: "POST"},
+		}},
+		{"envelope", `{"requests":[{"id":"a","module":"users","param":"get"}]}`, "", []BatchItem{
+			{ID: "a", Module: "users", Param: "get", Method: "GET"},
+		}},
+		{"reference adds a dependency", `[{"id":"a","module":"users"},{"id":"b","module":"orders","args":{"uid":"${a.user.id}"}}]`, "", []BatchItem{
+			{ID: "a", Module: "users", Method: "GET"},
+			{ID: "b", Module: "orders", Method: "GET", Args: map[string]interface{}{"uid": "${a.user.id}"}, DependsOn: []string{"a"}},
+		}},
+		{"not JSON", `{`, "invalid batch body", nil},
+		{"empty", `[]`, "batch is empty", nil},
+		{"too many", `[{"module":"a"},{"module":"b"},{"module":"c"},{"module":"d"}]`, "limit is 3", nil},
+		{"duplicate id", `[{"id":"a","module":"users"},{"id":"a","module":"orders"}]`, "duplicate item id", nil},
+		{"method", `[{"module":"users","method":"PUT"}]`, "not allowed", nil},
+		{"no module", `[{"id":"a"}]`, "invalid module", nil},
+		{"builtin module", `[{"module":"batch"}]`, "invalid module", nil},
+		{"unknown dependency", `[{"id":"a","module":"users","depends_on":["z"]}]`, "unknown item", nil},
+		{"unknown reference", `[{"id":"a","module":"users","args":{"x":"${z.id}"}}]`, "unknown item", nil},
+		{"cycle", `[{"id":"a","module":"users","depends_on":["b"]},{"id":"b","module":"orders","args":{"x":"${a}"}}]`, "dependency cycle", nil},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/api/v3/batch", strings.NewReader(tt.body))
+		items, err := parseBatch(r)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: err = %v, want %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		got, _ := json.Marshal(items)
+		want, _ := json.Marshal(tt.want)
+		if string(got) != string(want) {
+			t.Errorf("%s:\n got %s\nwant %s", tt.name, got, want)
+		}
+	}
+}
+
+func TestArgRef(t *testing.T) {
+	tests := []struct {
+		in       interface{}
+		id, path string
+		ok       bool
+	}{
+		{"${a}", "a", "$", true},
+		{"${a.user.id}", "a", "$.user.id", true},
+		{"${a[0].id}", "a", "$[0].id", true},
+		{"${a", "", "", false},
+		{"plain", "", "", false},
+		{42, "", "", false},
+	}
+	for _, tt := range tests {
+		id, path, ok := argRef(tt.in)
+		if id != tt.id || path != tt.path || ok != tt.ok {
+			t.Errorf("argRef(%v) = %q, %q, %v", tt.in, id, path, ok)
+		}
+	}
+}
+
+func TestBatchTimeout(t *testing.T) {
+	tests := []struct {
+		name       string
+		def, limit string
+		item       string
+		want       time.Duration
+	}{
+		{"defaults", "", "", "", 5 * time.Second},
+		{"configured", "2s", "", "", 2 * time.Second},
+		{"item", "2s", "", "3s", 3 * time.Second},
+		{"invalid item", "2s", "", "soon", 2 * time.Second},
+		{"capped", "", "10s", "1m", 10 * time.Second},
+		{"default cap", "", "", "1h", 30 * time.Second},
+	}
+	for _, tt := range tests {
+		setConfig(t, map[string]interface{}{"batch.timeout": tt.def, "batch.max_timeout": tt.limit})
+		if got := batchTimeout(tt.item); got != tt.want {
+			t.Errorf("%s: %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// batchResults posts body to the batch endpoint and decodes the results.
+func batchResults(t *testing.T, body string) (int, []BatchResult) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/api/v3/batch", strings.NewReader(body))
+	Batch(w, r, "v3")
+
+	var resp struct {
+		Data struct {
+			Results []BatchResult `json:"results"`
+		} `json:"data"`
+	}
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode %s: %v", w.Body, err)
+		}
+	}
+	return w.Code, resp.Data.Results
+}
+
+func TestBatch(t *testing.T) {
+	setConfig(t, map[string]interface{}{"security.edge_mode": "anonymous"})
+	f := &fakeTransport{answer: func(module string, req *pb.Request) (map[string]interface{}, error) {
+		args := sf.ToMapStringInterface(req.Args)
+		switch module {
+		case "users":
+			return map[string]interface{}{"user": map[string]interface{}{"id": "u7"}}, nil
+		case "orders":
+			return map[string]interface{}{"owner": args["owner"], "param": req.GetParam()}, nil
+		case "missing":
+			return map[string]interface{}{"httpcode": 404, "message": "no such thing"}, nil
+		}
+		return nil, errors.New("module unreachable")
+	}}
+	useTransport(t, f)
+
+	status, results := batchResults(t, `{"requests":[
+		{"id":"orders","module":"orders","param":"list","args":{"owner":"${user.user.id}"}},
+		{"id":"user","module":"users"},
+		{"id":"gone","module":"missing"},
+		{"id":"after-gone","module":"orders","depends_on":["gone"]},
+		{"id":"down","module":"billing"},
+		{"id":"bad-ref","module":"orders","args":{"owner":"${user.user.name}"}}
+	]}`)
+	if status != http.StatusOK {
+		t.Fatalf("status %d", status)
+	}
+
+	want := []struct {
+		id     string
+		status int
+		data   map[string]interface{}
+	}{
+		{"orders", 200, map[string]interface{}{"owner": "u7", "param": "list"}},
+		{"user", 200, map[string]interface{}{"user": map[string]interface{}{"id": "u7"}}},
+		{"gone", 404, nil},
+		{"after-gone", http.StatusFailedDependency, nil},
+		{"down", http.StatusBadGateway, nil},
+		{"bad-ref", 400, nil},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("%d results: %+v", len(results), results)
+	}
+	for i, w := range want {
+		res := results[i]
+		if res.ID != w.id || res.Status != w.status {
+			t.Errorf("result %d = %s/%d (%s), want %s/%d", i, res.ID, res.Status, res.Error, w.id, w.status)
+		}
+		if w.data != nil && fmt.Sprint(res.Data) != fmt.Sprint(w.data) {
+			t.Errorf("%s: data %v, want %v", w.id, res.Data, w.data)
+		}
+		if w.status >= 400 && res.Error == "" && w.id != "gone" {
+			t.Errorf("%s: no error message", w.id)
+		}
+	}
+	// "after-gone" and "bad-ref" never reached a module
+	if f.count() != 4 {
+		t.Errorf("%d module calls, want 4", f.count())
+	}
+}
+
+func TestBatchConcurrencyLimit(t *testing.T) {
+	setConfig(t, map[string]interface{}{"security.edge_mode": "anonymous", "batch.concurrency": 2})
+	var inFlight, peak atomic.Int32
+	useTransport(t, &fakeTransport{answer: func(string, *pb.Request) (map[string]interface{}, error) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		return map[string]interface{}{}, nil
+	}})
+
+	status, results := batchResults(t, `[{"module":"a"},{"module":"b"},{"module":"c"},{"module":"d"},{"module":"e"}]`)
+	if status != http.StatusOK || len(results) != 5 {
+		t.Fatalf("status %d, %d results", status, len(results))
+	}
+	if p := peak.Load(); p != 2 {
+		t.Fatalf("peak concurrency %d, want 2", p)
+	}
+}
+
+func TestBatchRejectsInvalidBody(t *testing.T) {
+	setConfig(t, map[string]interface{}{"security.edge_mode": "anonymous"})
+	useTransport(t, &fakeTransport{answer: func(string, *pb.Request) (map[string]interface{}, error) {
+		return map[string]interface{}{}, nil
+	}})
+
+	if status, _ := batchResults(t, `[]`); status != http.StatusBadRequest {
+		t.Fatalf("empty batch: status %d", status)
+	}
+	setConfig(t, map[string]interface{}{"batch.enabled": false})
+	if status, _ := batchResults(t, `[{"module":"a"}]`); status != http.StatusNotFound {
+		t.Fatalf("disabled: status %d", status)
+	}
+}
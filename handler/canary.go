@@ -0,0 +1,121 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// Canary and header-based routing between module versions
+
+package handler
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"net/http"
+	"strings"
+
+	pb "github.com/gogufo/gufo-api-gateway/proto/go"
+	"github.com/gogufo/gufo-api-gateway/registry"
+	"github.com/spf13/viper"
+)
+
+// VersionHeader pins a module version per request and reports the version
+// that served it.
+const VersionHeader = "X-Gufo-Version"
+
+// pickVersion selects the module version for this request from
+// microservices.<module>.routing. It returns "" when the module has no
+// versioned endpoints. Precedence: header, cookie, weights (UID-sticky or
+// random), routing.default.
+func pickVersion(w http.ResponseWriter, r *http.Request, t *pb.Request) string {
+	module := *t.Module
+	versions := registry.Versions(module)
+	if len(versions) == 0 {
+		return ""
+	}
+
+	prefix := fmt.Sprintf("microservices.%s.routing.", module)
+
+	// 1️⃣ Explicit header
+	header := viper.GetString(prefix + "header")
+	if header == "" {
+		header = VersionHeader
+	}
+	if v := r.Header.Get(header); v != "" && registry.HasVersion(module, v) {
+		return strings.ToLower(v)
+	}
+
+	// 2️⃣ Sticky cookie from an earlier split
+	cookie := viper.GetString(prefix + "cookie")
+	if cookie != "" {
+		if c, err := r.Cookie(cookie); err == nil && registry.HasVersion(module, c.Value) {
+			return strings.ToLower(c.Value)
+		}
+	}
+
+	// 3️⃣ Weighted split
+	version := weightedVersion(module, prefix, versions, t)
+	if version == "" {
+		version = strings.ToLower(viper.GetString(prefix + "default"))
+	}
+
+	if cookie != "" && version != "" {
+		maxAge := viper.GetInt(prefix + "cookie_max_age")
+		if maxAge == 0 {
+			maxAge = 86400
+		}
+		http.SetCookie(w, &http.Cookie{
+			Name:     cookie,
+			Value:    version,
+			Path:     "/",
+			MaxAge:   maxAge,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+
+	return version
+}
+
+// weightedVersion picks a version by routing.weights. With routing.sticky
+// the point on the scale is derived from the UID, so a user keeps seeing
+// the same version for as long as the weights do not change.
+func weightedVersion(module, prefix string, versions []string, t *pb.Request) string {
+	var total float64
+	weights := make([]float64, len(versions))
+	for i, v := range versions {
+		weights[i] = viper.GetFloat64(prefix + "weights." + v)
+		total += weights[i]
+	}
+	if total <= 0 {
+		return ""
+	}
+
+	var point float64
+	if viper.GetBool(prefix+"sticky") && t.UID != nil && *t.UID != "" {
+		h := fnv.New32a()
+		h.Write([]byte(module + ":" + *t.UID))
+		point = float64(h.Sum32()%10000) / 10000 * total
+	} else {
+		point = rand.Float64() * total
+	}
+
+	for i, v := range versions {
+		if point < weights[i] {
+			return v
+		}
+		point -= weights[i]
+	}
+	return versions[len(versions)-1]
+}
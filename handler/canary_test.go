@@ -0,0 +1,129 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	pb "github.com/gogufo/gufo-api-gateway/proto/go"
+)
+
+func TestPickVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		routing map[string]interface{} // under microservices.<module>.routing
+		header  map[string]string
+		cookie  *http.Cookie
+		uid     string
+		want    string
+	}{
+		{"default header pins", nil, map[string]string{VersionHeader: "V2"}, nil, "", "v2"},
+		{"custom header", map[string]interface{}{"header": "X-Track"}, map[string]string{"X-Track": "v2"}, nil, "", "v2"},
+		{"unknown header version ignored", map[string]interface{}{"default": "v1"}, map[string]string{VersionHeader: "v9"}, nil, "", "v1"},
+		{"cookie", map[string]interface{}{"cookie": "track", "weights.v1": 100}, nil, &http.Cookie{Name: "track", Value: "v2"}, "", "v2"},
+		{"header beats cookie", map[string]interface{}{"cookie": "track"}, map[string]string{VersionHeader: "v1"}, &http.Cookie{Name: "track", Value: "v2"}, "", "v1"},
+		{"weights", map[string]interface{}{"weights.v1": 0, "weights.v2": 100}, nil, nil, "", "v2"},
+		{"default without weights", map[string]interface{}{"default": "V1"}, nil, nil, "", "v1"},
+		{"nothing configured", nil, nil, nil, "", ""},
+	}
+	for i, tt := range tests {
+		module := fmt.Sprintf("canary%d", i)
+		cfg := map[string]interface{}{
+			"microservices." + module + ".versions.v1.host": "10.0.0.1",
+			"microservices." + module + ".versions.v2.host": "10.0.0.2",
+		}
+		for k, v := range tt.routing {
+			cfg["microservices."+module+".routing."+k] = v
+		}
+		setConfig(t, cfg)
+
+		r := httptest.NewRequest(http.MethodGet, "/api/v3/"+module, nil)
+		for k, v := range tt.header {
+			r.Header.Set(k, v)
+		}
+		if tt.cookie != nil {
+			r.AddCookie(tt.cookie)
+		}
+		req := &pb.Request{Module: &module}
+		if tt.uid != "" {
+			req.UID = &tt.uid
+		}
+		if got := pickVersion(httptest.NewRecorder(), r, req); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPickVersionUnversionedModule(t *testing.T) {
+	module := "plain"
+	r := httptest.NewRequest(http.MethodGet, "/api/v3/plain", nil)
+	r.Header.Set(VersionHeader, "v2")
+	if got := pickVersion(httptest.NewRecorder(), r, &pb.Request{Module: &module}); got != "" {
+		t.Fatalf("got %q for a module without versions", got)
+	}
+}
+
+func TestPickVersionSetsStickyCookie(t *testing.T) {
+	module := "canarycookie"
+	setConfig(t, map[string]interface{}{
+		"microservices.canarycookie.versions.v1.host":       "10.0.0.1",
+		"microservices.canarycookie.routing.cookie":         "track",
+		"microservices.canarycookie.routing.weights.v1":     1,
+		"microservices.canarycookie.routing.cookie_max_age": 60,
+	})
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/v3/canarycookie", nil)
+	if got := pickVersion(w, r, &pb.Request{Module: &module}); got != "v1" {
+		t.Fatalf("got %q", got)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "track" || cookies[0].Value != "v1" || cookies[0].MaxAge != 60 || !cookies[0].HttpOnly {
+		t.Fatalf("cookie = %+v", cookies)
+	}
+}
+
+// With routing.sticky a UID always lands on the same version, and the split
+// over many UIDs follows the weights.
+func TestWeightedVersionStickyUID(t *testing.T) {
+	prefix := "microservices.canarysticky.routing."
+	setConfig(t, map[string]interface{}{
+		prefix + "sticky":     true,
+		prefix + "weights.v1": 80,
+		prefix + "weights.v2": 20,
+	})
+	versions := []string{"v1", "v2"}
+
+	counts := map[string]int{}
+	for i := range 1000 {
+		uid := fmt.Sprintf("user-%d", i)
+		req := &pb.Request{UID: &uid}
+		first := weightedVersion("canarysticky", prefix, versions, req)
+		for range 3 {
+			if again := weightedVersion("canarysticky", prefix, versions, req); again != first {
+				t.Fatalf("uid %s moved from %s to %s", uid, first, again)
+			}
+		}
+		counts[first]++
+	}
+	if counts["v1"] < 700 || counts["v1"] > 900 {
+		t.Fatalf("split = %v, want about 800/200", counts)
+	}
+}
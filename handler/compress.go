@@ -0,0 +1,258 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// Response compression negotiated via Accept-Encoding (br, zstd, gzip, deflate)
+
+package handler
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/spf13/viper"
+)
+
+var (
+	// defaultEncodings is the server preference when q-values tie.
+	defaultEncodings = []string{"br", "zstd", "gzip", "deflate"}
+
+	// defaultCompressTypes are compressed unless compression.types is set.
+	defaultCompressTypes = []string{
+		"application/json",
+		"application/msgpack",
+		"application/cbor",
+		"application/x-protobuf",
+		"application/xml",
+		"application/javascript",
+		"image/svg+xml",
+		"text/*",
+	}
+)
+
+const defaultCompressMinSize = 1024
+
+// compressionEnabled reports whether responses on the route of r may be
+// compressed (compression.enabled, compression.exclude = ["module/param"]).
+func compressionEnabled(r *http.Request) bool {
+	if !viper.GetBool("compression.enabled") {
+		return false
+	}
+	if r.Header.Get("Range") != "" {
+		return false
+	}
+
+	parts := strings.Split(r.URL.Path, "/")
+	module, param := "", ""
+	if len(parts) > 3 {
+		module = parts[3]
+	}
+	if len(parts) > 4 {
+		param = parts[4]
+	}
+	for _, pattern := range viper.GetStringSlice("compression.exclude") {
+		if routeMatches(strings.TrimSpace(pattern), module, param) {
+			return false
+		}
+	}
+	return true
+}
+
+// negotiateEncoding picks the best encoding from Accept-Encoding among
+// compression.algorithms; ties go to the server's order.
+func negotiateEncoding(header string) string {
+	algos := viper.GetStringSlice("compression.algorithms")
+	if len(algos) == 0 {
+		algos = defaultEncodings
+	}
+
+	accepted := map[string]float64{}
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			q, _ = strconv.ParseFloat(v, 64)
+		}
+		accepted[strings.ToLower(strings.TrimSpace(name))] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, a := range algos {
+		q, ok := accepted[a]
+		if !ok {
+			q, ok = accepted["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = a, q
+		}
+	}
+	return best
+}
+
+// compressible reports whether a response Content-Type is in the allow-list.
+func compressible(contentType string) bool {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	types := viper.GetStringSlice("compression.types")
+	if len(types) == 0 {
+		types = defaultCompressTypes
+	}
+	for _, t := range types {
+		if t == mt || strings.HasSuffix(t, "/*") && strings.HasPrefix(mt, strings.TrimSuffix(t, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// compressWriter buffers the first compression.min_size bytes, then decides
+// whether to compress based on size, status and Content-Type.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	minSize  int
+
+	status  int
+	buf     []byte
+	decided bool
+	enc     io.WriteCloser
+}
+
+// newCompressWriter wraps w when r accepts a supported encoding; otherwise
+// it returns w unchanged. Call finish on the result after the handler.
+func newCompressWriter(w http.ResponseWriter, r *http.Request) http.ResponseWriter {
+	if !compressionEnabled(r) {
+		return w
+	}
+	enc := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+	if enc == "" {
+		return w
+	}
+	minSize := viper.GetInt("compression.min_size")
+	if minSize <= 0 {
+		minSize = defaultCompressMinSize
+	}
+	w.Header().Add("Vary", "Accept-Encoding")
+	return &compressWriter{ResponseWriter: w, encoding: enc, minSize: minSize, status: http.StatusOK}
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.decided {
+		return
+	}
+	cw.status = code
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if cw.decided {
+		if cw.enc != nil {
+			return cw.enc.Write(b)
+		}
+		return cw.ResponseWriter.Write(b)
+	}
+	cw.buf = append(cw.buf, b...)
+	if len(cw.buf) >= cw.minSize {
+		if err := cw.decide(); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// Flush sends what has been buffered so far (streamed answers).
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		cw.decide()
+	}
+	if f, ok := cw.enc.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (cw *compressWriter) decide() error {
+	cw.decided = true
+	h := cw.Header()
+
+	compress := len(cw.buf) >= cw.minSize &&
+		cw.status != http.StatusNoContent &&
+		cw.status != http.StatusNotModified &&
+		cw.status != http.StatusPartialContent &&
+		h.Get("Content-Encoding") == "" &&
+		compressible(h.Get("Content-Type"))
+
+	if compress {
+		h.Set("Content-Encoding", cw.encoding)
+		h.Del("Content-Length")
+		cw.enc = newEncoder(cw.encoding, cw.ResponseWriter)
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if cw.enc != nil {
+		_, err = cw.enc.Write(buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// finish flushes buffered data and closes the encoder.
+func (cw *compressWriter) finish() {
+	if !cw.decided {
+		cw.decide()
+	}
+	if cw.enc != nil {
+		cw.enc.Close()
+	}
+}
+
+// finishCompression completes w if it is a compressWriter.
+func finishCompression(w http.ResponseWriter) {
+	if cw, ok := w.(*compressWriter); ok {
+		cw.finish()
+	}
+}
+
+func newEncoder(encoding string, w io.Writer) io.WriteCloser {
+	switch encoding {
+	case "br":
+		return brotli.NewWriterLevel(w, brotli.DefaultCompression)
+	case "zstd":
+		zw, _ := zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.SpeedDefault))
+		return zw
+	case "deflate":
+		// HTTP "deflate" is the zlib format (RFC 9110)
+		return zlib.NewWriter(w)
+	}
+	return gzip.NewWriter(w)
+}
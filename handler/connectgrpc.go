@@ -138,16 +138,84 @@ func GetHostAndPort(t *pb.Request) (host string, port string, plygintype string)
 
 func connectgrpc(w http.ResponseWriter, r *http.Request, t *pb.Request) {
 
-	// 1️⃣ Internal signature check (optional)
-	if r.Header.Get("X-Sign") != "" {
-		sign := r.Header.Get("X-Sign")
-		expected := viper.GetString("server.sign")
-		if sign != expected {
-			errorAnswer(w, r, t, 401, "0000234", "Invalid internal signature")
+	// Drained modules are out of rotation (see admin API)
+	if registry.IsDrained(*t.Module) {
+		errorAnswer(w, r, t, 503, "0000503", "Module is drained")
+		return
+	}
+
+	// Self-registered internal services are reachable over gRPC only
+	if registry.IsInternal(*t.Module) {
+		errorAnswer(w, r, t, 404, "0000404", "Module not found")
+		return
+	}
+
+	// ------------------------------------------------------------
+	// Version routing (canary / header / sticky split)
+	// ------------------------------------------------------------
+	ctx := r.Context()
+	version := pickVersion(w, r, t)
+
+	var info registry.ServiceInfo
+	if version != "" {
+		vinfo, err := registry.GetServiceVersion(*t.Module, version)
+		if err != nil {
+			errorAnswer(w, r, t, 500, "0000501", err.Error())
+			return
+		}
+		info = vinfo
+		ctx = transport.WithEndpoint(ctx, transport.Endpoint{Host: info.Host, Port: info.Port, Version: version})
+		w.Header().Set(VersionHeader, version)
+	} else {
+		info = resolveEndpoint(w, r, t)
+		if info.Host == "" {
 			return
 		}
 	}
 
+	sf.SetDebugLog(fmt.Sprintf("route %s %s → %s:%s (version %q)", r.Method, *t.Module, info.Host, info.Port, version))
+
+	// ------------------------------------------------------------
+	// 2️⃣ Streaming uploads (PUT)
+	// ------------------------------------------------------------
+	if r.Method == http.MethodPut {
+		ans := sf.GRPCStreamPut(info.Host, info.Port, r, t)
+		if ans["httpcode"] == 200 {
+			markForwarded(ctx)
+		}
+		moduleAnswerv3(w, r, ans, t)
+		return
+	}
+
+	// ------------------------------------------------------------
+	// 3️⃣ Standard transport call
+	// ------------------------------------------------------------
+	// async routes (microservices.<module>.transport = "mq") answer 202 + job ID
+	tr := transport.For(*t.Module, t.GetParam())
+	start := time.Now()
+
+	resp, err := tr.Call(ctx, *t.Module, r.Method, t)
+	if err != nil {
+		ObserveUpstream(*t.Module, version, "error", start)
+		errorAnswer(w, r, t, 500, "0000500", err.Error())
+		return
+	}
+	ObserveUpstream(*t.Module, version, upstreamStatus(resp), start)
+	markForwarded(ctx)
+
+	// Shadow a sample of traffic to microservices.<module>.mirror (async);
+	// queued calls have no answer to compare
+	if !transport.Async(*t.Module, t.GetParam()) {
+		mirror(ctx, r.Method, t, resp)
+	}
+
+	moduleAnswerv3(w, r, sf.ToMapStringInterface(resp.Data), t)
+}
+
+// resolveEndpoint finds the unversioned module endpoint. On failure it
+// writes the error answer and returns an empty ServiceInfo.
+func resolveEndpoint(w http.ResponseWriter, r *http.Request, t *pb.Request) registry.ServiceInfo {
+
 	// ------------------------------------------------------------
 	// Resolve service from registry, fallback to GetHostAndPort
 	// ------------------------------------------------------------
@@ -158,7 +226,7 @@ func connectgrpc(w http.ResponseWriter, r *http.Request, t *pb.Request) {
 
 		if host == "" || port == "" {
 			errorAnswer(w, r, t, 500, "0000501", "Cannot resolve service: registry and masterservice unavailable")
-			return
+			return registry.ServiceInfo{}
 		}
 
 		// Create local info object WITHOUT touching registry internals
@@ -178,28 +246,17 @@ func connectgrpc(w http.ResponseWriter, r *http.Request, t *pb.Request) {
 		}
 	}
 
-	// ------------------------------------------------------------
-	// 2️⃣ Streaming uploads (PUT)
-	// ------------------------------------------------------------
-	if r.Method == http.MethodPut {
-		ans := sf.GRPCStreamPut(info.Host, info.Port, r, t)
-		moduleAnswerv3(w, r, ans, t)
-		return
-	}
-
-	// ------------------------------------------------------------
-	// 3️⃣ Standard transport call
-	// ------------------------------------------------------------
-	tr := transport.Get()
-	ctx := r.Context()
+	return info
+}
 
-	resp, err := tr.Call(ctx, *t.Module, r.Method, t)
-	if err != nil {
-		errorAnswer(w, r, t, 500, "0000500", err.Error())
-		return
+// upstreamStatus returns the module's httpcode (200 when it sent none).
+func upstreamStatus(resp *pb.Response) string {
+	if code, ok := resp.Data["httpcode"]; ok {
+		if v, err := sf.ConvertAnyToInterface(code); err == nil && v != nil {
+			return fmt.Sprint(v)
+		}
 	}
-
-	moduleAnswerv3(w, r, sf.ToMapStringInterface(resp.Data), t)
+	return "200"
 }
 
 func (d *uploader) Stop() {
@@ -0,0 +1,326 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// GraphQL facade: a schema assembled from per-module SDL fragments whose root
+// fields resolve to module calls (see graphqlexec.go).
+
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	sf "github.com/gogufo/gufo-api-gateway/gufodao"
+	pb "github.com/gogufo/gufo-api-gateway/proto/go"
+	"github.com/gogufo/gufo-api-gateway/transport"
+	"github.com/spf13/viper"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/formatter"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"github.com/vektah/gqlparser/v2/validator"
+)
+
+// gqlBase declares the binding directive and the root types the module
+// fragments extend:
+//
+//	extend type Query { user(id: ID!): User @gufo(param: "get", paramID: "id") }
+const gqlBase = `
+"Binds a root field to a module call. module defaults to the fragment's module, param to the field name."
+directive @gufo(module: String, param: String, paramID: String, method: String, path: String) on FIELD_DEFINITION
+
+type Query
+`
+
+var gqlExtendsMutation = regexp.MustCompile(`extend\s+type\s+Mutation\b`)
+
+var gqlCache struct {
+	sync.Mutex
+	schema   *ast.Schema
+	loaded   time.Time
+	err      error         // of the last build
+	building chan struct{} // closed when the running build ends
+}
+
+// gqlRequest is a GraphQL-over-HTTP request.
+type gqlRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// gqlResponse is the GraphQL result; it is not wrapped in the gateway envelope.
+type gqlResponse struct {
+	Data   interface{}   `json:"data"`
+	Errors gqlerror.List `json:"errors,omitempty"`
+}
+
+// GraphQL serves /api/<version>/graphql (GET and POST) and
+// /api/<version>/graphql/schema inside the public pipeline (see serveEdge).
+// The endpoint itself is authenticated and metered as module "graphql";
+// each root field is then a module call of its own (see subCall).
+func GraphQL(w http.ResponseWriter, r *http.Request, version string) {
+	serveEdge(w, r, version, nil, func(w http.ResponseWriter, r *http.Request, t *pb.Request) int {
+		if !viper.GetBool("graphql.enabled") {
+			errorAnswer(w, r, t, 404, "0000404", "GraphQL is disabled")
+			return http.StatusNotFound
+		}
+
+		// 🔐 Schema, introspection and queries need the "graphql" edge mode
+		module := "graphql"
+		t.Module = &module
+		ids := &edgeIdentity{}
+		r, fail := edgeAuth(r, t, ids)
+		if fail != nil {
+			errorAnswer(w, r, t, fail.status, fail.code, fail.msg)
+			return fail.status
+		}
+		if t.UID != nil && t.Readonly != nil && *t.Readonly == int32(1) {
+			errorAnswer(w, r, t, 401, "0000235", "Read Only User")
+			return http.StatusUnauthorized
+		}
+		if fail := quotaFailure(r, t); fail != nil {
+			errorAnswer(w, r, t, fail.status, fail.code, fail.msg)
+			return fail.status
+		}
+
+		schema, err := graphQLSchema(r.Context(), false)
+		if err != nil {
+			errorAnswer(w, r, t, 503, "0000503", err.Error())
+			return http.StatusServiceUnavailable
+		}
+
+		// SDL of the assembled schema for tooling
+		if r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/schema") {
+			w.Header().Set("Content-Type", "application/graphql; charset=utf-8")
+			formatter.NewFormatter(w).FormatSchema(schema)
+			return http.StatusOK
+		}
+
+		req, err := parseGraphQLRequest(r)
+		if err != nil {
+			return writeGraphQL(w, sf.BodyErrorStatus(err), gqlResponse{Errors: gqlerror.List{gqlerror.Errorf("%s", err.Error())}})
+		}
+
+		doc, errs := gqlparser.LoadQuery(schema, req.Query)
+		if len(errs) > 0 {
+			return writeGraphQL(w, http.StatusBadRequest, gqlResponse{Errors: errs})
+		}
+
+		op := doc.Operations.ForName(req.OperationName)
+		if op == nil {
+			return writeGraphQL(w, http.StatusBadRequest, gqlResponse{Errors: gqlerror.List{gqlerror.Errorf("operation %q not found", req.OperationName)}})
+		}
+		if op.Operation != ast.Query && r.Method != http.MethodPost {
+			return writeGraphQL(w, http.StatusMethodNotAllowed, gqlResponse{Errors: gqlerror.List{gqlerror.Errorf("%s operations require POST", op.Operation)}})
+		}
+
+		if req.Variables == nil {
+			req.Variables = map[string]interface{}{}
+		}
+		vars, err := validator.VariableValues(schema, op, req.Variables)
+		if err != nil {
+			return writeGraphQL(w, http.StatusBadRequest, gqlResponse{Errors: gqlerror.List{gqlerror.WrapIfUnwrapped(err)}})
+		}
+
+		e := newGQLExec(r, t, ids, getAPIMount(version), schema, doc, vars)
+		markForwarded(r.Context())
+		return writeGraphQL(w, http.StatusOK, e.execute(op))
+	})
+}
+
+// parseGraphQLRequest reads GET query parameters, a JSON body or an
+// application/graphql body.
+func parseGraphQLRequest(r *http.Request) (gqlRequest, error) {
+	var req gqlRequest
+
+	if r.Method == http.MethodGet {
+		q := r.URL.Query()
+		req.Query = q.Get("query")
+		req.OperationName = q.Get("operationName")
+		if v := q.Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				return req, fmt.Errorf("invalid variables: %w", err)
+			}
+		}
+	} else {
+		if err := sf.DecodeRequestBody(r); err != nil {
+			return req, err
+		}
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "application/graphql") {
+			b, err := io.ReadAll(r.Body)
+			if err != nil {
+				return req, err
+			}
+			req.Query = string(b)
+		} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return req, fmt.Errorf("invalid GraphQL request: %w", err)
+		}
+	}
+
+	if strings.TrimSpace(req.Query) == "" {
+		return req, errors.New("query is required")
+	}
+	return req, nil
+}
+
+// writeGraphQL writes resp and returns the status it was sent with.
+func writeGraphQL(w http.ResponseWriter, status int, resp gqlResponse) int {
+	b, err := json.Marshal(resp)
+	if err != nil {
+		sf.SetErrorLog("graphql: " + err.Error())
+		status, b = http.StatusInternalServerError, []byte(`{"data":null,"errors":[{"message":"internal error"}]}`)
+	}
+	for i := 0; i < len(HeaderKeys); i++ {
+		w.Header().Set(HeaderKeys[i], HeaderValues[i])
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(b)
+	return status
+}
+
+// graphQLSchema returns the assembled schema, rebuilding it every
+// graphql.refresh (default 5m). One rebuild runs at a time, outside the
+// lock; meanwhile callers get the previous schema. A failed rebuild keeps
+// the previous schema.
+func graphQLSchema(ctx context.Context, force bool) (*ast.Schema, error) {
+	refresh := viper.GetDuration("graphql.refresh")
+	if refresh <= 0 {
+		refresh = 5 * time.Minute
+	}
+
+	gqlCache.Lock()
+	if !force && gqlCache.schema != nil && time.Since(gqlCache.loaded) < refresh {
+		defer gqlCache.Unlock()
+		return gqlCache.schema, nil
+	}
+	if done := gqlCache.building; done != nil {
+		schema := gqlCache.schema
+		gqlCache.Unlock()
+		if schema != nil {
+			return schema, nil
+		}
+		// first build: wait for it
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		gqlCache.Lock()
+		defer gqlCache.Unlock()
+		if gqlCache.schema != nil {
+			return gqlCache.schema, nil
+		}
+		return nil, gqlCache.err
+	}
+	done := make(chan struct{})
+	gqlCache.building = done
+	gqlCache.Unlock()
+
+	// the build is shared, so it must not end with this caller's request
+	schema, err := buildGraphQLSchema(context.WithoutCancel(ctx))
+
+	gqlCache.Lock()
+	defer gqlCache.Unlock()
+	gqlCache.building = nil
+	close(done)
+
+	if err != nil {
+		gqlCache.err = err
+		if gqlCache.schema != nil {
+			sf.SetErrorLog("graphql: schema rebuild failed, keeping previous: " + err.Error())
+			gqlCache.loaded = time.Now()
+			return gqlCache.schema, nil
+		}
+		return nil, err
+	}
+
+	gqlCache.schema, gqlCache.loaded, gqlCache.err = schema, time.Now(), nil
+	return schema, nil
+}
+
+// buildGraphQLSchema loads one SDL fragment per module in graphql.modules.
+// The fragment source name is the module, which root fields default to.
+func buildGraphQLSchema(ctx context.Context) (*ast.Schema, error) {
+	sources := []*ast.Source{}
+	mutation := false
+
+	for _, module := range viper.GetStringSlice("graphql.modules") {
+		sdl, err := moduleSDL(ctx, module)
+		if err != nil {
+			sf.SetErrorLog(fmt.Sprintf("graphql: no schema for %s: %v", module, err))
+			continue
+		}
+		mutation = mutation || gqlExtendsMutation.MatchString(sdl)
+		sources = append(sources, &ast.Source{Name: module, Input: sdl})
+	}
+	if len(sources) == 0 {
+		return nil, errors.New("no GraphQL schema fragments available")
+	}
+
+	base := gqlBase
+	if mutation {
+		base += "\ntype Mutation\n"
+	}
+	sources = append([]*ast.Source{{Name: "gufo", Input: base}}, sources...)
+
+	return gqlparser.LoadSchema(sources...)
+}
+
+// moduleSDL reads <graphql.schema_dir>/<module>.graphql, else asks the
+// module itself (IR.Param = "schema", answer field "schema").
+func moduleSDL(ctx context.Context, module string) (string, error) {
+	if dir := viper.GetString("graphql.schema_dir"); dir != "" {
+		b, err := os.ReadFile(filepath.Join(dir, module+".graphql"))
+		if err == nil {
+			return string(b), nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+	}
+
+	param, method := "schema", http.MethodGet
+	req := &pb.Request{
+		Module: &module,
+		IR:     &pb.InternalRequest{Param: &param, Method: &method},
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	resp, err := transport.Get().Call(ctx, module, method, req)
+	if err != nil {
+		return "", err
+	}
+	data := sf.ToMapStringInterface(resp.Data)
+	sdl, _ := data["schema"].(string)
+	if sdl == "" {
+		return "", errors.New("module answered without a schema")
+	}
+	return sdl, nil
+}
@@ -0,0 +1,742 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// GraphQL execution over decoded module answers: root fields become module
+// calls (deduplicated per request), nested fields are read from the data.
+
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	pb "github.com/gogufo/gufo-api-gateway/proto/go"
+	"github.com/gogufo/gufo-api-gateway/registry"
+	"github.com/spf13/viper"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// gqlResolver is a lazily computed field value (used for introspection).
+type gqlResolver func(args map[string]interface{}) interface{}
+
+type gqlExec struct {
+	r      *http.Request
+	t      *pb.Request
+	mount  APIMount
+	schema *ast.Schema
+	doc    *ast.QueryDocument
+	vars   map[string]interface{}
+	ids    *edgeIdentity
+
+	mu    sync.Mutex
+	calls map[string]*gqlCall
+	errs  gqlerror.List
+	slots chan struct{}
+}
+
+// gqlCall is one upstream call shared by identical root fields.
+type gqlCall struct {
+	done   chan struct{}
+	data   map[string]interface{}
+	status int
+	err    error
+}
+
+// gqlField is a response key with its merged field selections.
+type gqlField struct {
+	key    string
+	fields []*ast.Field
+}
+
+func newGQLExec(r *http.Request, t *pb.Request, ids *edgeIdentity, m APIMount, schema *ast.Schema, doc *ast.QueryDocument, vars map[string]interface{}) *gqlExec {
+	limit := viper.GetInt("graphql.concurrency")
+	if limit <= 0 {
+		limit = 8
+	}
+	return &gqlExec{
+		r: r, t: t, mount: m, schema: schema, doc: doc, vars: vars,
+		ids:   ids,
+		calls: map[string]*gqlCall{},
+		slots: make(chan struct{}, limit),
+	}
+}
+
+// execute runs op. Query root fields resolve concurrently, mutation root
+// fields one after another.
+func (e *gqlExec) execute(op *ast.OperationDefinition) gqlResponse {
+	root := e.schema.Query
+	if op.Operation == ast.Mutation {
+		root = e.schema.Mutation
+	}
+	if root == nil || op.Operation == ast.Subscription {
+		return gqlResponse{Errors: gqlerror.List{gqlerror.Errorf("%s operations are not supported", op.Operation)}}
+	}
+
+	fields := e.collectFields(root, op.SelectionSet)
+	values := make([]interface{}, len(fields))
+	nulls := make([]bool, len(fields))
+
+	run := func(i int) {
+		f := fields[i]
+		path := ast.Path{ast.PathName(f.key)}
+		val, err := e.resolveRoot(f.fields[0])
+		if err != nil {
+			err.Path = path
+			e.addError(err)
+		}
+		values[i], nulls[i] = e.completeValue(f.fields[0].Definition.Type, f.fields, val, path)
+	}
+
+	if op.Operation == ast.Mutation {
+		for i := range fields {
+			run(i)
+		}
+	} else {
+		var wg sync.WaitGroup
+		for i := range fields {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				run(i)
+			}(i)
+		}
+		wg.Wait()
+	}
+
+	data := newOrderedMap(len(fields))
+	for i, f := range fields {
+		if nulls[i] {
+			return gqlResponse{Data: nil, Errors: e.errs}
+		}
+		data.set(f.key, values[i])
+	}
+	return gqlResponse{Data: data, Errors: e.errs}
+}
+
+func (e *gqlExec) addError(err *gqlerror.Error) {
+	e.mu.Lock()
+	e.errs = append(e.errs, err)
+	e.mu.Unlock()
+}
+
+// -------------------------------------------------------------------
+// Root fields → module calls
+// -------------------------------------------------------------------
+
+// resolveRoot calls the module bound to a root field (@gufo, defaulting
+// to the fragment's module and the field name as param).
+func (e *gqlExec) resolveRoot(f *ast.Field) (interface{}, *gqlerror.Error) {
+	switch f.Name {
+	case "__schema":
+		return gqlSchemaValue(e.schema), nil
+	case "__type":
+		name, _ := f.ArgumentMap(e.vars)["name"].(string)
+		if def := e.schema.Types[name]; def != nil {
+			return gqlTypeValue(e.schema, def), nil
+		}
+		return nil, nil
+	case "__typename":
+		return f.ObjectDefinition.Name, nil
+	}
+
+	def := f.Definition
+	module, param, paramArg, method, path := "", def.Name, "", http.MethodGet, ""
+	if def.Position != nil && def.Position.Src != nil {
+		module = def.Position.Src.Name
+	}
+	if f.ObjectDefinition == e.schema.Mutation {
+		method = http.MethodPost
+	}
+	if d := def.Directives.ForName("gufo"); d != nil {
+		for k, v := range d.ArgumentMap(nil) {
+			s, _ := v.(string)
+			switch k {
+			case "module":
+				module = s
+			case "param":
+				param = s
+			case "paramID":
+				paramArg = s
+			case "method":
+				method = strings.ToUpper(s)
+			case "path":
+				path = s
+			}
+		}
+	}
+
+	internal, ok := mountModule(e.mount, module)
+	if !ok {
+		return nil, gqlFieldError(404, "", fmt.Sprintf("Module is not available in API %s", e.mount.Version))
+	}
+	if registry.IsDrained(internal) {
+		return nil, gqlFieldError(503, "", "Module is drained")
+	}
+	if registry.IsInternal(internal) {
+		return nil, gqlFieldError(404, "", "Module not found")
+	}
+
+	args := f.ArgumentMap(e.vars)
+	item := BatchItem{Param: param, Method: method}
+	if paramArg != "" {
+		if v, ok := args[paramArg]; ok && v != nil {
+			item.ParamID = fmt.Sprint(v)
+		}
+		delete(args, paramArg)
+	}
+	req := batchRequest(e.t, internal, item, args)
+	call := e.call(internal, item, args, req, matchRoute(method, module, param))
+	if call.err != nil {
+		return nil, gqlFieldError(call.status, "", call.err.Error())
+	}
+	if call.status >= 400 {
+		msg, _ := call.data["message"].(string)
+		if msg == "" {
+			msg = http.StatusText(call.status)
+		}
+		return nil, gqlFieldError(call.status, fmt.Sprint(call.data["code"]), msg)
+	}
+
+	if path == "" {
+		return call.data, nil
+	}
+	val, _ := jsonPath(call.data, path)
+	return val, nil
+}
+
+// call runs req through subCall once per request for identical module,
+// param, ParamID, method and args; duplicates wait for the first call.
+func (e *gqlExec) call(module string, item BatchItem, args map[string]interface{}, req *pb.Request, rule *TransformRule) *gqlCall {
+	argsJSON, _ := json.Marshal(args) // map keys are sorted
+	key := strings.Join([]string{module, item.Param, item.ParamID, item.Method, string(argsJSON)}, "|")
+
+	e.mu.Lock()
+	if c, ok := e.calls[key]; ok {
+		e.mu.Unlock()
+		<-c.done
+		return c
+	}
+	c := &gqlCall{done: make(chan struct{})}
+	e.calls[key] = c
+	maxCalls := viper.GetInt("graphql.max_calls")
+	if maxCalls <= 0 {
+		maxCalls = 50
+	}
+	tooMany := len(e.calls) > maxCalls
+	e.mu.Unlock()
+	defer close(c.done)
+
+	if tooMany {
+		c.status, c.err = 400, fmt.Errorf("query needs more than %d module calls", maxCalls)
+		return c
+	}
+
+	e.slots <- struct{}{}
+	defer func() { <-e.slots }()
+
+	timeout := viper.GetDuration("graphql.timeout")
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+
+	var msg string
+	c.status, c.data, msg = subCall(e.r, e.ids, req, rule, timeout)
+	if msg != "" {
+		c.err = errors.New(msg)
+	}
+	return c
+}
+
+func gqlFieldError(status int, code, msg string) *gqlerror.Error {
+	ext := map[string]interface{}{"status": status}
+	if code != "" && code != "<nil>" {
+		ext["code"] = code
+	}
+	return &gqlerror.Error{Message: msg, Extensions: ext}
+}
+
+// -------------------------------------------------------------------
+// Value completion
+// -------------------------------------------------------------------
+
+// completeValue shapes val to typ and the selections. The bool is true when
+// a non-null position resolved to null (the parent becomes null).
+func (e *gqlExec) completeValue(typ *ast.Type, fields []*ast.Field, val interface{}, path ast.Path) (interface{}, bool) {
+	if val == nil {
+		if typ.NonNull {
+			e.addError(gqlerror.ErrorPathf(path, "non-null field resolved to null"))
+			return nil, true
+		}
+		return nil, false
+	}
+
+	if typ.Elem != nil {
+		list, ok := val.([]interface{})
+		if !ok {
+			e.addError(gqlerror.ErrorPathf(path, "expected a list"))
+			return nil, typ.NonNull
+		}
+		out := make([]interface{}, len(list))
+		for i, item := range list {
+			v, null := e.completeValue(typ.Elem, fields, item, append(append(ast.Path{}, path...), ast.PathIndex(i)))
+			if null {
+				return nil, typ.NonNull
+			}
+			out[i] = v
+		}
+		return out, false
+	}
+
+	def := e.schema.Types[typ.NamedType]
+	switch def.Kind {
+	case ast.Scalar, ast.Enum:
+		return gqlScalar(def.Name, val), false
+
+	case ast.Object, ast.Interface, ast.Union:
+		obj, ok := val.(map[string]interface{})
+		if !ok {
+			e.addError(gqlerror.ErrorPathf(path, "expected an object for %s", def.Name))
+			return nil, typ.NonNull
+		}
+		concrete := e.concreteType(def, obj)
+		if concrete == nil {
+			e.addError(gqlerror.ErrorPathf(path, "cannot determine the concrete type of %s (send __typename)", def.Name))
+			return nil, typ.NonNull
+		}
+		out, null := e.completeObject(concrete, fields, obj, path)
+		if null {
+			return nil, typ.NonNull
+		}
+		return out, false
+	}
+
+	return val, false
+}
+
+// concreteType resolves interfaces and unions by __typename or, when only
+// one type is possible, by that type.
+func (e *gqlExec) concreteType(def *ast.Definition, obj map[string]interface{}) *ast.Definition {
+	if def.Kind == ast.Object {
+		return def
+	}
+	possible := e.schema.GetPossibleTypes(def)
+	if name, ok := obj["__typename"].(string); ok {
+		for _, p := range possible {
+			if p.Name == name {
+				return p
+			}
+		}
+		return nil
+	}
+	if len(possible) == 1 {
+		return possible[0]
+	}
+	return nil
+}
+
+func (e *gqlExec) completeObject(def *ast.Definition, parents []*ast.Field, obj map[string]interface{}, path ast.Path) (interface{}, bool) {
+	var set ast.SelectionSet
+	for _, p := range parents {
+		set = append(set, p.SelectionSet...)
+	}
+
+	fields := e.collectFields(def, set)
+	out := newOrderedMap(len(fields))
+	for _, f := range fields {
+		fpath := append(append(ast.Path{}, path...), ast.PathName(f.key))
+		field := f.fields[0]
+		if field.Name == "__typename" {
+			out.set(f.key, def.Name)
+			continue
+		}
+
+		val := obj[field.Name]
+		if fn, ok := val.(gqlResolver); ok {
+			val = fn(field.ArgumentMap(e.vars))
+		}
+
+		fdef := def.Fields.ForName(field.Name)
+		if fdef == nil {
+			continue
+		}
+		v, null := e.completeValue(fdef.Type, f.fields, val, fpath)
+		if null {
+			return nil, true
+		}
+		out.set(f.key, v)
+	}
+	return out, false
+}
+
+// collectFields flattens fragments that apply to def and honours
+// @skip/@include; fields with the same response key are merged.
+func (e *gqlExec) collectFields(def *ast.Definition, set ast.SelectionSet) []gqlField {
+	var out []gqlField
+	index := map[string]int{}
+	visited := map[string]bool{}
+
+	var walk func(set ast.SelectionSet)
+	walk = func(set ast.SelectionSet) {
+		for _, sel := range set {
+			switch s := sel.(type) {
+			case *ast.Field:
+				if !e.included(s.Directives) {
+					continue
+				}
+				key := s.Alias
+				if key == "" {
+					key = s.Name
+				}
+				if i, ok := index[key]; ok {
+					out[i].fields = append(out[i].fields, s)
+					continue
+				}
+				index[key] = len(out)
+				out = append(out, gqlField{key: key, fields: []*ast.Field{s}})
+
+			case *ast.InlineFragment:
+				if e.included(s.Directives) && e.applies(s.TypeCondition, def) {
+					walk(s.SelectionSet)
+				}
+
+			case *ast.FragmentSpread:
+				if visited[s.Name] || !e.included(s.Directives) {
+					continue
+				}
+				visited[s.Name] = true
+				frag := e.doc.Fragments.ForName(s.Name)
+				if frag != nil && e.applies(frag.TypeCondition, def) {
+					walk(frag.SelectionSet)
+				}
+			}
+		}
+	}
+	walk(set)
+	return out
+}
+
+func (e *gqlExec) included(dirs ast.DirectiveList) bool {
+	if d := dirs.ForName("skip"); d != nil {
+		if skip, _ := d.ArgumentMap(e.vars)["if"].(bool); skip {
+			return false
+		}
+	}
+	if d := dirs.ForName("include"); d != nil {
+		if inc, _ := d.ArgumentMap(e.vars)["if"].(bool); !inc {
+			return false
+		}
+	}
+	return true
+}
+
+// applies reports whether a fragment on cond applies to object type def.
+func (e *gqlExec) applies(cond string, def *ast.Definition) bool {
+	if cond == "" || cond == def.Name {
+		return true
+	}
+	abstract := e.schema.Types[cond]
+	if abstract == nil {
+		return false
+	}
+	for _, p := range e.schema.GetPossibleTypes(abstract) {
+		if p.Name == def.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// gqlScalar coerces decoded JSON to the built-in scalar types.
+func gqlScalar(name string, val interface{}) interface{} {
+	switch name {
+	case "Int":
+		switch v := val.(type) {
+		case float64:
+			if v == math.Trunc(v) {
+				return int64(v)
+			}
+		case string:
+			if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+				return n
+			}
+		}
+	case "Float":
+		if s, ok := val.(string); ok {
+			if f, err := strconv.ParseFloat(s, 64); err == nil {
+				return f
+			}
+		}
+	case "String", "ID":
+		switch v := val.(type) {
+		case string:
+			return v
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			return strconv.FormatBool(v)
+		}
+	case "Boolean":
+		if s, ok := val.(string); ok {
+			if b, err := strconv.ParseBool(s); err == nil {
+				return b
+			}
+		}
+	}
+	return val
+}
+
+// orderedMap keeps the selection order in the JSON output.
+type orderedMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newOrderedMap(n int) *orderedMap {
+	return &orderedMap{keys: make([]string, 0, n), values: make(map[string]interface{}, n)}
+}
+
+func (m *orderedMap) set(k string, v interface{}) {
+	if _, ok := m.values[k]; !ok {
+		m.keys = append(m.keys, k)
+	}
+	m.values[k] = v
+}
+
+func (m *orderedMap) MarshalJSON() ([]byte, error) {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, k := range m.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		kb, _ := json.Marshal(k)
+		vb, err := json.Marshal(m.values[k])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(kb)
+		b.WriteByte(':')
+		b.Write(vb)
+	}
+	b.WriteByte('}')
+	return []byte(b.String()), nil
+}
+
+// -------------------------------------------------------------------
+// Introspection (__schema, __type)
+// -------------------------------------------------------------------
+
+func gqlSchemaValue(s *ast.Schema) map[string]interface{} {
+	return map[string]interface{}{
+		"description": gqlNullable(s.Description),
+		"types": gqlResolver(func(map[string]interface{}) interface{} {
+			names := make([]string, 0, len(s.Types))
+			for name := range s.Types {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			out := []interface{}{}
+			for _, name := range names {
+				out = append(out, gqlTypeValue(s, s.Types[name]))
+			}
+			return out
+		}),
+		"queryType":        gqlTypeValue(s, s.Query),
+		"mutationType":     gqlTypeOrNil(s, s.Mutation),
+		"subscriptionType": gqlTypeOrNil(s, s.Subscription),
+		"directives": gqlResolver(func(map[string]interface{}) interface{} {
+			names := make([]string, 0, len(s.Directives))
+			for name := range s.Directives {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			out := []interface{}{}
+			for _, name := range names {
+				d := s.Directives[name]
+				locations := make([]interface{}, len(d.Locations))
+				for i, l := range d.Locations {
+					locations[i] = string(l)
+				}
+				out = append(out, map[string]interface{}{
+					"name":         d.Name,
+					"description":  gqlNullable(d.Description),
+					"locations":    locations,
+					"args":         gqlArgs(s, d.Arguments),
+					"isRepeatable": d.IsRepeatable,
+				})
+			}
+			return out
+		}),
+	}
+}
+
+func gqlTypeOrNil(s *ast.Schema, def *ast.Definition) interface{} {
+	if def == nil {
+		return nil
+	}
+	return gqlTypeValue(s, def)
+}
+
+func gqlTypeValue(s *ast.Schema, def *ast.Definition) map[string]interface{} {
+	includeDeprecated := func(args map[string]interface{}) bool {
+		b, _ := args["includeDeprecated"].(bool)
+		return b
+	}
+
+	t := map[string]interface{}{
+		"kind":        string(def.Kind),
+		"name":        def.Name,
+		"description": gqlNullable(def.Description),
+	}
+	if d := def.Directives.ForName("specifiedBy"); d != nil {
+		t["specifiedByURL"] = d.ArgumentMap(nil)["url"]
+	}
+
+	switch def.Kind {
+	case ast.Object, ast.Interface:
+		t["fields"] = gqlResolver(func(args map[string]interface{}) interface{} {
+			out := []interface{}{}
+			for _, f := range def.Fields {
+				if strings.HasPrefix(f.Name, "__") {
+					continue
+				}
+				reason, deprecated := gqlDeprecation(f.Directives)
+				if deprecated && !includeDeprecated(args) {
+					continue
+				}
+				out = append(out, map[string]interface{}{
+					"name":              f.Name,
+					"description":       gqlNullable(f.Description),
+					"args":              gqlArgs(s, f.Arguments),
+					"type":              gqlTypeRef(s, f.Type),
+					"isDeprecated":      deprecated,
+					"deprecationReason": reason,
+				})
+			}
+			return out
+		})
+		t["interfaces"] = gqlResolver(func(map[string]interface{}) interface{} {
+			out := []interface{}{}
+			for _, name := range def.Interfaces {
+				if i := s.Types[name]; i != nil {
+					out = append(out, gqlTypeValue(s, i))
+				}
+			}
+			return out
+		})
+	case ast.InputObject:
+		t["inputFields"] = gqlResolver(func(map[string]interface{}) interface{} {
+			out := []interface{}{}
+			for _, f := range def.Fields {
+				out = append(out, gqlInputValue(s, f.Name, f.Description, f.Type, f.DefaultValue))
+			}
+			return out
+		})
+	case ast.Enum:
+		t["enumValues"] = gqlResolver(func(args map[string]interface{}) interface{} {
+			out := []interface{}{}
+			for _, v := range def.EnumValues {
+				reason, deprecated := gqlDeprecation(v.Directives)
+				if deprecated && !includeDeprecated(args) {
+					continue
+				}
+				out = append(out, map[string]interface{}{
+					"name":              v.Name,
+					"description":       gqlNullable(v.Description),
+					"isDeprecated":      deprecated,
+					"deprecationReason": reason,
+				})
+			}
+			return out
+		})
+	}
+
+	if def.Kind == ast.Interface || def.Kind == ast.Union {
+		t["possibleTypes"] = gqlResolver(func(map[string]interface{}) interface{} {
+			out := []interface{}{}
+			for _, p := range s.GetPossibleTypes(def) {
+				out = append(out, gqlTypeValue(s, p))
+			}
+			return out
+		})
+	}
+	return t
+}
+
+// gqlTypeRef describes a wrapped type (NON_NULL, LIST) down to the named type.
+func gqlTypeRef(s *ast.Schema, typ *ast.Type) interface{} {
+	if typ.NonNull {
+		inner := *typ
+		inner.NonNull = false
+		return map[string]interface{}{"kind": "NON_NULL", "ofType": gqlResolver(func(map[string]interface{}) interface{} {
+			return gqlTypeRef(s, &inner)
+		})}
+	}
+	if typ.Elem != nil {
+		return map[string]interface{}{"kind": "LIST", "ofType": gqlResolver(func(map[string]interface{}) interface{} {
+			return gqlTypeRef(s, typ.Elem)
+		})}
+	}
+	if def := s.Types[typ.NamedType]; def != nil {
+		return gqlTypeValue(s, def)
+	}
+	return nil
+}
+
+func gqlArgs(s *ast.Schema, args ast.ArgumentDefinitionList) []interface{} {
+	out := []interface{}{}
+	for _, a := range args {
+		out = append(out, gqlInputValue(s, a.Name, a.Description, a.Type, a.DefaultValue))
+	}
+	return out
+}
+
+func gqlInputValue(s *ast.Schema, name, desc string, typ *ast.Type, def *ast.Value) map[string]interface{} {
+	v := map[string]interface{}{
+		"name":         name,
+		"description":  gqlNullable(desc),
+		"type":         gqlTypeRef(s, typ),
+		"defaultValue": nil,
+	}
+	if def != nil {
+		v["defaultValue"] = def.String()
+	}
+	return v
+}
+
+func gqlDeprecation(dirs ast.DirectiveList) (interface{}, bool) {
+	d := dirs.ForName("deprecated")
+	if d == nil {
+		return nil, false
+	}
+	return d.ArgumentMap(nil)["reason"], true
+}
+
+func gqlNullable(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,304 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	sf "github.com/gogufo/gufo-api-gateway/gufodao"
+	pb "github.com/gogufo/gufo-api-gateway/proto/go"
+)
+
+// useGraphQL serves the SDL fixtures in testdata/graphql through a fake
+// transport and rebuilds the schema for the test.
+func useGraphQL(t *testing.T) *fakeTransport {
+	t.Helper()
+	setConfig(t, map[string]interface{}{
+		"graphql.enabled":    true,
+		"graphql.modules":    []string{"users", "orders"},
+		"graphql.schema_dir": "testdata/graphql",
+		"security.edge_mode": "anonymous",
+	})
+	resetSchema := func() {
+		gqlCache.Lock()
+		gqlCache.schema, gqlCache.err = nil, nil
+		gqlCache.Unlock()
+	}
+	resetSchema()
+	t.Cleanup(resetSchema)
+
+	f := &fakeTransport{answer: func(module string, req *pb.Request) (map[string]interface{}, error) {
+		args := sf.ToMapStringInterface(req.Args)
+		switch module + "/" + req.GetParam() {
+		case "users/get":
+			if req.GetParamID() == "404" {
+				return map[string]interface{}{"httpcode": 404, "code": "0000404", "message": "user not found"}, nil
+			}
+			return map[string]interface{}{
+				"id": req.GetParamID(), "name": "Ada", "age": 36,
+				"friends": []interface{}{map[string]interface{}{"id": "2", "name": "Bob"}},
+			}, nil
+		case "users/list":
+			return map[string]interface{}{"items": []interface{}{
+				map[string]interface{}{"id": "1", "name": "Ada"},
+				map[string]interface{}{"id": "2", "name": "Bob"},
+			}, "limit": args["limit"]}, nil
+		case "billing/order":
+			return map[string]interface{}{"id": req.GetParamID(), "total": "9.5"}, nil
+		case "orders/createOrder":
+			return map[string]interface{}{"id": "o1", "total": 1.5, "owner": map[string]interface{}{"id": "1"}}, nil
+		}
+		return nil, fmt.Errorf("unexpected call %s/%s", module, req.GetParam())
+	}}
+	useTransport(t, f)
+	return f
+}
+
+// gqlPost runs a GraphQL request and returns the status and raw body.
+func gqlPost(t *testing.T, query string, vars map[string]interface{}) (int, string) {
+	t.Helper()
+	body, _ := json.Marshal(map[string]interface{}{"query": query, "variables": vars})
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/api/v3/graphql", strings.NewReader(string(body)))
+	r.Header.Set("Content-Type", "application/json")
+	GraphQL(w, r, "v3")
+	return w.Code, w.Body.String()
+}
+
+func TestGraphQLQueries(t *testing.T) {
+	useGraphQL(t)
+
+	tests := []struct {
+		name  string
+		query string
+		vars  map[string]interface{}
+		want  string // exact response body
+	}{
+		{
+			"selection and aliases keep the query order",
+			`{ b: user(id: "2") { name id } a: user(id: "1") { name } }`, nil,
+			`{"data":{"b":{"name":"Ada","id":"2"},"a":{"name":"Ada"}}}`,
+		},
+		{
+			"nested lists",
+			`{ user(id: "1") { friends { name } } }`, nil,
+			`{"data":{"user":{"friends":[{"name":"Bob"}]}}}`,
+		},
+		{
+			"fragments",
+			`query { user(id: "1") { ...Basics ... on User { age } } } fragment Basics on User { id name }`, nil,
+			`{"data":{"user":{"id":"1","name":"Ada","age":36}}}`,
+		},
+		{
+			"variables and @include",
+			`query Q($id: ID!, $withAge: Boolean!) { user(id: $id) { name age @include(if: $withAge) } }`,
+			map[string]interface{}{"id": "7", "withAge": false},
+			`{"data":{"user":{"name":"Ada"}}}`,
+		},
+		{
+			"path and arguments",
+			`{ users(limit: 2) { name } }`, nil,
+			`{"data":{"users":[{"name":"Ada"},{"name":"Bob"}]}}`,
+		},
+		{
+			"module override and scalar coercion",
+			`{ order(id: "o9") { id total } }`, nil,
+			`{"data":{"order":{"id":"o9","total":9.5}}}`,
+		},
+		{
+			"__typename",
+			`{ user(id: "1") { __typename } }`, nil,
+			`{"data":{"user":{"__typename":"User"}}}`,
+		},
+	}
+	for _, tt := range tests {
+		status, body := gqlPost(t, tt.query, tt.vars)
+		if status != http.StatusOK || body != tt.want {
+			t.Errorf("%s: %d %s\nwant %s", tt.name, status, body, tt.want)
+		}
+	}
+}
+
+// @gufo maps root fields to module, param, ParamID and path; the ParamID
+// argument is not passed on as an arg. Mutations are POSTed.
+func TestGraphQLBinding(t *testing.T) {
+	f := useGraphQL(t)
+
+	if status, body := gqlPost(t, `{ user(id: "42") { id } users(limit: 5) { id } order(id: "o1") { id } }`, nil); status != http.StatusOK {
+		t.Fatalf("query: %d %s", status, body)
+	}
+	if status, body := gqlPost(t, `mutation { createOrder(item: "book") { id owner { id } } }`, nil); status != http.StatusOK {
+		t.Fatalf("mutation: %d %s", status, body)
+	}
+
+	type call struct {
+		module, param, paramID, method string
+		args                           map[string]interface{}
+	}
+	got := map[string]call{}
+	for _, req := range f.calls {
+		got[req.GetModule()+"/"+req.GetParam()] = call{req.GetModule(), req.GetParam(), req.GetParamID(), req.GetMethod(), sf.ToMapStringInterface(req.Args)}
+	}
+	want := map[string]call{
+		"users/get":          {"users", "get", "42", "GET", map[string]interface{}{}},
+		"users/list":         {"users", "list", "", "GET", map[string]interface{}{"limit": float64(5)}},
+		"billing/order":      {"billing", "order", "o1", "GET", map[string]interface{}{}},
+		"orders/createOrder": {"orders", "createOrder", "", "POST", map[string]interface{}{"item": "book"}},
+	}
+	for k, w := range want {
+		g := got[k]
+		if g.module != w.module || g.param != w.param || g.paramID != w.paramID || g.method != w.method || fmt.Sprint(g.args) != fmt.Sprint(w.args) {
+			t.Errorf("%s: got %+v, want %+v", k, g, w)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("calls: %v", got)
+	}
+}
+
+// A failing module call nulls its field and adds an error; the other root
+// fields are still answered.
+func TestGraphQLPartialErrors(t *testing.T) {
+	useGraphQL(t)
+
+	status, body := gqlPost(t, `{ ok: user(id: "1") { name } missing: user(id: "404") { name } }`, nil)
+	if status != http.StatusOK {
+		t.Fatalf("status %d", status)
+	}
+	var resp struct {
+		Data   map[string]interface{} `json:"data"`
+		Errors []struct {
+			Message    string                 `json:"message"`
+			Path       []interface{}          `json:"path"`
+			Extensions map[string]interface{} `json:"extensions"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(resp.Data, map[string]interface{}{"ok": map[string]interface{}{"name": "Ada"}, "missing": nil}) {
+		t.Fatalf("data = %v", resp.Data)
+	}
+	if len(resp.Errors) != 1 {
+		t.Fatalf("errors = %+v", resp.Errors)
+	}
+	e := resp.Errors[0]
+	if e.Message != "user not found" || !reflect.DeepEqual(e.Path, []interface{}{"missing"}) ||
+		e.Extensions["status"] != float64(404) || e.Extensions["code"] != "0000404" {
+		t.Fatalf("error = %+v", e)
+	}
+}
+
+// Identical root calls run once per request.
+func TestGraphQLDedup(t *testing.T) {
+	f := useGraphQL(t)
+
+	status, body := gqlPost(t, `{ a: user(id: "1") { name } b: user(id: "1") { age } c: user(id: "2") { name } }`, nil)
+	if status != http.StatusOK {
+		t.Fatalf("%d %s", status, body)
+	}
+	if f.count() != 2 {
+		t.Fatalf("%d module calls, want 2", f.count())
+	}
+}
+
+func TestGraphQLIntrospection(t *testing.T) {
+	f := useGraphQL(t)
+
+	status, body := gqlPost(t, `{
+		__schema { queryType { name } mutationType { name } directives { name } }
+		__type(name: "User") { kind fields { name type { kind ofType { name } } } }
+	}`, nil)
+	if status != http.StatusOK {
+		t.Fatalf("%d %s", status, body)
+	}
+	var resp struct {
+		Data struct {
+			Schema struct {
+				QueryType    struct{ Name string }
+				MutationType struct{ Name string }
+				Directives   []struct{ Name string }
+			} `json:"__schema"`
+			Type struct {
+				Kind   string
+				Fields []struct {
+					Name string
+					Type struct {
+						Kind   string
+						OfType *struct{ Name string }
+					}
+				}
+			} `json:"__type"`
+		}
+	}
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	s := resp.Data.Schema
+	if s.QueryType.Name != "Query" || s.MutationType.Name != "Mutation" {
+		t.Errorf("root types = %+v", s)
+	}
+	directives := []string{}
+	for _, d := range s.Directives {
+		directives = append(directives, d.Name)
+	}
+	if !strings.Contains(strings.Join(directives, ","), "gufo") {
+		t.Errorf("directives = %v", directives)
+	}
+
+	u := resp.Data.Type
+	fields := []string{}
+	for _, fd := range u.Fields {
+		fields = append(fields, fd.Name)
+	}
+	if u.Kind != "OBJECT" || strings.Join(fields, ",") != "id,name,age,friends" {
+		t.Errorf("User = %s %v", u.Kind, fields)
+	}
+	if id := u.Fields[0].Type; id.Kind != "NON_NULL" || id.OfType == nil || id.OfType.Name != "ID" {
+		t.Errorf("User.id type = %+v", id)
+	}
+	if f.count() != 0 {
+		t.Errorf("introspection called %d modules", f.count())
+	}
+}
+
+func TestGraphQLRequestErrors(t *testing.T) {
+	useGraphQL(t)
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+	}{
+		{"empty", ``, http.StatusBadRequest},
+		{"syntax", `{ user(id: "1") { name }`, http.StatusBadRequest},
+		{"unknown field", `{ user(id: "1") { email } }`, http.StatusBadRequest},
+		{"missing variable", `query Q($id: ID!) { user(id: $id) { name } }`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if status, body := gqlPost(t, tt.query, nil); status != tt.status {
+			t.Errorf("%s: %d %s", tt.name, status, body)
+		}
+	}
+}
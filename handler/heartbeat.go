@@ -16,12 +16,20 @@
 package handler
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	sf "github.com/gogufo/gufo-api-gateway/gufodao"
+	"github.com/gogufo/gufo-api-gateway/lease"
 	pb "github.com/gogufo/gufo-api-gateway/proto/go"
+	"github.com/gogufo/gufo-api-gateway/registry"
+	"github.com/gogufo/gufo-api-gateway/scheduler"
 	"github.com/spf13/viper"
 )
 
@@ -30,9 +38,25 @@ import (
 func HeartbeatHandler(w http.ResponseWriter, r *http.Request, t *pb.Request) {
 	// fmt.Fprintln(os.Stderr, ">>> HeartbeatHandler")
 
-	var payload map[string]interface{}
+	payload, err := parseJSONArgs(r)
+	if err != nil {
+		errorAnswer(w, r, t, sf.BodyErrorStatus(err), "0000400", err.Error())
+		return
+	}
 
-	ans, err := heartbeatCore(t, payload)
+	ans, err := heartbeatCore(restHeartbeatCaller(r, t), t, payload)
+	if errors.Is(err, registry.ErrInvalidInstance) {
+		errorAnswer(w, r, t, 400, "0000400", err.Error())
+		return
+	}
+	if errors.Is(err, errHeartbeatCaller) {
+		errorAnswer(w, r, t, 401, "00001", err.Error())
+		return
+	}
+	if errors.Is(err, registry.ErrNotAllowed) || errors.Is(err, registry.ErrStatic) {
+		errorAnswer(w, r, t, 403, "00005", err.Error())
+		return
+	}
 	if err != nil {
 		errorAnswer(w, r, t, 500, "0000501", err.Error())
 		return
@@ -47,32 +71,29 @@ func HeartbeatHandler(w http.ResponseWriter, r *http.Request, t *pb.Request) {
 // (no HTTP, no ResponseWriter, no Request).
 //
 // Behavior:
-// - If masterservice is DISABLED → returns a local mock response.
+// - If masterservice is DISABLED → grants leader leases locally (see leaseHeartbeat).
 // - If masterservice is ENABLED → proxies heartbeat to masterservice via gRPC.
 //
 // Input:
+// - caller: the authenticated service behind the heartbeat
 // - t: original gRPC request
 // - payload: optional heartbeat payload (can be nil for pure gRPC calls)
 //
 // Output:
 // - map[string]interface{}: heartbeat response payload
 // - error: any transport or masterservice error
-func heartbeatCore(t *pb.Request, payload map[string]interface{}) (map[string]interface{}, error) {
+func heartbeatCore(caller heartbeatCaller, t *pb.Request, payload map[string]interface{}) (map[string]interface{}, error) {
 
 	msEnabled := viper.GetBool("server.masterservice")
 
 	// ------------------------------------------------------------
-	// MODE 2: Standalone mode → return local mock (no masterservice)
+	// MODE 2: Standalone mode → leader leases granted by the gateway
 	// ------------------------------------------------------------
 	if !msEnabled {
-		mock := map[string]interface{}{
-			"leader": true,
-			"cron":   true,
-			"ttl":    0,
-			"epoch":  0,
-			"ts":     time.Now().Unix(),
+		if payload == nil {
+			payload = sf.ToMapStringInterface(t.Args)
 		}
-		return mock, nil
+		return leaseHeartbeat(caller, t, payload)
 	}
 
 	// ------------------------------------------------------------
@@ -113,3 +134,203 @@ func heartbeatCore(t *pb.Request, payload map[string]interface{}) (map[string]in
 
 	return ans, nil
 }
+
+var errHeartbeatCaller = errors.New("heartbeat: leases need an internal caller (gRPC port, internal token or mTLS)")
+
+// heartbeatCaller is the authenticated service behind a heartbeat.
+type heartbeatCaller struct {
+	grpc     bool   // arrived on the gateway gRPC port (authorizeRPC passed)
+	internal bool   // gRPC, an internal token or a verified client certificate
+	identity string // mTLS identity, if any
+}
+
+// principal names the caller in lease holders: its mTLS identity, else
+// "internal" for a caller proven by the shared gateway secret or an
+// internal token, which do not tell services apart.
+func (c heartbeatCaller) principal() string {
+	if c.identity != "" {
+		return c.identity
+	}
+	return "internal"
+}
+
+// grpcHeartbeatCaller is the caller of a heartbeat on the gateway gRPC port.
+func grpcHeartbeatCaller(ctx context.Context) heartbeatCaller {
+	return heartbeatCaller{grpc: true, internal: true, identity: sf.ContextIdentity(ctx)}
+}
+
+// restHeartbeatCaller identifies a REST heartbeat by a verified client
+// certificate or an internal token for module "heartbeat" in X-Sign. The
+// public edge modes (anonymous, session, API key) do not make a caller
+// internal.
+func restHeartbeatCaller(r *http.Request, t *pb.Request) heartbeatCaller {
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+		return heartbeatCaller{internal: true, identity: sf.IdentityFromCert(r.TLS.VerifiedChains[0][0])}
+	}
+	return heartbeatCaller{internal: sf.CheckSign("token", t)}
+}
+
+// leaseHeartbeat grants, renews or releases the leader lease of the
+// caller's service group (payload: group, service or name; instance or
+// host/port, optional ttl in seconds, release). With
+// registry.self_registration the instance (name or service, host, port,
+// version, tags, internal) is registered for the same ttl as well.
+//
+// The answer keeps the masterservice shape: leader, cron, ttl (seconds
+// left), epoch (fencing token, increases with every new leader), ts, plus
+// group and holder. With cron.enabled the gateway schedules module jobs
+// itself (see package scheduler), so cron is never granted to modules.
+//
+// Heartbeats naming no group or no instance predate leases: they get the
+// answer the gateway always gave in standalone mode (see legacyHeartbeat).
+//
+// Only internal callers get leases. The holder is the caller's principal
+// (see heartbeatCaller.principal) and the instance it names, so no other
+// service can renew or release that lease. An mTLS caller must also be
+// allowed to speak for the group (registry.announce_acl).
+func leaseHeartbeat(caller heartbeatCaller, t *pb.Request, payload map[string]interface{}) (map[string]interface{}, error) {
+	group := heartbeatString(payload, "group")
+	for _, key := range []string{"service", "name"} {
+		if group == "" {
+			group = heartbeatString(payload, key)
+		}
+	}
+	instance := heartbeatString(payload, "instance")
+	if instance == "" {
+		if host, port := heartbeatString(payload, "host"), heartbeatString(payload, "port"); host != "" && port != "" {
+			instance = net.JoinHostPort(host, port)
+		}
+	}
+	if group == "" || instance == "" {
+		return legacyHeartbeat(), nil
+	}
+
+	if !caller.internal {
+		return nil, errHeartbeatCaller
+	}
+	if caller.identity != "" && !registry.AnnounceAllowed(caller.identity, group) {
+		return nil, fmt.Errorf("%w: %q -> %s", registry.ErrNotAllowed, caller.identity, group)
+	}
+	holder := caller.principal() + "|" + instance
+
+	store := lease.GetStore()
+	ans := map[string]interface{}{
+		"group": group,
+		"ts":    time.Now().Unix(),
+	}
+
+	release, _ := payload["release"].(bool)
+	registered, err := announceHeartbeat(caller, payload, release, lease.TTL(heartbeatTTL(payload)))
+	if err != nil {
+		return nil, err
+	}
+	if registered {
+		ans["registered"] = !release
+	}
+
+	if release {
+		if err := store.Release(group, holder); err != nil {
+			return nil, err
+		}
+		ans["leader"], ans["cron"], ans["ttl"], ans["epoch"] = false, false, 0, 0
+		return ans, nil
+	}
+
+	l, err := store.Acquire(group, holder, lease.TTL(heartbeatTTL(payload)))
+	if err != nil {
+		return nil, err
+	}
+	leader := l.Holder == holder
+	ans["leader"] = leader
+	ans["cron"] = leader && !scheduler.Enabled()
+	ans["ttl"] = int64(l.Expires.Seconds())
+	ans["epoch"] = l.Epoch
+	ans["holder"] = l.Holder
+	return ans, nil
+}
+
+// legacyHeartbeat is the standalone answer to heartbeats without a group
+// or instance: every such service leads, and no lease is kept.
+func legacyHeartbeat() map[string]interface{} {
+	return map[string]interface{}{
+		"leader": true,
+		"cron":   !scheduler.Enabled(),
+		"ttl":    0,
+		"epoch":  0,
+		"ts":     time.Now().Unix(),
+	}
+}
+
+func heartbeatString(payload map[string]interface{}, key string) string {
+	switch v := payload[key].(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// announceHeartbeat registers (or with release deregisters) the sending
+// instance when it names its host and port. Only heartbeats on the gateway
+// gRPC port announce, under the caller's mTLS identity. It reports whether
+// the registry was involved.
+func announceHeartbeat(caller heartbeatCaller, payload map[string]interface{}, release bool, ttl time.Duration) (bool, error) {
+	if !registry.SelfRegistration() || !caller.grpc {
+		return false, nil
+	}
+	name := heartbeatString(payload, "name")
+	if name == "" {
+		name = heartbeatString(payload, "service")
+	}
+	host, port := heartbeatString(payload, "host"), heartbeatString(payload, "port")
+	if host == "" || port == "" {
+		return false, nil
+	}
+	if release {
+		return registry.Deregister(name, host, port, caller.identity)
+	}
+
+	internal, _ := strconv.ParseBool(heartbeatString(payload, "internal"))
+
+	err := registry.Announce(registry.Instance{
+		Name:     name,
+		Host:     host,
+		Port:     port,
+		Version:  heartbeatString(payload, "version"),
+		Tags:     heartbeatTags(payload["tags"]),
+		Internal: internal,
+		Identity: caller.identity,
+	}, ttl)
+	return err == nil, err
+}
+
+// heartbeatTTL is the ttl the heartbeat asks for (seconds), 0 when none.
+func heartbeatTTL(payload map[string]interface{}) time.Duration {
+	v, err := strconv.ParseFloat(fmt.Sprint(payload["ttl"]), 64)
+	if err != nil || v <= 0 {
+		return 0
+	}
+	return time.Duration(v * float64(time.Second))
+}
+
+// heartbeatTags accepts a JSON array or a comma-separated string.
+func heartbeatTags(v interface{}) []string {
+	var out []string
+	switch tags := v.(type) {
+	case []interface{}:
+		for _, tag := range tags {
+			out = append(out, fmt.Sprint(tag))
+		}
+	case string:
+		for _, tag := range strings.Split(tags, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				out = append(out, tag)
+			}
+		}
+	}
+	return out
+}
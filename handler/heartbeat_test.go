@@ -0,0 +1,138 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	pb "github.com/gogufo/gufo-api-gateway/proto/go"
+)
+
+var internalCaller = heartbeatCaller{internal: true}
+
+func TestLeaseHeartbeatLegacy(t *testing.T) {
+	ip := "10.0.0.7"
+	req := &pb.Request{IP: &ip}
+
+	tests := []struct {
+		name    string
+		caller  heartbeatCaller
+		payload map[string]interface{}
+	}{
+		{"empty", internalCaller, map[string]interface{}{}},
+		{"no group", internalCaller, map[string]interface{}{"instance": "a-1"}},
+		{"no instance", internalCaller, map[string]interface{}{"service": "legacy-a"}},
+		{"host without port", internalCaller, map[string]interface{}{"service": "legacy-a", "host": "a-1"}},
+		{"public caller", heartbeatCaller{}, map[string]interface{}{"name": "legacy-a", "status": "ok"}},
+	}
+	for _, tt := range tests {
+		ans, err := leaseHeartbeat(tt.caller, req, tt.payload)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if ans["leader"] != true || ans["epoch"] != 0 || ans["ttl"] != 0 {
+			t.Errorf("%s: answer = %v, want the standalone leader answer", tt.name, ans)
+		}
+		if _, ok := ans["holder"]; ok {
+			t.Errorf("%s: a lease was granted (holder %v)", tt.name, ans["holder"])
+		}
+	}
+}
+
+func TestLeaseHeartbeatCaller(t *testing.T) {
+	payload := map[string]interface{}{"service": "caller-a", "instance": "a-1"}
+	if _, err := leaseHeartbeat(heartbeatCaller{}, &pb.Request{}, payload); !errors.Is(err, errHeartbeatCaller) {
+		t.Fatalf("public caller: err = %v, want %v", err, errHeartbeatCaller)
+	}
+
+	// the holder is the authenticated principal and the named instance,
+	// never the client address
+	ip := "10.0.0.7"
+	tests := []struct {
+		caller          heartbeatCaller
+		group, instance string
+		want            string
+	}{
+		{internalCaller, "caller-a", "a-1", "internal|a-1"},
+		{heartbeatCaller{internal: true, identity: "spiffe://gufo/caller-b"}, "caller-b", "b-1", "spiffe://gufo/caller-b|b-1"},
+	}
+	for _, tt := range tests {
+		ans, err := leaseHeartbeat(tt.caller, &pb.Request{IP: &ip}, map[string]interface{}{"group": tt.group, "instance": tt.instance})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ans["holder"] != tt.want {
+			t.Errorf("holder = %v, want %s", ans["holder"], tt.want)
+		}
+	}
+}
+
+// TestLeaseHeartbeatFencing walks one group through renewal, a release by
+// a non-holder, a hand-over and an expiry; the epoch grows with each new
+// holder only.
+func TestLeaseHeartbeatFencing(t *testing.T) {
+	svcA := heartbeatCaller{internal: true, identity: "spiffe://gufo/a"}
+	svcB := heartbeatCaller{internal: true, identity: "spiffe://gufo/b"}
+	beat := func(c heartbeatCaller, payload map[string]interface{}) map[string]interface{} {
+		t.Helper()
+		payload["group"] = "fencing"
+		ans, err := leaseHeartbeat(c, &pb.Request{}, payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ans
+	}
+
+	first := beat(svcA, map[string]interface{}{"instance": "1", "ttl": 1})
+	epoch, _ := first["epoch"].(int64)
+	if first["leader"] != true || first["cron"] != true || epoch == 0 {
+		t.Fatalf("first heartbeat = %v", first)
+	}
+
+	steps := []struct {
+		name       string
+		caller     heartbeatCaller
+		payload    map[string]interface{}
+		wait       time.Duration
+		wantLeader bool
+		wantEpoch  int64
+	}{
+		{"renewal keeps the epoch", svcA, map[string]interface{}{"instance": "1", "ttl": 1}, 0, true, epoch},
+		{"other instance is refused", svcA, map[string]interface{}{"instance": "2"}, 0, false, epoch},
+		{"same instance, other identity", svcB, map[string]interface{}{"instance": "1"}, 0, false, epoch},
+		{"release by a non-holder", svcB, map[string]interface{}{"instance": "1", "release": true}, 0, false, 0},
+		{"still held after it", svcB, map[string]interface{}{"instance": "1"}, 0, false, epoch},
+		{"expired: new holder, new epoch", svcB, map[string]interface{}{"instance": "1"}, 1100 * time.Millisecond, true, epoch + 1},
+		{"stale leader is fenced", svcA, map[string]interface{}{"instance": "1"}, 0, false, epoch + 1},
+		{"release by the holder", svcB, map[string]interface{}{"instance": "1", "release": true}, 0, false, 0},
+		{"free: next epoch", svcA, map[string]interface{}{"instance": "1"}, 0, true, epoch + 2},
+	}
+	for _, st := range steps {
+		time.Sleep(st.wait)
+		ans := beat(st.caller, st.payload)
+		if ans["leader"] != st.wantLeader || fmt.Sprint(ans["epoch"]) != fmt.Sprint(st.wantEpoch) {
+			t.Errorf("%s: leader=%v epoch=%v, want %v %d", st.name, ans["leader"], ans["epoch"], st.wantLeader, st.wantEpoch)
+		}
+		if ans["cron"] != st.wantLeader {
+			t.Errorf("%s: cron = %v, want %v", st.name, ans["cron"], st.wantLeader)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+
+package handler
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	sf "github.com/gogufo/gufo-api-gateway/gufodao"
+	pb "github.com/gogufo/gufo-api-gateway/proto/go"
+	"github.com/gogufo/gufo-api-gateway/transport"
+	"github.com/spf13/viper"
+)
+
+// fakeTransport answers module calls in process and records them.
+type fakeTransport struct {
+	mu     sync.Mutex
+	calls  []*pb.Request
+	answer func(module string, req *pb.Request) (map[string]interface{}, error)
+}
+
+func (f *fakeTransport) Call(ctx context.Context, svc, method string, req *pb.Request) (*pb.Response, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, req)
+	f.mu.Unlock()
+
+	ans, err := f.answer(svc, req)
+	if err != nil {
+		return nil, err
+	}
+	return sf.Interfacetoresponse(req, ans), nil
+}
+
+func (f *fakeTransport) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.calls)
+}
+
+// useTransport registers f as the module transport for the test.
+func useTransport(t *testing.T, f *fakeTransport) {
+	t.Helper()
+	prev := transport.Get()
+	transport.Register(f)
+	t.Cleanup(func() { transport.Register(prev) })
+}
+
+// setConfig sets viper keys for the test and restores them afterwards.
+func setConfig(t *testing.T, values map[string]interface{}) {
+	t.Helper()
+	for k, v := range values {
+		prev, had := viper.Get(k), viper.IsSet(k)
+		viper.Set(k, v)
+		t.Cleanup(func() {
+			if had {
+				viper.Set(k, prev)
+			} else {
+				viper.Set(k, nil)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// Idempotency-Key handling for POST, PATCH and DELETE: the first response
+// is stored and replayed for retries (see the idempotency package).
+
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	sf "github.com/gogufo/gufo-api-gateway/gufodao"
+	"github.com/gogufo/gufo-api-gateway/idempotency"
+	pb "github.com/gogufo/gufo-api-gateway/proto/go"
+)
+
+// ReplayedHeader marks responses served from the idempotency store.
+const ReplayedHeader = "Idempotent-Replayed"
+
+// withIdempotency runs next once per Idempotency-Key. Retries of a completed
+// request get the stored response, concurrent duplicates get 409 and reuse
+// of a key with a different payload gets 422. Store errors fail open.
+func withIdempotency(w http.ResponseWriter, r *http.Request, t *pb.Request, next func(http.ResponseWriter)) {
+	clientKey := r.Header.Get(idempotency.Header)
+	if clientKey == "" || !idempotency.Enabled() ||
+		(r.Method != http.MethodPost && r.Method != http.MethodPatch && r.Method != http.MethodDelete) {
+		next(w)
+		return
+	}
+	if err := idempotency.ValidKey(clientKey); err != nil {
+		errorAnswer(w, r, t, 400, "0000400", err.Error())
+		return
+	}
+
+	key := idempotency.Key(clientKey, idempotencyCaller(r, t), r.Method, r.URL.Path)
+	hash := idempotencyHash(t)
+	store := idempotency.GetStore()
+
+	existing, err := store.Reserve(key, idempotency.Record{
+		State:     idempotency.Pending,
+		BodyHash:  hash,
+		CreatedAt: time.Now(),
+	}, idempotency.LockTTL())
+	if err != nil {
+		sf.SetErrorLog("idempotency: " + err.Error())
+		next(w)
+		return
+	}
+
+	if existing != nil {
+		switch {
+		case existing.BodyHash != hash:
+			errorAnswer(w, r, t, 422, "0000422", "Idempotency-Key reused with a different request")
+		case existing.State != idempotency.Done:
+			errorAnswer(w, r, t, 409, "0000409", "A request with this Idempotency-Key is in progress")
+		default:
+			if existing.ContentType != "" {
+				w.Header().Set("Content-Type", existing.ContentType)
+			}
+			w.Header().Set(ReplayedHeader, "true")
+			w.WriteHeader(existing.Status)
+			w.Write(existing.Body)
+		}
+		return
+	}
+
+	rw := &idempotencyRecorder{ResponseWriter: w, status: http.StatusOK}
+	completed := false
+	defer func() {
+		// 5xx and panics are not stored so the client can retry
+		if !completed || rw.status >= 500 {
+			if err := store.Release(key); err != nil {
+				sf.SetErrorLog("idempotency: " + err.Error())
+			}
+		}
+	}()
+
+	next(rw)
+
+	if rw.status < 500 {
+		err := store.Complete(key, idempotency.Record{
+			State:       idempotency.Done,
+			BodyHash:    hash,
+			Status:      rw.status,
+			ContentType: rw.Header().Get("Content-Type"),
+			Body:        rw.body.Bytes(),
+			CreatedAt:   time.Now(),
+		}, idempotency.TTL())
+		if err != nil {
+			sf.SetErrorLog("idempotency: " + err.Error())
+		}
+	}
+	completed = true
+}
+
+// idempotencyCaller scopes keys to the caller: UID, else API key consumer, else client IP.
+func idempotencyCaller(r *http.Request, t *pb.Request) string {
+	if t.UID != nil && *t.UID != "" {
+		return "uid:" + *t.UID
+	}
+	if c := sf.ContextConsumer(r.Context()); c != "" {
+		return "consumer:" + c
+	}
+	if t.IP != nil {
+		return "ip:" + *t.IP
+	}
+	return ""
+}
+
+// idempotencyHash fingerprints the payload sent upstream (args and ParamID).
+func idempotencyHash(t *pb.Request) string {
+	b, _ := json.Marshal(sf.ToMapStringInterface(t.Args)) // map keys are sorted
+	if t.ParamID != nil {
+		b = append(b, *t.ParamID...)
+	}
+	return idempotency.HashBody(b)
+}
+
+// idempotencyRecorder passes the response through and keeps a copy of it.
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	status      int
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func (rw *idempotencyRecorder) WriteHeader(code int) {
+	if !rw.wroteHeader {
+		rw.status = code
+		rw.wroteHeader = true
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *idempotencyRecorder) Write(b []byte) (int, error) {
+	rw.wroteHeader = true
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
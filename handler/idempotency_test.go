@@ -0,0 +1,133 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	sf "github.com/gogufo/gufo-api-gateway/gufodao"
+	"github.com/gogufo/gufo-api-gateway/idempotency"
+	pb "github.com/gogufo/gufo-api-gateway/proto/go"
+)
+
+// idemCall runs one request through withIdempotency; next answers status
+// with a body that counts the calls.
+func idemCall(method, key, uid string, args map[string]interface{}, calls *int, status int) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(method, "/api/v3/orders", nil)
+	if key != "" {
+		r.Header.Set(idempotency.Header, key)
+	}
+	module := "orders"
+	t := &pb.Request{Module: &module, UID: &uid, Args: sf.ToMapStringAny(args)}
+	withIdempotency(w, r, t, func(w http.ResponseWriter) {
+		*calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, `{"call":%d}`, *calls)
+	})
+	return w
+}
+
+func TestIdempotencyReplay(t *testing.T) {
+	setConfig(t, map[string]interface{}{"idempotency.enabled": true})
+	args := map[string]interface{}{"item": "book"}
+	calls := 0
+
+	tests := []struct {
+		name     string
+		method   string
+		key      string
+		uid      string
+		args     map[string]interface{}
+		status   int // answered by next
+		want     int
+		body     string
+		replayed bool
+		calls    int // total after the step
+	}{
+		{"first", "POST", "replay-1", "u1", args, 201, 201, `{"call":1}`, false, 1},
+		{"retry replays", "POST", "replay-1", "u1", args, 201, 201, `{"call":1}`, true, 1},
+		{"other payload", "POST", "replay-1", "u1", map[string]interface{}{"item": "pen"}, 201, 422, "", false, 1},
+		{"other caller", "POST", "replay-1", "u2", args, 201, 201, `{"call":2}`, false, 2},
+		{"no key", "POST", "", "u1", args, 201, 201, `{"call":3}`, false, 3},
+		{"GET ignores key", "GET", "replay-1", "u1", args, 200, 200, `{"call":4}`, false, 4},
+		{"4xx is stored", "PATCH", "replay-2", "u1", args, 404, 404, `{"call":5}`, false, 5},
+		{"4xx replays", "PATCH", "replay-2", "u1", args, 200, 404, `{"call":5}`, true, 5},
+		{"5xx is released", "DELETE", "replay-3", "u1", args, 503, 503, `{"call":6}`, false, 6},
+		{"retry after 5xx runs", "DELETE", "replay-3", "u1", args, 200, 200, `{"call":7}`, false, 7},
+		{"invalid key", "POST", strings.Repeat("k", 256), "u1", args, 200, 400, "", false, 7},
+	}
+	for _, tt := range tests {
+		w := idemCall(tt.method, tt.key, tt.uid, tt.args, &calls, tt.status)
+		if w.Code != tt.want {
+			t.Fatalf("%s: status %d, want %d (%s)", tt.name, w.Code, tt.want, w.Body)
+		}
+		if tt.body != "" && w.Body.String() != tt.body {
+			t.Fatalf("%s: body %s, want %s", tt.name, w.Body, tt.body)
+		}
+		if got := w.Header().Get(ReplayedHeader) == "true"; got != tt.replayed {
+			t.Fatalf("%s: replayed = %v", tt.name, got)
+		}
+		if calls != tt.calls {
+			t.Fatalf("%s: %d calls, want %d", tt.name, calls, tt.calls)
+		}
+	}
+}
+
+// A duplicate arriving while the first request runs gets 409.
+func TestIdempotencyInFlight(t *testing.T) {
+	setConfig(t, map[string]interface{}{"idempotency.enabled": true})
+
+	started, release := make(chan struct{}), make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		r := httptest.NewRequest(http.MethodPost, "/api/v3/orders", nil)
+		r.Header.Set(idempotency.Header, "inflight-1")
+		module, uid := "orders", "u1"
+		withIdempotency(httptest.NewRecorder(), r, &pb.Request{Module: &module, UID: &uid}, func(w http.ResponseWriter) {
+			close(started)
+			<-release
+		})
+	}()
+	<-started
+
+	calls := 0
+	if w := idemCall(http.MethodPost, "inflight-1", "u1", nil, &calls, 200); w.Code != http.StatusConflict || calls != 0 {
+		t.Fatalf("duplicate: status %d, %d calls", w.Code, calls)
+	}
+	close(release)
+	wg.Wait()
+}
+
+func TestIdempotencyDisabled(t *testing.T) {
+	setConfig(t, map[string]interface{}{"idempotency.enabled": false})
+	calls := 0
+	for range 2 {
+		idemCall(http.MethodPost, "disabled-1", "u1", nil, &calls, 201)
+	}
+	if calls != 2 {
+		t.Fatalf("%d calls with idempotency disabled", calls)
+	}
+}
@@ -0,0 +1,358 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// Inbound webhooks from third-party providers ([[inbound_webhooks]]):
+// POST /api/<version>/hooks/{name} is verified by signature instead of a
+// user session and routed to a module.
+
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	sf "github.com/gogufo/gufo-api-gateway/gufodao"
+	"github.com/gogufo/gufo-api-gateway/idempotency"
+	pb "github.com/gogufo/gufo-api-gateway/proto/go"
+	"github.com/gogufo/gufo-api-gateway/registry"
+	"github.com/gogufo/gufo-api-gateway/transport"
+	"github.com/gogufo/gufo-api-gateway/webhook"
+	"github.com/spf13/viper"
+)
+
+// Signature schemes of inbound webhooks.
+const (
+	// SchemeTimestamped: "t=<unix>,v1=<hex>" over "<t>.<body>" (Gufo, Stripe).
+	SchemeTimestamped = "timestamped"
+	// SchemeHex: hex HMAC-SHA256 after an optional prefix (GitHub "sha256=").
+	SchemeHex = "hex"
+	// SchemeBase64: base64 HMAC-SHA256 (Shopify).
+	SchemeBase64 = "base64"
+)
+
+// InboundWebhook is one [[inbound_webhooks]] entry.
+type InboundWebhook struct {
+	Name            string   `mapstructure:"name"` // route: /api/<version>/hooks/<name>
+	Module          string   `mapstructure:"module"`
+	Param           string   `mapstructure:"param"`
+	Secret          string   `mapstructure:"secret"`     // may be encrypted like other config secrets
+	SecretEnv       string   `mapstructure:"secret_env"` // environment variable with the secret
+	Scheme          string   `mapstructure:"scheme"`     // timestamped (default) | hex | base64
+	SignatureHeader string   `mapstructure:"signature_header"`
+	Prefix          string   `mapstructure:"prefix"`           // stripped from the signature, e.g. "sha256="
+	TimestampHeader string   `mapstructure:"timestamp_header"` // hex/base64: sign "<ts>.<body>" and check tolerance
+	Tolerance       string   `mapstructure:"tolerance"`        // default 5m
+	IDHeader        string   `mapstructure:"id_header"`        // delivery ID used for dedupe
+	DedupeTTL       string   `mapstructure:"dedupe_ttl"`       // default 24h
+	ForwardHeaders  []string `mapstructure:"forward_headers"`  // passed to the module in args "headers"
+	MaxBodySize     int64    `mapstructure:"max_body_size"`    // default 1MB
+}
+
+var (
+	errBadSignature = errors.New("invalid webhook signature")
+	errStale        = errors.New("webhook timestamp outside tolerance")
+)
+
+var inboundHooks struct {
+	sync.RWMutex
+	byName map[string]*InboundWebhook
+	loaded bool
+}
+
+// LoadInboundWebhooks parses [[inbound_webhooks]] and caches them by name.
+// Config reloads call it again (see sf.OnConfigReload).
+func LoadInboundWebhooks() {
+	var hooks []InboundWebhook
+	if err := viper.UnmarshalKey("inbound_webhooks", &hooks); err != nil {
+		sf.SetErrorLog("inbound_webhooks: " + err.Error())
+		hooks = nil
+	}
+	byName := make(map[string]*InboundWebhook, len(hooks))
+	for i := range hooks {
+		if _, dup := byName[hooks[i].Name]; !dup {
+			byName[hooks[i].Name] = &hooks[i]
+		}
+	}
+
+	inboundHooks.Lock()
+	inboundHooks.byName, inboundHooks.loaded = byName, true
+	inboundHooks.Unlock()
+}
+
+// inboundWebhook returns the [[inbound_webhooks]] entry called name.
+func inboundWebhook(name string) *InboundWebhook {
+	inboundHooks.RLock()
+	loaded := inboundHooks.loaded
+	inboundHooks.RUnlock()
+	if !loaded {
+		LoadInboundWebhooks()
+	}
+
+	inboundHooks.RLock()
+	defer inboundHooks.RUnlock()
+	return inboundHooks.byName[name]
+}
+
+// InboundHook serves POST /api/<version>/hooks/{name}.
+func InboundHook(w http.ResponseWriter, r *http.Request, version string) {
+	t := RequestInit(r)
+	t.APIVersion = &version
+
+	hook := inboundWebhook(chi.URLParam(r, "name"))
+	if hook == nil || hook.Module == "" {
+		errorAnswer(w, r, t, 404, "0000404", "Unknown webhook")
+		return
+	}
+
+	limit := hook.MaxBodySize
+	if limit <= 0 {
+		limit = 1 << 20
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+	if err != nil {
+		errorAnswer(w, r, t, 400, "0000400", "Cannot read body")
+		return
+	}
+	if int64(len(body)) > limit {
+		errorAnswer(w, r, t, 413, "0000400", "Webhook body too large")
+		return
+	}
+
+	if err := hook.verify(r.Header, body, time.Now()); err != nil {
+		sf.SetLog(fmt.Sprintf("inbound webhook %s rejected from %s: %v", hook.Name, t.GetIP(), err))
+		errorAnswer(w, r, t, 401, "00001", err.Error())
+		return
+	}
+
+	if registry.IsDrained(hook.Module) {
+		errorAnswer(w, r, t, 503, "0000503", "Module is drained")
+		return
+	}
+
+	// Dedupe by delivery ID: providers retry until they get a 2xx
+	id := ""
+	if hook.IDHeader != "" {
+		id = r.Header.Get(hook.IDHeader)
+	}
+	var key string
+	if id != "" {
+		key = idempotency.Key(id, "webhook:"+hook.Name, http.MethodPost, hook.Name)
+		existing, err := idempotency.GetStore().Reserve(key, idempotency.Record{
+			State:     idempotency.Pending,
+			BodyHash:  idempotency.HashBody(body),
+			CreatedAt: time.Now().UTC(),
+		}, idempotency.LockTTL())
+		if err != nil {
+			errorAnswer(w, r, t, 500, "0000500", err.Error())
+			return
+		}
+		if existing != nil {
+			if existing.State == idempotency.Pending {
+				errorAnswer(w, r, t, 409, "0000409", "Delivery is being processed")
+				return
+			}
+			moduleAnswerv3(w, r, map[string]interface{}{"delivery_id": id, "duplicate": true}, t)
+			return
+		}
+	}
+
+	status, ans, err := hook.call(r, t, id, body)
+	if key != "" {
+		if err != nil || status >= 500 {
+			idempotency.GetStore().Release(key)
+		} else {
+			idempotency.GetStore().Complete(key, idempotency.Record{
+				State:     idempotency.Done,
+				BodyHash:  idempotency.HashBody(body),
+				Status:    status,
+				CreatedAt: time.Now().UTC(),
+			}, hook.dedupeTTL())
+		}
+	}
+	if err != nil {
+		errorAnswer(w, r, t, 502, "0000500", err.Error())
+		return
+	}
+	moduleAnswerv3(w, r, ans, t)
+}
+
+// call forwards the verified delivery. The raw body is preserved in
+// Request.File; args carry webhook, delivery_id, content_type and headers.
+func (h *InboundWebhook) call(r *http.Request, t *pb.Request, id string, body []byte) (int, map[string]interface{}, error) {
+	headers := map[string]interface{}{}
+	for _, name := range h.ForwardHeaders {
+		if v := r.Header.Get(name); v != "" {
+			headers[name] = v
+		}
+	}
+	args := map[string]interface{}{
+		"webhook":      h.Name,
+		"content_type": r.Header.Get("Content-Type"),
+		"headers":      headers,
+	}
+	if id != "" {
+		args["delivery_id"] = id
+	}
+
+	module, method := h.Module, http.MethodPost
+	path := fmt.Sprintf("/api/%s/%s", t.GetAPIVersion(), module)
+	req := &pb.Request{
+		Module:    &module,
+		Method:    &method,
+		Args:      sf.ToMapStringAny(args),
+		File:      body,
+		IP:        t.IP,
+		UserAgent: t.UserAgent,
+	}
+	if h.Param != "" {
+		param := h.Param
+		req.Param = &param
+		path += "/" + param
+	}
+	req.Path = &path
+
+	start := time.Now()
+	resp, err := transport.For(module, h.Param).Call(r.Context(), module, method, req)
+	if err != nil {
+		ObserveUpstream(module, "", "error", start)
+		return 0, nil, err
+	}
+	status := upstreamStatus(resp)
+	ObserveUpstream(module, "", status, start)
+
+	code, _ := strconv.Atoi(status)
+	return code, sf.ToMapStringInterface(resp.Data), nil
+}
+
+// verify checks the provider signature over the raw body.
+func (h *InboundWebhook) verify(header http.Header, body []byte, now time.Time) error {
+	secret := h.secret()
+	if secret == "" {
+		return errors.New("webhook secret is not configured")
+	}
+
+	sigHeader := h.SignatureHeader
+	if sigHeader == "" {
+		sigHeader = webhook.SignatureHeader
+	}
+	sig := strings.TrimSpace(header.Get(sigHeader))
+	if sig == "" {
+		return errBadSignature
+	}
+
+	switch strings.ToLower(h.Scheme) {
+	case "", SchemeTimestamped:
+		ts, sigs := parseTimestamped(sig)
+		if ts == 0 || len(sigs) == 0 {
+			return errBadSignature
+		}
+		if !h.fresh(ts, now) {
+			return errStale
+		}
+		want, _ := hex.DecodeString(webhook.Digest(secret, ts, body))
+		for _, s := range sigs {
+			if got, err := hex.DecodeString(s); err == nil && hmac.Equal(got, want) {
+				return nil
+			}
+		}
+		return errBadSignature
+
+	case SchemeHex, SchemeBase64:
+		signed := body
+		if h.TimestampHeader != "" {
+			ts, err := strconv.ParseInt(header.Get(h.TimestampHeader), 10, 64)
+			if err != nil {
+				return errBadSignature
+			}
+			if !h.fresh(ts, now) {
+				return errStale
+			}
+			signed = append([]byte(strconv.FormatInt(ts, 10)+"."), body...)
+		}
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(signed)
+
+		// compare the decoded bytes: hex digits may come in either case
+		var got []byte
+		var err error
+		if strings.ToLower(h.Scheme) == SchemeBase64 {
+			got, err = base64.StdEncoding.DecodeString(strings.TrimPrefix(sig, h.Prefix))
+		} else {
+			got, err = hex.DecodeString(strings.TrimPrefix(sig, h.Prefix))
+		}
+		if err == nil && hmac.Equal(got, mac.Sum(nil)) {
+			return nil
+		}
+		return errBadSignature
+	}
+	return fmt.Errorf("unknown signature scheme %q", h.Scheme)
+}
+
+// parseTimestamped splits "t=<unix>,v1=<sig>[,v1=<sig>...]".
+func parseTimestamped(v string) (int64, []string) {
+	var ts int64
+	var sigs []string
+	for _, part := range strings.Split(v, ",") {
+		k, val, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch k {
+		case "t":
+			ts, _ = strconv.ParseInt(val, 10, 64)
+		case "v1":
+			sigs = append(sigs, val)
+		}
+	}
+	return ts, sigs
+}
+
+func (h *InboundWebhook) fresh(ts int64, now time.Time) bool {
+	tolerance, err := time.ParseDuration(h.Tolerance)
+	if err != nil || tolerance <= 0 {
+		tolerance = 5 * time.Minute
+	}
+	d := now.Sub(time.Unix(ts, 0))
+	return d <= tolerance && d >= -tolerance
+}
+
+func (h *InboundWebhook) secret() string {
+	if h.SecretEnv != "" {
+		if v, ok := os.LookupEnv(h.SecretEnv); ok && v != "" {
+			return v
+		}
+	}
+	return sf.DecryptConfigPasswords(h.Secret)
+}
+
+func (h *InboundWebhook) dedupeTTL() time.Duration {
+	if d, err := time.ParseDuration(h.DedupeTTL); err == nil && d > 0 {
+		return d
+	}
+	return 24 * time.Hour
+}
@@ -0,0 +1,143 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	sf "github.com/gogufo/gufo-api-gateway/gufodao"
+	"github.com/gogufo/gufo-api-gateway/webhook"
+	"github.com/spf13/viper"
+)
+
+// errAny accepts any verify error.
+var errAny = errors.New("any error")
+
+func TestInboundWebhookVerify(t *testing.T) {
+	body := []byte(`{"id":"evt_1"}`)
+	now := time.Unix(1700000000, 0)
+	ts := now.Unix()
+
+	mac := func(secret string, data []byte) []byte {
+		m := hmac.New(sha256.New, []byte(secret))
+		m.Write(data)
+		return m.Sum(nil)
+	}
+	withTS := append([]byte(strconv.FormatInt(ts, 10)+"."), body...)
+
+	stamped := &InboundWebhook{Secret: "whsec"}
+	github := &InboundWebhook{Secret: "ghs", Scheme: SchemeHex, SignatureHeader: "X-Hub-Signature-256", Prefix: "sha256="}
+	shopify := &InboundWebhook{Secret: "shp", Scheme: SchemeBase64, SignatureHeader: "X-Shopify-Hmac-Sha256"}
+	slack := &InboundWebhook{Secret: "slk", Scheme: SchemeHex, SignatureHeader: "X-Sig", TimestampHeader: "X-Ts", Tolerance: "1m"}
+
+	tests := []struct {
+		name    string
+		hook    *InboundWebhook
+		headers map[string]string
+		want    error // nil, errBadSignature, errStale or errAny
+	}{
+		{"timestamped", stamped, map[string]string{webhook.SignatureHeader: webhook.Sign("whsec", ts, body)}, nil},
+		{"timestamped, rotated secret", stamped, map[string]string{
+			webhook.SignatureHeader: webhook.Sign("old", ts, body) + ",v1=" + webhook.Digest("whsec", ts, body)}, nil},
+		{"timestamped, wrong secret", stamped, map[string]string{webhook.SignatureHeader: webhook.Sign("other", ts, body)}, errBadSignature},
+		{"timestamped, within tolerance", stamped, map[string]string{webhook.SignatureHeader: webhook.Sign("whsec", ts-299, body)}, nil},
+		{"timestamped, stale", stamped, map[string]string{webhook.SignatureHeader: webhook.Sign("whsec", ts-301, body)}, errStale},
+		{"timestamped, future", stamped, map[string]string{webhook.SignatureHeader: webhook.Sign("whsec", ts+301, body)}, errStale},
+		{"timestamped, upper-case hex", stamped, map[string]string{
+			webhook.SignatureHeader: "t=" + strconv.FormatInt(ts, 10) + ",v1=" + strings.ToUpper(webhook.Digest("whsec", ts, body))}, nil},
+		{"timestamped, not hex", stamped, map[string]string{webhook.SignatureHeader: "t=" + strconv.FormatInt(ts, 10) + ",v1=zz"}, errBadSignature},
+		{"timestamped, no t", stamped, map[string]string{webhook.SignatureHeader: "v1=" + webhook.Digest("whsec", ts, body)}, errBadSignature},
+		{"missing header", stamped, nil, errBadSignature},
+
+		{"hex", github, map[string]string{"X-Hub-Signature-256": "sha256=" + hex.EncodeToString(mac("ghs", body))}, nil},
+		{"hex, upper case", github, map[string]string{"X-Hub-Signature-256": "sha256=" + strings.ToUpper(hex.EncodeToString(mac("ghs", body)))}, nil},
+		{"hex, truncated", github, map[string]string{"X-Hub-Signature-256": "sha256=" + hex.EncodeToString(mac("ghs", body))[:62]}, errBadSignature},
+		{"hex, odd length", github, map[string]string{"X-Hub-Signature-256": "sha256=" + hex.EncodeToString(mac("ghs", body))[:63]}, errBadSignature},
+		{"hex, wrong secret", github, map[string]string{"X-Hub-Signature-256": "sha256=" + hex.EncodeToString(mac("x", body))}, errBadSignature},
+		{"hex, default header", github, map[string]string{webhook.SignatureHeader: "sha256=" + hex.EncodeToString(mac("ghs", body))}, errBadSignature},
+
+		{"base64", shopify, map[string]string{"X-Shopify-Hmac-Sha256": base64.StdEncoding.EncodeToString(mac("shp", body))}, nil},
+		{"base64 as hex", shopify, map[string]string{"X-Shopify-Hmac-Sha256": hex.EncodeToString(mac("shp", body))}, errBadSignature},
+
+		{"hex with timestamp", slack, map[string]string{"X-Sig": hex.EncodeToString(mac("slk", withTS)), "X-Ts": strconv.FormatInt(ts, 10)}, nil},
+		{"hex with timestamp, unsigned ts", slack, map[string]string{"X-Sig": hex.EncodeToString(mac("slk", body)), "X-Ts": strconv.FormatInt(ts, 10)}, errBadSignature},
+		{"hex with timestamp, stale", slack, map[string]string{"X-Sig": hex.EncodeToString(mac("slk", withTS)), "X-Ts": strconv.FormatInt(ts-61, 10)}, errStale},
+		{"hex with timestamp, missing ts", slack, map[string]string{"X-Sig": hex.EncodeToString(mac("slk", withTS))}, errBadSignature},
+
+		{"no secret", &InboundWebhook{}, map[string]string{webhook.SignatureHeader: webhook.Sign("", ts, body)}, errAny},
+		{"unknown scheme", &InboundWebhook{Secret: "s", Scheme: "md5"}, map[string]string{webhook.SignatureHeader: "x"}, errAny},
+	}
+	for _, tt := range tests {
+		header := http.Header{}
+		for k, v := range tt.headers {
+			header.Set(k, v)
+		}
+		err := tt.hook.verify(header, body, now)
+		switch {
+		case tt.want == errAny && err == nil,
+			tt.want != errAny && !errors.Is(err, tt.want):
+			t.Errorf("%s: verify = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+// TestInboundWebhookCache: [[inbound_webhooks]] is parsed once and re-read
+// on a config reload.
+func TestInboundWebhookCache(t *testing.T) {
+	setConfig(t, map[string]interface{}{"inbound_webhooks": []map[string]interface{}{
+		{"name": "stripe", "module": "payments", "secret": "a"},
+	}})
+	LoadInboundWebhooks()
+	t.Cleanup(func() {
+		inboundHooks.Lock()
+		inboundHooks.loaded = false
+		inboundHooks.Unlock()
+	})
+	sf.OnConfigReload(LoadInboundWebhooks)
+
+	if h := inboundWebhook("stripe"); h == nil || h.Module != "payments" {
+		t.Fatalf("stripe = %+v", h)
+	}
+	if h := inboundWebhook("github"); h != nil {
+		t.Fatalf("unknown hook = %+v", h)
+	}
+
+	viper.Set("inbound_webhooks", []map[string]interface{}{
+		{"name": "stripe", "module": "billing", "secret": "a"},
+		{"name": "github", "module": "ci", "secret": "b"},
+	})
+	if h := inboundWebhook("stripe"); h == nil || h.Module != "payments" {
+		t.Fatalf("config parsed again before a reload: %+v", h)
+	}
+
+	sf.ReloadConfig()
+	if h := inboundWebhook("stripe"); h == nil || h.Module != "billing" {
+		t.Errorf("stripe after reload = %+v", h)
+	}
+	if h := inboundWebhook("github"); h == nil || h.Module != "ci" {
+		t.Errorf("github after reload = %+v", h)
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// Jobs of asynchronous module calls: GET /api/<version>/jobs/{id} and the
+// result report modules send to the gateway gRPC port.
+
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	sf "github.com/gogufo/gufo-api-gateway/gufodao"
+	"github.com/gogufo/gufo-api-gateway/jobs"
+	pb "github.com/gogufo/gufo-api-gateway/proto/go"
+	"github.com/gogufo/gufo-api-gateway/registry"
+)
+
+// Jobs answers the state of a queued call inside the public pipeline (see
+// serveEdge). Jobs created by a signed-in user are visible to that user and
+// to admins only.
+func Jobs(w http.ResponseWriter, r *http.Request, version string) {
+	serveEdge(w, r, version, nil, func(w http.ResponseWriter, r *http.Request, t *pb.Request) int {
+		job, err := jobs.GetStore().Get(chi.URLParam(r, "id"))
+		if errors.Is(err, jobs.ErrNotFound) {
+			errorAnswer(w, r, t, 404, "0000404", "Job not found")
+			return http.StatusNotFound
+		}
+		if err != nil {
+			errorAnswer(w, r, t, 500, "0000500", err.Error())
+			return http.StatusInternalServerError
+		}
+
+		if job.Owner != "" {
+			t = authenticate(r, t, job.Module)
+		}
+		if job.Owner != "" && (t.UID == nil || *t.UID != job.Owner) && (t.IsAdmin == nil || *t.IsAdmin != 1) {
+			errorAnswer(w, r, t, 404, "0000404", "Job not found")
+			return http.StatusNotFound
+		}
+
+		ans := map[string]interface{}{
+			"job_id":     job.ID,
+			"module":     job.Module,
+			"status":     job.Status,
+			"created_at": job.CreatedAt,
+			"updated_at": job.UpdatedAt,
+		}
+		if job.Param != "" {
+			ans["param"] = job.Param
+		}
+		if job.Finished() {
+			ans["result_httpcode"] = job.HTTPCode
+			if job.Result != nil {
+				ans["result"] = job.Result
+			}
+			if job.Error != "" {
+				ans["error"] = job.Error
+			}
+		}
+		moduleAnswerv3(w, r, ans, t)
+		return http.StatusOK
+	})
+}
+
+// reportJob records a module's report (gRPC: Module "jobs", Param "report")
+// with args job_id, job_token, status (running | done | failed), httpcode,
+// result, error. job_token is the Gufo-Job-Token header of the queued
+// message, so only the consumer of job.Module's message can report; an
+// mTLS caller must also be allowed to speak for job.Module
+// (registry.announce_acl).
+func reportJob(ctx context.Context, t *pb.Request) *pb.Response {
+	args := sf.ToMapStringInterface(t.Args)
+
+	id, _ := args["job_id"].(string)
+	status, _ := args["status"].(string)
+	if id == "" || status == "" {
+		return sf.ErrorReturn(t, 400, "0000400", "job_id and status are required")
+	}
+
+	job, err := jobs.GetStore().Get(id)
+	if errors.Is(err, jobs.ErrNotFound) {
+		return sf.ErrorReturn(t, 404, "0000404", "Job not found")
+	}
+	if err != nil {
+		return sf.ErrorReturn(t, 500, "0000500", err.Error())
+	}
+	token, _ := args["job_token"].(string)
+	if !job.TokenValid(token) {
+		return sf.ErrorReturn(t, 403, "00005", "Reporter is not the job's module")
+	}
+	if caller := sf.ContextIdentity(ctx); caller != "" && !registry.AnnounceAllowed(caller, job.Module) {
+		return sf.ErrorReturn(t, 403, "00005", "Reporter is not the job's module")
+	}
+
+	code := 0
+	if v, ok := args["httpcode"]; ok {
+		code, _ = strconv.Atoi(fmt.Sprint(v))
+	}
+	result, _ := args["result"].(map[string]interface{})
+	errMsg, _ := args["error"].(string)
+
+	job, err = jobs.Report(id, status, code, result, errMsg)
+	if errors.Is(err, jobs.ErrNotFound) {
+		return sf.ErrorReturn(t, 404, "0000404", "Job not found")
+	}
+	if err != nil {
+		return sf.ErrorReturn(t, 400, "0000400", err.Error())
+	}
+	return sf.Interfacetoresponse(t, map[string]interface{}{"job_id": job.ID, "status": job.Status})
+}
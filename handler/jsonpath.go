@@ -0,0 +1,137 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// Minimal JSONPath subset for response reshaping: $.a.b, $.a[0], $.a[*].b
+
+package handler
+
+import (
+	"strconv"
+	"strings"
+)
+
+// jsonPath evaluates path against data (decoded JSON). "[*]" maps the rest
+// of the path over every element of an array.
+func jsonPath(data interface{}, path string) (interface{}, bool) {
+	path = strings.TrimPrefix(strings.TrimSpace(path), "$")
+	return walkPath(data, splitPath(path))
+}
+
+// splitPath turns ".a.b[0][*]" into ["a", "b", "[0]", "[*]"].
+func splitPath(path string) []string {
+	var parts []string
+	for _, seg := range strings.Split(path, ".") {
+		for seg != "" {
+			i := strings.IndexByte(seg, '[')
+			if i < 0 {
+				parts = append(parts, seg)
+				break
+			}
+			if i > 0 {
+				parts = append(parts, seg[:i])
+			}
+			j := strings.IndexByte(seg[i:], ']')
+			if j < 0 {
+				parts = append(parts, seg[i:])
+				break
+			}
+			parts = append(parts, seg[i:i+j+1])
+			seg = seg[i+j+1:]
+		}
+	}
+	return parts
+}
+
+func walkPath(cur interface{}, parts []string) (interface{}, bool) {
+	for i, p := range parts {
+		if strings.HasPrefix(p, "[") && strings.HasSuffix(p, "]") {
+			arr, ok := cur.([]interface{})
+			if !ok {
+				return nil, false
+			}
+			idx := p[1 : len(p)-1]
+			if idx == "*" {
+				out := make([]interface{}, 0, len(arr))
+				for _, item := range arr {
+					if v, ok := walkPath(item, parts[i+1:]); ok {
+						out = append(out, v)
+					}
+				}
+				return out, true
+			}
+			n, err := strconv.Atoi(idx)
+			if err != nil || n < 0 || n >= len(arr) {
+				return nil, false
+			}
+			cur = arr[n]
+			continue
+		}
+
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if cur, ok = m[p]; !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// setPath stores v at a dotted path, creating nested objects as needed.
+func setPath(m map[string]interface{}, path string, v interface{}) {
+	parts := strings.Split(path, ".")
+	for _, p := range parts[:len(parts)-1] {
+		next, ok := m[p].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			m[p] = next
+		}
+		m = next
+	}
+	m[parts[len(parts)-1]] = v
+}
+
+// deletePath removes the value at a dotted path and returns it.
+func deletePath(m map[string]interface{}, path string) (interface{}, bool) {
+	parts := strings.Split(path, ".")
+	for _, p := range parts[:len(parts)-1] {
+		next, ok := m[p].(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		m = next
+	}
+	last := parts[len(parts)-1]
+	v, ok := m[last]
+	delete(m, last)
+	return v, ok
+}
+
+// filterFields keeps only the listed dotted paths (?fields=a,b.c).
+func filterFields(data map[string]interface{}, fields []string) map[string]interface{} {
+	out := map[string]interface{}{}
+	for _, f := range fields {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		if v, ok := walkPath(data, strings.Split(f, ".")); ok {
+			setPath(out, f, v)
+		}
+	}
+	return out
+}
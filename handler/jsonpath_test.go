@@ -0,0 +1,139 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+
+package handler
+
+import (
+	"reflect"
+	"testing"
+)
+
+func sampleDoc() map[string]interface{} {
+	return map[string]interface{}{
+		"user": map[string]interface{}{
+			"id":      float64(7),
+			"profile": map[string]interface{}{"full_name": "Ada"},
+			"tags": []interface{}{
+				map[string]interface{}{"name": "admin"},
+				map[string]interface{}{"name": "ops"},
+				map[string]interface{}{"other": true},
+			},
+		},
+		"matrix": []interface{}{[]interface{}{"a", "b"}, []interface{}{"c"}},
+	}
+}
+
+func TestSplitPath(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{".a.b", []string{"a", "b"}},
+		{".a[0].b", []string{"a", "[0]", "b"}},
+		{".a[*][1]", []string{"a", "[*]", "[1]"}},
+		{"a.b[", []string{"a", "b", "["}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := splitPath(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitPath(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestJSONPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want interface{}
+		ok   bool
+	}{
+		{"$", sampleDoc(), true},
+		{"$.user.id", float64(7), true},
+		{" $.user.profile.full_name ", "Ada", true},
+		{"$.user.tags[1].name", "ops", true},
+		{"$.user.tags[*].name", []interface{}{"admin", "ops"}, true},
+		{"$.matrix[0][1]", "b", true},
+		{"$.matrix[*][0]", []interface{}{"a", "c"}, true},
+		{"$.user.tags[3]", nil, false},
+		{"$.user.tags[-1]", nil, false},
+		{"$.user.tags[x]", nil, false},
+		{"$.user.id.more", nil, false},
+		{"$.user[0]", nil, false},
+		{"$.missing", nil, false},
+	}
+	for _, tt := range tests {
+		got, ok := jsonPath(sampleDoc(), tt.path)
+		if ok != tt.ok || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("jsonPath(%q) = %v, %v, want %v, %v", tt.path, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestSetPath(t *testing.T) {
+	tests := []struct {
+		name string
+		in   map[string]interface{}
+		path string
+		want map[string]interface{}
+	}{
+		{"top level", map[string]interface{}{}, "a", map[string]interface{}{"a": 1}},
+		{"creates objects", map[string]interface{}{}, "a.b.c", map[string]interface{}{"a": map[string]interface{}{"b": map[string]interface{}{"c": 1}}}},
+		{"keeps siblings", map[string]interface{}{"a": map[string]interface{}{"x": 2}}, "a.b", map[string]interface{}{"a": map[string]interface{}{"x": 2, "b": 1}}},
+		{"replaces scalars", map[string]interface{}{"a": "s"}, "a.b", map[string]interface{}{"a": map[string]interface{}{"b": 1}}},
+		{"overwrites", map[string]interface{}{"a": 0}, "a", map[string]interface{}{"a": 1}},
+	}
+	for _, tt := range tests {
+		setPath(tt.in, tt.path, 1)
+		if !reflect.DeepEqual(tt.in, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.in, tt.want)
+		}
+	}
+}
+
+func TestDeletePath(t *testing.T) {
+	doc := sampleDoc()
+	if v, ok := deletePath(doc, "user.profile.full_name"); !ok || v != "Ada" {
+		t.Fatalf("deletePath = %v, %v", v, ok)
+	}
+	if _, ok := jsonPath(doc, "$.user.profile.full_name"); ok {
+		t.Fatal("value still present")
+	}
+	if _, ok := deletePath(doc, "user.id.more"); ok {
+		t.Fatal("deleted below a scalar")
+	}
+	if _, ok := deletePath(doc, "nope.x"); ok {
+		t.Fatal("deleted a missing path")
+	}
+}
+
+func TestFilterFields(t *testing.T) {
+	tests := []struct {
+		fields []string
+		want   map[string]interface{}
+	}{
+		{[]string{"user.id"}, map[string]interface{}{"user": map[string]interface{}{"id": float64(7)}}},
+		{[]string{"user.id", " user.profile.full_name ", ""}, map[string]interface{}{
+			"user": map[string]interface{}{"id": float64(7), "profile": map[string]interface{}{"full_name": "Ada"}},
+		}},
+		{[]string{"matrix"}, map[string]interface{}{"matrix": sampleDoc()["matrix"]}},
+		{[]string{"missing", "user.nope"}, map[string]interface{}{}},
+	}
+	for _, tt := range tests {
+		if got := filterFields(sampleDoc(), tt.fields); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("filterFields(%q) = %v, want %v", tt.fields, got, tt.want)
+		}
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/gogufo/gufo-api-gateway/registry"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
@@ -61,6 +62,50 @@ var (
 			Help: "Number of gRPC connection pool misses.",
 		},
 	)
+
+	// Authentication scheme actually used by callers (for sign → hmac/mtls migration)
+	authSchemeTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gufo_auth_scheme_total",
+			Help: "Authenticated requests, labeled by listener, module and security scheme used.",
+		},
+		[]string{"listener", "module", "scheme"},
+	)
+
+	// Requests per API consumer (API keys)
+	consumerRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gufo_consumer_requests_total",
+			Help: "Requests authenticated by API key, labeled by consumer and module.",
+		},
+		[]string{"consumer", "module"},
+	)
+
+	// Upstream calls per module version (compare error rates during rollouts)
+	upstreamRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gufo_upstream_requests_total",
+			Help: "Upstream module calls, labeled by module, version and status code.",
+		},
+		[]string{"module", "version", "status"},
+	)
+	upstreamRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "gufo_upstream_request_duration_seconds",
+			Help:    "Histogram of upstream module call durations (seconds), labeled by module and version.",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"module", "version"},
+	)
+
+	// Shadow traffic outcomes (sent, match, diff, error, dropped)
+	mirrorRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gufo_mirror_requests_total",
+			Help: "Mirrored requests, labeled by module and result (sent, match, diff, error, dropped).",
+		},
+		[]string{"module", "result"},
+	)
 )
 
 // -------------------------
@@ -72,6 +117,11 @@ func init() {
 	prometheus.MustRegister(httpRequestDuration)
 	prometheus.MustRegister(grpcPoolHits)
 	prometheus.MustRegister(grpcPoolMisses)
+	prometheus.MustRegister(authSchemeTotal)
+	prometheus.MustRegister(consumerRequestsTotal)
+	prometheus.MustRegister(upstreamRequestsTotal)
+	prometheus.MustRegister(upstreamRequestDuration)
+	prometheus.MustRegister(mirrorRequestsTotal)
 }
 
 // -------------------------
@@ -95,6 +145,45 @@ func IncrementGRPCPoolMiss() {
 	grpcPoolMisses.Inc()
 }
 
+// ObserveAuthScheme records which security scheme authenticated a request.
+func ObserveAuthScheme(listener, module, scheme string) {
+	authSchemeTotal.WithLabelValues(listener, moduleLabel(module), scheme).Inc()
+}
+
+// ObserveConsumerRequest counts a request made by an API consumer.
+func ObserveConsumerRequest(consumer, module string) {
+	consumerRequestsTotal.WithLabelValues(consumer, moduleLabel(module)).Inc()
+}
+
+// moduleLabel returns module for modules the gateway knows and "unknown"
+// otherwise. Auth runs before the module is resolved, and a label taken
+// straight from the URL would let any client create new series.
+func moduleLabel(module string) string {
+	switch module {
+	case "heartbeat", "info", "batch", "graphql":
+		return module
+	}
+	if registry.Known(module) {
+		return module
+	}
+	return "unknown"
+}
+
+// ObserveUpstream records an upstream module call. An empty version means
+// the module's unversioned endpoint.
+func ObserveUpstream(module, version, status string, start time.Time) {
+	if version == "" {
+		version = "default"
+	}
+	upstreamRequestsTotal.WithLabelValues(module, version, status).Inc()
+	upstreamRequestDuration.WithLabelValues(module, version).Observe(time.Since(start).Seconds())
+}
+
+// ObserveMirror records the outcome of a mirrored request.
+func ObserveMirror(module, result string) {
+	mirrorRequestsTotal.WithLabelValues(module, result).Inc()
+}
+
 // MetricsHandler exposes all registered Prometheus metrics.
 func MetricsHandler() http.Handler {
 	return promhttp.Handler()
@@ -0,0 +1,181 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// Traffic mirroring: replay a sample of live requests to a shadow endpoint
+
+package handler
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	sf "github.com/gogufo/gufo-api-gateway/gufodao"
+	pb "github.com/gogufo/gufo-api-gateway/proto/go"
+	"github.com/gogufo/gufo-api-gateway/transport"
+	"github.com/spf13/viper"
+	"google.golang.org/protobuf/proto"
+)
+
+// mirrorSlots caps in-flight shadow calls; requests beyond it are dropped
+// rather than queued so mirroring never builds up pressure on the gateway.
+var mirrorSlots = make(chan struct{}, 100)
+
+// mirrorConfig is microservices.<module>.mirror.
+type mirrorConfig struct {
+	Host    string
+	Port    string
+	Percent float64
+	Methods []string
+	Diff    bool
+	Ignore  []string
+	Timeout time.Duration
+}
+
+func getMirrorConfig(module string) (mirrorConfig, bool) {
+	prefix := fmt.Sprintf("microservices.%s.mirror.", module)
+	cfg := mirrorConfig{
+		Host:    viper.GetString(prefix + "host"),
+		Port:    viper.GetString(prefix + "port"),
+		Percent: 100,
+		Methods: viper.GetStringSlice(prefix + "methods"),
+		Diff:    viper.GetBool(prefix + "diff"),
+		Ignore:  viper.GetStringSlice(prefix + "ignore"),
+		Timeout: viper.GetDuration(prefix + "timeout"),
+	}
+	if cfg.Host == "" || cfg.Port == "" {
+		return cfg, false
+	}
+	if viper.IsSet(prefix + "percent") {
+		cfg.Percent = viper.GetFloat64(prefix + "percent")
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	if len(cfg.Methods) == 0 {
+		// replaying writes into a shadow that may share databases, queues or
+		// payment providers repeats their side effects, so they are opt-in
+		cfg.Methods = []string{"GET", "HEAD"}
+	}
+	return cfg, true
+}
+
+// mirror replays a sampled copy of t to the module's shadow endpoint after
+// the primary call. The shadow answer is discarded; with diff enabled its
+// Data is compared with the primary answer and the result is recorded.
+func mirror(ctx context.Context, method string, t *pb.Request, primary *pb.Response) {
+	module := *t.Module
+	cfg, ok := getMirrorConfig(module)
+	if !ok {
+		return
+	}
+	if !containsFold(cfg.Methods, method) {
+		return
+	}
+	if rand.Float64()*100 >= cfg.Percent {
+		return
+	}
+
+	select {
+	case mirrorSlots <- struct{}{}:
+	default:
+		ObserveMirror(module, "dropped")
+		return
+	}
+
+	shadow := proto.Clone(t).(*pb.Request)
+	consumer := sf.ContextConsumer(ctx)
+
+	go func() {
+		defer func() { <-mirrorSlots }()
+
+		mctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+		defer cancel()
+		if consumer != "" {
+			mctx = sf.WithConsumer(mctx, consumer)
+		}
+
+		resp, err := transport.CallAt(mctx, module, cfg.Host, cfg.Port, shadow)
+		if err != nil {
+			ObserveMirror(module, "error")
+			sf.SetDebugLog("mirror " + module + ": " + err.Error())
+			return
+		}
+		if !cfg.Diff {
+			ObserveMirror(module, "sent")
+			return
+		}
+
+		keys := diffData(
+			sf.ToMapStringInterface(primary.Data),
+			sf.ToMapStringInterface(resp.Data),
+			cfg.Ignore,
+		)
+		if len(keys) == 0 {
+			ObserveMirror(module, "match")
+			return
+		}
+		ObserveMirror(module, "diff")
+		sf.SetLog(fmt.Sprintf("mirror %s %s/%s: response differs in %s",
+			method, module, safeParam(t), strings.Join(keys, ", ")))
+	}()
+}
+
+// diffData returns the sorted top-level keys whose values differ.
+func diffData(a, b map[string]interface{}, ignore []string) []string {
+	var keys []string
+	seen := map[string]bool{}
+	for _, k := range ignore {
+		seen[k] = true
+	}
+	check := func(k string) {
+		if seen[k] {
+			return
+		}
+		seen[k] = true
+		if !reflect.DeepEqual(a[k], b[k]) {
+			keys = append(keys, k)
+		}
+	}
+	for k := range a {
+		check(k)
+	}
+	for k := range b {
+		check(k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func safeParam(t *pb.Request) string {
+	if t.Param == nil {
+		return ""
+	}
+	return *t.Param
+}
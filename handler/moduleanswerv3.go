@@ -20,7 +20,6 @@
 package handler
 
 import (
-	"encoding/json"
 	"net/http"
 	"reflect"
 	"strconv"
@@ -70,14 +69,18 @@ func moduleAnswerv3(w http.ResponseWriter, r *http.Request, s map[string]interfa
 		delete(out, "httpcode")
 	}
 
-	// Allow microservice to override Content-Type
+	// Response format: route config or Accept (see negotiate.go)
+	format := negotiateFormat(r)
+	contentType := formatContentType[format]
+
+	// Allow microservice to override Content-Type (JSON formats only)
 	if ct, ok := out["Content-Type"]; ok {
 		if cts, ok2 := ct.(string); ok2 {
-			w.Header().Set("Content-Type", cts)
+			if format == FormatEnvelope || format == FormatRaw {
+				contentType = cts
+			}
 			delete(out, "Content-Type")
 		}
-	} else {
-		w.Header().Set("Content-Type", "application/json")
 	}
 
 	// Propagate X-Request-ID if present
@@ -85,10 +88,17 @@ func moduleAnswerv3(w http.ResponseWriter, r *http.Request, s map[string]interfa
 		w.Header().Set("X-Request-ID", rid)
 	}
 
-	// Language field
+	// Language field: module answer, then the request language
 	resp.Language = "eng"
-	if out["lang"] != nil {
-		resp.Language = out["lang"].(string)
+	if lang, ok := out["lang"].(string); ok && lang != "" {
+		resp.Language = lang
+	} else if t.Language != nil && *t.Language != "" {
+		resp.Language = *t.Language
+	}
+
+	// Per-route response transforms and ?fields= filtering (success only)
+	if httpsstatus < 400 {
+		out = transformResponse(r, out)
 	}
 
 	// Timestamp
@@ -106,8 +116,8 @@ func moduleAnswerv3(w http.ResponseWriter, r *http.Request, s map[string]interfa
 		resp.Session = session
 	}
 
-	// Marshal response to JSON
-	answer, err := json.Marshal(resp)
+	// Marshal response in the negotiated format
+	answer, err := encodeAnswer(format, resp, httpsstatus)
 	if err != nil {
 		if viper.GetBool("server.sentry") {
 			sentry.CaptureException(err)
@@ -121,6 +131,8 @@ func moduleAnswerv3(w http.ResponseWriter, r *http.Request, s map[string]interfa
 	for i := 0; i < len(HeaderKeys); i++ {
 		w.Header().Set(HeaderKeys[i], HeaderValues[i])
 	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Add("Vary", "Accept")
 
 	// Final response
 	w.WriteHeader(httpsstatus)
@@ -0,0 +1,185 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// Response content negotiation: JSON envelope, raw data, protobuf,
+// MessagePack and CBOR
+
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+	sf "github.com/gogufo/gufo-api-gateway/gufodao"
+	pb "github.com/gogufo/gufo-api-gateway/proto/go"
+	"github.com/spf13/viper"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+)
+
+// Response formats.
+const (
+	FormatEnvelope = "envelope" // {data, session, timestamp, lang} as JSON (default)
+	FormatRaw      = "raw"      // Data only, as JSON
+	FormatProtobuf = "protobuf" // pb.Response, binary
+	FormatMsgpack  = "msgpack"  // envelope as MessagePack
+	FormatCBOR     = "cbor"     // envelope as CBOR
+)
+
+// mediaFormats maps Accept media types to formats.
+var mediaFormats = map[string]string{
+	"application/json":              FormatEnvelope,
+	"application/vnd.gufo.raw+json": FormatRaw,
+	"application/x-protobuf":        FormatProtobuf,
+	"application/protobuf":          FormatProtobuf,
+	"application/msgpack":           FormatMsgpack,
+	"application/x-msgpack":         FormatMsgpack,
+	"application/cbor":              FormatCBOR,
+}
+
+// formatContentType is the Content-Type written for each format.
+var formatContentType = map[string]string{
+	FormatEnvelope: "application/json",
+	FormatRaw:      "application/json",
+	FormatProtobuf: "application/x-protobuf",
+	FormatMsgpack:  "application/msgpack",
+	FormatCBOR:     "application/cbor",
+}
+
+// routeFormat returns the format configured for the route of r
+// (formats.routes = ["module/param=format"]), or formats.default.
+func routeFormat(r *http.Request) string {
+	module, param := "", ""
+	if parts := strings.Split(r.URL.Path, "/"); len(parts) > 4 {
+		module, param = parts[3], parts[4]
+	} else if len(parts) > 3 {
+		module = parts[3]
+	}
+
+	for _, kv := range viper.GetStringSlice("formats.routes") {
+		pattern, format, ok := strings.Cut(kv, "=")
+		if ok && routeMatches(strings.TrimSpace(pattern), module, param) {
+			return normalizeFormat(format)
+		}
+	}
+	return normalizeFormat(viper.GetString("formats.default"))
+}
+
+func normalizeFormat(f string) string {
+	f = strings.ToLower(strings.TrimSpace(f))
+	if _, ok := formatContentType[f]; ok {
+		return f
+	}
+	return FormatEnvelope
+}
+
+// negotiateFormat picks the response format. An explicit supported media
+// type in Accept wins; application/json and */* keep the route's JSON
+// format (envelope or raw).
+func negotiateFormat(r *http.Request) string {
+	base := routeFormat(r)
+
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		f, ok := mediaFormats[mt]
+		if !ok {
+			continue
+		}
+		q := 1.0
+		if qs, ok := params["q"]; ok {
+			q, _ = strconv.ParseFloat(qs, 64)
+		}
+		if q > bestQ {
+			best, bestQ = f, q
+		}
+	}
+
+	switch {
+	case best == "":
+		return base
+	case best == FormatEnvelope && (base == FormatEnvelope || base == FormatRaw):
+		return base
+	}
+	return best
+}
+
+// encodeAnswer renders resp in format. status is the HTTP status of the
+// answer; error answers carry Data {code, message} in every format.
+func encodeAnswer(format string, resp sf.Response, status int) ([]byte, error) {
+	envelope := map[string]interface{}{
+		"data":      resp.Data,
+		"session":   resp.Session,
+		"timestamp": resp.TimeStamp,
+		"lang":      resp.Language,
+	}
+
+	switch format {
+	case FormatRaw:
+		return json.Marshal(resp.Data)
+	case FormatMsgpack:
+		return msgpack.Marshal(envelope)
+	case FormatCBOR:
+		return cbor.Marshal(envelope)
+	case FormatProtobuf:
+		return proto.Marshal(protoAnswer(resp, status))
+	}
+	return json.Marshal(resp)
+}
+
+// protoAnswer maps the envelope onto pb.Response: Data as Any values, the
+// session in RequestBack and a unified Error for 4xx/5xx answers.
+func protoAnswer(resp sf.Response, status int) *pb.Response {
+	out := &pb.Response{Data: sf.ToMapStringAny(resp.Data)}
+
+	lang := resp.Language
+	rb := &pb.Request{Language: &lang}
+	if resp.Session != nil {
+		if uid, ok := resp.Session["uid"].(*string); ok {
+			rb.UID = uid
+		}
+		if v, ok := resp.Session["isAdmin"].(*int32); ok {
+			rb.IsAdmin = v
+		}
+		if v, ok := resp.Session["Sesionexp"].(*int32); ok {
+			rb.SessionEnd = v
+		}
+		if v, ok := resp.Session["completed"].(*int32); ok {
+			rb.Completed = v
+		}
+		if v, ok := resp.Session["readonly"].(*int32); ok {
+			rb.Readonly = v
+		}
+	}
+	out.RequestBack = rb
+
+	if status >= 400 {
+		out.Error = &pb.Error{
+			Code:    int32(status),
+			Message: fmt.Sprint(resp.Data["message"]),
+			Meta:    map[string]string{"code": fmt.Sprint(resp.Data["code"])},
+		}
+	}
+	return out
+}
@@ -28,7 +28,7 @@ import (
 	pb "github.com/gogufo/gufo-api-gateway/proto/go"
 )
 
-func ProcessOPTIONS(w http.ResponseWriter, r *http.Request, t *pb.Request, version int) {
+func ProcessOPTIONS(w http.ResponseWriter, r *http.Request, t *pb.Request, version string) {
 
 	for i := 0; i < len(HeaderKeys); i++ {
 		w.Header().Set(HeaderKeys[i], HeaderValues[i])
@@ -24,9 +24,9 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strings"
-	"time"
 
 	sf "github.com/gogufo/gufo-api-gateway/gufodao"
 	pb "github.com/gogufo/gufo-api-gateway/proto/go"
@@ -34,40 +34,10 @@ import (
 	"github.com/spf13/viper"
 )
 
-func ProcessREQ(w http.ResponseWriter, r *http.Request, t *pb.Request, version int) {
+func ProcessREQ(w http.ResponseWriter, r *http.Request, t *pb.Request, version string) {
 
-	// ===========================
-	//  SECURITY CHECK (same as gRPC Do)
-	// ===========================
-	mode := strings.ToLower(viper.GetString("security.mode"))
-
-	switch mode {
-	case "hmac":
-		secret := viper.GetString("security.hmac_secret")
-		maxAge := time.Duration(viper.GetInt("security.max_age")) * time.Second
-
-		if t.Sign == nil || t.Module == nil ||
-			!sf.VerifyHMAC(secret, *t.Module, *t.Sign, maxAge) {
-
-			errorAnswer(w, r, t, 401, "00001", "Invalid or expired HMAC signature")
-			return
-		}
-
-	case "sign":
-		if t.Sign == nil || viper.GetString("server.sign") != *t.Sign {
-			errorAnswer(w, r, t, 401, "00001", "Invalid signature")
-			return
-		}
-
-	case "mtls":
-		// r.TLS != nil only when HTTPS with client certificate
-		if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
-			errorAnswer(w, r, t, 401, "00001", "Client certificate required (mTLS)")
-			return
-		}
-
-	default:
-		errorAnswer(w, r, t, 500, "00002", "Security mode not configured")
+	r, ok := checkSecurity(w, r, t)
+	if !ok {
 		return
 	}
 
@@ -86,10 +56,7 @@ func ProcessREQ(w http.ResponseWriter, r *http.Request, t *pb.Request, version i
 		return
 
 	}
-	//Plagin Name
-
-	vrs := "v1"
-	t.APIVersion = &vrs
+	//Plagin Name (APIVersion is set from the mount, see applyMount)
 
 	if *t.Module == "entrypoint" {
 		errorAnswer(w, r, t, 401, "0000235", "Wrong module")
@@ -102,7 +69,12 @@ func ProcessREQ(w http.ResponseWriter, r *http.Request, t *pb.Request, version i
 	}
 
 	if r.Method == "POST" || r.Method == "DELETE" || r.Method == "PATCH" {
-		t.Args = sf.ToMapStringAny(parseJSONArgs(r))
+		args, err := parseJSONArgs(r)
+		if err != nil {
+			errorAnswer(w, r, t, sf.BodyErrorStatus(err), "0000400", err.Error())
+			return
+		}
+		t.Args = sf.ToMapStringAny(args)
 	}
 
 	if r.Method == "GET" && r.URL.Query() != nil || r.Method == "TRACE" && r.URL.Query() != nil || r.Method == "HEAD" && r.URL.Query() != nil {
@@ -117,16 +89,21 @@ func ProcessREQ(w http.ResponseWriter, r *http.Request, t *pb.Request, version i
 
 	}
 
-	//check for session
-	if viper.GetBool("server.session") {
+	// 🔐 Check session (may already be resolved by edge authentication)
+	if viper.GetBool("server.session") && t.UID == nil {
 		t = checksession(t, r)
+	}
+	if t.UID != nil && t.Readonly != nil && *t.Readonly == int32(1) {
+		errorAnswer(w, r, t, 401, "0000235", "Read Only User")
+		return
+	}
 
-		if t.UID != nil && *t.Readonly == int32(1) {
-
-			errorAnswer(w, r, t, 401, "0000235", "Read Only User")
-			return
+	// 🔧 Per-route request transforms ([[transforms]])
+	transformArgs(r, t)
 
-		}
+	// 📈 Usage quota (per consumer)
+	if !checkQuota(w, r, t) {
+		return
 	}
 
 	//Load microservice
@@ -134,14 +111,27 @@ func ProcessREQ(w http.ResponseWriter, r *http.Request, t *pb.Request, version i
 		Info(w, r, t)
 		return
 	}
-	connectgrpc(w, r, t)
+
+	// 🔁 Idempotency-Key (POST/PATCH/DELETE)
+	withIdempotency(w, r, t, func(w http.ResponseWriter) {
+		connectgrpc(w, r, t)
+	})
 
 }
 
-func parseJSONArgs(r *http.Request) map[string]interface{} {
+// parseJSONArgs decodes a JSON object body. A body that is not a JSON
+// object gives no args; an unreadable body is an error (see
+// sf.BodyErrorStatus).
+func parseJSONArgs(r *http.Request) (map[string]interface{}, error) {
+	if err := sf.DecodeRequestBody(r); err != nil {
+		return nil, err
+	}
 	var args map[string]interface{}
 	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
-		return nil
+		if errors.Is(err, sf.ErrBodyTooLarge) || errors.Is(err, sf.ErrCorruptBody) {
+			return nil, err
+		}
+		return nil, nil
 	}
-	return args
+	return args, nil
 }
@@ -0,0 +1,58 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	sf "github.com/gogufo/gufo-api-gateway/gufodao"
+)
+
+func TestParseJSONArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding string
+		body     string
+		want     map[string]interface{}
+		status   int // 0: no error
+	}{
+		{"object", "", `{"a":"b"}`, map[string]interface{}{"a": "b"}, 0},
+		{"not JSON", "", `a=b`, nil, 0},
+		{"empty", "", ``, nil, 0},
+		{"unsupported encoding", "br", `{"a":"b"}`, nil, http.StatusUnsupportedMediaType},
+		{"corrupt gzip", "gzip", `{"a":"b"}`, nil, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/api/v3/users", strings.NewReader(tt.body))
+		if tt.encoding != "" {
+			r.Header.Set("Content-Encoding", tt.encoding)
+		}
+		args, err := parseJSONArgs(r)
+		switch {
+		case tt.status == 0 && err != nil:
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		case tt.status != 0 && (err == nil || sf.BodyErrorStatus(err) != tt.status):
+			t.Errorf("%s: err = %v, want status %d", tt.name, err, tt.status)
+		case !reflect.DeepEqual(args, tt.want):
+			t.Errorf("%s: args = %v, want %v", tt.name, args, tt.want)
+		}
+	}
+}
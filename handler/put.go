@@ -25,48 +25,16 @@ package handler
 import (
 	"net/http"
 	"strings"
-	"time"
 
-	sf "github.com/gogufo/gufo-api-gateway/gufodao"
 	pb "github.com/gogufo/gufo-api-gateway/proto/go"
 
 	"github.com/spf13/viper"
 )
 
-func ProcessPUT(w http.ResponseWriter, r *http.Request, t *pb.Request, version int) {
+func ProcessPUT(w http.ResponseWriter, r *http.Request, t *pb.Request, version string) {
 
-	// ===========================
-	//  SECURITY CHECK (sign / HMAC / mTLS)
-	// ===========================
-	mode := strings.ToLower(viper.GetString("security.mode"))
-
-	switch mode {
-
-	case "hmac":
-		secret := viper.GetString("security.hmac_secret")
-		maxAge := time.Duration(viper.GetInt("security.max_age")) * time.Second
-
-		if t.Sign == nil || t.Module == nil ||
-			!sf.VerifyHMAC(secret, *t.Module, *t.Sign, maxAge) {
-
-			errorAnswer(w, r, t, 401, "00001", "Invalid or expired HMAC signature")
-			return
-		}
-
-	case "sign":
-		if t.Sign == nil || viper.GetString("server.sign") != *t.Sign {
-			errorAnswer(w, r, t, 401, "00001", "Invalid signature")
-			return
-		}
-
-	case "mtls":
-		if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
-			errorAnswer(w, r, t, 401, "00001", "Client certificate required (mTLS)")
-			return
-		}
-
-	default:
-		errorAnswer(w, r, t, 500, "00002", "Security mode not configured")
+	r, ok := checkSecurity(w, r, t)
+	if !ok {
 		return
 	}
 
@@ -77,20 +45,22 @@ func ProcessPUT(w http.ResponseWriter, r *http.Request, t *pb.Request, version i
 		return
 	}
 
-	// 🔐 Check session
-	if viper.GetBool("server.session") {
+	// 🔐 Check session (may already be resolved by edge authentication)
+	if viper.GetBool("server.session") && t.UID == nil {
 		t = checksession(t, r)
-		if t.UID != nil && *t.Readonly == int32(1) {
-			errorAnswer(w, r, t, 401, "0000235", "Read Only User")
-			return
-		}
+	}
+	if t.UID != nil && t.Readonly != nil && *t.Readonly == int32(1) {
+		errorAnswer(w, r, t, 401, "0000235", "Read Only User")
+		return
 	}
 
-	vrs := "v3"
-	if version == 2 {
-		vrs = "v2"
+	// 🔧 Per-route request transforms ([[transforms]])
+	transformArgs(r, t)
+
+	// 📈 Usage quota (per consumer)
+	if !checkQuota(w, r, t) {
+		return
 	}
-	t.APIVersion = &vrs
 
 	param := "stream"
 	t.IR = &pb.InternalRequest{Param: &param}
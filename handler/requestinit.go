@@ -23,7 +23,6 @@ import (
 	sf "github.com/gogufo/gufo-api-gateway/gufodao"
 	pb "github.com/gogufo/gufo-api-gateway/proto/go"
 	"github.com/microcosm-cc/bluemonday"
-	"github.com/spf13/viper"
 )
 
 func RequestInit(r *http.Request) *pb.Request {
@@ -39,11 +38,15 @@ func RequestInit(r *http.Request) *pb.Request {
 	t.Path = &path
 	t.Method = &r.Method
 
-	sgn := viper.GetString("server.sign")
 	curip := sf.ReadUserIP(r)
 	usagent := r.UserAgent()
 
-	t.Sign = &sgn
+	// Only a credential supplied by the caller (services calling through
+	// REST) is taken; the gateway never stamps its own secret here.
+	// Upstream calls are re-signed per call by sf.Gufosign.
+	if sgn := r.Header.Get("X-Sign"); sgn != "" {
+		t.Sign = &sgn
+	}
 
 	t.IP = &curip
 
@@ -0,0 +1,228 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// Edge authentication for the public REST listener
+// (shared by GET/POST/... and PUT).
+
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gogufo/gufo-api-gateway/apikey"
+	sf "github.com/gogufo/gufo-api-gateway/gufodao"
+	"github.com/gogufo/gufo-api-gateway/metering"
+	pb "github.com/gogufo/gufo-api-gateway/proto/go"
+	"github.com/spf13/viper"
+	"google.golang.org/protobuf/proto"
+)
+
+// checkSecurity authenticates the caller against the edge modes configured
+// for its module (see sf.EdgeModes). It writes the error answer itself
+// and returns false when the request must not be processed. The returned
+// request carries the API consumer in its context when one was identified.
+func checkSecurity(w http.ResponseWriter, r *http.Request, t *pb.Request) (*http.Request, bool) {
+	r, fail := edgeAuth(r, t, &edgeIdentity{})
+	if fail != nil {
+		errorAnswer(w, r, t, fail.status, fail.code, fail.msg)
+		return r, false
+	}
+	return r, true
+}
+
+// edgeFailure is a rejected edge authentication.
+type edgeFailure struct {
+	status    int
+	code, msg string
+}
+
+// edgeIdentity caches the session and JWT identity of one HTTP request, so
+// that batch items and GraphQL fields authenticated one by one do not ask
+// the session service again. A nil identity means the scheme did not match.
+type edgeIdentity struct {
+	mu                   sync.Mutex
+	session, jwt         *pb.Request
+	sessionDone, jwtDone bool
+}
+
+// lookup returns the identity that mode ("session" or "jwt") establishes
+// for r. base supplies the fields the session service call needs.
+func (ids *edgeIdentity) lookup(mode string, r *http.Request, base *pb.Request) *pb.Request {
+	ids.mu.Lock()
+	defer ids.mu.Unlock()
+
+	switch mode {
+	case "session":
+		if !ids.sessionDone {
+			ids.sessionDone = true
+			if viper.GetBool("server.session") {
+				if s := checksession(proto.Clone(base).(*pb.Request), r); s.UID != nil {
+					ids.session = s
+				}
+			}
+		}
+		return ids.session
+	case "jwt":
+		if !ids.jwtDone {
+			ids.jwtDone = true
+			if j := (&pb.Request{}); checkJWT(r, j) {
+				ids.jwt = j
+			}
+		}
+		return ids.jwt
+	}
+	return nil
+}
+
+// applyIdentity copies the session fields of id into t.
+func applyIdentity(t, id *pb.Request) {
+	t.UID, t.IsAdmin, t.SessionEnd, t.Completed, t.Readonly = id.UID, id.IsAdmin, id.SessionEnd, id.Completed, id.Readonly
+	if id.Token != nil {
+		t.Token, t.TokenType = id.Token, id.TokenType
+	}
+}
+
+// authenticate fills the session fields of t from the session or JWT the
+// caller presents, trying only the schemes module accepts. It never rejects;
+// callers decide what an anonymous caller may see.
+func authenticate(r *http.Request, t *pb.Request, module string) *pb.Request {
+	ids := &edgeIdentity{}
+	for _, mode := range sf.EdgeModes(module) {
+		if id := ids.lookup(mode, r, t); id != nil {
+			applyIdentity(t, id)
+			break
+		}
+	}
+	return t
+}
+
+// edgeAuth is checkSecurity without the answer: it authenticates t for
+// its module and param and reports why it failed. Batch items and GraphQL
+// field calls go through it one by one with a shared ids.
+func edgeAuth(r *http.Request, t *pb.Request, ids *edgeIdentity) (*http.Request, *edgeFailure) {
+	module, param := t.GetModule(), t.GetParam()
+
+	modes := sf.EdgeModes(module)
+	known := false
+
+	for _, mode := range modes {
+		ok := false
+
+		switch mode {
+		case "anonymous", "none":
+			known = true
+			ok = true
+
+		case "session", "jwt":
+			// a session token does not satisfy "jwt" and vice versa
+			known = true
+			if id := ids.lookup(mode, r, t); id != nil {
+				applyIdentity(t, id)
+				ok = true
+			}
+
+		case "apikey":
+			known = true
+			plain := apikey.FromRequest(r)
+			if plain == "" {
+				break
+			}
+			k, err := apikey.Authenticate(r.Context(), r, plain, module, param)
+			switch {
+			case err == nil:
+				r = r.WithContext(sf.WithConsumer(r.Context(), k.Consumer))
+				if rec := metering.FromContext(r.Context()); rec != nil {
+					rec.Consumer, rec.Module, rec.APIKey, rec.KeyID = k.Consumer, module, true, k.ID
+				}
+				ObserveConsumerRequest(k.Consumer, module)
+				ok = true
+			case errors.Is(err, apikey.ErrRateLimited):
+				return r, &edgeFailure{429, "000429", err.Error()}
+			case errors.Is(err, apikey.ErrQuotaReached):
+				return r, &edgeFailure{429, "000430", err.Error()}
+			case errors.Is(err, apikey.ErrScope):
+				return r, &edgeFailure{403, "00005", err.Error()}
+			default:
+				// a presented but invalid key is rejected outright
+				return r, &edgeFailure{401, "00001", "Invalid API key"}
+			}
+
+		case "hmac", "sign", "token":
+			// services calling through REST send their credential in X-Sign
+			known = true
+			ok = sf.CheckSign(mode, t)
+
+		case "mtls":
+			// r.TLS != nil only when HTTPS with client certificate
+			known = true
+			ok = r.TLS != nil && len(r.TLS.PeerCertificates) > 0
+		}
+
+		if ok {
+			ObserveAuthScheme(sf.ListenerREST, module, mode)
+			return r, nil
+		}
+	}
+
+	if !known {
+		return r, &edgeFailure{500, "00002", "Security mode not configured"}
+	}
+	if len(modes) == 1 && modes[0] == "mtls" {
+		return r, &edgeFailure{401, "00001", "Client certificate required (mTLS)"}
+	}
+	return r, &edgeFailure{401, "00001", "Unauthorized"}
+}
+
+// checkJWT verifies an HS256 bearer token and fills session fields from its claims.
+func checkJWT(r *http.Request, t *pb.Request) bool {
+	parts := strings.SplitN(strings.TrimSpace(r.Header.Get("Authorization")), " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+		return false
+	}
+
+	claims, err := sf.VerifyJWT(parts[1])
+	if err != nil {
+		sf.SetErrorLog("checkJWT: " + err.Error())
+		return false
+	}
+
+	uid := ""
+	if sub, ok := claims["sub"]; ok && sub != nil {
+		uid = fmt.Sprintf("%v", sub)
+	}
+	if uid == "" {
+		sf.SetErrorLog("checkJWT: token has no sub claim")
+		return false
+	}
+	t.UID = &uid
+	if admin, ok := claims["admin"].(bool); ok && admin {
+		t.IsAdmin = sf.Int32Ptr(1)
+	}
+	t.Readonly = sf.Int32Ptr(0)
+	if ro, ok := claims["readonly"].(bool); ok && ro {
+		t.Readonly = sf.Int32Ptr(1)
+	}
+	if exp, ok := claims["exp"].(float64); ok {
+		t.SessionEnd = sf.Int32Ptr(int32(exp))
+	}
+	tkn := parts[1]
+	t.Token = &tkn
+	return true
+}
@@ -0,0 +1,302 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// Declarative per-route request/response transforms ([[transforms]])
+
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+
+	sf "github.com/gogufo/gufo-api-gateway/gufodao"
+	pb "github.com/gogufo/gufo-api-gateway/proto/go"
+	"github.com/spf13/viper"
+)
+
+// TransformRule is one [[transforms]] entry. Name/value options are lists of
+// "name=value" strings so that names keep their case (viper lowercases map keys).
+type TransformRule struct {
+	Match    string            `mapstructure:"match"`   // "module", "module/param", "module/*" or "*"
+	Methods  []string          `mapstructure:"methods"` // empty = all
+	Request  RequestTransform  `mapstructure:"request"`
+	Response ResponseTransform `mapstructure:"response"`
+}
+
+// RequestTransform is applied before the call reaches connectgrpc.
+type RequestTransform struct {
+	SetHeaders    []string `mapstructure:"set_headers"`
+	RemoveHeaders []string `mapstructure:"remove_headers"`
+	RenameHeaders []string `mapstructure:"rename_headers"`
+	SetArgs       []string `mapstructure:"set_args"`     // value is JSON-decoded when valid
+	DefaultArgs   []string `mapstructure:"default_args"` // only when the arg is missing
+	RemoveArgs    []string `mapstructure:"remove_args"`
+	RenameArgs    []string `mapstructure:"rename_args"`
+	FromSession   []string `mapstructure:"from_session"` // arg=uid|ip|is_admin|lang|user_agent|consumer
+}
+
+// ResponseTransform is applied to Response.Data before it is marshaled.
+type ResponseTransform struct {
+	Map         []string `mapstructure:"map"` // out.key=$.json.path — rebuilds Data
+	Rename      []string `mapstructure:"rename"`
+	Remove      []string `mapstructure:"remove"`
+	Set         []string `mapstructure:"set"`
+	FieldsParam string   `mapstructure:"fields_param"` // query parameter selecting fields (e.g. "fields"); empty = off
+}
+
+type transformKey struct{}
+
+var transformCache struct {
+	sync.Once
+	rules []TransformRule
+}
+
+// transformRules returns the [[transforms]] rules, parsed once.
+func transformRules() []TransformRule {
+	transformCache.Do(func() {
+		if !viper.IsSet("transforms") {
+			return
+		}
+		if err := viper.UnmarshalKey("transforms", &transformCache.rules); err != nil {
+			sf.SetErrorLog("transforms: " + err.Error())
+			transformCache.rules = nil
+		}
+	})
+	return transformCache.rules
+}
+
+// matchTransform returns the first [[transforms]] rule for the public route
+// of r (/api/<version>/<module>/<param>...).
+func matchTransform(r *http.Request) *TransformRule {
+	parts := strings.Split(r.URL.Path, "/")
+	module, param := "", ""
+	if len(parts) > 3 {
+		module = parts[3]
+	}
+	if len(parts) > 4 {
+		param = parts[4]
+	}
+	return matchRoute(r.Method, module, param)
+}
+
+// matchRoute returns the first [[transforms]] rule for a public module and
+// param called with method. Batch items and GraphQL fields use it directly.
+func matchRoute(method, module, param string) *TransformRule {
+	rules := transformRules()
+	for i := range rules {
+		rule := &rules[i]
+		if len(rule.Methods) > 0 && !containsFold(rule.Methods, method) {
+			continue
+		}
+		if routeMatches(rule.Match, module, param) {
+			return rule
+		}
+	}
+	return nil
+}
+
+func routeMatches(pattern, module, param string) bool {
+	if pattern == "*" {
+		return true
+	}
+	pm, pp, hasParam := strings.Cut(pattern, "/")
+	if pm != module {
+		return false
+	}
+	return !hasParam || pp == "*" || pp == param
+}
+
+func withTransform(ctx context.Context, rule *TransformRule) context.Context {
+	if rule == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, transformKey{}, rule)
+}
+
+func transformFrom(ctx context.Context) *TransformRule {
+	rule, _ := ctx.Value(transformKey{}).(*TransformRule)
+	return rule
+}
+
+// transformHeaders rewrites incoming headers; it runs before RequestInit so
+// that renamed credentials (e.g. a partner's key header) are picked up.
+func transformHeaders(r *http.Request, rule *TransformRule) {
+	if rule == nil {
+		return
+	}
+	tr := rule.Request
+	for _, kv := range tr.RenameHeaders {
+		from, to, ok := strings.Cut(kv, "=")
+		if !ok {
+			continue
+		}
+		if vals := r.Header.Values(from); len(vals) > 0 {
+			r.Header.Del(from)
+			for _, v := range vals {
+				r.Header.Add(to, v)
+			}
+		}
+	}
+	for _, h := range tr.RemoveHeaders {
+		r.Header.Del(h)
+	}
+	for _, kv := range tr.SetHeaders {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			r.Header.Set(strings.TrimSpace(k), strings.TrimSpace(v))
+		}
+	}
+}
+
+// transformArgs reshapes t.Args for the route's rule (from r's context).
+func transformArgs(r *http.Request, t *pb.Request) {
+	rule := transformFrom(r.Context())
+	if rule == nil {
+		return
+	}
+	tr := rule.Request
+	if len(tr.SetArgs)+len(tr.DefaultArgs)+len(tr.RemoveArgs)+len(tr.RenameArgs)+len(tr.FromSession) == 0 {
+		return
+	}
+
+	args := sf.ToMapStringInterface(t.Args)
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+
+	for _, kv := range tr.RenameArgs {
+		if from, to, ok := strings.Cut(kv, "="); ok {
+			if v, ok := args[from]; ok {
+				delete(args, from)
+				args[to] = v
+			}
+		}
+	}
+	for _, k := range tr.RemoveArgs {
+		delete(args, k)
+	}
+	for _, kv := range tr.DefaultArgs {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			if _, exists := args[k]; !exists {
+				args[k] = decodeValue(v)
+			}
+		}
+	}
+	for _, kv := range tr.SetArgs {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			args[k] = decodeValue(v)
+		}
+	}
+	for _, kv := range tr.FromSession {
+		if k, src, ok := strings.Cut(kv, "="); ok {
+			if v, ok := sessionValue(r, t, src); ok {
+				args[k] = v
+			}
+		}
+	}
+
+	t.Args = sf.ToMapStringAny(args)
+}
+
+func sessionValue(r *http.Request, t *pb.Request, src string) (interface{}, bool) {
+	switch strings.ToLower(src) {
+	case "uid":
+		return derefString(t.UID)
+	case "ip":
+		return derefString(t.IP)
+	case "lang":
+		return derefString(t.Language)
+	case "user_agent":
+		return derefString(t.UserAgent)
+	case "is_admin":
+		if t.IsAdmin == nil {
+			return nil, false
+		}
+		return *t.IsAdmin == 1, true
+	case "consumer":
+		c := sf.ContextConsumer(r.Context())
+		return c, c != ""
+	}
+	return nil, false
+}
+
+func derefString(s *string) (interface{}, bool) {
+	if s == nil || *s == "" {
+		return nil, false
+	}
+	return *s, true
+}
+
+// decodeValue returns v decoded as JSON (numbers, booleans, objects),
+// or the raw string.
+func decodeValue(v string) interface{} {
+	var out interface{}
+	if err := json.Unmarshal([]byte(v), &out); err == nil {
+		return out
+	}
+	return v
+}
+
+// transformResponse reshapes successful answers: the route's response rule
+// first, then field selection when the rule enables it (fields_param).
+func transformResponse(r *http.Request, data map[string]interface{}) map[string]interface{} {
+	rule := transformFrom(r.Context())
+	if rule == nil {
+		return data
+	}
+
+	data = transformData(rule, data)
+	if param := rule.Response.FieldsParam; param != "" {
+		if fields := r.URL.Query().Get(param); fields != "" {
+			data = filterFields(data, strings.Split(fields, ","))
+		}
+	}
+	return data
+}
+
+// transformData applies the response part of rule to data.
+func transformData(rule *TransformRule, data map[string]interface{}) map[string]interface{} {
+	tr := rule.Response
+	if len(tr.Map) > 0 {
+		mapped := map[string]interface{}{}
+		for _, kv := range tr.Map {
+			if k, path, ok := strings.Cut(kv, "="); ok {
+				if v, ok := jsonPath(data, path); ok {
+					setPath(mapped, k, v)
+				}
+			}
+		}
+		data = mapped
+	}
+	for _, kv := range tr.Rename {
+		if from, to, ok := strings.Cut(kv, "="); ok {
+			if v, ok := deletePath(data, from); ok {
+				setPath(data, to, v)
+			}
+		}
+	}
+	for _, k := range tr.Remove {
+		deletePath(data, k)
+	}
+	for _, kv := range tr.Set {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			setPath(data, k, decodeValue(v))
+		}
+	}
+	return data
+}
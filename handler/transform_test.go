@@ -0,0 +1,88 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestRouteMatches(t *testing.T) {
+	tests := []struct {
+		pattern, module, param string
+		want                   bool
+	}{
+		{"*", "users", "get", true},
+		{"users", "users", "get", true},
+		{"users/*", "users", "get", true},
+		{"users/get", "users", "get", true},
+		{"users/get", "users", "list", false},
+		{"users", "orders", "get", false},
+	}
+	for _, tt := range tests {
+		if got := routeMatches(tt.pattern, tt.module, tt.param); got != tt.want {
+			t.Errorf("routeMatches(%q, %s, %s) = %v", tt.pattern, tt.module, tt.param, got)
+		}
+	}
+}
+
+// ?fields= only filters answers on routes whose rule sets fields_param.
+func TestTransformResponseFields(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  *TransformRule
+		query string
+		want  map[string]interface{}
+	}{
+		{"no rule", nil, "fields=user.id", sampleDoc()},
+		{"rule without fields_param", &TransformRule{Match: "*"}, "fields=user.id", sampleDoc()},
+		{"fields_param", &TransformRule{Response: ResponseTransform{FieldsParam: "fields"}}, "fields=user.id",
+			map[string]interface{}{"user": map[string]interface{}{"id": float64(7)}}},
+		{"custom param", &TransformRule{Response: ResponseTransform{FieldsParam: "select"}}, "fields=matrix&select=user.id",
+			map[string]interface{}{"user": map[string]interface{}{"id": float64(7)}}},
+		{"param absent", &TransformRule{Response: ResponseTransform{FieldsParam: "fields"}}, "", sampleDoc()},
+		{"after map", &TransformRule{Response: ResponseTransform{Map: []string{"id=$.user.id", "name=$.user.profile.full_name"}, FieldsParam: "fields"}},
+			"fields=name", map[string]interface{}{"name": "Ada"}},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/api/v3/users/get?"+tt.query, nil)
+		r = r.WithContext(withTransform(r.Context(), tt.rule))
+		if got := transformResponse(r, sampleDoc()); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTransformData(t *testing.T) {
+	rule := &TransformRule{Response: ResponseTransform{
+		Map:    []string{"id=$.user.id", "tags=$.user.tags[*].name", "profile.name=$.user.profile.full_name", "gone=$.nope"},
+		Rename: []string{"id=user_id"},
+		Remove: []string{"tags"},
+		Set:    []string{"api=\"v2\"", "meta.count=2"},
+	}}
+	want := map[string]interface{}{
+		"user_id": float64(7),
+		"profile": map[string]interface{}{"name": "Ada"},
+		"api":     "v2",
+		"meta":    map[string]interface{}{"count": float64(2)},
+	}
+	if got := transformData(rule, sampleDoc()); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// Usage quotas per consumer (the report is served by the admin API).
+
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	sf "github.com/gogufo/gufo-api-gateway/gufodao"
+	"github.com/gogufo/gufo-api-gateway/metering"
+	pb "github.com/gogufo/gufo-api-gateway/proto/go"
+)
+
+// checkQuota identifies the consumer (API key consumer, else "uid:<UID>"),
+// fills the usage record and rejects the request once a quota is used up.
+func checkQuota(w http.ResponseWriter, r *http.Request, t *pb.Request) bool {
+	if fail := quotaFailure(r, t); fail != nil {
+		errorAnswer(w, r, t, fail.status, fail.code, fail.msg)
+		return false
+	}
+	return true
+}
+
+// markForwarded flags the usage record of ctx as billable: the call was
+// handed to the module. Rejected requests are never marked.
+func markForwarded(ctx context.Context) {
+	if rec := metering.FromContext(ctx); rec != nil {
+		rec.Forwarded = true
+	}
+}
+
+// MarkRPC fills the usage record of a call served by ServeRPC once it is
+// authorized: consumer is the caller identity (mTLS), if any. Calls on the
+// gRPC port carry no record and are not metered.
+func MarkRPC(ctx context.Context, module, consumer string) {
+	if rec := metering.FromContext(ctx); rec != nil {
+		rec.Consumer, rec.Module, rec.Forwarded = consumer, module, true
+	}
+}
+
+// quotaFailure is checkQuota without the answer (batch items, GraphQL calls).
+func quotaFailure(r *http.Request, t *pb.Request) *edgeFailure {
+	rec := metering.FromContext(r.Context())
+	if rec == nil {
+		return nil
+	}
+
+	if rec.Consumer == "" {
+		if c := sf.ContextConsumer(r.Context()); c != "" {
+			rec.Consumer = c
+		} else if t.UID != nil && *t.UID != "" {
+			rec.Consumer = "uid:" + *t.UID
+		}
+	}
+	if t.Module != nil {
+		rec.Module = *t.Module
+	}
+
+	if exceeded, which := metering.Exceeded(rec.Consumer); exceeded {
+		return &edgeFailure{429, "000430", "Usage quota exceeded (" + which + ")"}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gogufo/gufo-api-gateway/metering"
+	pb "github.com/gogufo/gufo-api-gateway/proto/go"
+)
+
+func TestRejectedCallsAreNotMetered(t *testing.T) {
+	setConfig(t, map[string]interface{}{
+		"metering.enabled":              true,
+		"metering.quotas.default.daily": 2,
+		"security.edge_mode":            "anonymous",
+	})
+	failing := false
+	f := &fakeTransport{answer: func(string, *pb.Request) (map[string]interface{}, error) {
+		if failing {
+			return nil, errors.New("module unreachable")
+		}
+		return map[string]interface{}{"ok": true}, nil
+	}}
+	useTransport(t, f)
+
+	call := func(uid string) int {
+		module, method := "orders", "GET"
+		req := &pb.Request{Module: &module, Method: &method, UID: &uid}
+		r := httptest.NewRequest("POST", "/api/v3/batch", nil)
+		status, _, _ := subCall(r, &edgeIdentity{}, req, nil, time.Second)
+		return status
+	}
+
+	tests := []struct {
+		name    string
+		failing bool
+		status  int
+		used    int64
+	}{
+		{"forwarded", false, 200, 1},
+		{"module unreachable", true, 502, 1},
+		{"forwarded again", false, 200, 2},
+		{"quota reached", false, 429, 2},
+		{"still over quota", false, 429, 2},
+	}
+	for _, tt := range tests {
+		failing = tt.failing
+		if status := call("metered-1"); status != tt.status {
+			t.Fatalf("%s: status %d, want %d", tt.name, status, tt.status)
+		}
+		if used := metering.Used("uid:metered-1", metering.Day); used != tt.used {
+			t.Fatalf("%s: %d requests counted, want %d", tt.name, used, tt.used)
+		}
+	}
+	if f.count() != 3 {
+		t.Fatalf("%d calls reached the transport, want 3", f.count())
+	}
+}
+
+func TestServeEdgeMetersForwardedOnly(t *testing.T) {
+	setConfig(t, map[string]interface{}{
+		"metering.enabled":              true,
+		"metering.quotas.default.daily": 1,
+	})
+
+	// the REST handler path: quota check, then the module call
+	serve := func() int {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/api/v3/orders/list", nil)
+		serveEdge(w, r, "v3", nil, func(w http.ResponseWriter, r *http.Request, t *pb.Request) int {
+			uid := "metered-2"
+			t.UID = &uid
+			if !checkQuota(w, r, t) {
+				return http.StatusTooManyRequests
+			}
+			markForwarded(r.Context())
+			return http.StatusOK
+		})
+		return w.Code
+	}
+
+	for i, want := range []int{200, 429, 429} {
+		if status := serve(); status != want {
+			t.Fatalf("request %d: status %d, want %d", i, status, want)
+		}
+	}
+	if used := metering.Used("uid:metered-2", metering.Day); used != 1 {
+		t.Fatalf("%d requests counted, want 1 (rejected calls are free)", used)
+	}
+}
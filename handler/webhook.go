@@ -0,0 +1,58 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// Webhook events emitted by modules through the gateway gRPC port.
+
+package handler
+
+import (
+	"context"
+
+	sf "github.com/gogufo/gufo-api-gateway/gufodao"
+	pb "github.com/gogufo/gufo-api-gateway/proto/go"
+	"github.com/gogufo/gufo-api-gateway/webhook"
+)
+
+// emitWebhook fans an event out to matching subscribers
+// (IR.Param "webhook", IR args event and data; ParamID may carry the event).
+// In mTLS mode the emitting module is the verified peer identity.
+func emitWebhook(ctx context.Context, t *pb.Request) *pb.Response {
+	args := sf.ToMapStringInterface(t.IR.Args)
+
+	event, _ := args["event"].(string)
+	if event == "" {
+		event = t.IR.GetParamID()
+	}
+	if event == "" {
+		return sf.ErrorReturn(t, 400, "0000400", "event is required")
+	}
+
+	module := sf.ContextIdentity(ctx)
+	if module == "" {
+		module = t.GetModule()
+	}
+
+	ids, err := webhook.Emit(module, event, args["data"])
+	if err != nil {
+		sf.SetErrorLog("webhook emit: " + err.Error())
+		return sf.ErrorReturn(t, 500, "0000500", "Cannot queue webhook deliveries")
+	}
+	return sf.Interfacetoresponse(t, map[string]interface{}{
+		"event":      event,
+		"deliveries": len(ids),
+		"ids":        ids,
+	})
+}
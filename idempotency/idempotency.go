@@ -0,0 +1,127 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// Idempotency keys for unsafe methods: the first response to a key is
+// stored and replayed for retries of the same request.
+
+package idempotency
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Header is the request header carrying the client's key.
+const Header = "Idempotency-Key"
+
+// Record states.
+const (
+	Pending = "pending" // first request still in flight
+	Done    = "done"    // response stored
+)
+
+// Record is what the store keeps per key.
+type Record struct {
+	State       string    `json:"state"`
+	BodyHash    string    `json:"body_hash"`
+	Status      int       `json:"status,omitempty"`
+	ContentType string    `json:"content_type,omitempty"`
+	Body        []byte    `json:"body,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Store persists idempotency records.
+type Store interface {
+	// Reserve claims key for an in-flight request. When the key is already
+	// known it returns the existing record and does not change it.
+	Reserve(key string, rec Record, ttl time.Duration) (*Record, error)
+	// Complete stores the final response for key.
+	Complete(key string, rec Record, ttl time.Duration) error
+	// Release forgets key so the request can be retried.
+	Release(key string) error
+}
+
+var (
+	store     Store
+	storeOnce sync.Once
+)
+
+// Enabled reports whether Idempotency-Key handling is on (idempotency.enabled, default true).
+func Enabled() bool {
+	if !viper.IsSet("idempotency.enabled") {
+		return true
+	}
+	return viper.GetBool("idempotency.enabled")
+}
+
+// GetStore returns the configured store (idempotency.store = memory | redis).
+func GetStore() Store {
+	storeOnce.Do(func() {
+		switch strings.ToLower(viper.GetString("idempotency.store")) {
+		case "redis":
+			store = newRedisStore()
+		default:
+			store = newMemoryStore()
+		}
+	})
+	return store
+}
+
+// TTL is how long completed responses are kept (idempotency.ttl, default 24h).
+func TTL() time.Duration {
+	if d := viper.GetDuration("idempotency.ttl"); d > 0 {
+		return d
+	}
+	return 24 * time.Hour
+}
+
+// LockTTL bounds how long an in-flight reservation blocks retries
+// (idempotency.lock_ttl, default 1m).
+func LockTTL() time.Duration {
+	if d := viper.GetDuration("idempotency.lock_ttl"); d > 0 {
+		return d
+	}
+	return time.Minute
+}
+
+// Key derives the storage key from the client key, the caller and the route.
+func Key(clientKey, caller, method, route string) string {
+	sum := sha256.Sum256([]byte(clientKey + "|" + caller + "|" + method + " " + route))
+	return hex.EncodeToString(sum[:])
+}
+
+// HashBody fingerprints the request payload.
+func HashBody(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// ErrInvalidKey is returned for empty or oversized client keys.
+var ErrInvalidKey = errors.New("idempotency: key must be 1-255 characters")
+
+// ValidKey checks the client-supplied key.
+func ValidKey(k string) error {
+	if k == "" || len(k) > 255 {
+		return ErrInvalidKey
+	}
+	return nil
+}
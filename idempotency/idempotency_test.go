@@ -0,0 +1,113 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+
+package idempotency
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	sf "github.com/gogufo/gufo-api-gateway/gufodao"
+	"github.com/gogufo/gufo-api-gateway/internal/redistest"
+)
+
+func TestValidKey(t *testing.T) {
+	tests := []struct {
+		key string
+		ok  bool
+	}{
+		{"", false},
+		{"a", true},
+		{strings.Repeat("k", 255), true},
+		{strings.Repeat("k", 256), false},
+	}
+	for _, tt := range tests {
+		if err := ValidKey(tt.key); (err == nil) != tt.ok {
+			t.Errorf("ValidKey(len %d) = %v", len(tt.key), err)
+		}
+	}
+}
+
+// Keys are scoped to the caller, the method and the route.
+func TestKeyScope(t *testing.T) {
+	base := Key("k1", "uid:1", "POST", "/api/v3/orders")
+	if Key("k1", "uid:1", "POST", "/api/v3/orders") != base {
+		t.Fatal("Key is not deterministic")
+	}
+	for _, other := range []string{
+		Key("k2", "uid:1", "POST", "/api/v3/orders"),
+		Key("k1", "uid:2", "POST", "/api/v3/orders"),
+		Key("k1", "uid:1", "PATCH", "/api/v3/orders"),
+		Key("k1", "uid:1", "POST", "/api/v3/users"),
+	} {
+		if other == base {
+			t.Fatal("keys of different requests collide")
+		}
+	}
+}
+
+func TestStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) Store{
+		"memory": func(t *testing.T) Store { return newMemoryStore() },
+		"redis": func(t *testing.T) Store {
+			prev := sf.CachePool
+			sf.CachePool, _ = redistest.NewPool()
+			t.Cleanup(func() { sf.CachePool = prev })
+			return newRedisStore()
+		},
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			s := newStore(t)
+			pending := Record{State: Pending, BodyHash: "h1"}
+
+			existing, err := s.Reserve("k", pending, time.Minute)
+			if err != nil || existing != nil {
+				t.Fatalf("first Reserve = %v, %v", existing, err)
+			}
+			existing, err = s.Reserve("k", Record{State: Pending, BodyHash: "h2"}, time.Minute)
+			if err != nil || existing == nil || existing.State != Pending || existing.BodyHash != "h1" {
+				t.Fatalf("second Reserve = %+v, %v", existing, err)
+			}
+
+			done := Record{State: Done, BodyHash: "h1", Status: 201, ContentType: "application/json", Body: []byte(`{"id":1}`)}
+			if err := s.Complete("k", done, time.Minute); err != nil {
+				t.Fatal(err)
+			}
+			existing, err = s.Reserve("k", pending, time.Minute)
+			if err != nil || existing == nil || existing.State != Done || existing.Status != 201 || string(existing.Body) != `{"id":1}` {
+				t.Fatalf("Reserve after Complete = %+v, %v", existing, err)
+			}
+
+			if err := s.Release("k"); err != nil {
+				t.Fatal(err)
+			}
+			if existing, err = s.Reserve("k", pending, time.Minute); err != nil || existing != nil {
+				t.Fatalf("Reserve after Release = %v, %v", existing, err)
+			}
+
+			// an expired reservation no longer blocks
+			if existing, err = s.Reserve("short", pending, 10*time.Millisecond); err != nil || existing != nil {
+				t.Fatal(existing, err)
+			}
+			time.Sleep(20 * time.Millisecond)
+			if existing, err = s.Reserve("short", pending, time.Minute); err != nil || existing != nil {
+				t.Fatalf("Reserve after expiry = %v, %v", existing, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// Idempotency store backends: in-memory and Redis (sf.CachePool).
+
+package idempotency
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	sf "github.com/gogufo/gufo-api-gateway/gufodao"
+	"github.com/gomodule/redigo/redis"
+)
+
+// -------------------------------------------------------------------
+// Memory store
+// -------------------------------------------------------------------
+
+type memoryItem struct {
+	rec     Record
+	expires time.Time
+}
+
+type memoryStore struct {
+	mu    sync.Mutex
+	items map[string]memoryItem
+}
+
+func newMemoryStore() *memoryStore {
+	s := &memoryStore{items: map[string]memoryItem{}}
+	go s.sweep()
+	return s
+}
+
+func (s *memoryStore) Reserve(key string, rec Record, ttl time.Duration) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if it, ok := s.items[key]; ok && time.Now().Before(it.expires) {
+		existing := it.rec
+		return &existing, nil
+	}
+	s.items[key] = memoryItem{rec: rec, expires: time.Now().Add(ttl)}
+	return nil, nil
+}
+
+func (s *memoryStore) Complete(key string, rec Record, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.items[key] = memoryItem{rec: rec, expires: time.Now().Add(ttl)}
+	return nil
+}
+
+func (s *memoryStore) Release(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.items, key)
+	return nil
+}
+
+func (s *memoryStore) sweep() {
+	ticker := time.NewTicker(time.Minute)
+	for range ticker.C {
+		now := time.Now()
+		s.mu.Lock()
+		for k, it := range s.items {
+			if now.After(it.expires) {
+				delete(s.items, k)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// -------------------------------------------------------------------
+// Redis store
+// -------------------------------------------------------------------
+//
+// gufo:idem:<key> JSON Record, reserved with SET NX
+
+const redisIdemPrefix = "gufo:idem:"
+
+type redisStore struct{}
+
+func newRedisStore() *redisStore {
+	sf.EnsureCache()
+	return &redisStore{}
+}
+
+func (s *redisStore) Reserve(key string, rec Record, ttl time.Duration) (*Record, error) {
+	conn := sf.CachePool.Get()
+	defer conn.Close()
+
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := redis.String(conn.Do("SET", redisIdemPrefix+key, b, "NX", "PX", ttl.Milliseconds()))
+	if err == nil && ok == "OK" {
+		return nil, nil
+	}
+	if err != nil && err != redis.ErrNil {
+		return nil, err
+	}
+
+	raw, err := redis.Bytes(conn.Do("GET", redisIdemPrefix+key))
+	if err == redis.ErrNil {
+		// expired between SET and GET: try once more
+		return s.Reserve(key, rec, ttl)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var existing Record
+	if err := json.Unmarshal(raw, &existing); err != nil {
+		return nil, err
+	}
+	return &existing, nil
+}
+
+func (s *redisStore) Complete(key string, rec Record, ttl time.Duration) error {
+	conn := sf.CachePool.Get()
+	defer conn.Close()
+
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Do("SET", redisIdemPrefix+key, b, "PX", ttl.Milliseconds())
+	return err
+}
+
+func (s *redisStore) Release(key string) error {
+	conn := sf.CachePool.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("DEL", redisIdemPrefix+key)
+	return err
+}
@@ -0,0 +1,360 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// Package redistest is an in-process stand-in for Redis in unit tests.
+// It implements the string, set and hash commands the gateway's stores use
+// (no Lua scripts) behind a redigo pool.
+
+package redistest
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// Server holds the data shared by all connections of a pool.
+type Server struct {
+	mu      sync.Mutex
+	strings map[string][]byte
+	sets    map[string]map[string]bool
+	hashes  map[string]map[string][]byte
+	expires map[string]time.Time
+}
+
+// NewPool returns a pool whose connections share one empty Server.
+func NewPool() (*redis.Pool, *Server) {
+	s := &Server{
+		strings: map[string][]byte{},
+		sets:    map[string]map[string]bool{},
+		hashes:  map[string]map[string][]byte{},
+		expires: map[string]time.Time{},
+	}
+	return &redis.Pool{Dial: func() (redis.Conn, error) { return &conn{s: s}, nil }}, s
+}
+
+// Keys returns the live keys, sorted.
+func (s *Server) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []string
+	for _, m := range []map[string]bool{keysOf(s.strings), keysOf(s.sets), keysOf(s.hashes)} {
+		for k := range m {
+			if !s.expired(k) {
+				out = append(out, k)
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func keysOf[V any](m map[string]V) map[string]bool {
+	out := make(map[string]bool, len(m))
+	for k := range m {
+		out[k] = true
+	}
+	return out
+}
+
+// expired drops key if its TTL has passed. s.mu must be held.
+func (s *Server) expired(key string) bool {
+	at, ok := s.expires[key]
+	if !ok || time.Now().Before(at) {
+		return false
+	}
+	delete(s.strings, key)
+	delete(s.sets, key)
+	delete(s.hashes, key)
+	delete(s.expires, key)
+	return true
+}
+
+type conn struct {
+	s       *Server
+	pending [][]interface{}
+	replies []interface{}
+	closed  bool
+}
+
+func (c *conn) Close() error { c.closed = true; return nil }
+func (c *conn) Err() error {
+	if c.closed {
+		return errors.New("redistest: connection closed")
+	}
+	return nil
+}
+func (c *conn) Flush() error { return nil }
+
+func (c *conn) Send(cmd string, args ...interface{}) error {
+	c.pending = append(c.pending, append([]interface{}{cmd}, args...))
+	return nil
+}
+
+func (c *conn) Receive() (interface{}, error) {
+	c.run()
+	if len(c.replies) == 0 {
+		return nil, errors.New("redistest: no pending reply")
+	}
+	r := c.replies[0]
+	c.replies = c.replies[1:]
+	if err, ok := r.(redis.Error); ok {
+		return nil, err
+	}
+	return r, nil
+}
+
+// run executes the pipelined commands; MULTI queues until EXEC.
+func (c *conn) run() {
+	var queued [][]interface{}
+	inMulti := false
+	for _, p := range c.pending {
+		name := strings.ToUpper(fmt.Sprint(p[0]))
+		switch {
+		case name == "MULTI":
+			inMulti = true
+			c.replies = append(c.replies, "OK")
+		case name == "EXEC":
+			var results []interface{}
+			for _, q := range queued {
+				results = append(results, c.s.do(strings.ToUpper(fmt.Sprint(q[0])), q[1:]))
+			}
+			queued, inMulti = nil, false
+			c.replies = append(c.replies, results)
+		case inMulti:
+			queued = append(queued, p)
+			c.replies = append(c.replies, "QUEUED")
+		default:
+			c.replies = append(c.replies, c.s.do(name, p[1:]))
+		}
+	}
+	c.pending = nil
+}
+
+func (c *conn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	if cmd != "" {
+		c.Send(cmd, args...)
+	}
+	c.run()
+	if len(c.replies) == 0 {
+		return nil, nil
+	}
+	r := c.replies[len(c.replies)-1]
+	c.replies = nil
+	if err, ok := r.(redis.Error); ok {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (s *Server) do(cmd string, args []interface{}) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	str := func(i int) string {
+		if i >= len(args) {
+			return ""
+		}
+		switch v := args[i].(type) {
+		case []byte:
+			return string(v)
+		default:
+			return fmt.Sprint(v)
+		}
+	}
+	if len(args) > 0 {
+		s.expired(str(0))
+	}
+
+	switch cmd {
+	case "PING":
+		return "PONG"
+
+	case "GET":
+		if v, ok := s.strings[str(0)]; ok {
+			return v
+		}
+		return nil
+
+	case "SET":
+		key := str(0)
+		var ttl time.Duration
+		for i := 2; i < len(args); i++ {
+			switch strings.ToUpper(str(i)) {
+			case "NX":
+				if _, ok := s.strings[key]; ok {
+					return nil
+				}
+			case "EX":
+				n, _ := strconv.Atoi(str(i + 1))
+				ttl, i = time.Duration(n)*time.Second, i+1
+			case "PX":
+				n, _ := strconv.Atoi(str(i + 1))
+				ttl, i = time.Duration(n)*time.Millisecond, i+1
+			}
+		}
+		s.strings[key] = []byte(str(1))
+		delete(s.expires, key)
+		if ttl > 0 {
+			s.expires[key] = time.Now().Add(ttl)
+		}
+		return "OK"
+
+	case "DEL":
+		n := int64(0)
+		for i := range args {
+			k := str(i)
+			s.expired(k)
+			_, a := s.strings[k]
+			_, b := s.sets[k]
+			_, c := s.hashes[k]
+			if a || b || c {
+				n++
+			}
+			delete(s.strings, k)
+			delete(s.sets, k)
+			delete(s.hashes, k)
+			delete(s.expires, k)
+		}
+		return n
+
+	case "INCR", "INCRBY":
+		by := int64(1)
+		if cmd == "INCRBY" {
+			by, _ = strconv.ParseInt(str(1), 10, 64)
+		}
+		n, _ := strconv.ParseInt(string(s.strings[str(0)]), 10, 64)
+		n += by
+		s.strings[str(0)] = []byte(strconv.FormatInt(n, 10))
+		return n
+
+	case "EXPIRE", "PEXPIRE":
+		n, _ := strconv.Atoi(str(1))
+		d := time.Duration(n) * time.Second
+		if cmd == "PEXPIRE" {
+			d = time.Duration(n) * time.Millisecond
+		}
+		s.expires[str(0)] = time.Now().Add(d)
+		return int64(1)
+
+	case "SADD":
+		set := s.sets[str(0)]
+		if set == nil {
+			set = map[string]bool{}
+			s.sets[str(0)] = set
+		}
+		n := int64(0)
+		for i := 1; i < len(args); i++ {
+			if !set[str(i)] {
+				set[str(i)] = true
+				n++
+			}
+		}
+		return n
+
+	case "SREM":
+		n := int64(0)
+		for i := 1; i < len(args); i++ {
+			if s.sets[str(0)][str(i)] {
+				delete(s.sets[str(0)], str(i))
+				n++
+			}
+		}
+		return n
+
+	case "SMEMBERS":
+		out := []interface{}{}
+		for _, m := range sortedKeys(s.sets[str(0)]) {
+			out = append(out, []byte(m))
+		}
+		return out
+
+	case "HSET":
+		h := s.hashes[str(0)]
+		if h == nil {
+			h = map[string][]byte{}
+			s.hashes[str(0)] = h
+		}
+		n := int64(0)
+		for i := 1; i+1 < len(args); i += 2 {
+			if _, ok := h[str(i)]; !ok {
+				n++
+			}
+			h[str(i)] = []byte(str(i + 1))
+		}
+		return n
+
+	case "HGET":
+		if v, ok := s.hashes[str(0)][str(1)]; ok {
+			return v
+		}
+		return nil
+
+	case "HDEL":
+		n := int64(0)
+		for i := 1; i < len(args); i++ {
+			if _, ok := s.hashes[str(0)][str(i)]; ok {
+				delete(s.hashes[str(0)], str(i))
+				n++
+			}
+		}
+		return n
+
+	case "HINCRBY":
+		h := s.hashes[str(0)]
+		if h == nil {
+			h = map[string][]byte{}
+			s.hashes[str(0)] = h
+		}
+		by, _ := strconv.ParseInt(str(2), 10, 64)
+		n, _ := strconv.ParseInt(string(h[str(1)]), 10, 64)
+		n += by
+		h[str(1)] = []byte(strconv.FormatInt(n, 10))
+		return n
+
+	case "HGETALL", "HVALS":
+		h := s.hashes[str(0)]
+		keys := make([]string, 0, len(h))
+		for k := range h {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		out := []interface{}{}
+		for _, k := range keys {
+			if cmd == "HGETALL" {
+				out = append(out, []byte(k))
+			}
+			out = append(out, h[k])
+		}
+		return out
+	}
+	return redis.Error("ERR unknown command '" + cmd + "'")
+}
+
+func sortedKeys(m map[string]bool) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
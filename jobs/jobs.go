@@ -0,0 +1,145 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// Jobs of asynchronous module calls: created when a request is queued,
+// updated when the module reports back, polled via /api/<version>/jobs/{id}.
+
+package jobs
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Job states.
+const (
+	Queued  = "queued"
+	Running = "running"
+	Done    = "done"
+	Failed  = "failed"
+)
+
+// ErrNotFound is returned for unknown or expired jobs.
+var ErrNotFound = errors.New("jobs: not found")
+
+// Job is the state of one queued call.
+type Job struct {
+	ID        string                 `json:"id"`
+	Module    string                 `json:"module"`
+	Param     string                 `json:"param,omitempty"`
+	Owner     string                 `json:"-"` // UID of the caller; only they (or admins) may poll
+	Token     string                 `json:"-"` // sent with the queued message; reports must carry it
+	Status    string                 `json:"status"`
+	HTTPCode  int                    `json:"httpcode,omitempty"`
+	Result    map[string]interface{} `json:"result,omitempty"`
+	Error     string                 `json:"error,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+	UpdatedAt time.Time              `json:"updated_at"`
+}
+
+// Finished reports whether the job reached a final state.
+func (j *Job) Finished() bool {
+	return j.Status == Done || j.Status == Failed
+}
+
+// Store persists jobs.
+type Store interface {
+	Save(j *Job, ttl time.Duration) error
+	Get(id string) (*Job, error)
+}
+
+var (
+	store     Store
+	storeOnce sync.Once
+)
+
+// GetStore returns the configured store (jobs.store = memory | redis).
+func GetStore() Store {
+	storeOnce.Do(func() {
+		switch strings.ToLower(viper.GetString("jobs.store")) {
+		case "redis":
+			store = newRedisStore()
+		default:
+			store = newMemoryStore()
+		}
+	})
+	return store
+}
+
+// TTL is how long jobs and results are kept (jobs.ttl, default 24h).
+func TTL() time.Duration {
+	if d := viper.GetDuration("jobs.ttl"); d > 0 {
+		return d
+	}
+	return 24 * time.Hour
+}
+
+// New creates and stores a queued job.
+func New(module, param, owner string) (*Job, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	j := &Job{
+		ID:        hex.EncodeToString(b[:16]),
+		Token:     hex.EncodeToString(b[16:]),
+		Module:    module,
+		Param:     param,
+		Owner:     owner,
+		Status:    Queued,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	return j, GetStore().Save(j, TTL())
+}
+
+// TokenValid reports whether token is the job's report token.
+func (j *Job) TokenValid(token string) bool {
+	return j.Token != "" && subtle.ConstantTimeCompare([]byte(j.Token), []byte(token)) == 1
+}
+
+// Report records progress or the outcome of a job. Finished jobs are not
+// changed again.
+func Report(id, status string, httpcode int, result map[string]interface{}, errMsg string) (*Job, error) {
+	switch status {
+	case Running, Done, Failed:
+	default:
+		return nil, errors.New("jobs: status must be running, done or failed")
+	}
+
+	j, err := GetStore().Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if j.Finished() {
+		return j, nil
+	}
+
+	j.Status, j.HTTPCode, j.Result, j.Error = status, httpcode, result, errMsg
+	if j.HTTPCode == 0 && status == Done {
+		j.HTTPCode = 200
+	}
+	j.UpdatedAt = time.Now().UTC()
+	return j, GetStore().Save(j, TTL())
+}
@@ -0,0 +1,136 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// Job store backends: in-memory and Redis (sf.CachePool).
+
+package jobs
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	sf "github.com/gogufo/gufo-api-gateway/gufodao"
+	"github.com/gomodule/redigo/redis"
+)
+
+// -------------------------------------------------------------------
+// Memory store
+// -------------------------------------------------------------------
+
+type memoryItem struct {
+	job     Job
+	expires time.Time
+}
+
+type memoryStore struct {
+	mu    sync.Mutex
+	items map[string]memoryItem
+}
+
+func newMemoryStore() *memoryStore {
+	s := &memoryStore{items: map[string]memoryItem{}}
+	go s.sweep()
+	return s
+}
+
+func (s *memoryStore) Save(j *Job, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.items[j.ID] = memoryItem{job: *j, expires: time.Now().Add(ttl)}
+	return nil
+}
+
+func (s *memoryStore) Get(id string) (*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	it, ok := s.items[id]
+	if !ok || time.Now().After(it.expires) {
+		return nil, ErrNotFound
+	}
+	j := it.job
+	return &j, nil
+}
+
+func (s *memoryStore) sweep() {
+	ticker := time.NewTicker(time.Minute)
+	for range ticker.C {
+		now := time.Now()
+		s.mu.Lock()
+		for k, it := range s.items {
+			if now.After(it.expires) {
+				delete(s.items, k)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// -------------------------------------------------------------------
+// Redis store
+// -------------------------------------------------------------------
+//
+// gufo:job:<id> JSON Job (with owner)
+
+const redisJobPrefix = "gufo:job:"
+
+type redisStore struct{}
+
+func newRedisStore() *redisStore {
+	sf.EnsureCache()
+	return &redisStore{}
+}
+
+// redisJob keeps Owner and Token, which Job omits from JSON answers.
+type redisJob struct {
+	Job
+	Owner string `json:"owner"`
+	Token string `json:"token"`
+}
+
+func (s *redisStore) Save(j *Job, ttl time.Duration) error {
+	conn := sf.CachePool.Get()
+	defer conn.Close()
+
+	b, err := json.Marshal(redisJob{Job: *j, Owner: j.Owner, Token: j.Token})
+	if err != nil {
+		return err
+	}
+	_, err = conn.Do("SET", redisJobPrefix+j.ID, b, "PX", ttl.Milliseconds())
+	return err
+}
+
+func (s *redisStore) Get(id string) (*Job, error) {
+	conn := sf.CachePool.Get()
+	defer conn.Close()
+
+	raw, err := redis.Bytes(conn.Do("GET", redisJobPrefix+id))
+	if err == redis.ErrNil {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rj redisJob
+	if err := json.Unmarshal(raw, &rj); err != nil {
+		return nil, err
+	}
+	j := rj.Job
+	j.Owner, j.Token = rj.Owner, rj.Token
+	return &j, nil
+}
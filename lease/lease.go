@@ -0,0 +1,82 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// Leader leases per service group with TTL and fencing epoch, granted by
+// the gateway through the heartbeat path in standalone mode.
+
+package lease
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Lease is the current leadership of a service group.
+type Lease struct {
+	Group  string `json:"group"`
+	Holder string `json:"holder"`
+	// Epoch increases with every new acquisition, never with a renewal.
+	// Leaders pass it along with writes so stale leaders can be fenced off.
+	Epoch   int64         `json:"epoch"`
+	Expires time.Duration `json:"-"` // remaining TTL
+}
+
+// Store grants leases.
+type Store interface {
+	// Acquire grants group to holder when it is free or expired, renews it
+	// when holder already owns it, and otherwise returns the current lease.
+	Acquire(group, holder string, ttl time.Duration) (Lease, error)
+	// Release frees group if holder owns it.
+	Release(group, holder string) error
+}
+
+var (
+	store     Store
+	storeOnce sync.Once
+)
+
+// GetStore returns the configured store (leases.store = memory | redis).
+func GetStore() Store {
+	storeOnce.Do(func() {
+		switch strings.ToLower(viper.GetString("leases.store")) {
+		case "redis":
+			store = newRedisStore()
+		default:
+			store = newMemoryStore()
+		}
+	})
+	return store
+}
+
+// TTL returns the lease TTL for a requested one: leases.ttl (default 30s)
+// when none is requested, capped at leases.max_ttl (default 5m).
+func TTL(requested time.Duration) time.Duration {
+	ttl := viper.GetDuration("leases.ttl")
+	if ttl <= 0 {
+		ttl = 30 * time.Second
+	}
+	if requested > 0 {
+		ttl = requested
+	}
+	limit := viper.GetDuration("leases.max_ttl")
+	if limit <= 0 {
+		limit = 5 * time.Minute
+	}
+	return max(min(ttl, limit), time.Second)
+}
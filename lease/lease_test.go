@@ -0,0 +1,96 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+
+package lease
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestMemoryStore(t *testing.T) {
+	s := newMemoryStore()
+	const ttl = 50 * time.Millisecond
+
+	steps := []struct {
+		name       string
+		holder     string
+		release    bool
+		wait       time.Duration
+		wantHolder string
+		wantEpoch  int64
+	}{
+		{"first holder", "a", false, 0, "a", 1},
+		{"contender is refused", "b", false, 0, "a", 1},
+		{"renewal keeps the epoch", "a", false, 0, "a", 1},
+		{"release by a non-holder", "b", true, 0, "", 0},
+		{"still held after it", "b", false, 0, "a", 1},
+		{"expired: new holder, new epoch", "b", false, 2 * ttl, "b", 2},
+		{"stale leader is fenced", "a", false, 0, "b", 2},
+		{"release by the holder", "b", true, 0, "", 0},
+		{"free: next epoch", "a", false, 0, "a", 3},
+		{"same holder after expiry: new epoch", "a", false, 2 * ttl, "a", 4},
+	}
+	for _, st := range steps {
+		time.Sleep(st.wait)
+		if st.release {
+			if err := s.Release("g", st.holder); err != nil {
+				t.Fatalf("%s: %v", st.name, err)
+			}
+			continue
+		}
+		l, err := s.Acquire("g", st.holder, ttl)
+		if err != nil {
+			t.Fatalf("%s: %v", st.name, err)
+		}
+		if l.Holder != st.wantHolder || l.Epoch != st.wantEpoch {
+			t.Errorf("%s: Acquire(%s) = %s@%d, want %s@%d", st.name, st.holder, l.Holder, l.Epoch, st.wantHolder, st.wantEpoch)
+		}
+		if l.Expires <= 0 || l.Expires > ttl {
+			t.Errorf("%s: Expires = %v, want within (0, %v]", st.name, l.Expires, ttl)
+		}
+	}
+
+	if l, _ := s.Acquire("other", "b", ttl); l.Holder != "b" || l.Epoch != 1 {
+		t.Errorf("groups share a lease: %+v", l)
+	}
+}
+
+func TestTTL(t *testing.T) {
+	tests := []struct {
+		name            string
+		ttl, max        string
+		requested, want time.Duration
+	}{
+		{"default", "", "", 0, 30 * time.Second},
+		{"configured", "10s", "", 0, 10 * time.Second},
+		{"requested", "10s", "", 20 * time.Second, 20 * time.Second},
+		{"capped", "", "1m", 2 * time.Minute, time.Minute},
+		{"default cap", "", "", time.Hour, 5 * time.Minute},
+		{"at least a second", "", "", 10 * time.Millisecond, time.Second},
+	}
+	for _, tt := range tests {
+		viper.Set("leases.ttl", tt.ttl)
+		viper.Set("leases.max_ttl", tt.max)
+		if got := TTL(tt.requested); got != tt.want {
+			t.Errorf("%s: TTL(%v) = %v, want %v", tt.name, tt.requested, got, tt.want)
+		}
+	}
+	viper.Set("leases.ttl", nil)
+	viper.Set("leases.max_ttl", nil)
+}
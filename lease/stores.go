@@ -0,0 +1,142 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// Lease store backends: in-memory and Redis (sf.CachePool).
+
+package lease
+
+import (
+	"sync"
+	"time"
+
+	sf "github.com/gogufo/gufo-api-gateway/gufodao"
+	"github.com/gomodule/redigo/redis"
+)
+
+// -------------------------------------------------------------------
+// Memory store
+// -------------------------------------------------------------------
+
+type memoryLease struct {
+	holder  string
+	epoch   int64
+	expires time.Time
+}
+
+type memoryStore struct {
+	mu     sync.Mutex
+	leases map[string]memoryLease
+	epochs map[string]int64 // survive expiry so epochs never go back
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{leases: map[string]memoryLease{}, epochs: map[string]int64{}}
+}
+
+func (s *memoryStore) Acquire(group, holder string, ttl time.Duration) (Lease, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	l, ok := s.leases[group]
+	switch {
+	case ok && now.Before(l.expires) && l.holder != holder:
+		return Lease{Group: group, Holder: l.holder, Epoch: l.epoch, Expires: l.expires.Sub(now)}, nil
+	case ok && now.Before(l.expires):
+		l.expires = now.Add(ttl) // renewal keeps the epoch
+	default:
+		s.epochs[group]++
+		l = memoryLease{holder: holder, epoch: s.epochs[group], expires: now.Add(ttl)}
+	}
+	s.leases[group] = l
+	return Lease{Group: group, Holder: holder, Epoch: l.epoch, Expires: ttl}, nil
+}
+
+func (s *memoryStore) Release(group, holder string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if l, ok := s.leases[group]; ok && l.holder == holder {
+		delete(s.leases, group)
+	}
+	return nil
+}
+
+// -------------------------------------------------------------------
+// Redis store
+// -------------------------------------------------------------------
+//
+// gufo:lease:<group>        "<epoch>|<holder>" with the lease TTL
+// gufo:lease:<group>:epoch  counter, no TTL
+
+const redisLeasePrefix = "gufo:lease:"
+
+var (
+	acquireScript = redis.NewScript(2, `
+local v = redis.call('GET', KEYS[1])
+if v then
+  local sep = string.find(v, '|', 1, true)
+  local epoch = tonumber(string.sub(v, 1, sep - 1))
+  local holder = string.sub(v, sep + 1)
+  if holder == ARGV[1] then
+    redis.call('PEXPIRE', KEYS[1], ARGV[2])
+    return {holder, epoch, tonumber(ARGV[2])}
+  end
+  return {holder, epoch, redis.call('PTTL', KEYS[1])}
+end
+local epoch = redis.call('INCR', KEYS[2])
+redis.call('SET', KEYS[1], epoch .. '|' .. ARGV[1], 'PX', ARGV[2])
+return {ARGV[1], epoch, tonumber(ARGV[2])}`)
+	releaseScript = redis.NewScript(1, `
+local v = redis.call('GET', KEYS[1])
+if v and string.sub(v, string.find(v, '|', 1, true) + 1) == ARGV[1] then
+  return redis.call('DEL', KEYS[1])
+end
+return 0`)
+)
+
+type redisStore struct{}
+
+func newRedisStore() *redisStore {
+	sf.EnsureCache()
+	return &redisStore{}
+}
+
+func (s *redisStore) Acquire(group, holder string, ttl time.Duration) (Lease, error) {
+	conn := sf.CachePool.Get()
+	defer conn.Close()
+
+	key := redisLeasePrefix + group
+	vals, err := redis.Values(acquireScript.Do(conn, key, key+":epoch", holder, ttl.Milliseconds()))
+	if err != nil {
+		return Lease{}, err
+	}
+	var l Lease
+	var ms int64
+	if _, err := redis.Scan(vals, &l.Holder, &l.Epoch, &ms); err != nil {
+		return Lease{}, err
+	}
+	l.Group, l.Expires = group, time.Duration(ms)*time.Millisecond
+	return l, nil
+}
+
+func (s *redisStore) Release(group, holder string) error {
+	conn := sf.CachePool.Get()
+	defer conn.Close()
+
+	_, err := releaseScript.Do(conn, redisLeasePrefix+group, holder)
+	return err
+}
@@ -0,0 +1,293 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// Usage metering per consumer (API key consumer or user ID) and module.
+// Counts requests and, optionally, request/response bytes per day and
+// per month, enforces daily/monthly quotas and exports reports for billing.
+
+package metering
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	sf "github.com/gogufo/gufo-api-gateway/gufodao"
+	"github.com/spf13/viper"
+)
+
+// Period granularities.
+const (
+	Day   = "day"
+	Month = "month"
+)
+
+// Usage is the counter set of one consumer/module in one period.
+type Usage struct {
+	Period   string `json:"period"`
+	Consumer string `json:"consumer"`
+	Module   string `json:"module"`
+	Requests int64  `json:"requests"`
+	BytesIn  int64  `json:"bytes_in"`
+	BytesOut int64  `json:"bytes_out"`
+}
+
+// Store persists usage counters.
+type Store interface {
+	Add(period, consumer, module string, requests, bytesIn, bytesOut int64) error
+	// Get returns counters of period; consumer "" means all consumers.
+	Get(period, consumer string) ([]Usage, error)
+}
+
+// Record is attached to the request context by the HTTP entrypoint and
+// filled once the consumer is known; it is tracked after the response.
+// Requests the gateway rejects itself (rate limit, auth, quota, transport
+// errors) never reach a module and are not counted.
+type Record struct {
+	Consumer  string
+	Module    string
+	APIKey    bool   // API key consumers are always counted (per-key quotas)
+	KeyID     string // API key used; counted on its own for the key's quota
+	Forwarded bool   // the call reached the module
+}
+
+// keyConsumerPrefix marks the per-key counters, stored next to the consumer
+// rows (like "uid:<UID>" consumers) and hidden from reports.
+const keyConsumerPrefix = "key:"
+
+type recordKey struct{}
+
+var (
+	store     Store
+	storeOnce sync.Once
+)
+
+// Enabled reports whether metering is on (metering.enabled).
+func Enabled() bool {
+	return viper.GetBool("metering.enabled")
+}
+
+// GetStore returns the configured store (metering.store = memory | redis | sql).
+func GetStore() Store {
+	storeOnce.Do(func() {
+		switch strings.ToLower(viper.GetString("metering.store")) {
+		case "redis":
+			store = newRedisStore()
+		case "sql":
+			s, err := newSQLStore()
+			if err != nil {
+				sf.SetErrorLog("metering: sql store unavailable, falling back to memory: " + err.Error())
+				store = newMemoryStore()
+				return
+			}
+			store = s
+		default:
+			store = newMemoryStore()
+		}
+	})
+	return store
+}
+
+// PeriodKey returns the storage key of the current day or month, e.g.
+// "2026-10-19" or "2026-10".
+func PeriodKey(granularity string, at time.Time) string {
+	if granularity == Month {
+		return at.UTC().Format("2006-01")
+	}
+	return at.UTC().Format("2006-01-02")
+}
+
+// WithRecord attaches an empty Record to ctx.
+func WithRecord(ctx context.Context) (context.Context, *Record) {
+	rec := &Record{}
+	return context.WithValue(ctx, recordKey{}, rec), rec
+}
+
+// FromContext returns the Record attached by WithRecord, or nil.
+func FromContext(ctx context.Context) *Record {
+	rec, _ := ctx.Value(recordKey{}).(*Record)
+	return rec
+}
+
+// Track counts one forwarded request of rec. Byte counters are stored only
+// when metering.bytes is enabled.
+func Track(rec *Record, bytesIn, bytesOut int64) {
+	if rec == nil || !rec.Forwarded || rec.Consumer == "" || !(Enabled() || rec.APIKey) {
+		return
+	}
+	if !viper.GetBool("metering.bytes") {
+		bytesIn, bytesOut = 0, 0
+	}
+
+	now := time.Now()
+	for _, g := range []string{Day, Month} {
+		if err := GetStore().Add(PeriodKey(g, now), rec.Consumer, rec.Module, 1, bytesIn, bytesOut); err != nil {
+			sf.SetErrorLog("metering: " + err.Error())
+		}
+	}
+	// key quotas are daily; several keys may share one consumer
+	if rec.KeyID != "" {
+		if err := GetStore().Add(PeriodKey(Day, now), keyConsumerPrefix+rec.KeyID, "", 1, 0, 0); err != nil {
+			sf.SetErrorLog("metering: " + err.Error())
+		}
+	}
+}
+
+// Used returns the number of requests consumer made in the current period.
+func Used(consumer, granularity string) int64 {
+	rows, err := GetStore().Get(PeriodKey(granularity, time.Now()), consumer)
+	if err != nil {
+		sf.SetErrorLog("metering: " + err.Error())
+		return 0
+	}
+	var total int64
+	for _, u := range rows {
+		total += u.Requests
+	}
+	return total
+}
+
+// UsedByKey returns the number of requests made with API key id today.
+func UsedByKey(id string) int64 {
+	return Used(keyConsumerPrefix+id, Day)
+}
+
+// Limits returns the daily and monthly request quota of consumer
+// (metering.quotas.<consumer>, falling back to metering.quotas.default).
+// 0 means unlimited.
+func Limits(consumer string) (daily, monthly int64) {
+	key := "metering.quotas." + strings.ReplaceAll(consumer, ".", "_")
+	if !viper.IsSet(key) {
+		key = "metering.quotas.default"
+	}
+	return viper.GetInt64(key + ".daily"), viper.GetInt64(key + ".monthly")
+}
+
+// Exceeded reports whether consumer already used up its quota and which one.
+func Exceeded(consumer string) (bool, string) {
+	if !Enabled() || consumer == "" {
+		return false, ""
+	}
+	daily, monthly := Limits(consumer)
+	if daily > 0 && Used(consumer, Day) >= daily {
+		return true, "daily"
+	}
+	if monthly > 0 && Used(consumer, Month) >= monthly {
+		return true, "monthly"
+	}
+	return false, ""
+}
+
+// Report returns usage rows of a period sorted by consumer and module.
+func Report(period, consumer string) ([]Usage, error) {
+	rows, err := GetStore().Get(period, consumer)
+	if err != nil {
+		return nil, err
+	}
+	if consumer == "" {
+		rows = slices.DeleteFunc(rows, func(u Usage) bool { return strings.HasPrefix(u.Consumer, keyConsumerPrefix) })
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Consumer != rows[j].Consumer {
+			return rows[i].Consumer < rows[j].Consumer
+		}
+		return rows[i].Module < rows[j].Module
+	})
+	return rows, nil
+}
+
+// StartExporter periodically writes the current day and month reports to
+// metering.export.dir as JSON or CSV (metering.export.format).
+func StartExporter() {
+	interval := viper.GetDuration("metering.export.interval")
+	if !Enabled() || interval == 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		for range ticker.C {
+			now := time.Now()
+			for _, g := range []string{Day, Month} {
+				if err := Export(PeriodKey(g, now)); err != nil {
+					sf.SetErrorLog("metering: export failed: " + err.Error())
+				}
+			}
+		}
+	}()
+	sf.SetLog(fmt.Sprintf("📈 Usage export every %s", interval))
+}
+
+// Export writes the report of period to usage-<period>.<format>.
+// The file is replaced atomically so readers never see partial data.
+func Export(period string) error {
+	rows, err := Report(period, "")
+	if err != nil {
+		return err
+	}
+
+	dir := viper.GetString("metering.export.dir")
+	if dir == "" {
+		dir = filepath.Join(viper.GetString("server.sysdir"), "usage")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	format := strings.ToLower(viper.GetString("metering.export.format"))
+	if format != "csv" {
+		format = "json"
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("usage-%s.%s", period, format))
+	tmp, err := os.CreateTemp(dir, ".usage-*")
+	if err != nil {
+		return err
+	}
+
+	if format == "csv" {
+		cw := csv.NewWriter(tmp)
+		cw.Write([]string{"period", "consumer", "module", "requests", "bytes_in", "bytes_out"})
+		for _, u := range rows {
+			cw.Write([]string{u.Period, u.Consumer, u.Module,
+				strconv.FormatInt(u.Requests, 10),
+				strconv.FormatInt(u.BytesIn, 10),
+				strconv.FormatInt(u.BytesOut, 10),
+			})
+		}
+		cw.Flush()
+		err = cw.Error()
+	} else {
+		err = json.NewEncoder(tmp).Encode(rows)
+	}
+
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,154 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+
+package metering
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	sf "github.com/gogufo/gufo-api-gateway/gufodao"
+	"github.com/gogufo/gufo-api-gateway/internal/redistest"
+	"github.com/spf13/viper"
+)
+
+// useStore makes s the store returned by GetStore for the test.
+func useStore(t *testing.T, s Store) {
+	t.Helper()
+	storeOnce.Do(func() {})
+	prev := store
+	store = s
+	t.Cleanup(func() { store = prev })
+}
+
+func TestTrack(t *testing.T) {
+	useStore(t, newMemoryStore())
+	viper.Set("metering.enabled", true)
+	t.Cleanup(func() { viper.Set("metering.enabled", false) })
+
+	tests := []struct {
+		name string
+		rec  *Record
+		want int64 // requests counted for consumer "acme"
+	}{
+		{"forwarded", &Record{Consumer: "acme", Module: "orders", Forwarded: true}, 1},
+		{"rejected by the gateway", &Record{Consumer: "acme", Module: "orders"}, 0},
+		{"no consumer", &Record{Module: "orders", Forwarded: true}, 0},
+		{"no record", nil, 0},
+	}
+	for _, tt := range tests {
+		before := Used("acme", Day)
+		Track(tt.rec, 10, 20)
+		if got := Used("acme", Day) - before; got != tt.want {
+			t.Errorf("%s: counted %d, want %d", tt.name, got, tt.want)
+		}
+	}
+	if got := Used("acme", Month); got != 1 {
+		t.Errorf("month counter = %d, want 1", got)
+	}
+}
+
+func TestTrackAPIKey(t *testing.T) {
+	useStore(t, newMemoryStore())
+
+	// two keys of one consumer; API keys are counted with metering disabled
+	for range 3 {
+		Track(&Record{Consumer: "acme", Module: "orders", APIKey: true, KeyID: "k1", Forwarded: true}, 0, 0)
+	}
+	Track(&Record{Consumer: "acme", Module: "orders", APIKey: true, KeyID: "k2", Forwarded: true}, 0, 0)
+	Track(&Record{Consumer: "acme", Module: "orders", APIKey: true, KeyID: "k2"}, 0, 0) // rejected
+
+	if got := UsedByKey("k1"); got != 3 {
+		t.Errorf("UsedByKey(k1) = %d, want 3", got)
+	}
+	if got := UsedByKey("k2"); got != 1 {
+		t.Errorf("UsedByKey(k2) = %d, want 1", got)
+	}
+	if got := Used("acme", Day); got != 4 {
+		t.Errorf("Used(acme) = %d, want 4", got)
+	}
+
+	rows, err := Report(PeriodKey(Day, time.Now()), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Usage{{Period: PeriodKey(Day, time.Now()), Consumer: "acme", Module: "orders", Requests: 4}}
+	if !reflect.DeepEqual(rows, want) {
+		t.Fatalf("Report = %+v, want %+v (per-key rows hidden)", rows, want)
+	}
+}
+
+func TestMemoryStorePrune(t *testing.T) {
+	s := newMemoryStore()
+	now := time.Now()
+	old := []string{
+		PeriodKey(Day, now.Add(-dayRetention-48*time.Hour)),
+		PeriodKey(Month, now.Add(-monthRetention-62*24*time.Hour)),
+	}
+	kept := []string{
+		PeriodKey(Day, now.Add(-48*time.Hour)),
+		PeriodKey(Month, now.Add(-62*24*time.Hour)),
+	}
+	for _, p := range append(old, kept...) {
+		s.Add(p, "acme", "orders", 1, 0, 0)
+	}
+
+	s.Add(PeriodKey(Day, now), "acme", "orders", 1, 0, 0) // a new period prunes
+	for _, p := range old {
+		if rows, _ := s.Get(p, ""); len(rows) != 0 {
+			t.Errorf("period %s not pruned", p)
+		}
+	}
+	for _, p := range kept {
+		if rows, _ := s.Get(p, ""); len(rows) != 1 {
+			t.Errorf("period %s pruned", p)
+		}
+	}
+	if len(s.rows) != len(kept)+1 || len(s.periods) != len(kept)+1 {
+		t.Errorf("%d rows in %d periods left, want %d", len(s.rows), len(s.periods), len(kept)+1)
+	}
+}
+
+func TestRedisStore(t *testing.T) {
+	prev := sf.CachePool
+	sf.CachePool, _ = redistest.NewPool()
+	t.Cleanup(func() { sf.CachePool = prev })
+
+	s := newRedisStore()
+	s.Add("2026-10-19", "acme", "orders", 1, 10, 20)
+	s.Add("2026-10-19", "acme", "orders", 1, 5, 0)
+	s.Add("2026-10-19", "acme", "users", 1, 0, 0)
+	s.Add("2026-10-19", "beta", "orders", 1, 0, 0)
+
+	got, err := s.Get("2026-10-19", "acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	byModule := map[string]Usage{}
+	for _, u := range got {
+		byModule[u.Module] = u
+	}
+	if u := byModule["orders"]; u.Requests != 2 || u.BytesIn != 15 || u.BytesOut != 20 {
+		t.Errorf("acme/orders = %+v", u)
+	}
+	if u := byModule["users"]; u.Requests != 1 {
+		t.Errorf("acme/users = %+v", u)
+	}
+	if all, _ := s.Get("2026-10-19", ""); len(all) != 3 {
+		t.Errorf("all consumers: %d rows, want 3", len(all))
+	}
+}
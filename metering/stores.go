@@ -0,0 +1,280 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// Usage store backends: in-memory, Redis (sf.CachePool) and SQL (gorm).
+
+package metering
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	sf "github.com/gogufo/gufo-api-gateway/gufodao"
+	"github.com/gomodule/redigo/redis"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// -------------------------------------------------------------------
+// Memory store
+// -------------------------------------------------------------------
+
+// Day buckets are kept ~40 days and month buckets ~13 months, longer than
+// any quota window.
+const (
+	dayRetention   = 40 * 24 * time.Hour
+	monthRetention = 400 * 24 * time.Hour
+)
+
+// retention returns how long the bucket of period is kept.
+func retention(period string) time.Duration {
+	if len(period) == len("2006-01") {
+		return monthRetention
+	}
+	return dayRetention
+}
+
+// expired reports whether the bucket of period is past its retention.
+func expired(period string, now time.Time) bool {
+	layout := "2006-01-02"
+	if len(period) == len("2006-01") {
+		layout = "2006-01"
+	}
+	start, err := time.Parse(layout, period)
+	return err == nil && now.Sub(start) > retention(period)
+}
+
+type memoryStore struct {
+	mu      sync.Mutex
+	rows    map[string]*Usage // period|consumer|module -> usage
+	periods map[string]bool   // periods with rows
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{rows: map[string]*Usage{}, periods: map[string]bool{}}
+}
+
+func (s *memoryStore) Add(period, consumer, module string, requests, bytesIn, bytesOut int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// a new period starts: drop the buckets past their retention
+	if !s.periods[period] {
+		s.prune(time.Now())
+		s.periods[period] = true
+	}
+
+	key := period + "|" + consumer + "|" + module
+	u, ok := s.rows[key]
+	if !ok {
+		u = &Usage{Period: period, Consumer: consumer, Module: module}
+		s.rows[key] = u
+	}
+	u.Requests += requests
+	u.BytesIn += bytesIn
+	u.BytesOut += bytesOut
+	return nil
+}
+
+// prune drops expired periods. s.mu must be held.
+func (s *memoryStore) prune(now time.Time) {
+	for p := range s.periods {
+		if expired(p, now) {
+			delete(s.periods, p)
+		}
+	}
+	for key, u := range s.rows {
+		if !s.periods[u.Period] {
+			delete(s.rows, key)
+		}
+	}
+}
+
+func (s *memoryStore) Get(period, consumer string) ([]Usage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var out []Usage
+	for _, u := range s.rows {
+		if u.Period == period && (consumer == "" || u.Consumer == consumer) {
+			out = append(out, *u)
+		}
+	}
+	return out, nil
+}
+
+// -------------------------------------------------------------------
+// Redis store
+// -------------------------------------------------------------------
+//
+// gufo:usage:<period>            SET of consumers
+// gufo:usage:<period>:<consumer> HASH "<module>|requests" -> n, ...
+
+const redisUsagePrefix = "gufo:usage:"
+
+type redisStore struct{}
+
+func newRedisStore() *redisStore {
+	sf.EnsureCache()
+	return &redisStore{}
+}
+
+func (s *redisStore) Add(period, consumer, module string, requests, bytesIn, bytesOut int64) error {
+	conn := sf.CachePool.Get()
+	defer conn.Close()
+
+	ttl := int(retention(period).Seconds())
+
+	key := redisUsagePrefix + period + ":" + consumer
+	conn.Send("MULTI")
+	conn.Send("HINCRBY", key, module+"|requests", requests)
+	if bytesIn != 0 {
+		conn.Send("HINCRBY", key, module+"|bytes_in", bytesIn)
+	}
+	if bytesOut != 0 {
+		conn.Send("HINCRBY", key, module+"|bytes_out", bytesOut)
+	}
+	conn.Send("EXPIRE", key, ttl)
+	conn.Send("SADD", redisUsagePrefix+period, consumer)
+	conn.Send("EXPIRE", redisUsagePrefix+period, ttl)
+	_, err := conn.Do("EXEC")
+	return err
+}
+
+func (s *redisStore) Get(period, consumer string) ([]Usage, error) {
+	conn := sf.CachePool.Get()
+	defer conn.Close()
+
+	consumers := []string{consumer}
+	if consumer == "" {
+		var err error
+		consumers, err = redis.Strings(conn.Do("SMEMBERS", redisUsagePrefix+period))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var out []Usage
+	for _, c := range consumers {
+		fields, err := redis.Int64Map(conn.Do("HGETALL", redisUsagePrefix+period+":"+c))
+		if err != nil {
+			return nil, err
+		}
+		byModule := map[string]*Usage{}
+		for f, v := range fields {
+			i := strings.LastIndex(f, "|")
+			if i < 0 {
+				continue
+			}
+			module, field := f[:i], f[i+1:]
+			u, ok := byModule[module]
+			if !ok {
+				u = &Usage{Period: period, Consumer: c, Module: module}
+				byModule[module] = u
+			}
+			switch field {
+			case "requests":
+				u.Requests = v
+			case "bytes_in":
+				u.BytesIn = v
+			case "bytes_out":
+				u.BytesOut = v
+			}
+		}
+		for _, u := range byModule {
+			out = append(out, *u)
+		}
+	}
+	return out, nil
+}
+
+// -------------------------------------------------------------------
+// SQL store
+// -------------------------------------------------------------------
+
+type usageRow struct {
+	ID       uint   `gorm:"primaryKey"`
+	Period   string `gorm:"size:10;uniqueIndex:idx_gufo_usage"`
+	Consumer string `gorm:"size:128;uniqueIndex:idx_gufo_usage"`
+	Module   string `gorm:"size:128;uniqueIndex:idx_gufo_usage"`
+	Requests int64
+	BytesIn  int64
+	BytesOut int64
+}
+
+func (usageRow) TableName() string { return "gufo_usage" }
+
+type sqlStore struct {
+	db *gorm.DB
+}
+
+func newSQLStore() (*sqlStore, error) {
+	db, err := sf.ConnectDBv2()
+	if err != nil {
+		return nil, err
+	}
+	if db == nil || db.Conn == nil {
+		return nil, errors.New("database is not configured")
+	}
+	if err := db.Conn.AutoMigrate(&usageRow{}); err != nil {
+		return nil, err
+	}
+	return &sqlStore{db: db.Conn}, nil
+}
+
+func (s *sqlStore) Add(period, consumer, module string, requests, bytesIn, bytesOut int64) error {
+	row := usageRow{
+		Period:   period,
+		Consumer: consumer,
+		Module:   module,
+		Requests: requests,
+		BytesIn:  bytesIn,
+		BytesOut: bytesOut,
+	}
+	return s.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "period"}, {Name: "consumer"}, {Name: "module"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"requests":  gorm.Expr("requests + ?", requests),
+			"bytes_in":  gorm.Expr("bytes_in + ?", bytesIn),
+			"bytes_out": gorm.Expr("bytes_out + ?", bytesOut),
+		}),
+	}).Create(&row).Error
+}
+
+func (s *sqlStore) Get(period, consumer string) ([]Usage, error) {
+	q := s.db.Where("period = ?", period)
+	if consumer != "" {
+		q = q.Where("consumer = ?", consumer)
+	}
+	var rows []usageRow
+	if err := q.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make([]Usage, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, Usage{
+			Period:   r.Period,
+			Consumer: r.Consumer,
+			Module:   r.Module,
+			Requests: r.Requests,
+			BytesIn:  r.BytesIn,
+			BytesOut: r.BytesOut,
+		})
+	}
+	return out, nil
+}
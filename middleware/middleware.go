@@ -18,6 +18,7 @@ package middleware
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"time"
 )
@@ -58,3 +59,22 @@ func RunAfter(w http.ResponseWriter, status int, dur time.Duration) {
 		chain[i].After(w, status, dur)
 	}
 }
+
+// Inspector is implemented by middleware that can report its runtime state.
+type Inspector interface {
+	Inspect() map[string]any
+}
+
+// Describe returns the registered chain in execution order, with the state
+// of every middleware that implements Inspector.
+func Describe() []map[string]any {
+	out := make([]map[string]any, 0, len(chain))
+	for _, m := range chain {
+		item := map[string]any{"name": fmt.Sprintf("%T", m)}
+		if in, ok := m.(Inspector); ok {
+			item["state"] = in.Inspect()
+		}
+		out = append(out, item)
+	}
+	return out
+}
@@ -42,6 +42,19 @@ func NewRateLimiter(rps int, refill time.Duration, burst int) *RateLimiter {
 		burst = rps * 2
 	}
 
+	return newRateLimiter(rps, refill, burst)
+}
+
+// NewKeyRateLimiter creates the limiter of one API key: rps tokens per
+// second and exactly burst tokens at once (at least 1).
+func NewKeyRateLimiter(rps, burst int) *RateLimiter {
+	if rps <= 0 {
+		rps = 100
+	}
+	return newRateLimiter(rps, time.Second/time.Duration(rps), max(burst, 1))
+}
+
+func newRateLimiter(rps int, refill time.Duration, burst int) *RateLimiter {
 	return &RateLimiter{
 		rps:    rps,
 		tokens: burst, // start with full bucket
@@ -79,6 +92,20 @@ func (rl *RateLimiter) Before(r *http.Request, ctx context.Context) (context.Con
 
 func (rl *RateLimiter) After(w http.ResponseWriter, status int, dur time.Duration) {}
 
+// Inspect reports the current bucket state.
+func (rl *RateLimiter) Inspect() map[string]any {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.refillTokens()
+	return map[string]any{
+		"rps":    rl.rps,
+		"burst":  rl.burst,
+		"tokens": rl.tokens,
+		"refill": rl.refill.String(),
+	}
+}
+
 func min(a, b int) int {
 	if a < b {
 		return a
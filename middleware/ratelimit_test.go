@@ -0,0 +1,55 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+
+package middleware
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterBurst(t *testing.T) {
+	tests := []struct {
+		name string
+		rl   *RateLimiter
+		want int
+	}{
+		// the global limiter keeps at least twice rps, as it always did
+		{"global default", NewRateLimiter(10, time.Second, 0), 20},
+		{"global below rps", NewRateLimiter(10, time.Second, 5), 20},
+		{"global equal to rps", NewRateLimiter(10, time.Second, 10), 20},
+		{"global above rps", NewRateLimiter(10, time.Second, 15), 15},
+		{"global no rps", NewRateLimiter(0, time.Second, 0), 200},
+		// per-key limiters take the configured burst as is
+		{"key", NewKeyRateLimiter(10, 5), 5},
+		{"key zero burst", NewKeyRateLimiter(10, 0), 1},
+	}
+	for _, tt := range tests {
+		if got := tt.rl.Inspect()["burst"]; got != tt.want {
+			t.Errorf("%s: burst = %v, want %d", tt.name, got, tt.want)
+		}
+		allowed := 0
+		for range tt.want + 1 {
+			if _, err := tt.rl.Before(nil, context.Background()); err == nil {
+				allowed++
+			}
+		}
+		if allowed != tt.want {
+			t.Errorf("%s: %d requests allowed at once, want %d", tt.name, allowed, tt.want)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// In-process broker: buffered, round-robin delivery within a queue group.
+
+package mq
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrClosed is returned by a closed broker.
+var ErrClosed = errors.New("mq: broker closed")
+
+type memorySub struct {
+	b       *MemoryBroker
+	subject string
+	queue   string
+	ch      chan Message
+	h       Handler
+	once    sync.Once
+}
+
+func (s *memorySub) Unsubscribe() error {
+	s.b.mu.Lock()
+	defer s.b.mu.Unlock()
+	s.b.remove(s)
+	s.once.Do(func() { close(s.ch) })
+	return nil
+}
+
+// MemoryBroker is an embedded Broker for a single gateway and for tests.
+// Messages published while nobody subscribes are kept until a subscriber
+// appears (up to the buffer size).
+type MemoryBroker struct {
+	mu      sync.Mutex
+	subs    []*memorySub
+	next    map[string]int // queue group -> round-robin cursor
+	pending []Message
+	closed  bool
+}
+
+// NewMemoryBroker creates an empty in-process broker.
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{next: map[string]int{}}
+}
+
+const memoryBuffer = 1024
+
+func (b *MemoryBroker) Publish(ctx context.Context, msg Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return ErrClosed
+	}
+
+	targets := b.targets(msg.Subject)
+	if len(targets) == 0 {
+		if len(b.pending) >= memoryBuffer {
+			return errors.New("mq: no subscribers and buffer full")
+		}
+		b.pending = append(b.pending, msg)
+		return nil
+	}
+
+	// all or nothing: a message is not delivered to some subscribers only.
+	// Sends happen under b.mu, so free space cannot shrink in between.
+	for _, s := range targets {
+		if len(s.ch) == cap(s.ch) {
+			return errors.New("mq: subscriber buffer full")
+		}
+	}
+	for _, s := range targets {
+		s.ch <- msg
+	}
+	return nil
+}
+
+// targets picks one subscriber per queue group (and every subscriber
+// without a group). b.mu must be held.
+func (b *MemoryBroker) targets(subject string) []*memorySub {
+	groups := map[string][]*memorySub{}
+	var out []*memorySub
+	for _, s := range b.subs {
+		if !matches(s.subject, subject) {
+			continue
+		}
+		if s.queue == "" {
+			out = append(out, s)
+			continue
+		}
+		groups[s.queue] = append(groups[s.queue], s)
+	}
+	for q, members := range groups {
+		i := b.next[q] % len(members)
+		b.next[q]++
+		out = append(out, members[i])
+	}
+	return out
+}
+
+func (b *MemoryBroker) QueueSubscribe(subject, queue string, h Handler) (Subscription, error) {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil, ErrClosed
+	}
+	s := &memorySub{b: b, subject: subject, queue: queue, ch: make(chan Message, memoryBuffer), h: h}
+	b.subs = append(b.subs, s)
+
+	// hand over messages published before anyone listened
+	var keep []Message
+	for _, msg := range b.pending {
+		if matches(subject, msg.Subject) && len(s.ch) < cap(s.ch) {
+			s.ch <- msg
+		} else {
+			keep = append(keep, msg)
+		}
+	}
+	b.pending = keep
+	b.mu.Unlock()
+
+	go func() {
+		for msg := range s.ch {
+			s.h(context.Background(), msg)
+		}
+	}()
+	return s, nil
+}
+
+// remove drops s from the subscribers. b.mu must be held.
+func (b *MemoryBroker) remove(s *memorySub) {
+	for i, x := range b.subs {
+		if x == s {
+			b.subs = append(b.subs[:i], b.subs[i+1:]...)
+			return
+		}
+	}
+}
+
+func (b *MemoryBroker) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, s := range b.subs {
+		s.once.Do(func() { close(s.ch) })
+	}
+	b.subs, b.closed = nil, true
+	return nil
+}
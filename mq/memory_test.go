@@ -0,0 +1,161 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+
+package mq
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// counter counts deliveries per subscriber.
+type counter struct {
+	mu sync.Mutex
+	n  map[string]int
+	wg sync.WaitGroup
+}
+
+func (c *counter) handler(name string) Handler {
+	return func(ctx context.Context, msg Message) {
+		c.mu.Lock()
+		c.n[name]++
+		c.mu.Unlock()
+		c.wg.Done()
+	}
+}
+
+func (c *counter) wait(t *testing.T) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() { c.wg.Wait(); close(done) }()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("deliveries timed out")
+	}
+}
+
+func TestMemoryBrokerQueueGroups(t *testing.T) {
+	b := NewMemoryBroker()
+	defer b.Close()
+
+	c := &counter{n: map[string]int{}}
+	for _, s := range []struct{ name, subject, queue string }{
+		{"w1", "gufo.orders", "workers"},
+		{"w2", "gufo.orders", "workers"},
+		{"tap", "gufo.>", ""},
+		{"other", "gufo.users", "workers"},
+	} {
+		if _, err := b.QueueSubscribe(s.subject, s.queue, c.handler(s.name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c.wg.Add(8) // 4 messages: one worker each, plus the tap
+	for range 4 {
+		if err := b.Publish(context.Background(), Message{Subject: "gufo.orders"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.wait(t)
+
+	want := map[string]int{"w1": 2, "w2": 2, "tap": 4}
+	for name, n := range want {
+		if c.n[name] != n {
+			t.Errorf("%s got %d messages, want %d (%v)", name, c.n[name], n, c.n)
+		}
+	}
+	if c.n["other"] != 0 {
+		t.Errorf("other subject delivered: %v", c.n)
+	}
+}
+
+func TestMemoryBrokerPending(t *testing.T) {
+	b := NewMemoryBroker()
+	defer b.Close()
+
+	if err := b.Publish(context.Background(), Message{Subject: "gufo.jobs", Data: []byte("early")}); err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(chan Message, 1)
+	if _, err := b.QueueSubscribe("gufo.jobs", "workers", func(ctx context.Context, msg Message) { got <- msg }); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-got:
+		if string(msg.Data) != "early" {
+			t.Fatalf("got %q, want the message published before subscribing", msg.Data)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("pending message not delivered")
+	}
+}
+
+func TestMemoryBrokerAllOrNothing(t *testing.T) {
+	b := NewMemoryBroker()
+	defer b.Close()
+
+	taken, release := make(chan struct{}, 1), make(chan struct{})
+	if _, err := b.QueueSubscribe("gufo.>", "", func(ctx context.Context, msg Message) {
+		select {
+		case taken <- struct{}{}:
+		default:
+		}
+		<-release
+	}); err != nil {
+		t.Fatal(err)
+	}
+	defer close(release)
+
+	// the slow subscriber holds one message in its handler and fills its buffer
+	for i := range memoryBuffer + 1 {
+		if err := b.Publish(context.Background(), Message{Subject: "gufo.fill"}); err != nil {
+			t.Fatalf("publish %d: %v", i, err)
+		}
+		if i == 0 {
+			<-taken
+		}
+	}
+
+	got := make(chan Message, 1)
+	if _, err := b.QueueSubscribe("gufo.jobs", "", func(ctx context.Context, msg Message) { got <- msg }); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Publish(context.Background(), Message{Subject: "gufo.jobs"}); err == nil {
+		t.Fatal("publish to a full subscriber succeeded")
+	}
+	select {
+	case <-got:
+		t.Fatal("a failed publish was delivered to another subscriber")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestMemoryBrokerClosed(t *testing.T) {
+	b := NewMemoryBroker()
+	b.Close()
+
+	if err := b.Publish(context.Background(), Message{Subject: "gufo.jobs"}); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Publish after Close = %v, want ErrClosed", err)
+	}
+	if _, err := b.QueueSubscribe("gufo.jobs", "", func(context.Context, Message) {}); !errors.Is(err, ErrClosed) {
+		t.Fatalf("QueueSubscribe after Close = %v, want ErrClosed", err)
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// Message broker abstraction for asynchronous module calls. Subjects follow
+// NATS conventions ("gufo.jobs.<module>"); AMQP adapters map them to
+// routing keys. The in-process broker serves single-node setups and tests.
+
+package mq
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	sf "github.com/gogufo/gufo-api-gateway/gufodao"
+	"github.com/spf13/viper"
+)
+
+// Message is one published message.
+type Message struct {
+	Subject string
+	Header  map[string]string
+	Data    []byte
+}
+
+// Handler processes a delivered message.
+type Handler func(ctx context.Context, msg Message)
+
+// Subscription is an active subscription.
+type Subscription interface {
+	Unsubscribe() error
+}
+
+// Broker publishes messages and delivers them to queue subscribers. A
+// message is delivered to one subscriber of a queue group (competing
+// consumers). Subjects may end in ">" to match every subject with that prefix.
+type Broker interface {
+	Publish(ctx context.Context, msg Message) error
+	QueueSubscribe(subject, queue string, h Handler) (Subscription, error)
+	Close() error
+}
+
+// Factory creates a broker from configuration (mq.* keys).
+type Factory func() (Broker, error)
+
+var (
+	factoriesMu sync.Mutex
+	factories   = map[string]Factory{
+		"memory": func() (Broker, error) { return NewMemoryBroker(), nil },
+	}
+
+	broker     Broker
+	brokerErr  error
+	brokerOnce sync.Once
+
+	closedBroker = func() Broker {
+		b := NewMemoryBroker()
+		b.Close()
+		return b
+	}()
+)
+
+// RegisterBroker makes a broker implementation (e.g. "nats", "amqp")
+// selectable with mq.broker.
+func RegisterBroker(name string, f Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	factories[strings.ToLower(name)] = f
+}
+
+// Name is the configured broker (mq.broker, default "memory").
+func Name() string {
+	if n := strings.ToLower(viper.GetString("mq.broker")); n != "" {
+		return n
+	}
+	return "memory"
+}
+
+// Init connects the configured broker. An unknown mq.broker or a broker
+// that cannot be created is an error: queued calls must not silently go
+// to an in-process broker no external consumer reads. Call it at startup.
+func Init() error {
+	brokerOnce.Do(func() {
+		factoriesMu.Lock()
+		f, ok := factories[Name()]
+		factoriesMu.Unlock()
+
+		if !ok {
+			brokerErr = fmt.Errorf("mq: unknown broker %q", Name())
+			return
+		}
+		broker, brokerErr = f()
+		if brokerErr != nil {
+			brokerErr = fmt.Errorf("mq: broker %q unavailable: %w", Name(), brokerErr)
+		}
+	})
+	return brokerErr
+}
+
+// Get returns the configured broker (see Init). When Init failed, it
+// returns a closed broker, so every publish fails.
+func Get() Broker {
+	if err := Init(); err != nil {
+		sf.SetErrorLog(err.Error())
+		return closedBroker
+	}
+	return broker
+}
+
+// Subject returns the subject for module calls (mq.subject_prefix, default "gufo.jobs").
+func Subject(module string) string {
+	prefix := viper.GetString("mq.subject_prefix")
+	if prefix == "" {
+		prefix = "gufo.jobs"
+	}
+	return prefix + "." + module
+}
+
+// matches reports whether subject matches pattern (exact, or prefix with ">").
+func matches(pattern, subject string) bool {
+	if strings.HasSuffix(pattern, ">") {
+		return strings.HasPrefix(subject, strings.TrimSuffix(pattern, ">"))
+	}
+	return pattern == subject
+}
@@ -0,0 +1,70 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+
+package mq
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// resetBroker forgets the broker Init created, so the next Init reads
+// mq.broker again.
+func resetBroker(t *testing.T) {
+	t.Helper()
+	reset := func() { broker, brokerErr, brokerOnce = nil, nil, sync.Once{} }
+	reset()
+	t.Cleanup(func() {
+		viper.Set("mq.broker", nil)
+		reset()
+	})
+}
+
+func TestInit(t *testing.T) {
+	RegisterBroker("broken", func() (Broker, error) { return nil, ErrClosed })
+
+	cases := []struct {
+		name    string
+		broker  string
+		wantErr bool
+	}{
+		{"default", "", false},
+		{"memory", "Memory", false},
+		{"unknown", "kafka", true},
+		{"factory error", "broken", true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			resetBroker(t)
+			viper.Set("mq.broker", c.broker)
+
+			err := Init()
+			if (err != nil) != c.wantErr {
+				t.Fatalf("Init() = %v, want error %v", err, c.wantErr)
+			}
+			if !c.wantErr {
+				return
+			}
+			// publishing must fail instead of going to an unread broker
+			if err := Get().Publish(context.Background(), Message{Subject: Subject("orders")}); err == nil {
+				t.Error("Publish on a failed broker succeeded")
+			}
+		})
+	}
+}
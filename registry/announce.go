@@ -0,0 +1,271 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// Self-registration: services announce themselves through the heartbeat
+// (registry.self_registration) and expire when they stop.
+
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	sf "github.com/gogufo/gufo-api-gateway/gufodao"
+	viper "github.com/spf13/viper"
+)
+
+var (
+	ErrRegistrationDisabled = errors.New("registry: self-registration is disabled")
+	ErrNotAllowed           = errors.New("registry: identity may not register this service")
+	ErrStatic               = errors.New("registry: service is configured statically")
+	ErrInvalidInstance      = errors.New("registry: name, host and port are required")
+)
+
+// Instance is a service instance that announced itself.
+type Instance struct {
+	Name      string    `json:"name"`
+	Host      string    `json:"host"`
+	Port      string    `json:"port"`
+	Version   string    `json:"version,omitempty"`
+	Tags      []string  `json:"tags,omitempty"`
+	Internal  bool      `json:"internal,omitempty"` // not reachable from the public API
+	Identity  string    `json:"identity,omitempty"` // caller that registered it
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Expires   time.Time `json:"expires"`
+}
+
+// AnnounceRule lets an identity register service names ([[registry.announce_acl]]).
+type AnnounceRule struct {
+	Identity string   `mapstructure:"identity"` // exact, "*" or "prefix*"
+	Names    []string `mapstructure:"names"`
+}
+
+var (
+	announcedMu sync.Mutex
+	announced   = map[string]map[string]Instance{} // name → host:port → instance
+)
+
+// SelfRegistration reports whether heartbeats may register services
+// (registry.self_registration, standalone mode).
+func SelfRegistration() bool {
+	return viper.GetBool("registry.self_registration")
+}
+
+// AnnounceAllowed checks identity against [[registry.announce_acl]]. An
+// empty identity is never allowed; without rules any identity may register
+// any name.
+func AnnounceAllowed(identity, name string) bool {
+	if identity == "" {
+		return false
+	}
+	var rules []AnnounceRule
+	if err := viper.UnmarshalKey("registry.announce_acl", &rules); err != nil {
+		sf.SetErrorLog("registry: cannot parse registry.announce_acl: " + err.Error())
+		return false
+	}
+	if len(rules) == 0 {
+		return true
+	}
+	for _, rule := range rules {
+		if sf.MatchAny([]string{rule.Identity}, identity) && sf.MatchAny(rule.Names, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Overridable reports whether announced instances may serve name although
+// it is configured under microservices.<name> (registry.announce_override).
+func Overridable(name string) bool {
+	return !viper.IsSet("microservices."+name) || viper.GetBool("registry.announce_override")
+}
+
+// Announce registers or refreshes in for ttl. in.Identity must be allowed
+// for in.Name, and statically configured names are refused (see
+// Overridable).
+func Announce(in Instance, ttl time.Duration) error {
+	if !SelfRegistration() {
+		return ErrRegistrationDisabled
+	}
+	if in.Name == "" || in.Host == "" || in.Port == "" {
+		return ErrInvalidInstance
+	}
+	if !AnnounceAllowed(in.Identity, in.Name) {
+		return fmt.Errorf("%w: %q -> %s", ErrNotAllowed, in.Identity, in.Name)
+	}
+	if !Overridable(in.Name) {
+		return fmt.Errorf("%w: %s", ErrStatic, in.Name)
+	}
+
+	now := time.Now()
+	addr := net.JoinHostPort(in.Host, in.Port)
+	in.Version = strings.ToLower(in.Version)
+
+	announcedMu.Lock()
+	defer announcedMu.Unlock()
+
+	set := announced[in.Name]
+	if set == nil {
+		set = map[string]Instance{}
+		announced[in.Name] = set
+	}
+	in.FirstSeen = now
+	if prev, ok := set[addr]; ok {
+		in.FirstSeen = prev.FirstSeen
+	} else {
+		sf.SetLog(fmt.Sprintf("registry: %s announced at %s (version %q)", in.Name, addr, in.Version))
+	}
+	in.LastSeen, in.Expires = now, now.Add(ttl)
+	set[addr] = in
+	return nil
+}
+
+// Deregister removes an announced instance. identity must be allowed for
+// name and be the identity that announced the instance.
+func Deregister(name, host, port, identity string) (bool, error) {
+	if !AnnounceAllowed(identity, name) {
+		return false, fmt.Errorf("%w: %q -> %s", ErrNotAllowed, identity, name)
+	}
+
+	announcedMu.Lock()
+	defer announcedMu.Unlock()
+
+	set := announced[name]
+	addr := net.JoinHostPort(host, port)
+	in, ok := set[addr]
+	if !ok {
+		return false, nil
+	}
+	if in.Identity != identity {
+		return false, fmt.Errorf("%w: %q -> %s at %s", ErrNotAllowed, identity, name, addr)
+	}
+	delete(set, addr)
+	if len(set) == 0 {
+		delete(announced, name)
+	}
+	sf.SetLog("registry: " + name + " at " + addr + " deregistered")
+	return true, nil
+}
+
+// registered picks a live announced instance of name (any version when
+// version is empty), round-robin.
+func registered(name, version string) (ServiceInfo, bool) {
+	announcedMu.Lock()
+	defer announcedMu.Unlock()
+
+	now := time.Now()
+	var live []Instance
+	for _, in := range announced[name] {
+		if now.Before(in.Expires) && (version == "" || in.Version == version) {
+			live = append(live, in)
+		}
+	}
+	if len(live) == 0 {
+		return ServiceInfo{}, false
+	}
+	sort.Slice(live, func(i, j int) bool {
+		return net.JoinHostPort(live[i].Host, live[i].Port) < net.JoinHostPort(live[j].Host, live[j].Port)
+	})
+
+	eps := make([]Endpoint, len(live))
+	for i, l := range live {
+		eps[i] = Endpoint{Host: l.Host, Port: l.Port, Tags: l.Tags}
+	}
+	in := live[roundRobin(versionKey(name, version), len(live))]
+
+	return ServiceInfo{
+		Host:       in.Host,
+		Port:       in.Port,
+		Version:    in.Version,
+		Tags:       in.Tags,
+		Internal:   in.Internal,
+		Endpoints:  eps,
+		LastUpdate: in.LastSeen,
+	}, true
+}
+
+// Registered returns a live announced instance of name.
+func Registered(name string) (ServiceInfo, bool) {
+	return registered(name, "")
+}
+
+// IsInternal reports whether name was announced as internal: it is then
+// reachable through the gateway gRPC port only.
+func IsInternal(name string) bool {
+	info, ok := Registered(name)
+	return ok && info.Internal
+}
+
+// announcedVersions returns the versions announced by live instances of name.
+func announcedVersions(name string) []string {
+	announcedMu.Lock()
+	defer announcedMu.Unlock()
+
+	now := time.Now()
+	var out []string
+	for _, in := range announced[name] {
+		if in.Version != "" && now.Before(in.Expires) && !slices.Contains(out, in.Version) {
+			out = append(out, in.Version)
+		}
+	}
+	return out
+}
+
+// Instances returns all announced instances, expired ones included until
+// the sweeper removes them.
+func Instances() []Instance {
+	announcedMu.Lock()
+	defer announcedMu.Unlock()
+
+	out := []Instance{}
+	for _, set := range announced {
+		for _, in := range set {
+			out = append(out, in)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Name != out[j].Name {
+			return out[i].Name < out[j].Name
+		}
+		return net.JoinHostPort(out[i].Host, out[i].Port) < net.JoinHostPort(out[j].Host, out[j].Port)
+	})
+	return out
+}
+
+// sweepAnnounced drops instances that stopped sending heartbeats.
+func sweepAnnounced(now time.Time) {
+	announcedMu.Lock()
+	defer announcedMu.Unlock()
+
+	for name, set := range announced {
+		for addr, in := range set {
+			if now.After(in.Expires) {
+				delete(set, addr)
+				sf.SetLog("registry: " + name + " at " + addr + " expired")
+			}
+		}
+		if len(set) == 0 {
+			delete(announced, name)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+
+package registry
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	viper "github.com/spf13/viper"
+)
+
+// setConfig sets config keys for the test only.
+func setConfig(t *testing.T, values map[string]interface{}) {
+	t.Helper()
+	for k, v := range values {
+		viper.Set(k, v)
+		t.Cleanup(func() { viper.Set(k, nil) })
+	}
+}
+
+// dropAnnounced drops the announced instances of names when the test ends.
+func dropAnnounced(t *testing.T, names ...string) {
+	t.Cleanup(func() {
+		announcedMu.Lock()
+		defer announcedMu.Unlock()
+		for _, name := range names {
+			delete(announced, name)
+		}
+	})
+}
+
+func TestAnnounceAllowed(t *testing.T) {
+	rules := []map[string]interface{}{
+		{"identity": "spiffe://gufo/orders", "names": []string{"orders"}},
+		{"identity": "spiffe://gufo/billing/*", "names": []string{"billing", "invoices*"}},
+		{"identity": "*", "names": []string{"public-*"}},
+	}
+	tests := []struct {
+		name     string
+		rules    interface{}
+		identity string
+		service  string
+		want     bool
+	}{
+		{"no rules", nil, "spiffe://gufo/any", "orders", true},
+		{"no rules, no identity", nil, "", "orders", false},
+		{"exact", rules, "spiffe://gufo/orders", "orders", true},
+		{"exact, other name", rules, "spiffe://gufo/orders", "billing", false},
+		{"identity prefix", rules, "spiffe://gufo/billing/worker", "billing", true},
+		{"name prefix", rules, "spiffe://gufo/billing/worker", "invoices-v2", true},
+		{"identity prefix, other name", rules, "spiffe://gufo/billing/worker", "orders", false},
+		{"any identity", rules, "spiffe://other/x", "public-docs", true},
+		{"unknown identity", rules, "spiffe://other/x", "orders", false},
+		{"rules, no identity", rules, "", "public-docs", false},
+		{"unparsable rules", "not a list", "spiffe://gufo/orders", "orders", false},
+	}
+	for _, tt := range tests {
+		setConfig(t, map[string]interface{}{"registry.announce_acl": tt.rules})
+		if got := AnnounceAllowed(tt.identity, tt.service); got != tt.want {
+			t.Errorf("%s: AnnounceAllowed(%q, %q) = %v, want %v", tt.name, tt.identity, tt.service, got, tt.want)
+		}
+	}
+}
+
+func TestOverridable(t *testing.T) {
+	tests := []struct {
+		name     string
+		static   bool
+		override bool
+		want     bool
+	}{
+		{"announced only", false, false, true},
+		{"configured", true, false, false},
+		{"configured, override", true, true, true},
+		{"override without config", false, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.static {
+				setConfig(t, map[string]interface{}{"microservices.ovr": map[string]interface{}{"host": "10.0.0.1", "port": "5300"}})
+			}
+			setConfig(t, map[string]interface{}{"registry.announce_override": tt.override})
+			if got := Overridable("ovr"); got != tt.want {
+				t.Errorf("Overridable = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnnounce(t *testing.T) {
+	setConfig(t, map[string]interface{}{
+		"registry.self_registration": true,
+		"registry.announce_acl":      []map[string]interface{}{{"identity": "svc-a", "names": []string{"ann-*"}}},
+		"microservices.ann-static":   map[string]interface{}{"host": "10.0.0.1", "port": "5300"},
+	})
+	dropAnnounced(t, "ann-a", "ann-static")
+
+	tests := []struct {
+		name string
+		in   Instance
+		want error
+	}{
+		{"ok", Instance{Name: "ann-a", Host: "10.0.0.2", Port: "5300", Identity: "svc-a"}, nil},
+		{"no port", Instance{Name: "ann-a", Host: "10.0.0.2", Identity: "svc-a"}, ErrInvalidInstance},
+		{"no name", Instance{Host: "10.0.0.2", Port: "5300", Identity: "svc-a"}, ErrInvalidInstance},
+		{"identity not allowed", Instance{Name: "ann-a", Host: "10.0.0.2", Port: "5300", Identity: "svc-b"}, ErrNotAllowed},
+		{"name not allowed", Instance{Name: "orders", Host: "10.0.0.2", Port: "5300", Identity: "svc-a"}, ErrNotAllowed},
+		{"no identity", Instance{Name: "ann-a", Host: "10.0.0.2", Port: "5300"}, ErrNotAllowed},
+		{"static service", Instance{Name: "ann-static", Host: "10.0.0.2", Port: "5300", Identity: "svc-a"}, ErrStatic},
+	}
+	for _, tt := range tests {
+		if err := Announce(tt.in, time.Minute); !errors.Is(err, tt.want) {
+			t.Errorf("%s: Announce = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	// a refresh keeps FirstSeen and moves LastSeen
+	first := Instances()
+	time.Sleep(5 * time.Millisecond)
+	if err := Announce(Instance{Name: "ann-a", Host: "10.0.0.2", Port: "5300", Identity: "svc-a"}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	again := Instances()
+	if len(first) != 1 || len(again) != 1 {
+		t.Fatalf("instances = %v then %v, want one", first, again)
+	}
+	if !again[0].FirstSeen.Equal(first[0].FirstSeen) || !again[0].LastSeen.After(first[0].LastSeen) {
+		t.Errorf("refresh: first seen %v -> %v, last seen %v -> %v", first[0].FirstSeen, again[0].FirstSeen, first[0].LastSeen, again[0].LastSeen)
+	}
+
+	setConfig(t, map[string]interface{}{"registry.self_registration": false})
+	if err := Announce(Instance{Name: "ann-a", Host: "10.0.0.2", Port: "5300", Identity: "svc-a"}, time.Minute); !errors.Is(err, ErrRegistrationDisabled) {
+		t.Errorf("disabled: Announce = %v, want %v", err, ErrRegistrationDisabled)
+	}
+}
+
+func TestDeregister(t *testing.T) {
+	setConfig(t, map[string]interface{}{"registry.self_registration": true})
+	dropAnnounced(t, "dereg")
+
+	for _, in := range []Instance{
+		{Name: "dereg", Host: "10.0.0.2", Port: "5300", Identity: "svc-a"},
+		{Name: "dereg", Host: "10.0.0.2", Port: "5301", Identity: "svc-b"},
+	} {
+		if err := Announce(in, time.Minute); err != nil {
+			t.Fatal(err)
+		}
+	}
+	setConfig(t, map[string]interface{}{"registry.announce_acl": []map[string]interface{}{
+		{"identity": "svc-*", "names": []string{"dereg"}},
+	}})
+
+	tests := []struct {
+		name     string
+		port     string
+		identity string
+		want     bool
+		wantErr  error
+	}{
+		{"not allowed for the name", "5300", "other", false, ErrNotAllowed},
+		{"no identity", "5300", "", false, ErrNotAllowed},
+		{"another service's instance", "5300", "svc-b", false, ErrNotAllowed},
+		{"unknown instance", "5999", "svc-a", false, nil},
+		{"own instance", "5300", "svc-a", true, nil},
+		{"already gone", "5300", "svc-a", false, nil},
+	}
+	for _, tt := range tests {
+		got, err := Deregister("dereg", "10.0.0.2", tt.port, tt.identity)
+		if got != tt.want || !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: Deregister = %v, %v; want %v, %v", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+
+	// svc-b's instance survived every attempt but its own
+	if info, ok := Registered("dereg"); !ok || info.Port != "5301" {
+		t.Errorf("Registered = %+v, %v; want svc-b's instance", info, ok)
+	}
+}
+
+func TestAnnounceExpiry(t *testing.T) {
+	setConfig(t, map[string]interface{}{"registry.self_registration": true})
+	dropAnnounced(t, "ttl-svc")
+
+	const ttl = 50 * time.Millisecond
+	in := Instance{Name: "ttl-svc", Host: "10.0.0.2", Port: "5300", Version: "V2", Internal: true, Identity: "svc-a"}
+	if err := Announce(in, ttl); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		wait      time.Duration
+		sweep     bool
+		live      bool
+		instances int
+	}{
+		{"announced", 0, false, true, 1},
+		{"expired, listed until swept", 2 * ttl, false, false, 1},
+		{"swept", 0, true, false, 0},
+	}
+	for _, tt := range tests {
+		time.Sleep(tt.wait)
+		if tt.sweep {
+			sweepAnnounced(time.Now())
+		}
+		info, ok := Registered("ttl-svc")
+		if ok != tt.live {
+			t.Errorf("%s: Registered = %v, want %v", tt.name, ok, tt.live)
+		}
+		if IsInternal("ttl-svc") != tt.live {
+			t.Errorf("%s: IsInternal = %v, want %v", tt.name, !tt.live, tt.live)
+		}
+		if tt.live && info.Version != "v2" {
+			t.Errorf("%s: version = %q, want lower-cased v2", tt.name, info.Version)
+		}
+		if HasVersion("ttl-svc", "v2") != tt.live {
+			t.Errorf("%s: HasVersion = %v, want %v", tt.name, !tt.live, tt.live)
+		}
+		n := 0
+		for _, in := range Instances() {
+			if in.Name == "ttl-svc" {
+				n++
+			}
+		}
+		if n != tt.instances {
+			t.Errorf("%s: %d instances listed, want %d", tt.name, n, tt.instances)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// Consul discovery: healthy instances from the catalog health API, kept
+// current with blocking queries.
+
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	sf "github.com/gogufo/gufo-api-gateway/gufodao"
+	viper "github.com/spf13/viper"
+)
+
+type consulResolver struct {
+	addr   string // http://127.0.0.1:8500
+	token  string
+	dc     string
+	tag    string
+	wait   time.Duration
+	client *http.Client
+}
+
+// consulEntry is one item of /v1/health/service/<name>.
+type consulEntry struct {
+	Node struct {
+		Address string `json:"Address"`
+	} `json:"Node"`
+	Service struct {
+		Address string   `json:"Address"`
+		Port    int      `json:"Port"`
+		Tags    []string `json:"Tags"`
+		Weights struct {
+			Passing int `json:"Passing"`
+		} `json:"Weights"`
+	} `json:"Service"`
+}
+
+func newConsulResolver() (Resolver, error) {
+	c := &consulResolver{
+		addr:  strings.TrimSuffix(viper.GetString("registry.consul.address"), "/"),
+		token: sf.ConfigSecret("registry.consul.token"),
+		dc:    viper.GetString("registry.consul.datacenter"),
+		tag:   viper.GetString("registry.consul.tag"),
+		wait:  viper.GetDuration("registry.consul.wait"),
+	}
+	if c.addr == "" {
+		c.addr = "http://127.0.0.1:8500"
+	}
+	if !strings.Contains(c.addr, "://") {
+		c.addr = "http://" + c.addr
+	}
+	if c.wait <= 0 {
+		c.wait = 5 * time.Minute
+	}
+	// Consul adds up to wait/16 of jitter to blocking queries
+	c.client = &http.Client{Timeout: c.wait + c.wait/16 + 10*time.Second}
+	return c, nil
+}
+
+// query fetches the passing instances; index > 0 blocks until the result
+// changes or wait elapses. It returns the X-Consul-Index of the answer.
+func (c *consulResolver) query(ctx context.Context, service string, index uint64) ([]Endpoint, uint64, error) {
+	q := url.Values{"passing": {"true"}}
+	if c.dc != "" {
+		q.Set("dc", c.dc)
+	}
+	if c.tag != "" {
+		q.Set("tag", c.tag)
+	}
+	if index > 0 {
+		q.Set("index", strconv.FormatUint(index, 10))
+		q.Set("wait", fmt.Sprintf("%ds", int(c.wait.Seconds())))
+	}
+	u := c.addr + "/v1/health/service/" + url.PathEscape(service) + "?" + q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if c.token != "" {
+		req.Header.Set("X-Consul-Token", c.token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("consul: %s answered %s", u, resp.Status)
+	}
+
+	var entries []consulEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, 0, fmt.Errorf("consul: %w", err)
+	}
+	next, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+
+	eps := make([]Endpoint, 0, len(entries))
+	for _, e := range entries {
+		host := e.Service.Address
+		if host == "" {
+			host = e.Node.Address
+		}
+		eps = append(eps, Endpoint{
+			Host:   host,
+			Port:   strconv.Itoa(e.Service.Port),
+			Weight: e.Service.Weights.Passing,
+			Tags:   e.Service.Tags,
+		})
+	}
+	return eps, next, nil
+}
+
+func (c *consulResolver) Resolve(ctx context.Context, service string) ([]Endpoint, error) {
+	eps, _, err := c.query(ctx, service, 0)
+	return eps, err
+}
+
+// Watch runs blocking queries: each answer arrives as soon as the set of
+// passing instances changes.
+func (c *consulResolver) Watch(ctx context.Context, service string, update func([]Endpoint)) error {
+	var index uint64
+	for {
+		started := time.Now()
+		eps, next, err := c.query(ctx, service, index)
+		if err != nil {
+			return err
+		}
+		switch {
+		case next == 0:
+			update(eps)
+			return fmt.Errorf("consul: answer without X-Consul-Index")
+		case next < index:
+			index = 0 // index went backwards (e.g. snapshot restore): start over
+			continue
+		case next != index:
+			update(eps)
+		}
+		index = next
+
+		// blocking queries can wake up early; don't hammer the agent
+		if wait := time.Second - time.Since(started); wait > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(wait):
+			}
+		}
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+
+package registry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// consulAnswer is the scripted answer to a query with a given index.
+type consulAnswer struct {
+	index string // X-Consul-Index
+	body  string
+}
+
+func TestConsulWatch(t *testing.T) {
+	// index "" is the first (non-blocking) query; 12 → 5 goes backwards
+	answers := map[string]consulAnswer{
+		"":   {"10", `[{"Node":{"Address":"10.0.0.1"},"Service":{"Port":5000}}]`},
+		"10": {"12", `[{"Node":{"Address":"10.0.0.1"},"Service":{"Port":5000}},{"Service":{"Address":"10.0.0.2","Port":5000,"Weights":{"Passing":3}}}]`},
+		"12": {"5", `[{"Node":{"Address":"10.0.0.9"},"Service":{"Port":5000}}]`},
+	}
+
+	var mu sync.Mutex
+	var indexes []string
+	blocked := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/health/service/orders" || r.URL.Query().Get("passing") != "true" {
+			http.NotFound(w, r)
+			return
+		}
+		index := r.URL.Query().Get("index")
+		mu.Lock()
+		indexes = append(indexes, index)
+		restarted := index == "" && len(indexes) > 1
+		mu.Unlock()
+
+		a, ok := answers[index]
+		if restarted {
+			a, ok = consulAnswer{"6", `[{"Node":{"Address":"10.0.0.3"},"Service":{"Port":5000}}]`}, true
+		}
+		if !ok {
+			close(blocked)
+			<-r.Context().Done() // block like Consul until the watch ends
+			return
+		}
+		w.Header().Set("X-Consul-Index", a.index)
+		fmt.Fprint(w, a.body)
+	}))
+	defer srv.Close()
+
+	c := &consulResolver{addr: srv.URL, wait: time.Second, client: srv.Client()}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates := make(chan []Endpoint, 10)
+	done := make(chan error, 1)
+	go func() { done <- c.Watch(ctx, "orders", func(eps []Endpoint) { updates <- eps }) }()
+
+	want := [][]Endpoint{
+		{{Host: "10.0.0.1", Port: "5000"}},
+		{{Host: "10.0.0.1", Port: "5000"}, {Host: "10.0.0.2", Port: "5000", Weight: 3}},
+		{{Host: "10.0.0.3", Port: "5000"}}, // after the index went backwards
+	}
+	for i, w := range want {
+		select {
+		case got := <-updates:
+			if !reflect.DeepEqual(got, w) {
+				t.Fatalf("update %d = %+v, want %+v", i, got, w)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("update %d: timeout", i)
+		}
+	}
+
+	select {
+	case <-blocked:
+	case <-time.After(10 * time.Second):
+		t.Fatal("no blocking query after the last update")
+	}
+	cancel()
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	wantIndexes := []string{"", "10", "12", "", "6"}
+	if !reflect.DeepEqual(indexes, wantIndexes) {
+		t.Fatalf("queried indexes %q, want %q", indexes, wantIndexes)
+	}
+}
+
+func TestConsulWatchNoIndex(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[]`)
+	}))
+	defer srv.Close()
+
+	c := &consulResolver{addr: srv.URL, wait: time.Second, client: srv.Client()}
+	err := c.Watch(context.Background(), "orders", func([]Endpoint) {})
+	if err == nil {
+		t.Fatal("Watch without X-Consul-Index returned nil")
+	}
+}
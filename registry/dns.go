@@ -0,0 +1,164 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// DNS discovery: SRV records (_<srv_service>._<srv_proto>.<service>.<domain>)
+// with an A/AAAA fallback.
+
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	viper "github.com/spf13/viper"
+)
+
+type dnsResolver struct {
+	r          *net.Resolver
+	domain     string // appended to service names, e.g. "svc.cluster.local"
+	srv        bool
+	srvService string
+	srvProto   string
+	port       string // for A/AAAA answers; else microservices.<m>.port
+	interval   time.Duration
+}
+
+func newDNSResolver() (Resolver, error) {
+	d := &dnsResolver{
+		r:          net.DefaultResolver,
+		domain:     strings.Trim(viper.GetString("registry.dns.domain"), "."),
+		srv:        true,
+		srvService: viper.GetString("registry.dns.srv_service"),
+		srvProto:   viper.GetString("registry.dns.srv_proto"),
+		port:       viper.GetString("registry.dns.port"),
+		interval:   viper.GetDuration("registry.dns.interval"),
+	}
+	if viper.IsSet("registry.dns.srv") {
+		d.srv = viper.GetBool("registry.dns.srv")
+	}
+	if d.srvService == "" {
+		d.srvService = "grpc"
+	}
+	if d.srvProto == "" {
+		d.srvProto = "tcp"
+	}
+	if d.interval <= 0 {
+		d.interval = 10 * time.Second
+	}
+
+	// A dedicated server (host:port), e.g. a local DNS in tests or CoreDNS
+	if server := viper.GetString("registry.dns.server"); server != "" {
+		d.r = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, network, server)
+			},
+		}
+	}
+	return d, nil
+}
+
+func (d *dnsResolver) name(service string) string {
+	if d.domain == "" {
+		return service
+	}
+	return service + "." + d.domain
+}
+
+func (d *dnsResolver) Resolve(ctx context.Context, service string) ([]Endpoint, error) {
+	if d.srv {
+		_, records, err := d.r.LookupSRV(ctx, d.srvService, d.srvProto, d.name(service))
+		if err == nil && len(records) > 0 {
+			return srvEndpoints(records), nil
+		}
+		var dnsErr *net.DNSError
+		if err != nil && !(errors.As(err, &dnsErr) && dnsErr.IsNotFound) {
+			return nil, err
+		}
+	}
+
+	port := d.port
+	if port == "" {
+		port = viper.GetString("microservices." + strings.ReplaceAll(service, "-", "_") + ".port")
+	}
+	if port == "" {
+		return nil, fmt.Errorf("dns: no SRV records for %s and no port configured", d.name(service))
+	}
+
+	addrs, err := d.r.LookupHost(ctx, d.name(service))
+	if err != nil {
+		return nil, err
+	}
+	eps := make([]Endpoint, 0, len(addrs))
+	for _, a := range addrs {
+		eps = append(eps, Endpoint{Host: a, Port: port})
+	}
+	return eps, nil
+}
+
+// srvEndpoints keeps the records of the best (lowest) priority.
+func srvEndpoints(records []*net.SRV) []Endpoint {
+	best := records[0].Priority
+	for _, r := range records {
+		best = min(best, r.Priority)
+	}
+	var eps []Endpoint
+	for _, r := range records {
+		if r.Priority != best {
+			continue
+		}
+		eps = append(eps, Endpoint{
+			Host:   strings.TrimSuffix(r.Target, "."),
+			Port:   strconv.Itoa(int(r.Port)),
+			Weight: int(r.Weight),
+		})
+	}
+	return eps
+}
+
+// Watch re-resolves every registry.dns.interval (default 10s): DNS has no
+// change notifications. Failed lookups keep the last known endpoints.
+func (d *dnsResolver) Watch(ctx context.Context, service string, update func([]Endpoint)) error {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	var last []Endpoint
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		lctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		eps, err := d.Resolve(lctx, service)
+		cancel()
+		if err != nil || len(eps) == 0 {
+			continue
+		}
+		eps = sortEndpoints(eps)
+		if !reflect.DeepEqual(eps, last) {
+			last = eps
+			update(eps)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+
+package registry
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	viper "github.com/spf13/viper"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// serveDNS answers SRV and A queries from srv and a on a local UDP port
+// until the test ends; unknown names get NXDOMAIN.
+func serveDNS(t *testing.T, srv map[string][]dnsmessage.SRVResource, a map[string][]net.IP) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			var req dnsmessage.Message
+			if err := req.Unpack(buf[:n]); err != nil || len(req.Questions) != 1 {
+				continue
+			}
+			q := req.Questions[0]
+			name := strings.ToLower(q.Name.String())
+			resp := dnsmessage.Message{
+				Header:    dnsmessage.Header{ID: req.ID, Response: true, Authoritative: true},
+				Questions: req.Questions,
+			}
+			_, knownSRV := srv[name]
+			_, knownA := a[name]
+			switch {
+			case q.Type == dnsmessage.TypeSRV && knownSRV:
+				for _, r := range srv[name] {
+					resp.Answers = append(resp.Answers, dnsmessage.Resource{
+						Header: dnsmessage.ResourceHeader{Name: q.Name, Class: dnsmessage.ClassINET, TTL: 30},
+						Body:   &r,
+					})
+				}
+			case q.Type == dnsmessage.TypeA && knownA:
+				for _, ip := range a[name] {
+					var body dnsmessage.AResource
+					copy(body.A[:], ip.To4())
+					resp.Answers = append(resp.Answers, dnsmessage.Resource{
+						Header: dnsmessage.ResourceHeader{Name: q.Name, Class: dnsmessage.ClassINET, TTL: 30},
+						Body:   &body,
+					})
+				}
+			case knownSRV || knownA: // name exists, no records of this type
+			default:
+				resp.RCode = dnsmessage.RCodeNameError
+			}
+			out, err := resp.Pack()
+			if err != nil {
+				continue
+			}
+			conn.WriteTo(out, addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestDNSResolve(t *testing.T) {
+	target := func(s string) dnsmessage.Name { return dnsmessage.MustNewName(s) }
+	server := serveDNS(t,
+		map[string][]dnsmessage.SRVResource{
+			"_grpc._tcp.orders.gufo.test.": {
+				{Priority: 10, Weight: 3, Port: 5001, Target: target("a.gufo.test.")},
+				{Priority: 10, Weight: 1, Port: 5002, Target: target("b.gufo.test.")},
+				{Priority: 20, Weight: 5, Port: 5003, Target: target("backup.gufo.test.")},
+			},
+		},
+		map[string][]net.IP{
+			"users.gufo.test.": {net.ParseIP("10.0.0.7")},
+		},
+	)
+
+	viper.Set("registry.dns.server", server)
+	viper.Set("registry.dns.domain", "gufo.test")
+	viper.Set("registry.dns.port", "5100")
+	t.Cleanup(func() {
+		viper.Set("registry.dns.server", "")
+		viper.Set("registry.dns.domain", "")
+		viper.Set("registry.dns.port", "")
+	})
+
+	d, err := newDNSResolver()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		service string
+		want    []Endpoint
+		wantErr bool
+	}{
+		// SRV: only the lowest priority, weights kept
+		{"orders", []Endpoint{
+			{Host: "a.gufo.test", Port: "5001", Weight: 3},
+			{Host: "b.gufo.test", Port: "5002", Weight: 1},
+		}, false},
+		// no SRV records: A records on registry.dns.port
+		{"users", []Endpoint{{Host: "10.0.0.7", Port: "5100"}}, false},
+		{"missing", nil, true},
+	}
+	for _, tt := range tests {
+		eps, err := d.Resolve(context.Background(), tt.service)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("Resolve(%s) error = %v, wantErr %v", tt.service, err, tt.wantErr)
+		}
+		if got := sortEndpoints(eps); !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("Resolve(%s) = %+v, want %+v", tt.service, got, tt.want)
+		}
+	}
+}
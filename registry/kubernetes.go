@@ -0,0 +1,275 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// Kubernetes discovery: EndpointSlices of the service read from the API
+// server (in-cluster service account by default) and kept current with a
+// watch.
+
+package registry
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	viper "github.com/spf13/viper"
+)
+
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount/"
+
+type kubernetesResolver struct {
+	api       string // https://kubernetes.default.svc
+	tokenFile string
+	namespace string
+	portName  string
+	client    *http.Client
+}
+
+// endpointSlice is the subset of discovery.k8s.io/v1 EndpointSlice we use.
+type endpointSlice struct {
+	Metadata struct {
+		Name            string `json:"name"`
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Endpoints []struct {
+		Addresses  []string `json:"addresses"`
+		Conditions struct {
+			Ready *bool `json:"ready"`
+		} `json:"conditions"`
+	} `json:"endpoints"`
+	Ports []struct {
+		Name *string `json:"name"`
+		Port *int32  `json:"port"`
+	} `json:"ports"`
+}
+
+type endpointSliceList struct {
+	Metadata struct {
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Items []endpointSlice `json:"items"`
+}
+
+type watchEvent struct {
+	Type   string          `json:"type"` // ADDED, MODIFIED, DELETED, BOOKMARK, ERROR
+	Object json.RawMessage `json:"object"`
+}
+
+func newKubernetesResolver() (Resolver, error) {
+	k := &kubernetesResolver{
+		api:       strings.TrimSuffix(viper.GetString("registry.kubernetes.api_server"), "/"),
+		tokenFile: viper.GetString("registry.kubernetes.token_file"),
+		namespace: viper.GetString("registry.kubernetes.namespace"),
+		portName:  viper.GetString("registry.kubernetes.port_name"),
+	}
+	if k.api == "" {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" || port == "" {
+			return nil, errors.New("kubernetes: not in a cluster and registry.kubernetes.api_server is empty")
+		}
+		k.api = "https://" + strings.TrimSuffix(host, "/") + ":" + port
+		if strings.Contains(host, ":") {
+			k.api = "https://[" + host + "]:" + port
+		}
+	}
+	if k.tokenFile == "" {
+		k.tokenFile = serviceAccountDir + "token"
+	}
+	if k.namespace == "" {
+		if b, err := os.ReadFile(serviceAccountDir + "namespace"); err == nil {
+			k.namespace = strings.TrimSpace(string(b))
+		} else {
+			k.namespace = "default"
+		}
+	}
+	if k.portName == "" {
+		k.portName = "grpc"
+	}
+
+	caFile := viper.GetString("registry.kubernetes.ca_file")
+	if caFile == "" {
+		caFile = serviceAccountDir + "ca.crt"
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if pem, err := os.ReadFile(caFile); err == nil {
+		pool := x509.NewCertPool()
+		pool.AppendCertsFromPEM(pem)
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+	// no client timeout: watches are long-lived and bound by ctx
+	k.client = &http.Client{Transport: transport}
+	return k, nil
+}
+
+func (k *kubernetesResolver) get(ctx context.Context, service string, extra url.Values) (*http.Response, error) {
+	q := url.Values{"labelSelector": {"kubernetes.io/service-name=" + service}}
+	for key, v := range extra {
+		q[key] = v
+	}
+	u := fmt.Sprintf("%s/apis/discovery.k8s.io/v1/namespaces/%s/endpointslices?%s",
+		k.api, url.PathEscape(k.namespace), q.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if token, err := os.ReadFile(k.tokenFile); err == nil { // re-read: tokens are rotated
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("kubernetes: endpointslices of %s: %s", service, resp.Status)
+	}
+	return resp, nil
+}
+
+// list returns the endpoints per slice and the list's resourceVersion.
+func (k *kubernetesResolver) list(ctx context.Context, service string) (map[string][]Endpoint, string, error) {
+	resp, err := k.get(ctx, service, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	var list endpointSliceList
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, "", fmt.Errorf("kubernetes: %w", err)
+	}
+	slices := map[string][]Endpoint{}
+	for i := range list.Items {
+		slices[list.Items[i].Metadata.Name] = k.endpoints(&list.Items[i])
+	}
+	return slices, list.Metadata.ResourceVersion, nil
+}
+
+// endpoints returns the ready addresses of a slice with its port named
+// registry.kubernetes.port_name (default "grpc"), else its first port.
+func (k *kubernetesResolver) endpoints(s *endpointSlice) []Endpoint {
+	port := ""
+	for _, p := range s.Ports {
+		if p.Port == nil {
+			continue
+		}
+		if p.Name != nil && *p.Name == k.portName {
+			port = strconv.Itoa(int(*p.Port))
+			break
+		}
+		if port == "" {
+			port = strconv.Itoa(int(*p.Port))
+		}
+	}
+	if port == "" {
+		return nil
+	}
+
+	var eps []Endpoint
+	for _, e := range s.Endpoints {
+		if e.Conditions.Ready != nil && !*e.Conditions.Ready {
+			continue
+		}
+		for _, addr := range e.Addresses {
+			eps = append(eps, Endpoint{Host: addr, Port: port})
+		}
+	}
+	return eps
+}
+
+func flatten(slices map[string][]Endpoint) []Endpoint {
+	var out []Endpoint
+	for _, eps := range slices {
+		out = append(out, eps...)
+	}
+	return out
+}
+
+func (k *kubernetesResolver) Resolve(ctx context.Context, service string) ([]Endpoint, error) {
+	slices, _, err := k.list(ctx, service)
+	if err != nil {
+		return nil, err
+	}
+	return flatten(slices), nil
+}
+
+// Watch lists the slices, then follows the watch stream from that
+// resourceVersion. The stream is reopened when the API server closes it;
+// an expired resourceVersion (410) ends the watch so it starts over with a
+// fresh list.
+func (k *kubernetesResolver) Watch(ctx context.Context, service string, update func([]Endpoint)) error {
+	slices, rv, err := k.list(ctx, service)
+	if err != nil {
+		return err
+	}
+	update(flatten(slices))
+
+	for {
+		resp, err := k.get(ctx, service, url.Values{
+			"watch":               {"true"},
+			"resourceVersion":     {rv},
+			"allowWatchBookmarks": {"true"},
+			"timeoutSeconds":      {"300"},
+		})
+		if err != nil {
+			return err
+		}
+
+		dec := json.NewDecoder(resp.Body)
+		for {
+			var ev watchEvent
+			if err := dec.Decode(&ev); err != nil {
+				break // stream closed: re-watch from rv
+			}
+			if ev.Type == "ERROR" {
+				resp.Body.Close()
+				return fmt.Errorf("kubernetes: watch %s: %s", service, ev.Object)
+			}
+
+			var s endpointSlice
+			if err := json.Unmarshal(ev.Object, &s); err != nil {
+				continue
+			}
+			if s.Metadata.ResourceVersion != "" {
+				rv = s.Metadata.ResourceVersion
+			}
+			switch ev.Type {
+			case "ADDED", "MODIFIED":
+				slices[s.Metadata.Name] = k.endpoints(&s)
+			case "DELETED":
+				delete(slices, s.Metadata.Name)
+			default: // BOOKMARK
+				continue
+			}
+			update(flatten(slices))
+		}
+		resp.Body.Close()
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+
+package registry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testSliceList = `{"metadata":{"resourceVersion":"100"},"items":[{
+		"metadata":{"name":"s1","resourceVersion":"90"},
+		"endpoints":[
+			{"addresses":["10.0.1.1"],"conditions":{"ready":true}},
+			{"addresses":["10.0.1.2"],"conditions":{"ready":false}}],
+		"ports":[{"name":"http","port":8080},{"name":"grpc","port":5000}]}]}`
+	testSliceAdded   = `{"type":"ADDED","object":{"metadata":{"name":"s2","resourceVersion":"101"},"endpoints":[{"addresses":["10.0.2.1"]}],"ports":[{"name":"grpc","port":5000}]}}`
+	testSliceDeleted = `{"type":"DELETED","object":{"metadata":{"name":"s1","resourceVersion":"102"}}}`
+	testWatchGone    = `{"type":"ERROR","object":{"kind":"Status","status":"Failure","reason":"Expired","code":410}}`
+)
+
+func TestKubernetesWatch(t *testing.T) {
+	var watches []string // resourceVersion of each watch request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/apis/discovery.k8s.io/v1/namespaces/shop/endpointslices" ||
+			q.Get("labelSelector") != "kubernetes.io/service-name=orders" {
+			http.NotFound(w, r)
+			return
+		}
+		if q.Get("watch") != "true" {
+			fmt.Fprint(w, testSliceList)
+			return
+		}
+		watches = append(watches, q.Get("resourceVersion"))
+		if len(watches) == 1 {
+			fmt.Fprintln(w, testSliceAdded)
+			fmt.Fprintln(w, testSliceDeleted)
+			return // stream closes: the resolver re-watches
+		}
+		fmt.Fprintln(w, testWatchGone)
+	}))
+	defer srv.Close()
+
+	k := &kubernetesResolver{
+		api:       srv.URL,
+		tokenFile: t.TempDir() + "/token", // missing: no Authorization header
+		namespace: "shop",
+		portName:  "grpc",
+		client:    srv.Client(),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var updates [][]string
+	err := k.Watch(ctx, "orders", func(eps []Endpoint) {
+		var hosts []string
+		for _, ep := range eps {
+			hosts = append(hosts, ep.Host+":"+ep.Port)
+		}
+		sort.Strings(hosts)
+		updates = append(updates, hosts)
+	})
+
+	if err == nil || !strings.Contains(err.Error(), "410") {
+		t.Fatalf("Watch error = %v, want the 410 Status", err)
+	}
+	want := [][]string{
+		{"10.0.1.1:5000"},                  // list: not-ready address skipped
+		{"10.0.1.1:5000", "10.0.2.1:5000"}, // ADDED s2
+		{"10.0.2.1:5000"},                  // DELETED s1
+	}
+	if !reflect.DeepEqual(updates, want) {
+		t.Fatalf("updates = %q, want %q", updates, want)
+	}
+	if wantRV := []string{"100", "102"}; !reflect.DeepEqual(watches, wantRV) {
+		t.Fatalf("watched from %q, want %q", watches, wantRV)
+	}
+}
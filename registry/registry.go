@@ -31,21 +31,35 @@ import (
 
 // ServiceInfo describes a resolved microservice endpoint.
 type ServiceInfo struct {
-	Host       string
-	Port       string
-	LastUpdate time.Time
+	Host       string     `json:"host"`
+	Port       string     `json:"port"`
+	Version    string     `json:"version,omitempty"`
+	Tags       []string   `json:"tags,omitempty"`
+	Internal   bool       `json:"internal,omitempty"`
+	Endpoints  []Endpoint `json:"endpoints,omitempty"` // every live endpoint; Host/Port is the one picked
+	LastUpdate time.Time  `json:"last_update"`
 }
 
 var (
-	cache sync.Map           // map[string]ServiceInfo
-	ttl   = 60 * time.Second // default TTL for cached entries
+	cache   sync.Map           // map[string]ServiceInfo
+	ttl     = 60 * time.Second // default TTL for cached entries
+	drained sync.Map           // map[string]time.Time — modules taken out of rotation
 )
 
+// Entry is a cached registry record as exposed to the admin API.
+type Entry struct {
+	Module  string      `json:"module"`
+	Info    ServiceInfo `json:"info"`
+	Expired bool        `json:"expired"`
+}
+
 // getRegistryMode returns normalized registry mode.
 //
 // Supported modes:
 //   - "master"  – resolve via masterservice
 //   - "static"  – resolve from local config/env
+//   - "dns", "consul", "kubernetes" – resolve via a Resolver (see
+//     resolver.go), falling back to local config/env
 //
 // If server.masterservice=true and no explicit mode set -> "master".
 // Otherwise -> "static".
@@ -118,6 +132,11 @@ func getServiceFromMaster(module string) (ServiceInfo, error) {
 func GetService(module string) (ServiceInfo, error) {
 	// fmt.Fprintln(os.Stderr, ">>> REGISTRY GetService:", module)
 
+	// 0️⃣ Self-registered instances (see Announce), then the discovery backend
+	if info, ok := Discover(module); ok {
+		return info, nil
+	}
+
 	// 1️⃣ Cache
 	if v, ok := cache.Load(module); ok {
 		info := v.(ServiceInfo)
@@ -131,7 +150,7 @@ func GetService(module string) (ServiceInfo, error) {
 	mode := strings.ToLower(viper.GetString("server.registry_mode"))
 	// fmt.Fprintln(os.Stderr, ">>> REGISTRY static lookup for:", module)
 
-	if mode == "static" || viper.GetBool("server.masterservice") == false {
+	if mode == "static" || usesResolver(mode) || viper.GetBool("server.masterservice") == false {
 
 		key := strings.ReplaceAll(module, "-", "_")
 
@@ -214,11 +233,15 @@ func RefreshCache() {
 			err     error
 		)
 
-		switch mode {
-		case "static":
+		switch {
+		case strings.Contains(mod, "@"):
+			// versioned endpoints always come from config/env
+			name, version, _ := strings.Cut(mod, "@")
+			newInfo, err = getVersionFromConfig(name, version)
+		case mode == "static" || usesResolver(mode):
 			// For static mode we simply reload from config/env.
 			newInfo, err = getStaticServiceFromConfig(mod)
-		case "master":
+		case mode == "master":
 			newInfo, err = getServiceFromMaster(mod)
 		default:
 			err = fmt.Errorf("unknown registry mode: %s", mode)
@@ -238,6 +261,7 @@ func StartSweeper() {
 		ticker := time.NewTicker(1 * time.Minute)
 		for range ticker.C {
 			now := time.Now()
+			sweepAnnounced(now)
 			cache.Range(func(key, value any) bool {
 				info := value.(ServiceInfo)
 				if now.Sub(info.LastUpdate) > ttl {
@@ -248,3 +272,82 @@ func StartSweeper() {
 		}
 	}()
 }
+
+// List returns a snapshot of cached registry entries.
+// Versioned endpoints are listed under their module name.
+func List() []Entry {
+	var out []Entry
+	cache.Range(func(key, value any) bool {
+		info := value.(ServiceInfo)
+		module, _, _ := strings.Cut(key.(string), "@")
+		out = append(out, Entry{
+			Module:  module,
+			Info:    info,
+			Expired: time.Since(info.LastUpdate) > ttl,
+		})
+		return true
+	})
+	return out
+}
+
+// Evict removes module (and its versioned endpoints) from the cache so the
+// next call resolves it again.
+func Evict(module string) bool {
+	_, ok := cache.LoadAndDelete(module)
+	if forget(module) {
+		ok = true
+	}
+	cache.Range(func(key, _ any) bool {
+		if strings.HasPrefix(key.(string), module+"@") {
+			cache.Delete(key)
+			ok = true
+		}
+		return true
+	})
+	return ok
+}
+
+// Known reports whether module is configured, cached, announced or
+// watched. Unlike GetService it never asks a backend.
+func Known(module string) bool {
+	if viper.IsSet("microservices." + strings.ReplaceAll(module, "-", "_")) {
+		return true
+	}
+	if _, ok := cache.Load(module); ok {
+		return true
+	}
+	if _, ok := watched.Load(module); ok {
+		return true
+	}
+	announcedMu.Lock()
+	defer announcedMu.Unlock()
+	return len(announced[module]) > 0
+}
+
+// Drain takes module out of rotation: the gateway answers 503 for it
+// until Undrain is called.
+func Drain(module string) {
+	drained.Store(module, time.Now())
+}
+
+// Undrain puts a drained module back into rotation.
+func Undrain(module string) bool {
+	_, ok := drained.LoadAndDelete(module)
+	return ok
+}
+
+// IsDrained reports whether module is drained.
+func IsDrained(module string) bool {
+	_, ok := drained.Load(module)
+	return ok
+}
+
+// Drained returns drained modules with the time they were drained.
+func Drained() map[string]time.Time {
+	out := map[string]time.Time{}
+	drained.Range(func(key, value any) bool {
+		out[key.(string)] = value.(time.Time)
+		return true
+	})
+	return out
+}
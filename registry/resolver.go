@@ -0,0 +1,292 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// Discovery backends (server.registry_mode = dns | consul | kubernetes):
+// a Resolver lists a service's endpoints and pushes changes, the registry
+// keeps one watch per service and picks endpoints weighted round-robin.
+
+package registry
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	sf "github.com/gogufo/gufo-api-gateway/gufodao"
+	viper "github.com/spf13/viper"
+)
+
+// Endpoint is one address of a service.
+type Endpoint struct {
+	Host   string   `json:"host"`
+	Port   string   `json:"port"`
+	Weight int      `json:"weight,omitempty"`
+	Tags   []string `json:"tags,omitempty"`
+}
+
+// Resolver discovers service endpoints.
+type Resolver interface {
+	// Resolve returns the current endpoints of service.
+	Resolve(ctx context.Context, service string) ([]Endpoint, error)
+	// Watch calls update with the full endpoint list whenever it changes,
+	// until ctx is done or the backend fails. Backends without change
+	// notifications (DNS) re-resolve periodically.
+	Watch(ctx context.Context, service string, update func([]Endpoint)) error
+}
+
+// ResolverFactory creates a resolver from configuration (registry.<name>.* keys).
+type ResolverFactory func() (Resolver, error)
+
+var (
+	resolversMu sync.Mutex
+	resolvers   = map[string]ResolverFactory{
+		"dns":        newDNSResolver,
+		"consul":     newConsulResolver,
+		"kubernetes": newKubernetesResolver,
+	}
+
+	resolver     Resolver
+	resolverOnce sync.Once
+
+	watched sync.Map // service → *watch
+	misses  sync.Map // service → time.Time of the last empty lookup
+
+	rrMu sync.Mutex
+	rr   = map[string]int{} // round-robin position per service (and version)
+)
+
+// RegisterResolver makes a discovery backend selectable with server.registry_mode.
+func RegisterResolver(name string, f ResolverFactory) {
+	resolversMu.Lock()
+	defer resolversMu.Unlock()
+	resolvers[strings.ToLower(name)] = f
+}
+
+// usesResolver reports whether mode is served by a registered Resolver.
+func usesResolver(mode string) bool {
+	resolversMu.Lock()
+	defer resolversMu.Unlock()
+	_, ok := resolvers[mode]
+	return ok
+}
+
+// activeResolver returns the resolver of the configured mode, nil for
+// master/static or when the backend cannot be created.
+func activeResolver() Resolver {
+	resolverOnce.Do(func() {
+		mode := getRegistryMode()
+		resolversMu.Lock()
+		f, ok := resolvers[mode]
+		resolversMu.Unlock()
+		if !ok {
+			return
+		}
+		r, err := f()
+		if err != nil {
+			sf.SetErrorLog(fmt.Sprintf("registry: %s resolver unavailable, using static config: %v", mode, err))
+			return
+		}
+		resolver = r
+		sf.SetLog("🧭 Service discovery: " + mode)
+	})
+	return resolver
+}
+
+// Discover returns a live endpoint of name from self-registration or the
+// discovery backend, without touching the static/master cache.
+func Discover(name string) (ServiceInfo, bool) {
+	if info, ok := Registered(name); ok {
+		return info, true
+	}
+	return resolved(name)
+}
+
+// resolved picks an endpoint of name from its watch, starting the watch on
+// first use. Empty lookups are remembered for registry.miss_ttl (default 5s)
+// so unknown names do not hit the backend on every request.
+func resolved(name string) (ServiceInfo, bool) {
+	r := activeResolver()
+	if r == nil {
+		return ServiceInfo{}, false
+	}
+
+	v, ok := watched.Load(name)
+	if !ok {
+		if t, ok := misses.Load(name); ok && time.Since(t.(time.Time)) < missTTL() {
+			return ServiceInfo{}, false
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		eps, err := r.Resolve(ctx, name)
+		cancel()
+		if err != nil || len(eps) == 0 {
+			if err != nil {
+				sf.SetDebugLog(fmt.Sprintf("registry: resolve %s: %v", name, err))
+			}
+			misses.Store(name, time.Now())
+			return ServiceInfo{}, false
+		}
+
+		w := newWatch(eps)
+		actual, loaded := watched.LoadOrStore(name, w)
+		if !loaded {
+			go w.run(r, name)
+		}
+		v = actual
+	}
+
+	w := v.(*watch)
+	eps, updated := w.get()
+	if len(eps) == 0 {
+		return ServiceInfo{}, false
+	}
+	ep := pickEndpoint(name, eps)
+	return ServiceInfo{
+		Host:       ep.Host,
+		Port:       ep.Port,
+		Tags:       ep.Tags,
+		Endpoints:  eps,
+		LastUpdate: updated,
+	}, true
+}
+
+// Discovered returns the endpoints of every watched service.
+func Discovered() map[string][]Endpoint {
+	out := map[string][]Endpoint{}
+	watched.Range(func(key, value any) bool {
+		out[key.(string)], _ = value.(*watch).get()
+		return true
+	})
+	return out
+}
+
+// forget stops the watch of name (see Evict).
+func forget(name string) bool {
+	misses.Delete(name)
+	v, ok := watched.LoadAndDelete(name)
+	if ok {
+		v.(*watch).cancel()
+	}
+	return ok
+}
+
+func missTTL() time.Duration {
+	if d := viper.GetDuration("registry.miss_ttl"); d > 0 {
+		return d
+	}
+	return 5 * time.Second
+}
+
+// roundRobin returns the next index in [0, n) for key.
+func roundRobin(key string, n int) int {
+	rrMu.Lock()
+	defer rrMu.Unlock()
+	i := rr[key] % n
+	rr[key]++
+	return i
+}
+
+// pickEndpoint returns the next endpoint for key, round-robin weighted by
+// Endpoint.Weight (SRV weight, Consul passing weight). A missing or zero
+// weight counts as 1.
+func pickEndpoint(key string, eps []Endpoint) Endpoint {
+	total := 0
+	for _, ep := range eps {
+		total += endpointWeight(ep)
+	}
+	n := roundRobin(key, total)
+	for _, ep := range eps {
+		if n -= endpointWeight(ep); n < 0 {
+			return ep
+		}
+	}
+	return eps[len(eps)-1]
+}
+
+func endpointWeight(ep Endpoint) int {
+	return max(ep.Weight, 1)
+}
+
+// -------------------------------------------------------------------
+// Watches
+// -------------------------------------------------------------------
+
+type watch struct {
+	mu        sync.Mutex
+	endpoints []Endpoint
+	updated   time.Time
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func newWatch(eps []Endpoint) *watch {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &watch{endpoints: sortEndpoints(eps), updated: time.Now(), ctx: ctx, cancel: cancel}
+}
+
+func (w *watch) get() ([]Endpoint, time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.endpoints, w.updated
+}
+
+func (w *watch) set(eps []Endpoint) {
+	eps = sortEndpoints(eps)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.updated = time.Now()
+	if !reflect.DeepEqual(eps, w.endpoints) {
+		w.endpoints = eps
+	}
+}
+
+// run keeps the watch alive, restarting it with backoff (1s up to 30s)
+// when the backend fails.
+func (w *watch) run(r Resolver, name string) {
+	backoff := time.Second
+	for w.ctx.Err() == nil {
+		start := time.Now()
+		err := r.Watch(w.ctx, name, w.set)
+		if w.ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			sf.SetErrorLog(fmt.Sprintf("registry: watch %s: %v", name, err))
+		}
+		if time.Since(start) > time.Minute {
+			backoff = time.Second
+		}
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, 30*time.Second)
+	}
+}
+
+func sortEndpoints(eps []Endpoint) []Endpoint {
+	out := append([]Endpoint{}, eps...)
+	sort.Slice(out, func(i, j int) bool {
+		return net.JoinHostPort(out[i].Host, out[i].Port) < net.JoinHostPort(out[j].Host, out[j].Port)
+	})
+	return out
+}
@@ -0,0 +1,42 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+
+package registry
+
+import "testing"
+
+func TestPickEndpoint(t *testing.T) {
+	tests := []struct {
+		name string
+		eps  []Endpoint
+		want map[string]int // picks per host over 8 calls
+	}{
+		{"weighted", []Endpoint{{Host: "a", Weight: 3}, {Host: "b", Weight: 1}}, map[string]int{"a": 6, "b": 2}},
+		{"zero counts as 1", []Endpoint{{Host: "a"}, {Host: "b", Weight: 1}}, map[string]int{"a": 4, "b": 4}},
+		{"single", []Endpoint{{Host: "a", Weight: 7}}, map[string]int{"a": 8}},
+	}
+	for _, tt := range tests {
+		got := map[string]int{}
+		for range 8 {
+			got[pickEndpoint("test/"+tt.name, tt.eps).Host]++
+		}
+		for host, n := range tt.want {
+			if got[host] != n {
+				t.Fatalf("%s: %s picked %d times, want %d (%v)", tt.name, host, got[host], n, got)
+			}
+		}
+	}
+}
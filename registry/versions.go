@@ -0,0 +1,103 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// Versioned endpoint sets (microservices.<module>.versions.<version>)
+
+package registry
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	viper "github.com/spf13/viper"
+)
+
+// versionKey is the cache key of a versioned endpoint.
+func versionKey(module, version string) string {
+	return module + "@" + version
+}
+
+// Versions returns the version names configured or announced for module, sorted.
+func Versions(module string) []string {
+	raw := viper.GetStringMap(fmt.Sprintf("microservices.%s.versions", module))
+	out := make([]string, 0, len(raw))
+	for v := range raw {
+		out = append(out, v)
+	}
+	for _, v := range announcedVersions(module) {
+		if !slices.Contains(out, v) {
+			out = append(out, v)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// HasVersion reports whether module has an endpoint set for version.
+func HasVersion(module, version string) bool {
+	version = strings.ToLower(version)
+	if slices.Contains(announcedVersions(module), version) {
+		return true
+	}
+	return viper.IsSet(fmt.Sprintf("microservices.%s.versions.%s.host", module, version))
+}
+
+// GetServiceVersion resolves the endpoint of a specific module version.
+// Versions always come from config/env: masterservice knows one address per module.
+func GetServiceVersion(module, version string) (ServiceInfo, error) {
+	version = strings.ToLower(version)
+	key := versionKey(module, version)
+
+	if info, ok := registered(module, version); ok {
+		return info, nil
+	}
+
+	if v, ok := cache.Load(key); ok {
+		info := v.(ServiceInfo)
+		if time.Since(info.LastUpdate) < ttl {
+			return info, nil
+		}
+	}
+
+	info, err := getVersionFromConfig(module, version)
+	if err != nil {
+		return ServiceInfo{}, err
+	}
+
+	cache.Store(key, info)
+	return info, nil
+}
+
+func getVersionFromConfig(module, version string) (ServiceInfo, error) {
+	keyPrefix := fmt.Sprintf("microservices.%s.versions.%s.", module, version)
+
+	host := viper.GetString(keyPrefix + "host")
+	port := viper.GetString(keyPrefix + "port")
+
+	if host == "" || port == "" {
+		return ServiceInfo{}, fmt.Errorf("registry: version %q of %q not found in config", version, module)
+	}
+
+	return ServiceInfo{
+		Host:       host,
+		Port:       port,
+		Version:    version,
+		LastUpdate: time.Now(),
+	}, nil
+}
@@ -0,0 +1,69 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+
+package registry
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	viper "github.com/spf13/viper"
+)
+
+func TestVersions(t *testing.T) {
+	viper.Set("microservices.vsvc.versions.v2.host", "10.0.0.2")
+	viper.Set("microservices.vsvc.versions.v2.port", "5300")
+	viper.Set("microservices.vsvc.versions.v1.host", "10.0.0.1")
+	viper.Set("microservices.vsvc.versions.v1.port", "5300")
+	announcedMu.Lock()
+	announced["vsvc"] = map[string]Instance{
+		"10.0.0.3:5300": {Name: "vsvc", Host: "10.0.0.3", Port: "5300", Version: "v3", Expires: time.Now().Add(time.Minute)},
+		"10.0.0.4:5300": {Name: "vsvc", Host: "10.0.0.4", Port: "5300", Version: "v4", Expires: time.Now().Add(-time.Second)},
+	}
+	announcedMu.Unlock()
+	t.Cleanup(func() {
+		viper.Set("microservices.vsvc", nil)
+		announcedMu.Lock()
+		delete(announced, "vsvc")
+		announcedMu.Unlock()
+	})
+
+	if got, want := Versions("vsvc"), []string{"v1", "v2", "v3"}; !slices.Equal(got, want) {
+		t.Fatalf("Versions = %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		version string
+		want    bool
+		host    string
+	}{
+		{"v1", true, "10.0.0.1"},
+		{"V2", true, "10.0.0.2"},
+		{"v3", true, "10.0.0.3"}, // announced
+		{"v4", false, ""},        // announcement expired
+		{"v9", false, ""},
+	}
+	for _, tt := range tests {
+		if got := HasVersion("vsvc", tt.version); got != tt.want {
+			t.Errorf("HasVersion(%s) = %v, want %v", tt.version, got, tt.want)
+		}
+		info, err := GetServiceVersion("vsvc", tt.version)
+		if (err == nil) != tt.want || info.Host != tt.host {
+			t.Errorf("GetServiceVersion(%s) = %+v, %v", tt.version, info, err)
+		}
+	}
+}
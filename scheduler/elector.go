@@ -0,0 +1,271 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// Leader election among gateway replicas (cron.lock = memory | redis | file)
+// and run history (cron.store = memory | redis).
+
+package scheduler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	sf "github.com/gogufo/gufo-api-gateway/gufodao"
+	"github.com/gomodule/redigo/redis"
+	"github.com/spf13/viper"
+)
+
+// Elector decides which gateway runs scheduled jobs.
+type Elector interface {
+	// Campaign acquires or renews leadership for ttl and reports whether
+	// this gateway leads.
+	Campaign(ttl time.Duration) (bool, error)
+	// Resign gives leadership up.
+	Resign() error
+}
+
+// newElector picks cron.lock. Unset means redis: with the memory elector
+// every replica leads and fires every job, so it must be asked for by name.
+func newElector() (Elector, error) {
+	switch lock := strings.ToLower(viper.GetString("cron.lock")); lock {
+	case "", "redis":
+		sf.EnsureCache()
+		return redisElector{}, nil
+	case "file":
+		path := viper.GetString("cron.lock_file")
+		if path == "" {
+			path = filepath.Join(viper.GetString("server.sysdir"), "cron.lock")
+		}
+		return fileElector{path: path}, nil
+	case "memory":
+		return memoryElector{}, nil
+	default:
+		return nil, fmt.Errorf("cron: unknown cron.lock %q (redis | file | memory)", lock)
+	}
+}
+
+// memoryElector: a single gateway always leads. Only for one gateway
+// (cron.lock = "memory").
+type memoryElector struct{}
+
+func (memoryElector) Campaign(time.Duration) (bool, error) { return true, nil }
+func (memoryElector) Resign() error                        { return nil }
+
+// redisElector holds gufo:cron:leader = <node> with a TTL.
+const redisLeaderKey = "gufo:cron:leader"
+
+var (
+	campaignScript = redis.NewScript(1, `
+local v = redis.call('GET', KEYS[1])
+if v == false then
+  redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+  return 1
+end
+if v == ARGV[1] then
+  redis.call('PEXPIRE', KEYS[1], ARGV[2])
+  return 1
+end
+return 0`)
+	resignScript = redis.NewScript(1, `
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+  return redis.call('DEL', KEYS[1])
+end
+return 0`)
+)
+
+type redisElector struct{}
+
+func (redisElector) Campaign(ttl time.Duration) (bool, error) {
+	conn := sf.CachePool.Get()
+	defer conn.Close()
+
+	ok, err := redis.Int(campaignScript.Do(conn, redisLeaderKey, node, ttl.Milliseconds()))
+	return ok == 1, err
+}
+
+func (redisElector) Resign() error {
+	conn := sf.CachePool.Get()
+	defer conn.Close()
+
+	_, err := resignScript.Do(conn, redisLeaderKey, node)
+	return err
+}
+
+// fileElector is for replicas sharing one host: the lock file holds the
+// leader's node ID and is considered stale when not touched within the TTL.
+// Every check and change happens under an exclusive flock on <path>.guard,
+// so two replicas cannot both take over a stale lock. The kernel drops the
+// flock when a replica dies, so the guard itself never goes stale.
+type fileElector struct {
+	path string
+}
+
+func (e fileElector) Campaign(ttl time.Duration) (bool, error) {
+	unlock, err := lockFile(e.path + ".guard")
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	b, err := os.ReadFile(e.path)
+	switch {
+	case err == nil && string(bytes.TrimSpace(b)) == node:
+		now := time.Now()
+		return true, os.Chtimes(e.path, now, now)
+	case err == nil:
+		fi, err := os.Stat(e.path)
+		if err != nil || time.Since(fi.ModTime()) < ttl {
+			return false, nil
+		}
+		// stale leader: replaced below
+	case !errors.Is(err, os.ErrNotExist):
+		return false, err
+	}
+
+	// write a temp file and rename it over the lock, so the lock file is
+	// never missing or half written
+	tmp, err := os.CreateTemp(filepath.Dir(e.path), filepath.Base(e.path)+".*")
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(node); err != nil {
+		tmp.Close()
+		return false, err
+	}
+	if err := tmp.Close(); err != nil {
+		return false, err
+	}
+	if err := os.Rename(tmp.Name(), e.path); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (e fileElector) Resign() error {
+	unlock, err := lockFile(e.path + ".guard")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	b, err := os.ReadFile(e.path)
+	if err == nil && string(bytes.TrimSpace(b)) == node {
+		return os.Remove(e.path)
+	}
+	return nil
+}
+
+// -------------------------------------------------------------------
+// Run history
+// -------------------------------------------------------------------
+
+// History keeps the newest runs per job (cron.history, default 50).
+type History interface {
+	Add(job string, run Run) error
+	List(job string, limit int) ([]Run, error)
+}
+
+func newHistory() History {
+	if strings.ToLower(viper.GetString("cron.store")) == "redis" {
+		sf.EnsureCache()
+		return redisHistory{}
+	}
+	return &memoryHistory{runs: map[string][]Run{}}
+}
+
+func historySize() int {
+	if n := viper.GetInt("cron.history"); n > 0 {
+		return n
+	}
+	return 50
+}
+
+type memoryHistory struct {
+	mu   sync.Mutex
+	runs map[string][]Run // newest first
+}
+
+func (h *memoryHistory) Add(job string, run Run) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	runs := append([]Run{run}, h.runs[job]...)
+	if len(runs) > historySize() {
+		runs = runs[:historySize()]
+	}
+	h.runs[job] = runs
+	return nil
+}
+
+func (h *memoryHistory) List(job string, limit int) ([]Run, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	runs := h.runs[job]
+	if limit > 0 && len(runs) > limit {
+		runs = runs[:limit]
+	}
+	return append([]Run{}, runs...), nil
+}
+
+// redisHistory: gufo:cron:runs:<module>/<job> LIST of JSON runs, newest first.
+const redisRunsPrefix = "gufo:cron:runs:"
+
+type redisHistory struct{}
+
+func (redisHistory) Add(job string, run Run) error {
+	conn := sf.CachePool.Get()
+	defer conn.Close()
+
+	b, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	conn.Send("MULTI")
+	conn.Send("LPUSH", redisRunsPrefix+job, b)
+	conn.Send("LTRIM", redisRunsPrefix+job, 0, historySize()-1)
+	_, err = conn.Do("EXEC")
+	return err
+}
+
+func (redisHistory) List(job string, limit int) ([]Run, error) {
+	conn := sf.CachePool.Get()
+	defer conn.Close()
+
+	if limit <= 0 {
+		limit = historySize()
+	}
+	vals, err := redis.ByteSlices(conn.Do("LRANGE", redisRunsPrefix+job, 0, limit-1))
+	if err != nil {
+		return nil, err
+	}
+	runs := make([]Run, 0, len(vals))
+	for _, raw := range vals {
+		var run Run
+		if json.Unmarshal(raw, &run) == nil {
+			runs = append(runs, run)
+		}
+	}
+	return runs, nil
+}
@@ -0,0 +1,170 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+
+package scheduler
+
+import (
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	sf "github.com/gogufo/gufo-api-gateway/gufodao"
+	"github.com/gogufo/gufo-api-gateway/internal/redistest"
+	"github.com/spf13/viper"
+)
+
+// setConfig sets config keys for the test only.
+func setConfig(t *testing.T, values map[string]interface{}) {
+	t.Helper()
+	for k, v := range values {
+		viper.Set(k, v)
+		t.Cleanup(func() { viper.Set(k, nil) })
+	}
+}
+
+// asNode makes this gateway's node ID id for the test.
+func asNode(t *testing.T, id string) {
+	t.Helper()
+	prev := node
+	node = id
+	t.Cleanup(func() { node = prev })
+}
+
+func TestNewElector(t *testing.T) {
+	prev := sf.CachePool
+	sf.CachePool, _ = redistest.NewPool()
+	t.Cleanup(func() { sf.CachePool = prev })
+
+	dir := t.TempDir()
+	tests := []struct {
+		lock, lockFile string
+		want           Elector
+		wantErr        bool
+	}{
+		{"", "", redisElector{}, false}, // unset: replicas must not all lead
+		{"redis", "", redisElector{}, false},
+		{"Redis", "", redisElector{}, false},
+		{"memory", "", memoryElector{}, false},
+		{"file", "/run/cron.lock", fileElector{path: "/run/cron.lock"}, false},
+		{"file", "", fileElector{path: filepath.Join(dir, "cron.lock")}, false},
+		{"etcd", "", nil, true},
+	}
+	for _, tt := range tests {
+		setConfig(t, map[string]interface{}{"cron.lock": tt.lock, "cron.lock_file": tt.lockFile, "server.sysdir": dir})
+		got, err := newElector()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("cron.lock=%q: err = %v, want error %v", tt.lock, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("cron.lock=%q: elector = %#v, want %#v", tt.lock, got, tt.want)
+		}
+	}
+}
+
+func TestStartUnknownLock(t *testing.T) {
+	t.Cleanup(func() { startOnce = sync.Once{} })
+	setConfig(t, map[string]interface{}{"cron.enabled": true, "cron.lock": "zookeeper"})
+
+	if err := Start(); err == nil {
+		t.Fatal("Start accepted an unknown cron.lock")
+	}
+	if current != nil {
+		t.Error("scheduler started without an elector")
+	}
+}
+
+func TestMemoryElector(t *testing.T) {
+	// one gateway: it always leads, whoever asks
+	for _, id := range []string{"a", "b"} {
+		asNode(t, id)
+		if ok, err := (memoryElector{}).Campaign(time.Second); !ok || err != nil {
+			t.Errorf("%s: Campaign = %v, %v", id, ok, err)
+		}
+	}
+}
+
+func TestFileElector(t *testing.T) {
+	e := fileElector{path: filepath.Join(t.TempDir(), "cron.lock")}
+	unlock, err := lockFile(e.path + ".guard")
+	if err != nil {
+		t.Skip(err) // no flock on this system
+	}
+	unlock()
+	const ttl = 100 * time.Millisecond
+
+	steps := []struct {
+		name   string
+		node   string
+		resign bool
+		wait   time.Duration
+		want   bool
+	}{
+		{"first campaign wins", "a", false, 0, true},
+		{"held by the leader", "b", false, 0, false},
+		{"leader renews", "a", false, 0, true},
+		{"follower resigns: no effect", "b", true, 0, false},
+		{"stale lock taken over", "b", false, 2 * ttl, true},
+		{"old leader is follower", "a", false, 0, false},
+		{"leader resigns", "b", true, 0, false},
+		{"free lock won", "a", false, 0, true},
+	}
+	for _, st := range steps {
+		time.Sleep(st.wait)
+		asNode(t, st.node)
+		if st.resign {
+			if err := e.Resign(); err != nil {
+				t.Fatalf("%s: Resign: %v", st.name, err)
+			}
+			continue
+		}
+		got, err := e.Campaign(ttl)
+		if err != nil {
+			t.Fatalf("%s: %v", st.name, err)
+		}
+		if got != st.want {
+			t.Errorf("%s: Campaign(%s) = %v, want %v", st.name, st.node, got, st.want)
+		}
+	}
+}
+
+func TestLoadJobs(t *testing.T) {
+	setConfig(t, map[string]interface{}{"microservices": map[string]interface{}{
+		"users": map[string]interface{}{"cron": []map[string]interface{}{
+			{"name": "cleanup", "schedule": "*/15 * * * *"},
+			{"name": "", "schedule": "@hourly"},
+		}},
+		"orders": map[string]interface{}{"cron": []map[string]interface{}{
+			{"name": "expire", "schedule": "@every 30s", "timeout": "2m"},
+			{"name": "broken", "schedule": "not a schedule"},
+		}},
+		"legacy": map[string]interface{}{"cron": true},
+	}})
+
+	jobs, err := LoadJobs()
+	if err == nil {
+		t.Error("invalid entries were not reported")
+	}
+	var keys []string
+	for _, j := range jobs {
+		keys = append(keys, j.Key())
+	}
+	if want := []string{"orders/expire", "users/cleanup"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("jobs = %v, want %v", keys, want)
+	}
+}
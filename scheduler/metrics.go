@@ -0,0 +1,75 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// Prometheus metrics of the cron scheduler.
+
+package scheduler
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// Job runs by result (ok, failed, skipped)
+	runsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gufo_cron_runs_total",
+			Help: "Scheduled job runs, labeled by module, job and result (ok, failed, skipped).",
+		},
+		[]string{"module", "job", "result"},
+	)
+	runDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "gufo_cron_run_duration_seconds",
+			Help:    "Histogram of scheduled job durations (seconds), labeled by module and job.",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"module", "job"},
+	)
+	lastSuccess = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "gufo_cron_last_success_timestamp_seconds",
+			Help: "Unix time of the last successful run, labeled by module and job.",
+		},
+		[]string{"module", "job"},
+	)
+	leaderGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "gufo_cron_leader",
+			Help: "1 when this gateway is the cron leader.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(runsTotal)
+	prometheus.MustRegister(runDuration)
+	prometheus.MustRegister(lastSuccess)
+	prometheus.MustRegister(leaderGauge)
+}
+
+func observeRun(j *Job, run Run) {
+	runsTotal.WithLabelValues(j.Module, j.Name, run.Status).Inc()
+	if run.Status == Skipped {
+		return
+	}
+	runDuration.WithLabelValues(j.Module, j.Name).Observe(float64(run.DurationMs) / 1000)
+	if run.Status == OK {
+		lastSuccess.WithLabelValues(j.Module, j.Name).Set(float64(time.Now().Unix()))
+	}
+}
@@ -0,0 +1,427 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// Cron scheduler: jobs declared per module ([[microservices.<m>.cron]])
+// are invoked on the leading gateway via Reverse.Do with IR.Param = "cron".
+
+package scheduler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	sf "github.com/gogufo/gufo-api-gateway/gufodao"
+	pb "github.com/gogufo/gufo-api-gateway/proto/go"
+	"github.com/gogufo/gufo-api-gateway/transport"
+	"github.com/robfig/cron/v3"
+	"github.com/spf13/viper"
+)
+
+// Run results.
+const (
+	OK      = "ok"
+	Failed  = "failed"
+	Skipped = "skipped" // previous run still in progress
+)
+
+var ErrUnknownJob = errors.New("scheduler: unknown job")
+
+// Job is one [[microservices.<module>.cron]] entry.
+type Job struct {
+	Module       string   `json:"module"`
+	Name         string   `mapstructure:"name" json:"name"`
+	Schedule     string   `mapstructure:"schedule" json:"schedule"` // "*/5 * * * *", "@hourly", "@every 30s"
+	Timeout      string   `mapstructure:"timeout" json:"timeout,omitempty"`
+	Args         []string `mapstructure:"args" json:"args,omitempty"` // "name=value", JSON-decoded when valid
+	AllowOverlap bool     `mapstructure:"allow_overlap" json:"allow_overlap,omitempty"`
+
+	sched   cron.Schedule
+	next    time.Time
+	running atomic.Int32
+}
+
+// Key identifies a job: "<module>/<name>".
+func (j *Job) Key() string {
+	return j.Module + "/" + j.Name
+}
+
+// Run is one invocation of a job.
+type Run struct {
+	ID          string    `json:"id"`
+	Job         string    `json:"job"`
+	Module      string    `json:"module"`
+	Node        string    `json:"node"`
+	Manual      bool      `json:"manual,omitempty"`
+	ScheduledAt time.Time `json:"scheduled_at"`
+	StartedAt   time.Time `json:"started_at"`
+	DurationMs  int64     `json:"duration_ms"`
+	Status      string    `json:"status"`
+	HTTPCode    int       `json:"httpcode,omitempty"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// JobState is a job as listed by the admin API.
+type JobState struct {
+	*Job
+	NextRun time.Time `json:"next_run"`
+	Running bool      `json:"running"`
+	LastRun *Run      `json:"last_run,omitempty"`
+}
+
+type scheduler struct {
+	mu      sync.Mutex
+	jobs    []*Job
+	elector Elector
+	history History
+	leader  atomic.Bool
+}
+
+var (
+	current   *scheduler
+	startOnce sync.Once
+	node      = nodeID()
+)
+
+// Enabled reports whether the gateway schedules module jobs (cron.enabled).
+func Enabled() bool {
+	return viper.GetBool("cron.enabled")
+}
+
+// Node is this gateway's ID in leader election and run history.
+func Node() string {
+	return node
+}
+
+// Leader reports whether this gateway currently runs scheduled jobs.
+func Leader() bool {
+	return current != nil && current.leader.Load()
+}
+
+// Start loads the jobs, joins leader election and starts the clock. An
+// unusable cron.lock is an error: the gateway must not fire jobs unelected.
+func Start() (err error) {
+	if !Enabled() {
+		return nil
+	}
+	startOnce.Do(func() {
+		elector, eerr := newElector()
+		if eerr != nil {
+			err = eerr
+			return
+		}
+		jobs, lerr := LoadJobs()
+		if lerr != nil {
+			sf.SetErrorLog("cron: " + lerr.Error())
+		}
+		s := &scheduler{jobs: jobs, elector: elector, history: newHistory()}
+		now := time.Now()
+		for _, j := range jobs {
+			j.next = j.sched.Next(now)
+		}
+		current = s
+
+		go s.campaign()
+		go s.clock()
+		sf.SetLog(fmt.Sprintf("⏰ Cron scheduler started: %d jobs, node %s", len(jobs), node))
+	})
+	return err
+}
+
+// Stop resigns leadership so another replica takes over without waiting for the TTL.
+func Stop() {
+	if current == nil {
+		return
+	}
+	current.leader.Store(false)
+	leaderGauge.Set(0)
+	if err := current.elector.Resign(); err != nil {
+		sf.SetErrorLog("cron: resign: " + err.Error())
+	}
+}
+
+// LoadJobs reads [[microservices.<module>.cron]] from config.
+func LoadJobs() ([]*Job, error) {
+	var jobs []*Job
+	var errs []error
+
+	modules := viper.GetStringMap("microservices")
+	names := make([]string, 0, len(modules))
+	for m := range modules {
+		names = append(names, m)
+	}
+	sort.Strings(names)
+
+	for _, m := range names {
+		key := "microservices." + m + ".cron"
+		switch viper.Get(key).(type) {
+		case []interface{}, []map[string]interface{}:
+		default:
+			continue // unset, or the module's legacy "cron = true" flag
+		}
+		var entries []*Job
+		if err := viper.UnmarshalKey(key, &entries); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			continue
+		}
+		for _, j := range entries {
+			j.Module = m
+			sched, err := cron.ParseStandard(j.Schedule)
+			if err != nil || j.Name == "" {
+				errs = append(errs, fmt.Errorf("%s %q: invalid name or schedule %q", key, j.Name, j.Schedule))
+				continue
+			}
+			j.sched = sched
+			jobs = append(jobs, j)
+		}
+	}
+	return jobs, errors.Join(errs...)
+}
+
+// Jobs lists the scheduled jobs with their state.
+func Jobs() []JobState {
+	if current == nil {
+		return []JobState{}
+	}
+	current.mu.Lock()
+	defer current.mu.Unlock()
+
+	out := make([]JobState, 0, len(current.jobs))
+	for _, j := range current.jobs {
+		st := JobState{Job: j, NextRun: j.next, Running: j.running.Load() > 0}
+		if runs, err := current.history.List(j.Key(), 1); err == nil && len(runs) > 0 {
+			st.LastRun = &runs[0]
+		}
+		out = append(out, st)
+	}
+	return out
+}
+
+// Runs returns the newest runs of job "<module>/<name>".
+func Runs(key string, limit int) ([]Run, error) {
+	if current == nil || current.find(key) == nil {
+		return nil, ErrUnknownJob
+	}
+	return current.history.List(key, limit)
+}
+
+// Trigger runs a job now on this gateway, whether it leads or not.
+func Trigger(key string) (Run, error) {
+	if current == nil {
+		return Run{}, ErrUnknownJob
+	}
+	j := current.find(key)
+	if j == nil {
+		return Run{}, ErrUnknownJob
+	}
+	return current.run(j, time.Now(), true), nil
+}
+
+func (s *scheduler) find(key string) *Job {
+	for _, j := range s.jobs {
+		if j.Key() == key {
+			return j
+		}
+	}
+	return nil
+}
+
+// campaign keeps (or tries to win) leadership, renewing at a third of the TTL.
+func (s *scheduler) campaign() {
+	ttl := leaderTTL()
+	ticker := time.NewTicker(ttl / 3)
+	defer ticker.Stop()
+
+	for ; ; <-ticker.C {
+		ok, err := s.elector.Campaign(ttl)
+		if err != nil {
+			sf.SetErrorLog("cron: leader election: " + err.Error())
+			ok = false
+		}
+		if was := s.leader.Swap(ok); was != ok {
+			if ok {
+				sf.SetLog("⏰ Cron: " + node + " is now the leader")
+			} else {
+				sf.SetLog("⏰ Cron: " + node + " lost leadership")
+			}
+		}
+		if ok {
+			leaderGauge.Set(1)
+		} else {
+			leaderGauge.Set(0)
+		}
+	}
+}
+
+// clock fires due jobs once per second. Followers advance their schedule
+// too, so a new leader does not replay missed runs.
+func (s *scheduler) clock() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		s.mu.Lock()
+		var due []*Job
+		var at []time.Time
+		for _, j := range s.jobs {
+			if now.Before(j.next) {
+				continue
+			}
+			due, at = append(due, j), append(at, j.next)
+			j.next = j.sched.Next(now)
+		}
+		s.mu.Unlock()
+
+		if !s.leader.Load() {
+			continue
+		}
+		for i, j := range due {
+			go s.run(j, at[i], false)
+		}
+	}
+}
+
+// run invokes the job unless a previous run is still in progress.
+func (s *scheduler) run(j *Job, scheduled time.Time, manual bool) Run {
+	run := Run{
+		ID:          newRunID(),
+		Job:         j.Name,
+		Module:      j.Module,
+		Node:        node,
+		Manual:      manual,
+		ScheduledAt: scheduled.UTC(),
+		StartedAt:   time.Now().UTC(),
+	}
+
+	if j.running.Add(1) > 1 && !j.AllowOverlap {
+		j.running.Add(-1)
+		run.Status = Skipped
+		run.Error = "previous run still in progress"
+		s.record(j, run)
+		return run
+	}
+	defer j.running.Add(-1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), j.timeout())
+	defer cancel()
+
+	resp, err := transport.Get().Call(ctx, j.Module, "POST", j.request(run))
+	run.DurationMs = time.Since(run.StartedAt).Milliseconds()
+
+	switch {
+	case err != nil:
+		run.Status, run.Error = Failed, err.Error()
+	default:
+		data := sf.ToMapStringInterface(resp.Data)
+		run.HTTPCode = 200
+		if v, ok := data["httpcode"]; ok {
+			run.HTTPCode, _ = strconv.Atoi(fmt.Sprint(v))
+		}
+		run.Status = OK
+		if run.HTTPCode >= 400 {
+			run.Status = Failed
+			run.Error, _ = data["message"].(string)
+		}
+	}
+
+	s.record(j, run)
+	if run.Status == Failed {
+		sf.SetErrorLog(fmt.Sprintf("cron: %s failed: %s", j.Key(), run.Error))
+	}
+	return run
+}
+
+// request builds the module call: IR.Param "cron", IR.ParamID the job name,
+// IR args job, run_id, scheduled_at plus the configured args.
+func (j *Job) request(run Run) *pb.Request {
+	args := map[string]interface{}{
+		"job":          j.Name,
+		"run_id":       run.ID,
+		"scheduled_at": run.ScheduledAt.Format(time.RFC3339),
+	}
+	for _, kv := range j.Args {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			continue
+		}
+		var val interface{}
+		if json.Unmarshal([]byte(v), &val) != nil {
+			val = v
+		}
+		args[k] = val
+	}
+
+	module, method := j.Module, "POST"
+	return &pb.Request{
+		Module: &module,
+		Method: &method,
+		IR: &pb.InternalRequest{
+			Param:   sf.StringPtr("cron"),
+			ParamID: sf.StringPtr(j.Name),
+			Method:  sf.StringPtr(method),
+			Args:    sf.ToMapStringAny(args),
+		},
+	}
+}
+
+func (j *Job) timeout() time.Duration {
+	if d, err := time.ParseDuration(j.Timeout); err == nil && d > 0 {
+		return d
+	}
+	if d := viper.GetDuration("cron.timeout"); d > 0 {
+		return d
+	}
+	return 5 * time.Minute
+}
+
+func (s *scheduler) record(j *Job, run Run) {
+	observeRun(j, run)
+	if err := s.history.Add(j.Key(), run); err != nil {
+		sf.SetErrorLog("cron: history: " + err.Error())
+	}
+}
+
+// leaderTTL is how long leadership survives without renewal (cron.leader_ttl, default 15s).
+func leaderTTL() time.Duration {
+	if d := viper.GetDuration("cron.leader_ttl"); d >= 3*time.Second {
+		return d
+	}
+	return 15 * time.Second
+}
+
+func nodeID() string {
+	host, _ := os.Hostname()
+	if host == "" {
+		host = "gufo"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+func newRunID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
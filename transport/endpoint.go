@@ -0,0 +1,42 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// Explicit upstream endpoint chosen by the gateway (version routing)
+
+package transport
+
+import "context"
+
+// Endpoint is an upstream address selected before the call,
+// e.g. a canary version of the module.
+type Endpoint struct {
+	Host    string
+	Port    string
+	Version string
+}
+
+type endpointKey struct{}
+
+// WithEndpoint pins the upstream endpoint for calls made with ctx.
+func WithEndpoint(ctx context.Context, ep Endpoint) context.Context {
+	return context.WithValue(ctx, endpointKey{}, ep)
+}
+
+// EndpointFromContext returns the pinned endpoint, if any.
+func EndpointFromContext(ctx context.Context) (Endpoint, bool) {
+	ep, ok := ctx.Value(endpointKey{}).(Endpoint)
+	return ep, ok && ep.Host != "" && ep.Port != ""
+}
@@ -24,7 +24,9 @@ import (
 
 	sf "github.com/gogufo/gufo-api-gateway/gufodao"
 	pb "github.com/gogufo/gufo-api-gateway/proto/go"
+	"github.com/gogufo/gufo-api-gateway/registry"
 	"github.com/spf13/viper"
+	"google.golang.org/grpc/metadata"
 )
 
 // GRPCTransport implements the Transport interface via gRPC calls.
@@ -36,12 +38,21 @@ var (
 )
 
 // Call executes a gRPC call to a remote microservice.
-// Host/Port is resolved from cache or via masterservice discovery.
+// Host/Port comes from an endpoint pinned in ctx (see WithEndpoint),
+// otherwise it is resolved from cache or via masterservice discovery.
 func (t *GRPCTransport) Call(ctx context.Context, svc, method string, req *pb.Request) (*pb.Response, error) {
+	if ep, ok := EndpointFromContext(ctx); ok {
+		return CallAt(ctx, svc, ep.Host, ep.Port, req)
+	}
 	host, port := resolveService(svc, req)
+	return CallAt(ctx, svc, host, port, req)
+}
 
-	conn, err := sf.GetGRPCConn(
-		host, port,
+// CallAt executes a gRPC call to svc at an explicit host:port
+// (used for traffic mirroring).
+func CallAt(ctx context.Context, svc, host, port string, req *pb.Request) (*pb.Response, error) {
+	conn, err := sf.GetGRPCConnFor(
+		svc, host, port,
 		viper.GetString("security.ca_path"),
 		viper.GetString("security.cert_path"),
 		viper.GetString("security.key_path"),
@@ -60,6 +71,14 @@ func (t *GRPCTransport) Call(ctx context.Context, svc, method string, req *pb.Re
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
+	// fresh gateway → service credential for every upstream call
+	sf.Gufosign(req)
+
+	// forward the API consumer (if any) as gRPC metadata
+	if consumer := sf.ContextConsumer(ctx); consumer != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, sf.ConsumerMetadataKey, consumer)
+	}
+
 	resp, err := client.Do(ctx, req)
 	if err != nil {
 		return nil, fmt.Errorf("grpc call failed: %w", err)
@@ -70,6 +89,11 @@ func (t *GRPCTransport) Call(ctx context.Context, svc, method string, req *pb.Re
 
 // resolveService returns host and port from cache or asks masterservice.
 func resolveService(svc string, req *pb.Request) (string, string) {
+	// 0. Self-registered or discovered endpoints (not cached: they change)
+	if info, ok := registry.Discover(svc); ok {
+		return info.Host, info.Port
+	}
+
 	// 1. Check in-memory cache
 	if v, ok := svcCache.Load(svc); ok {
 		addr := v.(string)
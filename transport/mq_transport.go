@@ -0,0 +1,187 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// Asynchronous transport: requests are published to the message broker and
+// answered with 202 and a job ID; the module reports the result back
+// through the gateway gRPC port (module "jobs", param "report").
+
+package transport
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	sf "github.com/gogufo/gufo-api-gateway/gufodao"
+	"github.com/gogufo/gufo-api-gateway/jobs"
+	"github.com/gogufo/gufo-api-gateway/mq"
+	pb "github.com/gogufo/gufo-api-gateway/proto/go"
+	"github.com/spf13/viper"
+	"google.golang.org/protobuf/proto"
+)
+
+// Message headers set on queued requests. The endpoint headers are set
+// when version routing pinned an endpoint (see WithEndpoint).
+const (
+	JobIDHeader    = "Gufo-Job-Id"
+	JobTokenHeader = "Gufo-Job-Token"
+	MethodHeader   = "Gufo-Method"
+	VersionHeader  = "Gufo-Version"
+	HostHeader     = "Gufo-Endpoint-Host"
+	PortHeader     = "Gufo-Endpoint-Port"
+)
+
+// MQTransport implements Transport by queueing requests on mq.Get().
+type MQTransport struct{}
+
+var mqTransport = &MQTransport{}
+
+// For returns the transport for a call: MQTransport for async routes
+// (see Async), otherwise the registered transport.
+func For(module, param string) Transport {
+	if Async(module, param) {
+		return mqTransport
+	}
+	return Get()
+}
+
+// Async reports whether calls to module/param are queued:
+// microservices.<module>.transport = "mq", optionally limited to
+// microservices.<module>.async_params.
+func Async(module, param string) bool {
+	if viper.GetString(fmt.Sprintf("microservices.%s.transport", module)) != "mq" {
+		return false
+	}
+	params := viper.GetStringSlice(fmt.Sprintf("microservices.%s.async_params", module))
+	return len(params) == 0 || slices.Contains(params, param)
+}
+
+// Call creates a job, publishes req (signed like a gRPC call) and answers
+// 202 with the job ID and its status URL. An endpoint pinned in ctx (canary
+// routing) travels with the message, so the job runs on that version.
+func (m *MQTransport) Call(ctx context.Context, svc, method string, req *pb.Request) (*pb.Response, error) {
+	job, err := jobs.New(svc, req.GetParam(), req.GetUID())
+	if err != nil {
+		return nil, fmt.Errorf("job create failed: %w", err)
+	}
+
+	sf.Gufosign(req)
+	data, err := proto.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := mq.Message{
+		Subject: mq.Subject(svc),
+		Header:  map[string]string{JobIDHeader: job.ID, JobTokenHeader: job.Token, MethodHeader: method},
+		Data:    data,
+	}
+	if ep, ok := EndpointFromContext(ctx); ok {
+		msg.Header[VersionHeader] = ep.Version
+		msg.Header[HostHeader], msg.Header[PortHeader] = ep.Host, ep.Port
+	}
+	if err := mq.Get().Publish(ctx, msg); err != nil {
+		jobs.Report(job.ID, jobs.Failed, http.StatusBadGateway, nil, err.Error())
+		return nil, fmt.Errorf("mq publish failed: %w", err)
+	}
+
+	version := req.GetAPIVersion()
+	if version == "" {
+		version = "v1"
+	}
+	ans := map[string]interface{}{
+		"httpcode":   http.StatusAccepted,
+		"job_id":     job.ID,
+		"status":     job.Status,
+		"status_url": fmt.Sprintf("/api/%s/jobs/%s", version, job.ID),
+	}
+	return &pb.Response{Data: sf.ToMapStringAny(ans), RequestBack: req}, nil
+}
+
+// StartMQWorker consumes queued requests inside the gateway and runs them
+// over gRPC (jobs.local_worker; default on with the in-process broker), so
+// async routes work without an external consumer. Up to jobs.workers
+// (default 4) jobs run at a time.
+func StartMQWorker() {
+	enabled := mq.Name() == "memory"
+	if viper.IsSet("jobs.local_worker") {
+		enabled = viper.GetBool("jobs.local_worker")
+	}
+	if !enabled {
+		return
+	}
+
+	workers := viper.GetInt("jobs.workers")
+	if workers <= 0 {
+		workers = 4
+	}
+	if _, err := startWorkers(mq.Get(), workers, runJob); err != nil {
+		sf.SetErrorLog("mq worker: " + err.Error())
+		return
+	}
+	sf.SetLog(fmt.Sprintf("📬 Local job worker consuming %s (%d workers)", mq.Subject(">"), workers))
+}
+
+// startWorkers subscribes to all job subjects and runs h for up to n
+// messages at a time. Once n jobs run, the subscription waits for a free
+// worker, so a busy gateway takes no more messages off the broker.
+func startWorkers(b mq.Broker, n int, h mq.Handler) (mq.Subscription, error) {
+	slots := make(chan struct{}, n)
+	return b.QueueSubscribe(mq.Subject(">"), "gufo-gateway", func(ctx context.Context, msg mq.Message) {
+		slots <- struct{}{}
+		go func() {
+			defer func() { <-slots }()
+			h(ctx, msg)
+		}()
+	})
+}
+
+func runJob(ctx context.Context, msg mq.Message) {
+	id := msg.Header[JobIDHeader]
+	req := &pb.Request{}
+	if err := proto.Unmarshal(msg.Data, req); err != nil {
+		jobs.Report(id, jobs.Failed, http.StatusBadRequest, nil, err.Error())
+		return
+	}
+	jobs.Report(id, jobs.Running, 0, nil, "")
+
+	if host, port := msg.Header[HostHeader], msg.Header[PortHeader]; host != "" && port != "" {
+		ctx = WithEndpoint(ctx, Endpoint{Host: host, Port: port, Version: msg.Header[VersionHeader]})
+	}
+	resp, err := (&GRPCTransport{}).Call(ctx, req.GetModule(), msg.Header[MethodHeader], req)
+	if err != nil {
+		jobs.Report(id, jobs.Failed, http.StatusBadGateway, nil, err.Error())
+		return
+	}
+
+	result := sf.ToMapStringInterface(resp.Data)
+	code := http.StatusOK
+	if v, ok := result["httpcode"]; ok {
+		if n, err := strconv.Atoi(fmt.Sprint(v)); err == nil {
+			code = n
+		}
+		delete(result, "httpcode")
+	}
+
+	if code >= 400 {
+		msgText, _ := result["message"].(string)
+		jobs.Report(id, jobs.Failed, code, result, msgText)
+		return
+	}
+	jobs.Report(id, jobs.Done, code, result, "")
+}
@@ -0,0 +1,110 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+
+package transport
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gogufo/gufo-api-gateway/mq"
+)
+
+// TestStartWorkersParallel: two jobs that each wait for the other can only
+// finish when the pool runs them at the same time.
+func TestStartWorkersParallel(t *testing.T) {
+	b := mq.NewMemoryBroker()
+	defer b.Close()
+
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	var finished atomic.Int32
+
+	_, err := startWorkers(b, 2, func(ctx context.Context, msg mq.Message) {
+		started <- struct{}{}
+		<-release
+		finished.Add(1)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for range 2 {
+		if err := b.Publish(context.Background(), mq.Message{Subject: mq.Subject("orders")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := range 2 {
+		select {
+		case <-started:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("only %d of 2 jobs running at once", i)
+		}
+	}
+	close(release)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for finished.Load() != 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := finished.Load(); n != 2 {
+		t.Fatalf("finished = %d, want 2", n)
+	}
+}
+
+// TestStartWorkersLimit: no more than n jobs run at a time.
+func TestStartWorkersLimit(t *testing.T) {
+	b := mq.NewMemoryBroker()
+	defer b.Close()
+
+	const n, jobs = 3, 12
+	var running, peak, done atomic.Int32
+
+	_, err := startWorkers(b, n, func(ctx context.Context, msg mq.Message) {
+		cur := running.Add(1)
+		for {
+			p := peak.Load()
+			if cur <= p || peak.CompareAndSwap(p, cur) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		running.Add(-1)
+		done.Add(1)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for range jobs {
+		if err := b.Publish(context.Background(), mq.Message{Subject: mq.Subject("orders")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for done.Load() != jobs && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if d := done.Load(); d != jobs {
+		t.Fatalf("done = %d, want %d", d, jobs)
+	}
+	if p := peak.Load(); p > n {
+		t.Errorf("peak concurrency = %d, want at most %d", p, n)
+	}
+}
@@ -0,0 +1,236 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// Delivery workers: signed POSTs with exponential-backoff retries; after
+// webhooks.max_attempts a delivery becomes dead (dead-letter store).
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	sf "github.com/gogufo/gufo-api-gateway/gufodao"
+	"github.com/spf13/viper"
+)
+
+var (
+	queue     chan string
+	startOnce sync.Once
+	client    = &http.Client{Timeout: 10 * time.Second}
+
+	// owner names this gateway in delivery leases (see Store.ClaimDelivery)
+	owner = newID(8)
+
+	inflightMu sync.Mutex
+	inflight   = map[string]bool{} // deliveries being sent by this gateway
+)
+
+// Start launches the delivery workers (webhooks.workers, default 4) and
+// requeues deliveries left pending by a previous run. Every replica
+// requeues them; the delivery lease lets only one of them send each.
+func Start() {
+	startOnce.Do(func() {
+		workers := viper.GetInt("webhooks.workers")
+		if workers <= 0 {
+			workers = 4
+		}
+		client = &http.Client{Timeout: sendTimeout()}
+		queue = make(chan string, 1024)
+		for i := 0; i < workers; i++ {
+			go worker()
+		}
+
+		pending, err := GetStore().ListDeliveries(Pending, "", 0)
+		if err != nil {
+			sf.SetErrorLog("webhooks: cannot load pending deliveries: " + err.Error())
+			return
+		}
+		for _, d := range pending {
+			enqueue(d.ID, time.Until(d.NextAttempt))
+		}
+		sf.SetLog(fmt.Sprintf("🪝 Webhook delivery started (%d workers, %d pending)", workers, len(pending)))
+	})
+}
+
+// schedule queues delivery id after delay, starting the workers if needed.
+func schedule(id string, delay time.Duration) {
+	Start()
+	enqueue(id, delay)
+}
+
+func enqueue(id string, delay time.Duration) {
+	if delay <= 0 {
+		select {
+		case queue <- id:
+		default:
+			go func() { queue <- id }()
+		}
+		return
+	}
+	time.AfterFunc(delay, func() { queue <- id })
+}
+
+func worker() {
+	for id := range queue {
+		deliver(id)
+	}
+}
+
+// deliver makes one attempt and reschedules or retires the delivery.
+// Stale queue entries (not due yet or already in flight) are dropped. The
+// attempt runs under a store lease, so a delivery queued on several
+// replicas is sent by one; the lease outlives the attempt's timeout.
+func deliver(id string) {
+	inflightMu.Lock()
+	if inflight[id] {
+		inflightMu.Unlock()
+		return
+	}
+	inflight[id] = true
+	inflightMu.Unlock()
+	defer func() {
+		inflightMu.Lock()
+		delete(inflight, id)
+		inflightMu.Unlock()
+	}()
+
+	st := GetStore()
+	ok, err := st.ClaimDelivery(id, owner, sendTimeout()+30*time.Second)
+	if err != nil {
+		sf.SetErrorLog("webhooks: cannot claim delivery " + id + ": " + err.Error())
+		return
+	}
+	if !ok {
+		return // another gateway is sending it
+	}
+	defer func() {
+		if err := st.ReleaseDelivery(id, owner); err != nil {
+			sf.SetErrorLog("webhooks: cannot release delivery " + id + ": " + err.Error())
+		}
+	}()
+
+	// read after the claim: a previous holder may have finished it
+	d, err := st.GetDelivery(id)
+	if err != nil || d.Status != Pending || time.Until(d.NextAttempt) > time.Second {
+		return
+	}
+
+	sub, err := st.GetSubscription(d.SubscriptionID)
+	if err != nil || !sub.Active {
+		d.Attempts = append(d.Attempts, Attempt{At: time.Now().UTC(), Error: "subscription removed or inactive"})
+		d.Status = Dead
+		saveDelivery(d)
+		return
+	}
+
+	attempt := send(sub, d)
+	d.Attempts = append(d.Attempts, attempt)
+
+	switch {
+	case attempt.Error == "" && attempt.StatusCode >= 200 && attempt.StatusCode < 300:
+		d.Status = Delivered
+	case len(d.Attempts) >= maxAttempts():
+		d.Status = Dead
+		sf.SetErrorLog(fmt.Sprintf("webhooks: delivery %s to %s is dead after %d attempts", d.ID, sub.URL, len(d.Attempts)))
+	default:
+		delay := backoff(len(d.Attempts))
+		d.NextAttempt = time.Now().UTC().Add(delay)
+		schedule(d.ID, delay)
+	}
+	saveDelivery(d)
+}
+
+func saveDelivery(d *Delivery) {
+	if err := GetStore().SaveDelivery(d); err != nil {
+		sf.SetErrorLog("webhooks: " + err.Error())
+	}
+}
+
+func send(sub *Subscription, d *Delivery) Attempt {
+	start := time.Now()
+	a := Attempt{At: start.UTC()}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(d.Body))
+	if err != nil {
+		a.Error = err.Error()
+		return a
+	}
+	ts := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Gufo-Webhooks")
+	req.Header.Set(EventHeader, d.Event)
+	req.Header.Set(DeliveryHeader, d.ID)
+	req.Header.Set("X-Gufo-Timestamp", strconv.FormatInt(ts, 10))
+	req.Header.Set(SignatureHeader, Sign(sub.Secret, ts, d.Body))
+
+	resp, err := client.Do(req)
+	a.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		a.Error = err.Error()
+		return a
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	a.StatusCode = resp.StatusCode
+	return a
+}
+
+// sendTimeout bounds one attempt (webhooks.timeout, default 10s).
+func sendTimeout() time.Duration {
+	if d := viper.GetDuration("webhooks.timeout"); d > 0 {
+		return d
+	}
+	return 10 * time.Second
+}
+
+func maxAttempts() int {
+	if n := viper.GetInt("webhooks.max_attempts"); n > 0 {
+		return n
+	}
+	return 8
+}
+
+// backoff is base * 2^(n-1) with ±20% jitter, capped at webhooks.max_backoff
+// (base: webhooks.backoff, default 10s; cap default 1h).
+func backoff(n int) time.Duration {
+	base := viper.GetDuration("webhooks.backoff")
+	if base <= 0 {
+		base = 10 * time.Second
+	}
+	limit := viper.GetDuration("webhooks.max_backoff")
+	if limit <= 0 {
+		limit = time.Hour
+	}
+
+	d := base
+	for i := 1; i < n && d < limit; i++ {
+		d *= 2
+	}
+	d = min(d, limit)
+	jitter := time.Duration(rand.Int63n(int64(d)/5+1)) - d/10
+	return d + jitter
+}
@@ -0,0 +1,148 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+
+package webhook
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	sf "github.com/gogufo/gufo-api-gateway/gufodao"
+	"github.com/gogufo/gufo-api-gateway/internal/redistest"
+	"github.com/spf13/viper"
+)
+
+// useStore makes s the store GetStore returns.
+func useStore(t *testing.T, s Store) {
+	t.Helper()
+	storeOnce.Do(func() {})
+	prev := store
+	store = s
+	t.Cleanup(func() { store = prev })
+}
+
+func TestClaimDelivery(t *testing.T) {
+	stores := map[string]func(t *testing.T) Store{
+		"memory": func(t *testing.T) Store { return newMemoryStore() },
+		"redis": func(t *testing.T) Store {
+			prev := sf.CachePool
+			sf.CachePool, _ = redistest.NewPool()
+			t.Cleanup(func() { sf.CachePool = prev })
+			return &redisStore{}
+		},
+	}
+	steps := []struct {
+		name  string
+		owner string
+		ttl   time.Duration
+		wait  time.Duration
+		want  bool
+	}{
+		{"first claim", "a", 50 * time.Millisecond, 0, true},
+		{"held by another owner", "b", time.Minute, 0, false},
+		{"held, even for the holder", "a", time.Minute, 0, false},
+		{"taken over after expiry", "b", time.Minute, 80 * time.Millisecond, true},
+		{"old holder locked out", "a", time.Minute, 0, false},
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			s := newStore(t)
+			for _, st := range steps {
+				time.Sleep(st.wait)
+				got, err := s.ClaimDelivery("d1", st.owner, st.ttl)
+				if err != nil {
+					t.Fatalf("%s: %v", st.name, err)
+				}
+				if got != st.want {
+					t.Errorf("%s: ClaimDelivery(%s) = %v, want %v", st.name, st.owner, got, st.want)
+				}
+			}
+			if ok, _ := s.ClaimDelivery("d2", "a", time.Minute); !ok {
+				t.Error("claims are not per delivery")
+			}
+		})
+	}
+}
+
+func TestReleaseDelivery(t *testing.T) {
+	s := newMemoryStore()
+	s.ClaimDelivery("d1", "a", time.Minute)
+
+	s.ReleaseDelivery("d1", "b")
+	if ok, _ := s.ClaimDelivery("d1", "b", time.Minute); ok {
+		t.Fatal("a non-holder released the lease")
+	}
+	s.ReleaseDelivery("d1", "a")
+	if ok, _ := s.ClaimDelivery("d1", "b", time.Minute); !ok {
+		t.Fatal("the holder's release did not free the lease")
+	}
+}
+
+// TestDeliverClaimed: a delivery leased by another gateway is not sent;
+// once the lease is free it is sent exactly once.
+func TestDeliverClaimed(t *testing.T) {
+	viper.Set("webhooks.timeout", "2s")
+	t.Cleanup(func() { viper.Set("webhooks.timeout", nil) })
+
+	s := newMemoryStore()
+	useStore(t, s)
+	Start()
+	if client.Timeout != 2*time.Second {
+		t.Errorf("client.Timeout = %v, want webhooks.timeout", client.Timeout)
+	}
+
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	defer srv.Close()
+
+	now := time.Now().UTC()
+	s.SaveSubscription(&Subscription{ID: "s1", URL: srv.URL, Active: true, Secret: "k"})
+	s.SaveDelivery(&Delivery{ID: "d1", SubscriptionID: "s1", Event: "order.created", Body: []byte(`{}`), Status: Pending, NextAttempt: now, CreatedAt: now})
+
+	s.ClaimDelivery("d1", "other-gateway", time.Minute)
+	deliver("d1")
+	if n := hits.Load(); n != 0 {
+		t.Fatalf("sent %d times while leased elsewhere", n)
+	}
+	if d, _ := s.GetDelivery("d1"); d.Status != Pending || len(d.Attempts) != 0 {
+		t.Fatalf("delivery changed while leased elsewhere: %+v", d)
+	}
+
+	s.ReleaseDelivery("d1", "other-gateway")
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() { defer wg.Done(); deliver("d1") }()
+	}
+	wg.Wait()
+	deliver("d1") // already delivered: dropped
+
+	if n := hits.Load(); n != 1 {
+		t.Errorf("sent %d times, want 1", n)
+	}
+	if d, _ := s.GetDelivery("d1"); d.Status != Delivered {
+		t.Errorf("status = %s, want %s", d.Status, Delivered)
+	}
+	if ok, _ := s.ClaimDelivery("d1", "other-gateway", time.Minute); !ok {
+		t.Error("lease not released after the attempt")
+	}
+}
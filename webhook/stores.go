@@ -0,0 +1,336 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// Webhook store backends: in-memory and Redis (sf.CachePool).
+
+package webhook
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	sf "github.com/gogufo/gufo-api-gateway/gufodao"
+	"github.com/gomodule/redigo/redis"
+)
+
+// keep reports whether d passes the list filters.
+func keep(d *Delivery, status, subscriptionID string) bool {
+	return (status == "" || d.Status == status) &&
+		(subscriptionID == "" || d.SubscriptionID == subscriptionID)
+}
+
+// -------------------------------------------------------------------
+// Memory store
+// -------------------------------------------------------------------
+
+type memoryStore struct {
+	mu         sync.Mutex
+	subs       map[string]Subscription
+	deliveries map[string]Delivery
+	claims     map[string]memoryClaim
+}
+
+type memoryClaim struct {
+	owner   string
+	expires time.Time
+}
+
+func newMemoryStore() *memoryStore {
+	s := &memoryStore{subs: map[string]Subscription{}, deliveries: map[string]Delivery{}, claims: map[string]memoryClaim{}}
+	go s.sweep()
+	return s
+}
+
+func (s *memoryStore) SaveSubscription(sub *Subscription) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subs[sub.ID] = *sub
+	return nil
+}
+
+func (s *memoryStore) GetSubscription(id string) (*Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub, ok := s.subs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &sub, nil
+}
+
+func (s *memoryStore) DeleteSubscription(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.subs[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.subs, id)
+	return nil
+}
+
+func (s *memoryStore) ListSubscriptions() ([]Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Subscription, 0, len(s.subs))
+	for _, sub := range s.subs {
+		out = append(out, sub)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out, nil
+}
+
+func (s *memoryStore) SaveDelivery(d *Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deliveries[d.ID] = *d
+	return nil
+}
+
+func (s *memoryStore) GetDelivery(id string) (*Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.deliveries[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &d, nil
+}
+
+func (s *memoryStore) ListDeliveries(status, subscriptionID string, limit int) ([]Delivery, error) {
+	s.mu.Lock()
+	out := []Delivery{}
+	for _, d := range s.deliveries {
+		if keep(&d, status, subscriptionID) {
+			out = append(out, d)
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+func (s *memoryStore) ClaimDelivery(id, owner string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c, ok := s.claims[id]; ok && time.Now().Before(c.expires) {
+		return false, nil
+	}
+	s.claims[id] = memoryClaim{owner: owner, expires: time.Now().Add(ttl)}
+	return true, nil
+}
+
+func (s *memoryStore) ReleaseDelivery(id, owner string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c, ok := s.claims[id]; ok && c.owner == owner {
+		delete(s.claims, id)
+	}
+	return nil
+}
+
+func (s *memoryStore) sweep() {
+	ticker := time.NewTicker(time.Minute)
+	for range ticker.C {
+		cutoff := time.Now().Add(-Retention())
+		s.mu.Lock()
+		for k, d := range s.deliveries {
+			if d.Status != Pending && d.CreatedAt.Before(cutoff) {
+				delete(s.deliveries, k)
+			}
+		}
+		now := time.Now()
+		for k, c := range s.claims {
+			if now.After(c.expires) {
+				delete(s.claims, k)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// -------------------------------------------------------------------
+// Redis store
+// -------------------------------------------------------------------
+//
+// gufo:webhook:subs           HASH id -> JSON Subscription
+// gufo:webhook:delivery:<id>  JSON Delivery (expires after Retention)
+// gufo:webhook:deliveries     ZSET id scored by creation time
+// gufo:webhook:claim:<id>     owner of the delivery lease (expires)
+
+const (
+	redisSubsKey          = "gufo:webhook:subs"
+	redisDeliveryPrefix   = "gufo:webhook:delivery:"
+	redisDeliveriesByTime = "gufo:webhook:deliveries"
+	redisClaimPrefix      = "gufo:webhook:claim:"
+)
+
+var releaseScript = redis.NewScript(1, `
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+  return redis.call('DEL', KEYS[1])
+end
+return 0`)
+
+type redisStore struct{}
+
+func newRedisStore() *redisStore {
+	sf.EnsureCache()
+	return &redisStore{}
+}
+
+func (s *redisStore) SaveSubscription(sub *Subscription) error {
+	conn := sf.CachePool.Get()
+	defer conn.Close()
+
+	b, err := json.Marshal(sub)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Do("HSET", redisSubsKey, sub.ID, b)
+	return err
+}
+
+func (s *redisStore) GetSubscription(id string) (*Subscription, error) {
+	conn := sf.CachePool.Get()
+	defer conn.Close()
+
+	raw, err := redis.Bytes(conn.Do("HGET", redisSubsKey, id))
+	if err == redis.ErrNil {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var sub Subscription
+	return &sub, json.Unmarshal(raw, &sub)
+}
+
+func (s *redisStore) DeleteSubscription(id string) error {
+	conn := sf.CachePool.Get()
+	defer conn.Close()
+
+	n, err := redis.Int(conn.Do("HDEL", redisSubsKey, id))
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *redisStore) ListSubscriptions() ([]Subscription, error) {
+	conn := sf.CachePool.Get()
+	defer conn.Close()
+
+	vals, err := redis.ByteSlices(conn.Do("HVALS", redisSubsKey))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Subscription, 0, len(vals))
+	for _, raw := range vals {
+		var sub Subscription
+		if json.Unmarshal(raw, &sub) == nil {
+			out = append(out, sub)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out, nil
+}
+
+func (s *redisStore) SaveDelivery(d *Delivery) error {
+	conn := sf.CachePool.Get()
+	defer conn.Close()
+
+	b, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	conn.Send("MULTI")
+	conn.Send("SET", redisDeliveryPrefix+d.ID, b, "PX", Retention().Milliseconds())
+	conn.Send("ZADD", redisDeliveriesByTime, d.CreatedAt.UnixMilli(), d.ID)
+	conn.Send("ZREMRANGEBYSCORE", redisDeliveriesByTime, "-inf", time.Now().Add(-Retention()).UnixMilli())
+	_, err = conn.Do("EXEC")
+	return err
+}
+
+func (s *redisStore) GetDelivery(id string) (*Delivery, error) {
+	conn := sf.CachePool.Get()
+	defer conn.Close()
+
+	raw, err := redis.Bytes(conn.Do("GET", redisDeliveryPrefix+id))
+	if err == redis.ErrNil {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var d Delivery
+	return &d, json.Unmarshal(raw, &d)
+}
+
+func (s *redisStore) ListDeliveries(status, subscriptionID string, limit int) ([]Delivery, error) {
+	conn := sf.CachePool.Get()
+	defer conn.Close()
+
+	ids, err := redis.Strings(conn.Do("ZREVRANGE", redisDeliveriesByTime, 0, -1))
+	if err != nil {
+		return nil, err
+	}
+
+	out := []Delivery{}
+	for _, id := range ids {
+		raw, err := redis.Bytes(conn.Do("GET", redisDeliveryPrefix+id))
+		if err != nil {
+			continue
+		}
+		var d Delivery
+		if json.Unmarshal(raw, &d) != nil || !keep(&d, status, subscriptionID) {
+			continue
+		}
+		out = append(out, d)
+		if limit > 0 && len(out) >= limit {
+			break
+		}
+	}
+	return out, nil
+}
+
+func (s *redisStore) ClaimDelivery(id, owner string, ttl time.Duration) (bool, error) {
+	conn := sf.CachePool.Get()
+	defer conn.Close()
+
+	_, err := redis.String(conn.Do("SET", redisClaimPrefix+id, owner, "NX", "PX", ttl.Milliseconds()))
+	if err == redis.ErrNil {
+		return false, nil // leased by another gateway
+	}
+	return err == nil, err
+}
+
+func (s *redisStore) ReleaseDelivery(id, owner string) error {
+	conn := sf.CachePool.Get()
+	defer conn.Close()
+
+	_, err := releaseScript.Do(conn, redisClaimPrefix+id, owner)
+	return err
+}
@@ -0,0 +1,275 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+//
+// Outbound webhooks: modules emit events (IR.Param = "webhook" on the
+// gateway gRPC port), the gateway signs and delivers them to subscribers.
+
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Delivery states. Dead deliveries form the dead-letter store.
+const (
+	Pending   = "pending"
+	Delivered = "delivered"
+	Dead      = "dead"
+)
+
+// Headers sent with every delivery.
+const (
+	SignatureHeader = "X-Gufo-Signature" // t=<unix>,v1=<hex HMAC-SHA256(secret, "<t>.<body>")>
+	EventHeader     = "X-Gufo-Event"
+	DeliveryHeader  = "X-Gufo-Delivery"
+)
+
+var (
+	ErrNotFound = errors.New("webhook: not found")
+	ErrInvalid  = errors.New("webhook: invalid subscription")
+)
+
+// Subscription is a third party receiving events.
+type Subscription struct {
+	ID          string    `json:"id"`
+	URL         string    `json:"url"`
+	Events      []string  `json:"events"`            // "user.created", "user.*" or "*"
+	Modules     []string  `json:"modules,omitempty"` // emitting modules (empty = any)
+	Secret      string    `json:"secret,omitempty"`  // HMAC key; only shown on create
+	Description string    `json:"description,omitempty"`
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Attempt is one delivery try.
+type Attempt struct {
+	At         time.Time `json:"at"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs int64     `json:"duration_ms"`
+}
+
+// Delivery is one event for one subscription.
+type Delivery struct {
+	ID             string          `json:"id"`
+	SubscriptionID string          `json:"subscription_id"`
+	Event          string          `json:"event"`
+	Module         string          `json:"module"`
+	Body           json.RawMessage `json:"body"`
+	Status         string          `json:"status"`
+	Attempts       []Attempt       `json:"attempts"`
+	NextAttempt    time.Time       `json:"next_attempt,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// Store persists subscriptions and deliveries.
+type Store interface {
+	SaveSubscription(s *Subscription) error
+	GetSubscription(id string) (*Subscription, error)
+	DeleteSubscription(id string) error
+	ListSubscriptions() ([]Subscription, error)
+
+	SaveDelivery(d *Delivery) error
+	GetDelivery(id string) (*Delivery, error)
+	// ListDeliveries returns the newest deliveries, optionally filtered.
+	ListDeliveries(status, subscriptionID string, limit int) ([]Delivery, error)
+
+	// ClaimDelivery leases delivery id to owner for ttl. It reports false
+	// while the lease is held, so replicas sharing the store never send
+	// the same attempt twice.
+	ClaimDelivery(id, owner string, ttl time.Duration) (bool, error)
+	// ReleaseDelivery drops owner's lease on id; another owner's is kept.
+	ReleaseDelivery(id, owner string) error
+}
+
+var (
+	store     Store
+	storeOnce sync.Once
+)
+
+// GetStore returns the configured store (webhooks.store = memory | redis).
+func GetStore() Store {
+	storeOnce.Do(func() {
+		switch strings.ToLower(viper.GetString("webhooks.store")) {
+		case "redis":
+			store = newRedisStore()
+		default:
+			store = newMemoryStore()
+		}
+	})
+	return store
+}
+
+// Retention is how long deliveries are kept (webhooks.retention, default 7 days).
+func Retention() time.Duration {
+	if d := viper.GetDuration("webhooks.retention"); d > 0 {
+		return d
+	}
+	return 7 * 24 * time.Hour
+}
+
+func newID(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Sign returns the signature header value for body sent at ts.
+func Sign(secret string, ts int64, body []byte) string {
+	return "t=" + strconv.FormatInt(ts, 10) + ",v1=" + Digest(secret, ts, body)
+}
+
+// Digest is hex HMAC-SHA256(secret, "<ts>.<body>").
+func Digest(secret string, ts int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", ts)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// -------------------------------------------------------------------
+// Subscriptions
+// -------------------------------------------------------------------
+
+// Validate normalizes s and checks URL and events.
+func (s *Subscription) Validate() error {
+	u, err := url.Parse(s.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http(s) URL", ErrInvalid)
+	}
+	if len(s.Events) == 0 {
+		s.Events = []string{"*"}
+	}
+	for _, e := range s.Events {
+		if strings.TrimSpace(e) == "" {
+			return fmt.Errorf("%w: empty event pattern", ErrInvalid)
+		}
+	}
+	return nil
+}
+
+// Create stores a new subscription; a secret is generated when none is given.
+func Create(s Subscription) (*Subscription, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	s.ID = newID(8)
+	if s.Secret == "" {
+		s.Secret = "whsec_" + newID(24)
+	}
+	s.CreatedAt, s.UpdatedAt = now, now
+	return &s, GetStore().SaveSubscription(&s)
+}
+
+// Matches reports whether s receives event emitted by module.
+func (s *Subscription) Matches(module, event string) bool {
+	if !s.Active {
+		return false
+	}
+	if len(s.Modules) > 0 && !slices.Contains(s.Modules, module) {
+		return false
+	}
+	for _, p := range s.Events {
+		switch {
+		case p == "*", p == event:
+			return true
+		case strings.HasSuffix(p, ".*") && strings.HasPrefix(event, strings.TrimSuffix(p, "*")):
+			return true
+		}
+	}
+	return false
+}
+
+// -------------------------------------------------------------------
+// Emitting
+// -------------------------------------------------------------------
+
+// Emit creates a delivery per matching subscription and queues it.
+// It returns the delivery IDs.
+func Emit(module, event string, data interface{}) ([]string, error) {
+	if event == "" {
+		return nil, errors.New("webhook: event is required")
+	}
+	subs, err := GetStore().ListSubscriptions()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+	now := time.Now().UTC()
+	for i := range subs {
+		if !subs[i].Matches(module, event) {
+			continue
+		}
+		d := &Delivery{
+			ID:             newID(12),
+			SubscriptionID: subs[i].ID,
+			Event:          event,
+			Module:         module,
+			Status:         Pending,
+			Attempts:       []Attempt{},
+			NextAttempt:    now,
+			CreatedAt:      now,
+		}
+		d.Body, err = json.Marshal(map[string]interface{}{
+			"id":         d.ID,
+			"event":      event,
+			"module":     module,
+			"created_at": now.Format(time.RFC3339),
+			"data":       data,
+		})
+		if err != nil {
+			return ids, err
+		}
+		if err := GetStore().SaveDelivery(d); err != nil {
+			return ids, err
+		}
+		ids = append(ids, d.ID)
+		schedule(d.ID, 0)
+	}
+	return ids, nil
+}
+
+// Redeliver queues a delivery again (also delivered and dead ones);
+// earlier attempts are kept.
+func Redeliver(id string) (*Delivery, error) {
+	d, err := GetStore().GetDelivery(id)
+	if err != nil {
+		return nil, err
+	}
+	d.Status, d.NextAttempt = Pending, time.Now().UTC()
+	if err := GetStore().SaveDelivery(d); err != nil {
+		return nil, err
+	}
+	schedule(d.ID, 0)
+	return d, nil
+}
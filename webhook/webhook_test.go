@@ -0,0 +1,70 @@
+// Copyright 2019-2025 Alexey Yanchenko <mail@yanchenko.me>
+//
+// This file is part of the Gufo library.
+//
+// Licensed under the Business Source License 1.1 (the "License");
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License in the LICENSE file at the root of this repository.
+//
+// As of the Change Date specified in that file, in accordance with the Business Source
+// License, use of this software will be governed by the Apache License, Version 2.0.
+//
+// THIS SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR
+// PURPOSE AND NON-INFRINGEMENT.
+
+package webhook
+
+import "testing"
+
+func TestSign(t *testing.T) {
+	const ts = 1700000000
+	tests := []struct {
+		name, secret, body, want string
+	}{
+		// hex HMAC-SHA256(secret, "<ts>.<body>"), computed independently
+		{"body", "whsec_test", `{"event":"order.created"}`, "44ccdd37cc0cde29381624e0495514ce79007393020fddb05c89075cd26cc6bd"},
+		{"empty body", "whsec_test", "", "5967f3c560522fa40cf2876ebc3c3a08551dd6959aaade3b413460591895bdcc"},
+	}
+	for _, tt := range tests {
+		if got := Digest(tt.secret, ts, []byte(tt.body)); got != tt.want {
+			t.Errorf("%s: Digest = %s, want %s", tt.name, got, tt.want)
+		}
+		if got, want := Sign(tt.secret, ts, []byte(tt.body)), "t=1700000000,v1="+tt.want; got != want {
+			t.Errorf("%s: Sign = %s, want %s", tt.name, got, want)
+		}
+	}
+
+	body := []byte(`{"event":"order.created"}`)
+	if Digest("other", ts, body) == Digest("whsec_test", ts, body) {
+		t.Error("Digest ignores the secret")
+	}
+	if Digest("whsec_test", ts+1, body) == Digest("whsec_test", ts, body) {
+		t.Error("Digest ignores the timestamp")
+	}
+}
+
+func TestSubscriptionMatches(t *testing.T) {
+	s := Subscription{Active: true, Modules: []string{"orders"}, Events: []string{"order.*", "refund.issued"}}
+	tests := []struct {
+		module, event string
+		want          bool
+	}{
+		{"orders", "order.created", true},
+		{"orders", "refund.issued", true},
+		{"orders", "refund.failed", false},
+		{"orders", "orders.created", false},
+		{"users", "order.created", false},
+	}
+	for _, tt := range tests {
+		if got := s.Matches(tt.module, tt.event); got != tt.want {
+			t.Errorf("Matches(%q, %q) = %v, want %v", tt.module, tt.event, got, tt.want)
+		}
+	}
+
+	s.Active = false
+	if s.Matches("orders", "order.created") {
+		t.Error("inactive subscription matches")
+	}
+}